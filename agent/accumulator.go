@@ -18,6 +18,12 @@ type MetricMaker interface {
 	MakeMetric(metric telegraf.Metric) telegraf.Metric
 }
 
+// ErrorCounter is implemented by the MetricMakers counting the errors added
+// to their accumulator.
+type ErrorCounter interface {
+	IncrErrors()
+}
+
 type accumulator struct {
 	maker     MetricMaker
 	metrics   chan telegraf.Metric
@@ -104,6 +110,9 @@ func (ac *accumulator) AddError(err error) {
 		return
 	}
 	NErrors.Incr(1)
+	if counter, ok := ac.maker.(ErrorCounter); ok {
+		counter.IncrErrors()
+	}
 	log.Printf("E! Error in plugin [%s]: %s", ac.maker.Name(), err)
 }
 
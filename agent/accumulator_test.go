@@ -53,7 +53,8 @@ func TestAccAddError(t *testing.T) {
 
 	metrics := make(chan telegraf.Metric, 10)
 	defer close(metrics)
-	a := NewAccumulator(&TestMetricMaker{}, metrics)
+	maker := &TestMetricMaker{}
+	a := NewAccumulator(maker, metrics)
 
 	a.AddError(fmt.Errorf("foo"))
 	a.AddError(fmt.Errorf("bar"))
@@ -61,6 +62,7 @@ func TestAccAddError(t *testing.T) {
 
 	errs := bytes.Split(errBuf.Bytes(), []byte{'\n'})
 	assert.EqualValues(t, int64(3), NErrors.Get())
+	assert.Equal(t, 3, maker.errors)
 	require.Len(t, errs, 4) // 4 because of trailing newline
 	assert.Contains(t, string(errs[0]), "TestPlugin")
 	assert.Contains(t, string(errs[0]), "foo")
@@ -128,6 +130,7 @@ func TestSetPrecision(t *testing.T) {
 }
 
 type TestMetricMaker struct {
+	errors int
 }
 
 func (tm *TestMetricMaker) Name() string {
@@ -137,3 +140,7 @@ func (tm *TestMetricMaker) Name() string {
 func (tm *TestMetricMaker) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 	return metric
 }
+
+func (tm *TestMetricMaker) IncrErrors() {
+	tm.errors++
+}
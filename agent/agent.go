@@ -15,6 +15,14 @@ import (
 	"github.com/influxdata/telegraf/selfstat"
 )
 
+var (
+	// Number of metrics waiting in the channels between the inputs,
+	// aggregators and outputs.
+	metricChannelLength    = selfstat.Register("agent", "metric_channel_length", map[string]string{})
+	aggMetricChannelLength = selfstat.Register("agent", "aggregator_channel_length", map[string]string{})
+	outMetricChannelLength = selfstat.Register("agent", "output_channel_length", map[string]string{})
+)
+
 // Agent runs telegraf and collects data based on the given config
 type Agent struct {
 	Config *config.Config
@@ -83,6 +91,71 @@ func (a *Agent) Close() error {
 	return err
 }
 
+// startProcessors starts the service processors.  The returned slice holds,
+// at the index of each service processor, the channel receiving the metrics
+// it emits.
+func (a *Agent) startProcessors() ([]chan telegraf.Metric, error) {
+	procMetricC := make([]chan telegraf.Metric, len(a.Config.Processors))
+	for i, p := range a.Config.Processors {
+		switch pt := p.Processor.(type) {
+		case telegraf.ServiceProcessor:
+			procMetricC[i] = make(chan telegraf.Metric, 100)
+			if err := pt.Start(procMetricC[i]); err != nil {
+				log.Printf("E! Service for processor %s failed to start, exiting\n%s\n",
+					p.Name, err.Error())
+				a.stopProcessors(procMetricC[:i], nil)
+				return nil, err
+			}
+		}
+	}
+	return procMetricC, nil
+}
+
+// stopProcessors stops the service processors in order, waiting for the
+// metrics emitted by each to be forwarded before stopping the next.
+func (a *Agent) stopProcessors(
+	procMetricC []chan telegraf.Metric,
+	forwarded []chan struct{},
+) {
+	for i, out := range procMetricC {
+		if out == nil {
+			continue
+		}
+		a.Config.Processors[i].Processor.(telegraf.ServiceProcessor).Stop()
+		close(out)
+		if forwarded != nil {
+			<-forwarded[i]
+		}
+	}
+}
+
+// process applies the processors to the metrics and forwards the results to
+// the aggregators and outputs.
+func (a *Agent) process(
+	processors []*models.RunningProcessor,
+	metrics []telegraf.Metric,
+	outMetricC chan telegraf.Metric,
+) {
+	for _, processor := range processors {
+		metrics = processor.Apply(metrics...)
+	}
+
+	for _, metric := range metrics {
+		// Apply Aggregators
+		var dropOriginal bool
+		for _, agg := range a.Config.Aggregators {
+			if ok := agg.Add(metric.Copy()); ok {
+				dropOriginal = true
+			}
+		}
+
+		// Forward metric to Outputs
+		if !dropOriginal {
+			outMetricC <- metric
+		}
+	}
+}
+
 func panicRecover(input *models.RunningInput) {
 	if err := recover(); err != nil {
 		trace := make([]byte, 2048)
@@ -158,6 +231,8 @@ func gatherWithTimeout(
 		case err := <-done:
 			if err != nil {
 				acc.AddError(err)
+			} else {
+				input.GatherSucceeded()
 			}
 			return
 		case <-ticker.C:
@@ -246,47 +321,64 @@ func (a *Agent) flusher(
 	metricC chan telegraf.Metric,
 	aggMetricC chan telegraf.Metric,
 	outMetricC chan telegraf.Metric,
+	procMetricC []chan telegraf.Metric,
 ) error {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for {
-			select {
-			case <-shutdown:
-				if len(outMetricC) > 0 {
-					// keep going until channel is empty
-					continue
-				}
-				return
-			case metric := <-outMetricC:
-				for i, o := range a.Config.Outputs {
-					if i == len(a.Config.Outputs)-1 {
-						o.AddMetric(metric)
-					} else {
-						o.AddMetric(metric.Copy())
-					}
+		for metric := range outMetricC {
+			for i, o := range a.Config.Outputs {
+				if i == len(a.Config.Outputs)-1 {
+					o.AddMetric(metric)
+				} else {
+					o.AddMetric(metric.Copy())
 				}
 			}
 		}
 	}()
 
-	wg.Add(1)
+	var senders sync.WaitGroup
+	// The metrics emitted by a service processor continue through the
+	// processors following it.
+	forwarded := make([]chan struct{}, len(procMetricC))
+	for i, out := range procMetricC {
+		if out == nil {
+			continue
+		}
+		forwarded[i] = make(chan struct{})
+		go func(i int, out chan telegraf.Metric) {
+			defer close(forwarded[i])
+			processor := a.Config.Processors[i]
+			for metric := range out {
+				processor.MetricsEmitted.Incr(1)
+				a.process(a.Config.Processors[i+1:],
+					[]telegraf.Metric{metric}, outMetricC)
+			}
+		}(i, out)
+	}
+
+	senders.Add(1)
 	go func() {
-		defer wg.Done()
+		defer senders.Done()
 		for metric := range aggMetricC {
 			// Apply Processors
 			metrics := []telegraf.Metric{metric}
 			for _, processor := range a.Config.Processors {
+				// Service processors emit their results into the input
+				// pipeline, so aggregated metrics are not sent to them.
+				if _, ok := processor.Processor.(telegraf.ServiceProcessor); ok {
+					continue
+				}
 				metrics = processor.Apply(metrics...)
 			}
 			outMetricC <- metric
 		}
 	}()
 
-	wg.Add(1)
+	senders.Add(1)
 	go func() {
-		defer wg.Done()
+		defer senders.Done()
 		for {
 			select {
 			case <-shutdown:
@@ -294,33 +386,22 @@ func (a *Agent) flusher(
 					// keep going until channel is empty
 					continue
 				}
+				a.stopProcessors(procMetricC, forwarded)
 				close(aggMetricC)
 				return
 			case metric := <-metricC:
-				// Apply Processors
-				metrics := []telegraf.Metric{metric}
-				for _, processor := range a.Config.Processors {
-					metrics = processor.Apply(metrics...)
-				}
-
-				for _, metric := range metrics {
-					// Apply Aggregators
-					var dropOriginal bool
-					for _, agg := range a.Config.Aggregators {
-						if ok := agg.Add(metric.Copy()); ok {
-							dropOriginal = true
-						}
-					}
-
-					// Forward metric to Outputs
-					if !dropOriginal {
-						outMetricC <- metric
-					}
-				}
+				a.process(a.Config.Processors,
+					[]telegraf.Metric{metric}, outMetricC)
 			}
 		}
 	}()
 
+	// outMetricC is closed once every routine sending to it has returned.
+	go func() {
+		senders.Wait()
+		close(outMetricC)
+	}()
+
 	ticker := time.NewTicker(a.Config.Agent.FlushInterval.Duration)
 	semaphore := make(chan struct{}, 1)
 	for {
@@ -348,6 +429,31 @@ func (a *Agent) flusher(
 	}
 }
 
+// channelMonitor records the number of metrics waiting in the channels every
+// interval.
+func channelMonitor(
+	shutdown chan struct{},
+	interval time.Duration,
+	metricC chan telegraf.Metric,
+	aggMetricC chan telegraf.Metric,
+	outMetricC chan telegraf.Metric,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		metricChannelLength.Set(int64(len(metricC)))
+		aggMetricChannelLength.Set(int64(len(aggMetricC)))
+		outMetricChannelLength.Set(int64(len(outMetricC)))
+
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Run runs the agent daemon, gathering every Interval
 func (a *Agent) Run(shutdown chan struct{}) error {
 	var wg sync.WaitGroup
@@ -375,7 +481,19 @@ func (a *Agent) Run(shutdown chan struct{}) error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := a.flusher(shutdown, metricC, aggMetricC, outMetricC); err != nil {
+		channelMonitor(shutdown, a.Config.Agent.Interval.Duration,
+			metricC, aggMetricC, outMetricC)
+	}()
+
+	procMetricC, err := a.startProcessors()
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := a.flusher(shutdown, metricC, aggMetricC, outMetricC, procMetricC); err != nil {
 			log.Printf("E! Flusher routine failed, exiting: %s\n", err.Error())
 			close(shutdown)
 		}
@@ -2,8 +2,11 @@ package agent
 
 import (
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/testutil"
 
 	// needing to load the plugins
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
@@ -109,3 +112,26 @@ func TestAgent_LoadOutput(t *testing.T) {
 	a, _ = NewAgent(c)
 	assert.Equal(t, 3, len(a.Config.Outputs))
 }
+
+func TestChannelMonitor(t *testing.T) {
+	metricC := make(chan telegraf.Metric, 10)
+	aggMetricC := make(chan telegraf.Metric, 10)
+	outMetricC := make(chan telegraf.Metric, 10)
+	for i := 0; i < 3; i++ {
+		metricC <- testutil.TestMetric(i)
+	}
+	outMetricC <- testutil.TestMetric(1)
+
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		channelMonitor(shutdown, time.Hour, metricC, aggMetricC, outMetricC)
+		close(done)
+	}()
+	close(shutdown)
+	<-done
+
+	assert.Equal(t, int64(3), metricChannelLength.Get())
+	assert.Equal(t, int64(0), aggMetricChannelLength.Get())
+	assert.Equal(t, int64(1), outMetricChannelLength.Get())
+}
@@ -0,0 +1,456 @@
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrShortBuffer = errors.New("avro: unexpected end of data")
+)
+
+// Decode reads a single datum of the given schema from the Avro binary
+// encoding in buf, returning the value and the remaining bytes.
+//
+// Values are returned as: nil, bool, int64 (int and long), float64 (float
+// and double), string (string and enum), []byte (bytes and fixed),
+// time.Time (long with a timestamp-millis or timestamp-micros logical type),
+// []interface{} (array) and map[string]interface{} (map and record).  Unions
+// decode to the value of the selected branch.
+func Decode(s *Schema, buf []byte) (interface{}, []byte, error) {
+	switch s.Type {
+	case "null":
+		return nil, buf, nil
+	case "boolean":
+		if len(buf) < 1 {
+			return nil, nil, ErrShortBuffer
+		}
+		return buf[0] != 0, buf[1:], nil
+	case "int", "long":
+		v, rest, err := readLong(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch s.LogicalType {
+		case "timestamp-millis":
+			return time.Unix(0, v*int64(time.Millisecond)).UTC(), rest, nil
+		case "timestamp-micros":
+			return time.Unix(0, v*int64(time.Microsecond)).UTC(), rest, nil
+		}
+		return v, rest, nil
+	case "float":
+		if len(buf) < 4 {
+			return nil, nil, ErrShortBuffer
+		}
+		bits := binary.LittleEndian.Uint32(buf)
+		return float64(math.Float32frombits(bits)), buf[4:], nil
+	case "double":
+		if len(buf) < 8 {
+			return nil, nil, ErrShortBuffer
+		}
+		bits := binary.LittleEndian.Uint64(buf)
+		return math.Float64frombits(bits), buf[8:], nil
+	case "bytes":
+		b, rest, err := readBytes(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append([]byte(nil), b...), rest, nil
+	case "string":
+		b, rest, err := readBytes(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return string(b), rest, nil
+	case "fixed":
+		if len(buf) < s.Size {
+			return nil, nil, ErrShortBuffer
+		}
+		return append([]byte(nil), buf[:s.Size]...), buf[s.Size:], nil
+	case "enum":
+		idx, rest, err := readLong(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if idx < 0 || int(idx) >= len(s.Symbols) {
+			return nil, nil, fmt.Errorf("avro: enum index %d out of range for %q", idx, s.Name)
+		}
+		return s.Symbols[idx], rest, nil
+	case "union":
+		idx, rest, err := readLong(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if idx < 0 || int(idx) >= len(s.Branches) {
+			return nil, nil, fmt.Errorf("avro: union index %d out of range", idx)
+		}
+		return Decode(s.Branches[idx], rest)
+	case "array":
+		var items []interface{}
+		err := readBlocks(&buf, func() error {
+			var item interface{}
+			var err error
+			item, buf, err = Decode(s.Items, buf)
+			items = append(items, item)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return items, buf, nil
+	case "map":
+		values := make(map[string]interface{})
+		err := readBlocks(&buf, func() error {
+			key, rest, err := readBytes(buf)
+			if err != nil {
+				return err
+			}
+			var value interface{}
+			value, buf, err = Decode(s.Values, rest)
+			values[string(key)] = value
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return values, buf, nil
+	case "record":
+		record := make(map[string]interface{}, len(s.Fields))
+		for _, f := range s.Fields {
+			var value interface{}
+			var err error
+			value, buf, err = Decode(f.Type, buf)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %q: %v", f.Name, err)
+			}
+			record[f.Name] = value
+		}
+		return record, buf, nil
+	default:
+		return nil, nil, fmt.Errorf("avro: unsupported type %q", s.Type)
+	}
+}
+
+// Encode appends the Avro binary encoding of value to buf.  The value types
+// accepted are the same as those returned by Decode, in addition to the
+// remaining Go integer and float types.
+func Encode(s *Schema, buf []byte, value interface{}) ([]byte, error) {
+	switch s.Type {
+	case "null":
+		if value != nil {
+			return nil, typeError(s, value)
+		}
+		return buf, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "int", "long":
+		if t, ok := value.(time.Time); ok {
+			switch s.LogicalType {
+			case "timestamp-millis":
+				return appendLong(buf, t.UnixNano()/int64(time.Millisecond)), nil
+			case "timestamp-micros":
+				return appendLong(buf, t.UnixNano()/int64(time.Microsecond)), nil
+			}
+		}
+		v, ok := toInt64(value)
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		if s.Type == "int" && (v < math.MinInt32 || v > math.MaxInt32) {
+			return nil, fmt.Errorf("avro: value %d overflows int", v)
+		}
+		return appendLong(buf, v), nil
+	case "float":
+		v, ok := toFloat64(value)
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(v)))
+		return append(buf, b[:]...), nil
+	case "double":
+		v, ok := toFloat64(value)
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		return append(buf, b[:]...), nil
+	case "bytes", "string":
+		switch v := value.(type) {
+		case string:
+			buf = appendLong(buf, int64(len(v)))
+			return append(buf, v...), nil
+		case []byte:
+			buf = appendLong(buf, int64(len(v)))
+			return append(buf, v...), nil
+		}
+		return nil, typeError(s, value)
+	case "fixed":
+		v, ok := value.([]byte)
+		if !ok || len(v) != s.Size {
+			return nil, typeError(s, value)
+		}
+		return append(buf, v...), nil
+	case "enum":
+		v, ok := value.(string)
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		for i, sym := range s.Symbols {
+			if sym == v {
+				return appendLong(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("avro: %q is not a symbol of enum %q", v, s.Name)
+	case "union":
+		idx := unionBranch(s, value)
+		if idx < 0 {
+			return nil, typeError(s, value)
+		}
+		buf = appendLong(buf, int64(idx))
+		return Encode(s.Branches[idx], buf, value)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		if len(items) > 0 {
+			buf = appendLong(buf, int64(len(items)))
+			for _, item := range items {
+				var err error
+				buf, err = Encode(s.Items, buf, item)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(buf, 0), nil
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		if len(values) > 0 {
+			buf = appendLong(buf, int64(len(values)))
+			for k, v := range values {
+				buf = appendLong(buf, int64(len(k)))
+				buf = append(buf, k...)
+				var err error
+				buf, err = Encode(s.Values, buf, v)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(buf, 0), nil
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, typeError(s, value)
+		}
+		for _, f := range s.Fields {
+			v, ok := record[f.Name]
+			if !ok && f.HasDefault {
+				v = f.Default
+				if f.Type.Type == "union" && len(f.Type.Branches) > 0 && f.Type.Branches[0].Type == "null" {
+					v = nil
+				}
+			}
+			var err error
+			buf, err = Encode(f.Type, buf, v)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", f.Name, err)
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("avro: unsupported type %q", s.Type)
+	}
+}
+
+// unionBranch returns the index of the first branch able to hold value, or -1.
+func unionBranch(s *Schema, value interface{}) int {
+	for i, b := range s.Branches {
+		switch value.(type) {
+		case nil:
+			if b.Type == "null" {
+				return i
+			}
+		case bool:
+			if b.Type == "boolean" {
+				return i
+			}
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			if b.Type == "long" || b.Type == "int" {
+				return i
+			}
+		case float32, float64:
+			if b.Type == "double" || b.Type == "float" {
+				return i
+			}
+		case string:
+			if b.Type == "string" {
+				return i
+			}
+		case []byte:
+			if b.Type == "bytes" || (b.Type == "fixed" && len(value.([]byte)) == b.Size) {
+				return i
+			}
+		case time.Time:
+			if b.Type == "long" {
+				return i
+			}
+		case []interface{}:
+			if b.Type == "array" {
+				return i
+			}
+		case map[string]interface{}:
+			if b.Type == "record" || b.Type == "map" {
+				return i
+			}
+		}
+	}
+
+	// Fall back to lossy conversions, such as integers to doubles.
+	for i, b := range s.Branches {
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			if b.Type == "double" || b.Type == "float" {
+				return i
+			}
+		case string:
+			if b.Type == "bytes" {
+				return i
+			}
+			if b.Type == "enum" {
+				for _, sym := range b.Symbols {
+					if sym == value.(string) {
+						return i
+					}
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func typeError(s *Schema, value interface{}) error {
+	return fmt.Errorf("avro: cannot encode %T as %s", value, s.Type)
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return math.MaxInt64, true
+		}
+		return int64(v), true
+	case float64:
+		// JSON schema defaults are decoded as float64.
+		if v == math.Trunc(v) {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	if i, ok := toInt64(value); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func readLong(buf []byte) (int64, []byte, error) {
+	v, n := binary.Varint(buf)
+	if n <= 0 {
+		return 0, nil, ErrShortBuffer
+	}
+	return v, buf[n:], nil
+}
+
+func appendLong(buf []byte, v int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func readBytes(buf []byte) ([]byte, []byte, error) {
+	n, rest, err := readLong(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 || int64(len(rest)) < n {
+		return nil, nil, ErrShortBuffer
+	}
+	return rest[:n], rest[n:], nil
+}
+
+// readBlocks reads the blocks of an array or map, calling fn once for each
+// item.  fn is responsible for advancing buf.
+func readBlocks(buf *[]byte, fn func() error) error {
+	for {
+		count, rest, err := readLong(*buf)
+		if err != nil {
+			return err
+		}
+		*buf = rest
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// A negative count is followed by the block size in bytes.
+			count = -count
+			_, rest, err = readLong(*buf)
+			if err != nil {
+				return err
+			}
+			*buf = rest
+		}
+		// Every item but null takes at least one byte, so a count larger
+		// than the remaining data comes from a corrupt or hostile message.
+		if count < 0 || count > int64(len(*buf)) {
+			return fmt.Errorf("avro: block count %d exceeds the %d bytes remaining", count, len(*buf))
+		}
+		for i := int64(0); i < count; i++ {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
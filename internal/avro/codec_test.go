@@ -0,0 +1,144 @@
+package avro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+{
+  "type": "record",
+  "name": "cpu",
+  "namespace": "telegraf",
+  "fields": [
+    {"name": "host", "type": "string"},
+    {"name": "usage", "type": "double"},
+    {"name": "cores", "type": "int"},
+    {"name": "active", "type": "boolean"},
+    {"name": "state", "type": {"type": "enum", "name": "State", "symbols": ["UP", "DOWN"]}},
+    {"name": "note", "type": ["null", "string"], "default": null},
+    {"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "labels", "type": {"type": "map", "values": "string"}},
+    {"name": "samples", "type": {"type": "array", "items": "long"}},
+    {"name": "next", "type": ["null", "cpu"], "default": null}
+  ]
+}`
+
+func TestParseSchema(t *testing.T) {
+	s, err := ParseSchema(testSchema)
+	require.NoError(t, err)
+	require.Equal(t, "record", s.Type)
+	require.Equal(t, "telegraf.cpu", s.FullName())
+	require.Len(t, s.Fields, 10)
+	require.Equal(t, []string{"UP", "DOWN"}, s.Fields[4].Type.Symbols)
+	require.True(t, s.Fields[5].Type.Nullable())
+	require.Equal(t, "timestamp-millis", s.Fields[6].Type.LogicalType)
+	// recursive reference resolves to the record itself
+	require.True(t, s == s.Fields[9].Type.Branches[1])
+}
+
+func TestParseSchemaUnknownType(t *testing.T) {
+	_, err := ParseSchema(`{"type": "record", "name": "a", "fields": [{"name": "b", "type": "c"}]}`)
+	require.Error(t, err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	s, err := ParseSchema(testSchema)
+	require.NoError(t, err)
+
+	ts := time.Unix(1500000000, 123000000).UTC()
+	value := map[string]interface{}{
+		"host":    "localhost",
+		"usage":   42.5,
+		"cores":   int64(4),
+		"active":  true,
+		"state":   "DOWN",
+		"note":    nil,
+		"time":    ts,
+		"labels":  map[string]interface{}{"a": "b"},
+		"samples": []interface{}{int64(1), int64(-2), int64(300)},
+		"next": map[string]interface{}{
+			"host":    "other",
+			"usage":   1.0,
+			"cores":   int64(1),
+			"active":  false,
+			"state":   "UP",
+			"note":    "hello",
+			"time":    ts,
+			"labels":  map[string]interface{}{},
+			"samples": []interface{}{},
+			"next":    nil,
+		},
+	}
+
+	buf, err := Encode(s, nil, value)
+	require.NoError(t, err)
+
+	actual, rest, err := Decode(s, buf)
+	require.NoError(t, err)
+	require.Len(t, rest, 0)
+
+	next := value["next"].(map[string]interface{})
+	next["samples"] = []interface{}(nil)
+	require.Equal(t, value, actual)
+}
+
+func TestDecodeKnownEncoding(t *testing.T) {
+	s, err := ParseSchema(`{"type": "record", "name": "r", "fields": [
+		{"name": "a", "type": "long"},
+		{"name": "b", "type": "string"}
+	]}`)
+	require.NoError(t, err)
+
+	// Example from the specification: 27 and "foo"
+	actual, _, err := Decode(s, []byte{0x36, 0x06, 'f', 'o', 'o'})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": int64(27), "b": "foo"}, actual)
+}
+
+func TestDecodeShortBuffer(t *testing.T) {
+	s, err := ParseSchema(`"string"`)
+	require.NoError(t, err)
+
+	_, _, err = Decode(s, []byte{0x06, 'f'})
+	require.Equal(t, ErrShortBuffer, err)
+}
+
+func TestDecodeBlockCountTooLarge(t *testing.T) {
+	s, err := ParseSchema(`{"type": "array", "items": "null"}`)
+	require.NoError(t, err)
+
+	// A block of 1000 items without any data following.
+	_, _, err = Decode(s, []byte{0xd0, 0x0f})
+	require.Error(t, err)
+}
+
+func TestEncodeDefault(t *testing.T) {
+	s, err := ParseSchema(`{"type": "record", "name": "r", "fields": [
+		{"name": "a", "type": "long", "default": 7},
+		{"name": "b", "type": ["null", "string"], "default": null}
+	]}`)
+	require.NoError(t, err)
+
+	buf, err := Encode(s, nil, map[string]interface{}{})
+	require.NoError(t, err)
+
+	actual, _, err := Decode(s, buf)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": int64(7), "b": nil}, actual)
+}
+
+func TestHeader(t *testing.T) {
+	buf := AppendHeader(nil, 42)
+	buf = append(buf, 0x02)
+
+	id, datum, err := SplitHeader(buf)
+	require.NoError(t, err)
+	require.Equal(t, 42, id)
+	require.Equal(t, []byte{0x02}, datum)
+
+	_, _, err = SplitHeader([]byte{1, 0, 0, 0, 1})
+	require.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MagicByte is the first byte of a message in the schema registry wire
+// format.  It is followed by the 4 byte big-endian schema ID and the Avro
+// encoded datum.
+const MagicByte byte = 0
+
+// HeaderLen is the length of the schema registry wire format header.
+const HeaderLen = 5
+
+// SplitHeader separates a message in the schema registry wire format into
+// the schema ID and the encoded datum.
+func SplitHeader(buf []byte) (int, []byte, error) {
+	if len(buf) < HeaderLen {
+		return 0, nil, fmt.Errorf("avro: message too short for schema header")
+	}
+	if buf[0] != MagicByte {
+		return 0, nil, fmt.Errorf("avro: unknown magic byte %d", buf[0])
+	}
+	return int(binary.BigEndian.Uint32(buf[1:HeaderLen])), buf[HeaderLen:], nil
+}
+
+// AppendHeader appends the schema registry wire format header for the schema
+// ID to buf.
+func AppendHeader(buf []byte, id int) []byte {
+	var b [HeaderLen]byte
+	b[0] = MagicByte
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	return append(buf, b[:]...)
+}
+
+// Registry is a caching client for a Confluent compatible schema registry.
+// Schemas may also be added directly with Add, in which case no registry URL
+// is required to look them up.
+type Registry struct {
+	URL      string
+	Username string
+	Password string
+	Client   *http.Client
+
+	mu     sync.Mutex
+	byID   map[int]*Schema
+	bySpec map[string]int
+}
+
+// NewRegistry creates a registry client.  An empty url creates a registry
+// that only knows about schemas added with Add.
+func NewRegistry(url string, timeout time.Duration) *Registry {
+	return &Registry{
+		URL:    strings.TrimRight(url, "/"),
+		Client: &http.Client{Timeout: timeout},
+		byID:   make(map[int]*Schema),
+		bySpec: make(map[string]int),
+	}
+}
+
+// Add inserts a schema into the cache with the given ID.
+func (r *Registry) Add(id int, s *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[id] = s
+	r.bySpec[s.String()] = id
+}
+
+// SchemaByID returns the schema with the given ID, fetching it from the
+// registry if it is not already cached.
+func (r *Registry) SchemaByID(id int) (*Schema, error) {
+	r.mu.Lock()
+	s, ok := r.byID[id]
+	r.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	if r.URL == "" {
+		return nil, fmt.Errorf("avro: unknown schema id %d", id)
+	}
+
+	// The lock is not held during the request so that a slow registry does
+	// not stall lookups of cached schemas.
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+	err := r.do("GET", fmt.Sprintf("/schemas/ids/%d", id), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = ParseSchema(resp.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.byID[id]; ok {
+		return cached, nil
+	}
+	r.byID[id] = s
+	r.bySpec[s.String()] = id
+	return s, nil
+}
+
+// Register registers the schema under subject, returning its ID.  Previously
+// registered schemas are served from the cache.
+func (r *Registry) Register(subject string, s *Schema) (int, error) {
+	r.mu.Lock()
+	id, ok := r.bySpec[s.String()]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	if r.URL == "" {
+		return 0, fmt.Errorf("avro: no schema registry configured")
+	}
+
+	req := struct {
+		Schema string `json:"schema"`
+	}{Schema: s.String()}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
+	err := r.do("POST", path, req, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byID[resp.ID]; !ok {
+		r.byID[resp.ID] = s
+	}
+	r.bySpec[s.String()] = resp.ID
+	return resp.ID, nil
+}
+
+func (r *Registry) do(method, path string, body interface{}, v interface{}) error {
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, r.URL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if r.Username != "" || r.Password != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("avro: schema registry %s %s returned %s: %s",
+			method, path, resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	return json.Unmarshal(b, v)
+}
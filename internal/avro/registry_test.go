@@ -0,0 +1,49 @@
+package avro
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryFetchDoesNotBlockCache(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprintf(w, `{"schema": %q}`, `"string"`)
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	r := NewRegistry(ts.URL, 5*time.Second)
+	cached, err := ParseSchema(`"long"`)
+	require.NoError(t, err)
+	r.Add(1, cached)
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := r.SchemaByID(2)
+		fetched <- err
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		s, err := r.SchemaByID(1)
+		require.NoError(t, err)
+		require.Equal(t, cached, s)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cached lookup blocked by registry request")
+	}
+
+	release <- struct{}{}
+	require.NoError(t, <-fetched)
+}
@@ -0,0 +1,235 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema is a parsed Avro schema.  Named types referenced by name elsewhere
+// in the schema share the same *Schema value.
+type Schema struct {
+	Type        string
+	Name        string
+	Namespace   string
+	LogicalType string
+
+	// Fields of a record.
+	Fields []*Field
+	// Items of an array.
+	Items *Schema
+	// Values of a map.
+	Values *Schema
+	// Symbols of an enum.
+	Symbols []string
+	// Size of a fixed.
+	Size int
+	// Branches of a union.
+	Branches []*Schema
+
+	// source is the canonical JSON the schema was parsed from.
+	source string
+}
+
+// Field is a single field of a record schema.
+type Field struct {
+	Name       string
+	Type       *Schema
+	Default    interface{}
+	HasDefault bool
+}
+
+// FullName returns the namespace qualified name of a named type.
+func (s *Schema) FullName() string {
+	if s.Namespace == "" || strings.Contains(s.Name, ".") {
+		return s.Name
+	}
+	return s.Namespace + "." + s.Name
+}
+
+// String returns the JSON the schema was parsed from.
+func (s *Schema) String() string {
+	return s.source
+}
+
+// Nullable returns true if the schema is a union containing the null type.
+func (s *Schema) Nullable() bool {
+	if s.Type != "union" {
+		return false
+	}
+	for _, b := range s.Branches {
+		if b.Type == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+var primitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// ParseSchema parses an Avro schema from its JSON representation.
+func ParseSchema(text string) (*Schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+
+	p := &schemaParser{named: make(map[string]*Schema)}
+	s, err := p.parse(v, "")
+	if err != nil {
+		return nil, err
+	}
+	s.source = text
+	return s, nil
+}
+
+type schemaParser struct {
+	named map[string]*Schema
+}
+
+func (p *schemaParser) parse(v interface{}, namespace string) (*Schema, error) {
+	switch t := v.(type) {
+	case string:
+		if primitives[t] {
+			return &Schema{Type: t}, nil
+		}
+		if s, ok := p.named[t]; ok {
+			return s, nil
+		}
+		if s, ok := p.named[qualify(t, namespace)]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", t)
+	case []interface{}:
+		s := &Schema{Type: "union"}
+		for _, b := range t {
+			branch, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.Branches = append(s.Branches, branch)
+		}
+		return s, nil
+	case map[string]interface{}:
+		return p.parseComplex(t, namespace)
+	default:
+		return nil, fmt.Errorf("unexpected schema element %v", v)
+	}
+}
+
+func (p *schemaParser) parseComplex(m map[string]interface{}, namespace string) (*Schema, error) {
+	typ, ok := m["type"]
+	if !ok {
+		return nil, fmt.Errorf("schema object missing type")
+	}
+
+	typeName, ok := typ.(string)
+	if !ok {
+		// The type is itself a schema, such as {"type": {"type": "array" ...}}
+		return p.parse(typ, namespace)
+	}
+
+	s := &Schema{Type: typeName}
+	if lt, ok := m["logicalType"].(string); ok {
+		s.LogicalType = lt
+	}
+
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s schema missing name", typeName)
+		}
+		s.Name = name
+		if ns, ok := m["namespace"].(string); ok {
+			namespace = ns
+		}
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			namespace = name[:i]
+			s.Name = name[i+1:]
+		}
+		s.Namespace = namespace
+		p.named[s.FullName()] = s
+		p.named[s.Name] = s
+	}
+
+	switch typeName {
+	case "record", "error":
+		s.Type = "record"
+		fields, ok := m["fields"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %q missing fields", s.Name)
+		}
+		for _, f := range fields {
+			fm, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %q has invalid field", s.Name)
+			}
+			name, _ := fm["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("record %q has field without name", s.Name)
+			}
+			ft, err := p.parse(fm["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", name, err)
+			}
+			field := &Field{Name: name, Type: ft}
+			if def, ok := fm["default"]; ok {
+				field.Default = def
+				field.HasDefault = true
+			}
+			s.Fields = append(s.Fields, field)
+		}
+	case "enum":
+		symbols, ok := m["symbols"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("enum %q missing symbols", s.Name)
+		}
+		for _, sym := range symbols {
+			str, ok := sym.(string)
+			if !ok {
+				return nil, fmt.Errorf("enum %q has invalid symbol", s.Name)
+			}
+			s.Symbols = append(s.Symbols, str)
+		}
+	case "fixed":
+		size, ok := m["size"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("fixed %q missing size", s.Name)
+		}
+		s.Size = int(size)
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		s.Items = items
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		s.Values = values
+	default:
+		if !primitives[typeName] {
+			return p.parse(typeName, namespace)
+		}
+	}
+	return s, nil
+}
+
+func qualify(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -52,34 +52,43 @@ func (b *Buffer) Len() int {
 	return b.size - (b.first - b.last - 1) // size - gap
 }
 
-func (b *Buffer) push(m telegraf.Metric) {
+// push adds a metric to the buffer and returns true if the oldest metric was
+// dropped to make room for it.
+func (b *Buffer) push(m telegraf.Metric) bool {
 	// Empty
 	if b.empty {
 		b.last = b.first // Reset
 		b.buf[b.last] = m
 		b.empty = false
-		return
+		return false
 	}
 
 	b.last++
 	b.last %= b.size
 
 	// Full
-	if b.first == b.last {
+	dropped := b.first == b.last
+	if dropped {
 		MetricsDropped.Incr(1)
 		b.first = (b.first + 1) % b.size
 	}
 	b.buf[b.last] = m
+	return dropped
 }
 
-// Add adds metrics to the buffer.
-func (b *Buffer) Add(metrics ...telegraf.Metric) {
+// Add adds metrics to the buffer and returns the number of metrics dropped
+// because the buffer was full.
+func (b *Buffer) Add(metrics ...telegraf.Metric) int {
 	b.Lock()
 	defer b.Unlock()
+	dropped := 0
 	for i := range metrics {
 		MetricsWritten.Incr(1)
-		b.push(metrics[i])
+		if b.push(metrics[i]) {
+			dropped++
+		}
 	}
+	return dropped
 }
 
 // Batch returns a batch of metrics of size batchSize.
@@ -158,15 +158,15 @@ func TestDroppingMetrics(t *testing.T) {
 	MetricsWritten.Set(0)
 
 	// Add up to the size of the buffer
-	b.Add(metricList...)
-	b.Add(metricList...)
+	assert.Equal(t, 0, b.Add(metricList...))
+	assert.Equal(t, 0, b.Add(metricList...))
 	assert.False(t, b.IsEmpty())
 	assert.Equal(t, b.Len(), 10)
 	assert.Equal(t, int64(0), MetricsDropped.Get())
 	assert.Equal(t, int64(10), MetricsWritten.Get())
 
 	// Add 5 more and verify they were dropped
-	b.Add(metricList...)
+	assert.Equal(t, 5, b.Add(metricList...))
 	assert.False(t, b.IsEmpty())
 	assert.Equal(t, b.Len(), 10)
 	assert.Equal(t, int64(5), MetricsDropped.Get())
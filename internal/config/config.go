@@ -24,6 +24,8 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/plugins/parsers/xml"
 	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/serializers"
 
@@ -774,6 +776,31 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 	}
 	processor := creator()
 
+	// If the processor exchanges metrics with an external program, it needs
+	// a parser and a serializer for the same data_format.  Building the
+	// parser removes data_format from the table, so restore it afterwards.
+	dataFormat, hasDataFormat := table.Fields["data_format"]
+	switch t := processor.(type) {
+	case parsers.ParserInput:
+		parser, err := buildParser(name, table)
+		if err != nil {
+			return err
+		}
+		t.SetParser(parser)
+	}
+
+	switch t := processor.(type) {
+	case serializers.SerializerOutput:
+		if hasDataFormat {
+			table.Fields["data_format"] = dataFormat
+		}
+		serializer, err := buildSerializer(name, table)
+		if err != nil {
+			return err
+		}
+		t.SetSerializer(serializer)
+	}
+
 	processorConfig, err := buildProcessor(name, table)
 	if err != nil {
 		return err
@@ -783,11 +810,7 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 		return err
 	}
 
-	rf := &models.RunningProcessor{
-		Name:      name,
-		Processor: processor,
-		Config:    processorConfig,
-	}
+	rf := models.NewRunningProcessor(name, processor, processorConfig)
 
 	c.Processors = append(c.Processors, rf)
 	return nil
@@ -1573,6 +1596,106 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 		}
 	}
 
+	//for avro parser
+	if node, ok := tbl.Fields["avro_schema_registry"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroSchemaRegistry = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_schema_files"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.AvroSchemaFiles = append(c.AvroSchemaFiles, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_measurement"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroMeasurement = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_tags"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.AvroTags = append(c.AvroTags, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_fields"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.AvroFields = append(c.AvroFields, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_timestamp"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroTimestamp = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroTimestampFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_object"]; ok {
+		switch subtbls := node.(type) {
+		case *ast.Table:
+			c.JSONObjects = append(c.JSONObjects, getJSONObjectConfig(subtbls))
+		case []*ast.Table:
+			for _, subtbl := range subtbls {
+				c.JSONObjects = append(c.JSONObjects, getJSONObjectConfig(subtbl))
+			}
+		}
+	}
+
+	//for xml parser
+	if node, ok := tbl.Fields["xml"]; ok {
+		switch subtbls := node.(type) {
+		case *ast.Table:
+			xc, err := getXMLConfig(subtbls)
+			if err != nil {
+				return nil, err
+			}
+			c.XMLConfig = append(c.XMLConfig, xc)
+		case []*ast.Table:
+			for _, subtbl := range subtbls {
+				xc, err := getXMLConfig(subtbl)
+				if err != nil {
+					return nil, err
+				}
+				c.XMLConfig = append(c.XMLConfig, xc)
+			}
+		}
+	}
+
 	c.MetricName = name
 
 	delete(tbl.Fields, "data_format")
@@ -1612,6 +1735,123 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 	delete(tbl.Fields, "csv_timestamp_column")
 	delete(tbl.Fields, "csv_timestamp_format")
 	delete(tbl.Fields, "csv_trim_space")
+	delete(tbl.Fields, "avro_schema_registry")
+	delete(tbl.Fields, "avro_schema_files")
+	delete(tbl.Fields, "avro_measurement")
+	delete(tbl.Fields, "avro_tags")
+	delete(tbl.Fields, "avro_fields")
+	delete(tbl.Fields, "avro_timestamp")
+	delete(tbl.Fields, "avro_timestamp_format")
+	delete(tbl.Fields, "json_object")
+	delete(tbl.Fields, "xml")
+
+	return c, nil
+}
+
+// getJSONObjectConfig parses a single [[inputs.x.json_object]] sub-table of
+// the json parser.
+func getJSONObjectConfig(tbl *ast.Table) json.JSONObject {
+	var c json.JSONObject
+
+	getString := func(key string, dest *string) {
+		if node, ok := tbl.Fields[key]; ok {
+			if kv, ok := node.(*ast.KeyValue); ok {
+				if str, ok := kv.Value.(*ast.String); ok {
+					*dest = str.Value
+				}
+			}
+		}
+	}
+	getStrings := func(key string, dest *[]string) {
+		if node, ok := tbl.Fields[key]; ok {
+			if kv, ok := node.(*ast.KeyValue); ok {
+				if ary, ok := kv.Value.(*ast.Array); ok {
+					for _, elem := range ary.Value {
+						if str, ok := elem.(*ast.String); ok {
+							*dest = append(*dest, str.Value)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	getString("path", &c.Path)
+	getString("measurement_name", &c.MeasurementName)
+	getString("measurement_name_key", &c.MeasurementNameKey)
+	getString("timestamp_key", &c.TimestampKey)
+	getString("timestamp_format", &c.TimestampFormat)
+	getStrings("expand_arrays", &c.ExpandArrays)
+	getStrings("tag_keys", &c.TagKeys)
+	getStrings("included_keys", &c.IncludedKeys)
+	getStrings("excluded_keys", &c.ExcludedKeys)
+
+	if node, ok := tbl.Fields["field_types"]; ok {
+		if subtbl, ok := node.(*ast.Table); ok {
+			c.FieldTypes = make(map[string]string)
+			for name, val := range subtbl.Fields {
+				if kv, ok := val.(*ast.KeyValue); ok {
+					if str, ok := kv.Value.(*ast.String); ok {
+						c.FieldTypes[name] = str.Value
+					}
+				}
+			}
+		}
+	}
+
+	return c
+}
+
+// getXMLConfig parses a single [[inputs.x.xml]] sub-table of the xml parser.
+// Unknown keys are rejected, as they would otherwise be silently ignored.
+func getXMLConfig(tbl *ast.Table) (xml.Config, error) {
+	var c xml.Config
+
+	for key := range tbl.Fields {
+		switch key {
+		case "metric_selection", "metric_name", "timestamp", "timestamp_format",
+			"field_selection", "field_name", "field_value",
+			"tags", "fields_int", "fields":
+		default:
+			return c, fmt.Errorf("unknown xml parser setting %q on line %d", key, tbl.Line)
+		}
+	}
+
+	getString := func(key string, dest *string) {
+		if node, ok := tbl.Fields[key]; ok {
+			if kv, ok := node.(*ast.KeyValue); ok {
+				if str, ok := kv.Value.(*ast.String); ok {
+					*dest = str.Value
+				}
+			}
+		}
+	}
+	getMap := func(key string) map[string]string {
+		m := make(map[string]string)
+		if node, ok := tbl.Fields[key]; ok {
+			if subtbl, ok := node.(*ast.Table); ok {
+				for name, val := range subtbl.Fields {
+					if kv, ok := val.(*ast.KeyValue); ok {
+						if str, ok := kv.Value.(*ast.String); ok {
+							m[name] = str.Value
+						}
+					}
+				}
+			}
+		}
+		return m
+	}
+
+	getString("metric_selection", &c.Selection)
+	getString("metric_name", &c.MetricName)
+	getString("timestamp", &c.Timestamp)
+	getString("timestamp_format", &c.TimestampFormat)
+	getString("field_selection", &c.FieldSelection)
+	getString("field_name", &c.FieldName)
+	getString("field_value", &c.FieldValue)
+	c.Tags = getMap("tags")
+	c.FieldsInt = getMap("fields_int")
+	c.Fields = getMap("fields")
 
 	return c, nil
 }
@@ -1755,6 +1995,74 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 		}
 	}
 
+	if node, ok := tbl.Fields["prometheus_string_as_label"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.PrometheusStringAsLabel, err = b.Boolean()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_schema_registry"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroSchemaRegistry = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_schema_file"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroSchemaFile = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_namespace"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroNamespace = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_timestamp_field"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroTimestampField = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_measurement_field"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroMeasurementField = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["carbon2_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.Carbon2Format = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["carbon2_separator"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.Carbon2Separator = str.Value
+			}
+		}
+	}
+
 	delete(tbl.Fields, "photon_sender_id")
 	delete(tbl.Fields, "influx_max_line_bytes")
 	delete(tbl.Fields, "influx_sort_fields")
@@ -1765,6 +2073,14 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 	delete(tbl.Fields, "template")
 	delete(tbl.Fields, "json_timestamp_units")
 	delete(tbl.Fields, "splunkmetric_hec_routing")
+	delete(tbl.Fields, "prometheus_string_as_label")
+	delete(tbl.Fields, "avro_schema_registry")
+	delete(tbl.Fields, "avro_schema_file")
+	delete(tbl.Fields, "avro_namespace")
+	delete(tbl.Fields, "avro_timestamp_field")
+	delete(tbl.Fields, "avro_measurement_field")
+	delete(tbl.Fields, "carbon2_format")
+	delete(tbl.Fields, "carbon2_separator")
 	return serializers.NewSerializer(c)
 }
 
@@ -0,0 +1,482 @@
+package listener
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+var (
+	errClosed  = errors.New("listener closed")
+	errRefused = errors.New("maximum number of connections reached")
+)
+
+// handshakeTimeout is the time allowed to clients for sending the proxy
+// protocol header and completing the TLS handshake.
+const handshakeTimeout = 10 * time.Second
+
+// Config is the common configuration of the listeners of service inputs.
+type Config struct {
+	MaxConnections       int                `toml:"max_connections"`
+	IdleTimeout          internal.Duration  `toml:"idle_timeout"`
+	KeepAlivePeriod      *internal.Duration `toml:"keep_alive_period"`
+	RateLimit            float64            `toml:"rate_limit"`
+	ProxyProtocol        bool               `toml:"proxy_protocol"`
+	ProxyProtocolTrusted []string           `toml:"proxy_protocol_trusted"`
+	ClientAddressTag     string             `toml:"client_address_tag"`
+}
+
+// SetReadTimeout applies the read_timeout option deprecated in favor of
+// idle_timeout. When set, it overrides idle_timeout.
+func (c *Config) SetReadTimeout(readTimeout *internal.Duration) {
+	if readTimeout != nil {
+		c.IdleTimeout = *readTimeout
+	}
+}
+
+// trustedProxies returns the networks of proxy_protocol_trusted, nil if the
+// proxy protocol header is read from all clients.
+func (c *Config) trustedProxies() ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range c.ProxyProtocolTrusted {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy_protocol_trusted address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_protocol_trusted network %q: %s", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// proxied reports whether the proxy protocol header is to be read from the
+// peer at addr.
+func proxied(enabled bool, trusted []*net.IPNet, addr net.Addr) bool {
+	if !enabled {
+		return false
+	}
+	if len(trusted) == 0 {
+		return true
+	}
+
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		// unix sockets have no address to check
+		return true
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddClientTag adds the address of the client to the tags, if enabled.
+func (c *Config) AddClientTag(tags map[string]string, addr string) {
+	if c.ClientAddressTag == "" || addr == "" {
+		return
+	}
+	tags[c.ClientAddressTag] = host(addr)
+}
+
+// Address returns the string form of an address, which may be nil.
+func Address(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// host returns the host of an address, without its port.
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+type stats struct {
+	accepted     selfstat.Stat
+	active       selfstat.Stat
+	refused      selfstat.Stat
+	rateLimited  selfstat.Stat
+	tlsErrors    selfstat.Stat
+	proxyErrors  selfstat.Stat
+	idleTimeouts selfstat.Stat
+}
+
+func newStats(input, address string) *stats {
+	tags := map[string]string{
+		"input":   input,
+		"address": address,
+	}
+	return &stats{
+		accepted:     selfstat.Register("listener", "connections_accepted", tags),
+		active:       selfstat.Register("listener", "connections_active", tags),
+		refused:      selfstat.Register("listener", "connections_refused", tags),
+		rateLimited:  selfstat.Register("listener", "rate_limited", tags),
+		tlsErrors:    selfstat.Register("listener", "tls_errors", tags),
+		proxyErrors:  selfstat.Register("listener", "proxy_errors", tags),
+		idleTimeouts: selfstat.Register("listener", "idle_timeouts", tags),
+	}
+}
+
+// Listener is a stream listener accepting the connections of clients within
+// the limits of its configuration. The connections are returned by Accept
+// once the proxy protocol header has been read and the TLS handshake has
+// completed, and are closed along with the listener. The connections count
+// toward the maximum number of connections as soon as they are accepted,
+// including while their handshake is in progress.
+type Listener struct {
+	// Refuse, if set, is called with the connections refused because of the
+	// maximum number of connections before they are closed, unless the
+	// listener uses TLS. It must be set before the first call to Accept.
+	Refuse func(net.Conn)
+
+	listener  net.Listener
+	config    Config
+	trusted   []*net.IPNet
+	tlsConfig *tls.Config
+	limiter   *rateLimiter
+	stats     *stats
+
+	// whether the rate limit applies to connections, rather than to requests
+	limitConnections bool
+	idleTimeout      time.Duration
+
+	start sync.Once
+	ready chan net.Conn
+	done  chan struct{}
+	err   error
+
+	mu     sync.Mutex
+	conns  map[*conn]struct{}
+	active int
+	closed bool
+}
+
+// Listen announces on the local network address. The input is the name of
+// the plugin, used to tag the statistics of the listener. If tlsConfig is not
+// nil, the connections are TLS connections.
+func (c *Config) Listen(input, network, address string, tlsConfig *tls.Config) (*Listener, error) {
+	trusted, err := c.trustedProxies()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := &Listener{
+		listener:         l,
+		config:           *c,
+		trusted:          trusted,
+		tlsConfig:        tlsConfig,
+		stats:            newStats(input, address),
+		limitConnections: true,
+		idleTimeout:      c.IdleTimeout.Duration,
+		ready:            make(chan net.Conn),
+		done:             make(chan struct{}),
+		conns:            make(map[*conn]struct{}),
+	}
+	if c.RateLimit > 0 {
+		listener.limiter = newRateLimiter(c.RateLimit)
+	}
+	return listener, nil
+}
+
+// ListenHTTP announces on the TCP address for the HTTP server, which is to be
+// served with Serve on the returned listener. The rate limit applies to the
+// requests of each client rather than to their connections, and the idle
+// timeout is the one of the keep-alive connections of the server.
+func (c *Config) ListenHTTP(input, address string, tlsConfig *tls.Config, server *http.Server) (*Listener, error) {
+	l, err := c.Listen(input, "tcp", address, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	l.idleTimeout = 0
+	if c.IdleTimeout.Duration > 0 {
+		server.IdleTimeout = c.IdleTimeout.Duration
+	}
+
+	if l.limiter != nil {
+		l.limitConnections = false
+		handler := server.Handler
+		server.Handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if !l.limiter.allow(host(req.RemoteAddr)) {
+				l.stats.rateLimited.Incr(1)
+				http.Error(res, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			handler.ServeHTTP(res, req)
+		})
+	}
+	return l, nil
+}
+
+// Addr returns the network address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	l.start.Do(func() {
+		go l.acceptLoop()
+	})
+
+	select {
+	case c := <-l.ready:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// Close closes the listener and the connections it accepted.
+func (l *Listener) Close() error {
+	err := l.listener.Close()
+
+	l.mu.Lock()
+	l.closed = true
+	conns := make([]*conn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	return err
+}
+
+func (l *Listener) acceptLoop() {
+	for {
+		c, err := l.listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Printf("W! Error accepting connection on %s: %s", l.Addr(), err)
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			l.err = err
+			close(l.done)
+			return
+		}
+
+		lc := &conn{Conn: c, listener: l, remote: c.RemoteAddr()}
+		if err := l.add(lc); err != nil {
+			if err == errRefused && l.Refuse != nil && l.tlsConfig == nil {
+				l.Refuse(lc)
+			}
+			lc.Close()
+			continue
+		}
+
+		// the proxy protocol header and the TLS handshake are handled
+		// concurrently
+		go l.prepare(lc)
+	}
+}
+
+// add tracks a new connection, to be closed along with the listener, and
+// counts it toward the maximum number of connections. It returns an error if
+// the listener is closed or has reached the maximum number of connections.
+func (l *Listener) add(c *conn) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errClosed
+	}
+	if l.config.MaxConnections > 0 && len(l.conns) >= l.config.MaxConnections {
+		l.stats.refused.Incr(1)
+		return errRefused
+	}
+	l.conns[c] = struct{}{}
+	return nil
+}
+
+// activate counts a connection ready to be accepted.
+func (l *Listener) activate(c *conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.active = true
+	l.active++
+	l.stats.active.Incr(1)
+}
+
+func (l *Listener) remove(c *conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.conns[c]; ok {
+		delete(l.conns, c)
+		if c.active {
+			l.active--
+			l.stats.active.Incr(-1)
+		}
+	}
+}
+
+// prepare reads the proxy protocol header and completes the TLS handshake of
+// a new connection before handing it over to Accept.
+func (l *Listener) prepare(c *conn) {
+	raw := c.Conn
+	if err := setKeepAlive(raw, l.config.KeepAlivePeriod); err != nil {
+		log.Printf("W! Unable to configure keep alive on %s: %s", l.Addr(), err)
+	}
+
+	raw.SetDeadline(time.Now().Add(handshakeTimeout))
+	if proxied(l.config.ProxyProtocol, l.trusted, raw.RemoteAddr()) {
+		reader := bufio.NewReader(raw)
+		addr, err := readProxyHeader(reader)
+		if err != nil {
+			l.stats.proxyErrors.Incr(1)
+			log.Printf("D! Invalid proxy protocol header from %s: %s", Address(raw.RemoteAddr()), err)
+			c.Close()
+			return
+		}
+		c.reader = reader
+		if addr != nil {
+			c.remote = addr
+		}
+	}
+
+	if l.limitConnections && l.limiter != nil && !l.limiter.allow(host(Address(c.remote))) {
+		l.stats.rateLimited.Incr(1)
+		c.Close()
+		return
+	}
+
+	var nc net.Conn = c
+	if l.tlsConfig != nil {
+		tc := tls.Server(c, l.tlsConfig)
+		if err := tc.Handshake(); err != nil {
+			l.stats.tlsErrors.Incr(1)
+			log.Printf("D! TLS handshake with %s failed: %s", Address(c.remote), err)
+			c.Close()
+			return
+		}
+		nc = tc
+	}
+	raw.SetDeadline(time.Time{})
+	c.idleTimeout = l.idleTimeout
+
+	l.activate(c)
+	l.stats.accepted.Incr(1)
+	select {
+	case l.ready <- nc:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+func setKeepAlive(c net.Conn, period *internal.Duration) error {
+	if period == nil {
+		return nil
+	}
+	tcpc, ok := c.(*net.TCPConn)
+	if !ok {
+		return fmt.Errorf("cannot set keep alive on a %s socket", c.LocalAddr().Network())
+	}
+	if period.Duration == 0 {
+		return tcpc.SetKeepAlive(false)
+	}
+	if err := tcpc.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return tcpc.SetKeepAlivePeriod(period.Duration)
+}
+
+// conn is a connection accepted by a Listener.
+type conn struct {
+	net.Conn
+	listener *Listener
+
+	// reader holds the data read after the proxy protocol header
+	reader io.Reader
+	remote net.Addr
+
+	idleTimeout time.Duration
+	closeOnce   sync.Once
+
+	// active is set once the connection is ready to be accepted, guarded by
+	// the mutex of the listener
+	active bool
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	if c.idleTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+
+	var n int
+	var err error
+	if c.reader != nil {
+		n, err = c.reader.Read(b)
+	} else {
+		n, err = c.Conn.Read(b)
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() && c.idleTimeout > 0 {
+		c.listener.stats.idleTimeouts.Incr(1)
+	}
+	return n, err
+}
+
+// RemoteAddr returns the address of the client, as sent in the proxy protocol
+// header if enabled.
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetReadBuffer sets the size of the receive buffer of the socket.
+func (c *conn) SetReadBuffer(bytes int) error {
+	srb, ok := c.Conn.(interface {
+		SetReadBuffer(bytes int) error
+	})
+	if !ok {
+		return fmt.Errorf("cannot set read buffer on a %s socket", c.LocalAddr().Network())
+	}
+	return srb.SetReadBuffer(bytes)
+}
+
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.Conn.Close()
+		c.listener.remove(c)
+	})
+	return err
+}
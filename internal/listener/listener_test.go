@@ -0,0 +1,373 @@
+package listener
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pki = testutil.NewPKI("../../testutil/pki")
+
+// accept returns the next connection of the listener, failing the test after
+// a second.
+func accept(t *testing.T, l *Listener) net.Conn {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		c, err := l.Accept()
+		results <- result{c, err}
+	}()
+
+	select {
+	case r := <-results:
+		require.NoError(t, r.err)
+		return r.conn
+	case <-time.After(time.Second):
+		t.Fatal("timeout accepting connection")
+	}
+	return nil
+}
+
+// assertClosed checks that the server closed the client connection.
+func assertClosed(t *testing.T, c net.Conn) {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := c.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestListenMaxConnections(t *testing.T) {
+	config := Config{MaxConnections: 1}
+	l, err := config.Listen("test_max_connections", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+
+	refused := make(chan net.Addr, 1)
+	l.Refuse = func(c net.Conn) {
+		refused <- c.RemoteAddr()
+	}
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c1.Close()
+	s1 := accept(t, l)
+	assert.Equal(t, c1.LocalAddr().String(), s1.RemoteAddr().String())
+
+	c2, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c2.Close()
+	assertClosed(t, c2)
+	assert.Equal(t, c2.LocalAddr().String(), (<-refused).String())
+	assert.Equal(t, int64(1), l.stats.refused.Get())
+	assert.Equal(t, int64(1), l.stats.active.Get())
+
+	// the connection is closed along with the listener
+	require.NoError(t, l.Close())
+	assertClosed(t, c1)
+	assert.Equal(t, int64(0), l.stats.active.Get())
+	assert.Equal(t, int64(1), l.stats.accepted.Get())
+
+	_, err = l.Accept()
+	require.Error(t, err)
+}
+
+func TestListenProxyProtocol(t *testing.T) {
+	config := Config{ProxyProtocol: true, ClientAddressTag: "source"}
+	l, err := config.Listen("test_proxy_protocol", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 8094\r\ncpu value=42\n")
+	require.NoError(t, err)
+
+	s := accept(t, l)
+	assert.Equal(t, "192.0.2.1:56324", s.RemoteAddr().String())
+	line, err := bufio.NewReader(s).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "cpu value=42\n", line)
+
+	tags := map[string]string{}
+	config.AddClientTag(tags, s.RemoteAddr().String())
+	assert.Equal(t, map[string]string{"source": "192.0.2.1"}, tags)
+
+	// connections without the header are refused
+	invalid, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer invalid.Close()
+	_, err = io.WriteString(invalid, "cpu value=42\n")
+	require.NoError(t, err)
+	assertClosed(t, invalid)
+	assert.Equal(t, int64(1), l.stats.proxyErrors.Get())
+}
+
+func TestListenProxyProtocolTrusted(t *testing.T) {
+	config := Config{ProxyProtocol: true, ProxyProtocolTrusted: []string{"192.0.2.0/24"}}
+	l, err := config.Listen("test_proxy_protocol_untrusted", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// the header of an untrusted peer is not read
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 8094\r\n")
+	require.NoError(t, err)
+
+	s := accept(t, l)
+	assert.Equal(t, c.LocalAddr().String(), s.RemoteAddr().String())
+	line, err := bufio.NewReader(s).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 8094\r\n", line)
+
+	config.ProxyProtocolTrusted = []string{"192.0.2.0/24", "127.0.0.1"}
+	trusted, err := config.Listen("test_proxy_protocol_trusted", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer trusted.Close()
+
+	c, err = net.Dial("tcp", trusted.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 8094\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1:56324", accept(t, trusted).RemoteAddr().String())
+
+	config.ProxyProtocolTrusted = []string{"192.0.2.300"}
+	_, err = config.Listen("test_proxy_protocol_invalid", "tcp", "127.0.0.1:0", nil)
+	require.Error(t, err)
+}
+
+func TestListenMaxConnectionsPending(t *testing.T) {
+	config := Config{MaxConnections: 1, ProxyProtocol: true}
+	l, err := config.Listen("test_max_connections_pending", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer l.Close()
+	go l.Accept()
+
+	// a client yet to send its header uses up a connection
+	pending, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer pending.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	assertClosed(t, c)
+	assert.Equal(t, int64(0), l.stats.active.Get())
+	assert.Equal(t, int64(1), l.stats.refused.Get())
+}
+
+func TestListenRateLimit(t *testing.T) {
+	config := Config{RateLimit: 1}
+	l, err := config.Listen("test_rate_limit", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c1.Close()
+	accept(t, l)
+
+	c2, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c2.Close()
+	assertClosed(t, c2)
+	assert.Equal(t, int64(1), l.stats.rateLimited.Get())
+}
+
+func TestListenIdleTimeout(t *testing.T) {
+	config := Config{IdleTimeout: internal.Duration{Duration: 50 * time.Millisecond}}
+	l, err := config.Listen("test_idle_timeout", "tcp", "127.0.0.1:0", nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	s := accept(t, l)
+
+	_, err = s.Read(make([]byte, 1))
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.True(t, ok)
+	assert.True(t, netErr.Timeout())
+	assert.Equal(t, int64(1), l.stats.idleTimeouts.Get())
+}
+
+func TestListenTLSAllowedCNs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		allowed []string
+		err     bool
+	}{
+		{
+			name:    "allowed",
+			input:   "test_tls_allowed",
+			allowed: []string{"client.localdomain"},
+		},
+		{
+			name:    "not allowed",
+			input:   "test_tls_not_allowed",
+			allowed: []string{"other.localdomain"},
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig := pki.TLSServerConfig()
+			serverConfig.TLSAllowedCNs = tt.allowed
+			tlsConfig, err := serverConfig.TLSConfig()
+			require.NoError(t, err)
+
+			config := Config{}
+			l, err := config.Listen(tt.input, "tcp", "127.0.0.1:0", tlsConfig)
+			require.NoError(t, err)
+			defer l.Close()
+
+			// the handshake completes once the listener accepts connections
+			conns := make(chan net.Conn, 1)
+			go func() {
+				if s, err := l.Accept(); err == nil {
+					conns <- s
+				}
+			}()
+
+			clientConfig, err := pki.TLSClientConfig().TLSConfig()
+			require.NoError(t, err)
+			clientConfig.ServerName = "localhost"
+
+			c, err := tls.Dial("tcp", l.Addr().String(), clientConfig)
+			require.NoError(t, err)
+			defer c.Close()
+
+			if tt.err {
+				// the client learns of the rejection with its first read
+				c.SetReadDeadline(time.Now().Add(time.Second))
+				_, err := c.Read(make([]byte, 1))
+				require.Error(t, err)
+				for i := 0; i < 100 && l.stats.tlsErrors.Get() == 0; i++ {
+					time.Sleep(10 * time.Millisecond)
+				}
+				assert.Equal(t, int64(1), l.stats.tlsErrors.Get())
+				return
+			}
+
+			var s net.Conn
+			select {
+			case s = <-conns:
+			case <-time.After(time.Second):
+				t.Fatal("timeout accepting connection")
+			}
+			_, ok := s.(*tls.Conn)
+			assert.True(t, ok)
+			_, err = io.WriteString(c, "cpu value=42\n")
+			require.NoError(t, err)
+			line, err := bufio.NewReader(s).ReadString('\n')
+			require.NoError(t, err)
+			assert.Equal(t, "cpu value=42\n", line)
+		})
+	}
+}
+
+func TestListenHTTPRateLimit(t *testing.T) {
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.WriteHeader(http.StatusNoContent)
+		}),
+	}
+
+	config := Config{RateLimit: 1}
+	l, err := config.ListenHTTP("test_http_rate_limit", "127.0.0.1:0", nil, server)
+	require.NoError(t, err)
+	go server.Serve(l)
+	defer l.Close()
+
+	url := "http://" + l.Addr().String() + "/write"
+	resp, err := http.Post(url, "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// the same connection is reused for the second request
+	resp, err = http.Post(url, "text/plain", nil)
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int64(1), l.stats.rateLimited.Get())
+}
+
+func TestListenPacket(t *testing.T) {
+	config := Config{ProxyProtocol: true, RateLimit: 1}
+	l, err := config.ListenPacket("test_listen_packet", "udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	c, err := net.Dial("udp", l.LocalAddr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	packets := [][]byte{
+		// dropped for the missing header
+		[]byte("cpu:1|c"),
+		append(proxyV2(0x1, 0x12, ipv4Addrs("192.0.2.1", "192.0.2.2", 56324, 8125)), "cpu:2|c"...),
+		// dropped for the rate limit of the first client
+		append(proxyV2(0x1, 0x12, ipv4Addrs("192.0.2.1", "192.0.2.2", 56324, 8125)), "cpu:3|c"...),
+		append(proxyV2(0x1, 0x12, ipv4Addrs("192.0.2.3", "192.0.2.2", 56324, 8125)), "cpu:4|c"...),
+	}
+	for _, p := range packets {
+		_, err := c.Write(p)
+		require.NoError(t, err)
+	}
+
+	buf := make([]byte, 1024)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := l.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu:2|c", string(buf[:n]))
+	assert.Equal(t, "192.0.2.1:56324", addr.String())
+
+	n, addr, err = l.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu:4|c", string(buf[:n]))
+	assert.Equal(t, "192.0.2.3:56324", addr.String())
+
+	assert.Equal(t, int64(1), l.stats.proxyErrors.Get())
+	assert.Equal(t, int64(1), l.stats.rateLimited.Get())
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newRateLimiter(2)
+	r.now = func() time.Time { return now }
+	r.lastPrune = now
+
+	assert.True(t, r.allow("192.0.2.1"))
+	assert.True(t, r.allow("192.0.2.1"))
+	assert.False(t, r.allow("192.0.2.1"))
+	assert.True(t, r.allow("192.0.2.2"))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, r.allow("192.0.2.1"))
+	assert.False(t, r.allow("192.0.2.1"))
+
+	// the clients are forgotten once their buckets are full again
+	now = now.Add(pruneInterval)
+	assert.True(t, r.allow("192.0.2.3"))
+	assert.Len(t, r.buckets, 1)
+}
@@ -0,0 +1,85 @@
+package listener
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+// PacketListener is a packet-oriented connection dropping the packets of the
+// clients over the rate limit, and reading the proxy protocol header of the
+// packets if enabled.
+type PacketListener struct {
+	net.PacketConn
+
+	config  Config
+	trusted []*net.IPNet
+	limiter *rateLimiter
+	stats   *stats
+}
+
+// ListenPacket announces on the local network address. The input is the name
+// of the plugin, used to tag the statistics of the listener.
+func (c *Config) ListenPacket(input, network, address string) (*PacketListener, error) {
+	trusted, err := c.trustedProxies()
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &PacketListener{
+		PacketConn: pc,
+		config:     *c,
+		trusted:    trusted,
+		stats:      newStats(input, address),
+	}
+	if c.RateLimit > 0 {
+		l.limiter = newRateLimiter(c.RateLimit)
+	}
+	return l, nil
+}
+
+// ReadFrom reads the next accepted packet into b, returning the number of
+// bytes of its payload and the address of the client.
+func (l *PacketListener) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := l.PacketConn.ReadFrom(b)
+		if err != nil {
+			return n, addr, err
+		}
+
+		if proxied(l.config.ProxyProtocol, l.trusted, addr) {
+			src, payload, err := parseProxyPacket(b[:n])
+			if err != nil {
+				l.stats.proxyErrors.Incr(1)
+				log.Printf("D! Invalid proxy protocol header from %s: %s", Address(addr), err)
+				continue
+			}
+			n = copy(b, payload)
+			if src != nil {
+				addr = src
+			}
+		}
+
+		if l.limiter != nil && !l.limiter.allow(host(Address(addr))) {
+			l.stats.rateLimited.Incr(1)
+			continue
+		}
+		return n, addr, nil
+	}
+}
+
+// SetReadBuffer sets the size of the receive buffer of the socket.
+func (l *PacketListener) SetReadBuffer(bytes int) error {
+	srb, ok := l.PacketConn.(interface {
+		SetReadBuffer(bytes int) error
+	})
+	if !ok {
+		return fmt.Errorf("cannot set read buffer on a %s socket", l.LocalAddr().Network())
+	}
+	return srb.SetReadBuffer(bytes)
+}
@@ -0,0 +1,164 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// proxyV2Signature starts the headers of version 2 of the proxy protocol.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// maxProxyV1Length is the maximum length of a version 1 header, including the
+// CRLF.
+const maxProxyV1Length = 107
+
+// readProxyHeader reads the proxy protocol header, either version, sent by a
+// proxy at the start of a connection and returns the address of the client.
+// The address is nil for connections established by the proxy itself.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(5)
+	if err != nil {
+		return nil, err
+	}
+	if string(start) == "PROXY" {
+		return readProxyV1(r)
+	}
+
+	signature, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(signature, proxyV2Signature) {
+		return nil, errors.New("missing proxy protocol header")
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return parseProxyV2(header, body)
+}
+
+// readProxyV1 reads a human-readable header, such as
+// "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < maxProxyV1Length {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxy protocol header is too long")
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(parts) != 6 || (parts[1] != "TCP4" && parts[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol header %q", line)
+	}
+
+	ip := net.ParseIP(parts[2])
+	if ip == nil || (parts[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid source address %q", parts[2])
+	}
+	port, err := strconv.ParseUint(parts[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port %q", parts[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// parseProxyV2 parses the binary header, of which the first 16 bytes are the
+// signature, version, command, family and length of the body holding the
+// addresses and the optional TLVs.
+func parseProxyV2(header, body []byte) (net.Addr, error) {
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version %d", header[12]>>4)
+	}
+	switch header[12] & 0x0f {
+	case 0x0:
+		// LOCAL, the connection was established by the proxy
+		return nil, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol command %d", header[12]&0x0f)
+	}
+
+	family, transport := header[13]>>4, header[13]&0x0f
+	var ip net.IP
+	var port int
+	switch family {
+	case 0x0:
+		// UNSPEC
+		return nil, nil
+	case 0x1:
+		if len(body) < 12 {
+			return nil, errors.New("proxy protocol header is too short")
+		}
+		ip = net.IP(body[0:4])
+		port = int(binary.BigEndian.Uint16(body[8:10]))
+	case 0x2:
+		if len(body) < 36 {
+			return nil, errors.New("proxy protocol header is too short")
+		}
+		ip = net.IP(body[0:16])
+		port = int(binary.BigEndian.Uint16(body[32:34]))
+	case 0x3:
+		if len(body) < 216 {
+			return nil, errors.New("proxy protocol header is too short")
+		}
+		name := string(bytes.TrimRight(body[0:108], "\x00"))
+		network := "unix"
+		if transport == 0x2 {
+			network = "unixgram"
+		}
+		return &net.UnixAddr{Name: name, Net: network}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol address family %d", family)
+	}
+
+	ip = append(net.IP(nil), ip...)
+	switch transport {
+	case 0x1:
+		return &net.TCPAddr{IP: ip, Port: port}, nil
+	case 0x2:
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy protocol transport %d", transport)
+}
+
+// parseProxyPacket parses the version 2 header at the start of a datagram and
+// returns the address of the client and the payload.
+func parseProxyPacket(b []byte) (net.Addr, []byte, error) {
+	if len(b) < 16 || !bytes.Equal(b[:len(proxyV2Signature)], proxyV2Signature) {
+		return nil, nil, errors.New("missing proxy protocol header")
+	}
+	end := 16 + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < end {
+		return nil, nil, errors.New("proxy protocol header is too short")
+	}
+	addr, err := parseProxyV2(b[:16], b[16:end])
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, b[end:], nil
+}
@@ -0,0 +1,144 @@
+package listener
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proxyV2 returns a version 2 header with the given command, family and
+// transport, and address block.
+func proxyV2(command, family byte, addrs []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func ipv4Addrs(src, dst string, sport, dport uint16) []byte {
+	b := append(append([]byte{}, net.ParseIP(src).To4()...), net.ParseIP(dst).To4()...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[8:10], sport)
+	binary.BigEndian.PutUint16(b[10:12], dport)
+	return b
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv6 := append(append([]byte{}, net.ParseIP("2001:db8::1")...), net.ParseIP("2001:db8::2")...)
+	ipv6 = append(ipv6, 0xdc, 0x04, 0x01, 0xbb)
+	unix := make([]byte, 216)
+	copy(unix, "/run/client.sock")
+
+	tests := []struct {
+		name   string
+		header string
+		addr   string
+		err    bool
+	}{
+		{
+			name:   "v1 tcp4",
+			header: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n",
+			addr:   "192.0.2.1:56324",
+		},
+		{
+			name:   "v1 tcp6",
+			header: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+			addr:   "[2001:db8::1]:56324",
+		},
+		{
+			name:   "v1 unknown",
+			header: "PROXY UNKNOWN\r\n",
+		},
+		{
+			name:   "v1 mismatched family",
+			header: "PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n",
+			err:    true,
+		},
+		{
+			name:   "v1 invalid port",
+			header: "PROXY TCP4 192.0.2.1 192.0.2.2 65536 443\r\n",
+			err:    true,
+		},
+		{
+			name:   "v1 too long",
+			header: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n",
+			err:    true,
+		},
+		{
+			name:   "v2 tcp4",
+			header: string(proxyV2(0x1, 0x11, ipv4Addrs("192.0.2.1", "192.0.2.2", 56324, 443))),
+			addr:   "192.0.2.1:56324",
+		},
+		{
+			name:   "v2 tcp6 with tlv",
+			header: string(proxyV2(0x1, 0x21, append(ipv6, 0x04, 0x00, 0x01, 0x00))),
+			addr:   "[2001:db8::1]:56324",
+		},
+		{
+			name:   "v2 unix",
+			header: string(proxyV2(0x1, 0x31, unix)),
+			addr:   "/run/client.sock",
+		},
+		{
+			name:   "v2 local",
+			header: string(proxyV2(0x0, 0x00, nil)),
+		},
+		{
+			name:   "v2 truncated addresses",
+			header: string(proxyV2(0x1, 0x11, []byte{192, 0, 2, 1})),
+			err:    true,
+		},
+		{
+			name:   "v2 invalid command",
+			header: string(proxyV2(0x2, 0x11, ipv4Addrs("192.0.2.1", "192.0.2.2", 56324, 443))),
+			err:    true,
+		},
+		{
+			name:   "missing",
+			header: "cpu value=42\n",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.header + "payload"))
+			addr, err := readProxyHeader(r)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.addr == "" {
+				assert.Nil(t, addr)
+			} else {
+				require.NotNil(t, addr)
+				assert.Equal(t, tt.addr, addr.String())
+			}
+
+			rest, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(rest))
+		})
+	}
+}
+
+func TestParseProxyPacket(t *testing.T) {
+	packet := append(proxyV2(0x1, 0x12, ipv4Addrs("192.0.2.1", "192.0.2.2", 56324, 8125)), "cpu:1|c"...)
+	addr, payload, err := parseProxyPacket(packet)
+	require.NoError(t, err)
+	assert.Equal(t, &net.UDPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324}, addr)
+	assert.Equal(t, "cpu:1|c", string(payload))
+
+	_, _, err = parseProxyPacket([]byte("cpu:1|c"))
+	require.Error(t, err)
+
+	_, _, err = parseProxyPacket(packet[:20])
+	require.Error(t, err)
+}
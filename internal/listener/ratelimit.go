@@ -0,0 +1,75 @@
+package listener
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval is the interval between removals of the idle clients from
+// the rate limiter.
+const pruneInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client, refilled at the given rate per
+// second and holding up to one second worth of tokens.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     math.Max(1, math.Ceil(rate)),
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of the client, returning false if it
+// is empty.
+func (r *rateLimiter) allow(client string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Sub(r.lastPrune) >= pruneInterval {
+		r.prune(now)
+	}
+
+	b, ok := r.buckets[client]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[client] = b
+	}
+	b.tokens = math.Min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune removes the buckets which have been refilled, as they are the same as
+// new ones.
+func (r *rateLimiter) prune(now time.Time) {
+	for client, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, client)
+		}
+	}
+	r.lastPrune = now
+}
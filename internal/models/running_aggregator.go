@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type RunningAggregator struct {
@@ -15,6 +16,11 @@ type RunningAggregator struct {
 
 	periodStart time.Time
 	periodEnd   time.Time
+
+	MetricsAdded   selfstat.Stat
+	MetricsDropped selfstat.Stat
+	MetricsPushed  selfstat.Stat
+	PushTime       selfstat.Stat
 }
 
 func NewRunningAggregator(
@@ -25,6 +31,26 @@ func NewRunningAggregator(
 		a:       a,
 		Config:  conf,
 		metrics: make(chan telegraf.Metric, 100),
+		MetricsAdded: selfstat.Register(
+			"aggregate",
+			"metrics_added",
+			map[string]string{"aggregator": conf.Name},
+		),
+		MetricsDropped: selfstat.Register(
+			"aggregate",
+			"metrics_dropped",
+			map[string]string{"aggregator": conf.Name},
+		),
+		MetricsPushed: selfstat.Register(
+			"aggregate",
+			"metrics_pushed",
+			map[string]string{"aggregator": conf.Name},
+		),
+		PushTime: selfstat.RegisterTiming(
+			"aggregate",
+			"push_time_ns",
+			map[string]string{"aggregator": conf.Name},
+		),
 	}
 }
 
@@ -57,6 +83,7 @@ func (r *RunningAggregator) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 
 	if m != nil {
 		m.SetAggregate(true)
+		r.MetricsPushed.Incr(1)
 	}
 
 	return m
@@ -81,10 +108,13 @@ func (r *RunningAggregator) Add(metric telegraf.Metric) bool {
 
 func (r *RunningAggregator) add(in telegraf.Metric) {
 	r.a.Add(in)
+	r.MetricsAdded.Incr(1)
 }
 
 func (r *RunningAggregator) push(acc telegraf.Accumulator) {
+	start := time.Now()
 	r.a.Push(acc)
+	r.PushTime.Incr(time.Since(start).Nanoseconds())
 }
 
 func (r *RunningAggregator) reset() {
@@ -135,6 +165,7 @@ func (r *RunningAggregator) Run(
 				// the metric is outside the current aggregation period, so
 				// skip it.
 				log.Printf("D! aggregator: metric \"%s\" is not in the current timewindow, skipping", m.Name())
+				r.MetricsDropped.Incr(1)
 				continue
 			}
 			r.add(m)
@@ -144,6 +144,56 @@ func TestAddAndPushOnePeriod(t *testing.T) {
 	wg.Wait()
 }
 
+func TestAggregatorStats(t *testing.T) {
+	ra := NewRunningAggregator(&TestAggregator{}, &AggregatorConfig{
+		Name: "TestAggregatorStats",
+		Filter: Filter{
+			NamePass: []string{"*"},
+		},
+		Period: time.Millisecond * 500,
+	})
+	assert.NoError(t, ra.Config.Filter.Compile())
+	acc := testutil.Accumulator{}
+	shutdown := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ra.Run(&acc, shutdown)
+	}()
+
+	for _, tm := range []time.Time{time.Now().Add(time.Millisecond * 50), time.Now().Add(-time.Hour)} {
+		m, err := metric.New("RITest",
+			map[string]string{},
+			map[string]interface{}{
+				"value": int64(101),
+			},
+			tm,
+			telegraf.Untyped)
+		require.NoError(t, err)
+		assert.False(t, ra.Add(m))
+	}
+
+	for ra.MetricsAdded.Get()+ra.MetricsDropped.Get() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(shutdown)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), ra.MetricsAdded.Get())
+	assert.Equal(t, int64(1), ra.MetricsDropped.Get())
+
+	m, err := metric.New("TestMetric",
+		map[string]string{},
+		map[string]interface{}{"sum": int64(101)},
+		time.Now(),
+		telegraf.Untyped)
+	require.NoError(t, err)
+	assert.NotNil(t, ra.MakeMetric(m))
+	assert.Equal(t, int64(1), ra.MetricsPushed.Get())
+}
+
 func TestAddDropOriginal(t *testing.T) {
 	ra := NewRunningAggregator(&TestAggregator{}, &AggregatorConfig{
 		Name: "TestRunningAggregator",
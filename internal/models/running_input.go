@@ -19,6 +19,8 @@ type RunningInput struct {
 	defaultTags map[string]string
 
 	MetricsGathered selfstat.Stat
+	GatherErrors    selfstat.Stat
+	LastGather      selfstat.Stat
 }
 
 func NewRunningInput(
@@ -33,6 +35,16 @@ func NewRunningInput(
 			"metrics_gathered",
 			map[string]string{"input": config.Name},
 		),
+		GatherErrors: selfstat.Register(
+			"gather",
+			"errors",
+			map[string]string{"input": config.Name},
+		),
+		LastGather: selfstat.Register(
+			"gather",
+			"last_success",
+			map[string]string{"input": config.Name},
+		),
 	}
 }
 
@@ -84,6 +96,16 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 	return m
 }
 
+// IncrErrors counts an error reported by the input.
+func (r *RunningInput) IncrErrors() {
+	r.GatherErrors.Incr(1)
+}
+
+// GatherSucceeded records the time of the last gather returning no error.
+func (r *RunningInput) GatherSucceeded() {
+	r.LastGather.Set(time.Now().UnixNano())
+}
+
 func (r *RunningInput) Trace() bool {
 	return r.trace
 }
@@ -152,6 +152,21 @@ func TestMakeMetricWithDaemonTags(t *testing.T) {
 	require.Equal(t, expected, m)
 }
 
+func TestRunningInputStats(t *testing.T) {
+	ri := NewRunningInput(&testInput{}, &InputConfig{
+		Name: "TestRunningInputStats",
+	})
+	assert.Zero(t, ri.LastGather.Get())
+
+	ri.IncrErrors()
+	ri.IncrErrors()
+	assert.Equal(t, int64(2), ri.GatherErrors.Get())
+
+	start := time.Now().UnixNano()
+	ri.GatherSucceeded()
+	assert.True(t, ri.LastGather.Get() >= start)
+}
+
 func TestMakeMetricNameOverride(t *testing.T) {
 	now := time.Now()
 	ri := NewRunningInput(&testInput{}, &InputConfig{
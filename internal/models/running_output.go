@@ -31,6 +31,9 @@ type RunningOutput struct {
 	BufferSize      selfstat.Stat
 	BufferLimit     selfstat.Stat
 	WriteTime       selfstat.Stat
+	WriteErrors     selfstat.Stat
+	MetricsDropped  selfstat.Stat
+	LastWrite       selfstat.Stat
 
 	metrics     *buffer.Buffer
 	failMetrics *buffer.Buffer
@@ -93,6 +96,21 @@ func NewRunningOutput(
 			"write_time_ns",
 			map[string]string{"output": name},
 		),
+		WriteErrors: selfstat.Register(
+			"write",
+			"errors",
+			map[string]string{"output": name},
+		),
+		MetricsDropped: selfstat.Register(
+			"write",
+			"metrics_dropped",
+			map[string]string{"output": name},
+		),
+		LastWrite: selfstat.Register(
+			"write",
+			"last_success",
+			map[string]string{"output": name},
+		),
 	}
 	ro.BufferLimit.Set(int64(ro.MetricBufferLimit))
 	return ro
@@ -118,12 +136,12 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 		return
 	}
 
-	ro.metrics.Add(metric)
+	ro.addMetrics(ro.metrics, metric)
 	if ro.metrics.Len() == ro.MetricBatchSize {
 		batch := ro.metrics.Batch(ro.MetricBatchSize)
 		err := ro.write(batch)
 		if err != nil {
-			ro.failMetrics.Add(batch...)
+			ro.addMetrics(ro.failMetrics, batch...)
 			log.Printf("E! Error writing to output [%s]: %v", ro.Name, err)
 		}
 	}
@@ -134,7 +152,7 @@ func (ro *RunningOutput) Write() error {
 	if output, ok := ro.Output.(telegraf.AggregatingOutput); ok {
 		ro.aggMutex.Lock()
 		metrics := output.Push()
-		ro.metrics.Add(metrics...)
+		ro.addMetrics(ro.metrics, metrics...)
 		output.Reset()
 		ro.aggMutex.Unlock()
 	}
@@ -163,7 +181,7 @@ func (ro *RunningOutput) Write() error {
 				err = ro.write(batch)
 			}
 			if err != nil {
-				ro.failMetrics.Add(batch...)
+				ro.addMetrics(ro.failMetrics, batch...)
 			}
 		}
 	}
@@ -176,7 +194,7 @@ func (ro *RunningOutput) Write() error {
 	}
 
 	if err != nil {
-		ro.failMetrics.Add(batch...)
+		ro.addMetrics(ro.failMetrics, batch...)
 		return err
 	}
 	return nil
@@ -197,6 +215,17 @@ func (ro *RunningOutput) write(metrics []telegraf.Metric) error {
 			ro.Name, nMetrics, elapsed)
 		ro.MetricsWritten.Incr(int64(nMetrics))
 		ro.WriteTime.Incr(elapsed.Nanoseconds())
+		ro.LastWrite.Set(time.Now().UnixNano())
+	} else {
+		ro.WriteErrors.Incr(1)
 	}
 	return err
 }
+
+// addMetrics adds metrics to one of the buffers of the output, counting the
+// metrics dropped when it is full.
+func (ro *RunningOutput) addMetrics(b *buffer.Buffer, metrics ...telegraf.Metric) {
+	if dropped := b.Add(metrics...); dropped > 0 {
+		ro.MetricsDropped.Incr(int64(dropped))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/testutil"
@@ -462,6 +463,33 @@ func TestRunningOutputWriteFailOrder3(t *testing.T) {
 	assert.Equal(t, expected, m.Metrics())
 }
 
+func TestRunningOutputStats(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("TestRunningOutputStats", m, conf, 4, 6)
+
+	// both batches fail and the second one overflows the buffer
+	for _, metric := range append(first5, next5[:3]...) {
+		ro.AddMetric(metric)
+	}
+	assert.Equal(t, int64(2), ro.WriteErrors.Get())
+	assert.Equal(t, int64(2), ro.MetricsDropped.Get())
+	assert.Zero(t, ro.LastWrite.Get())
+
+	m.failWrite = false
+	start := time.Now().UnixNano()
+	err := ro.Write()
+	require.NoError(t, err)
+
+	assert.Len(t, m.Metrics(), 6)
+	assert.Equal(t, int64(2), ro.WriteErrors.Get())
+	assert.True(t, ro.LastWrite.Get() >= start)
+}
+
 type mockOutput struct {
 	sync.Mutex
 
@@ -2,8 +2,10 @@ package models
 
 import (
 	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type RunningProcessor struct {
@@ -12,6 +14,37 @@ type RunningProcessor struct {
 	sync.Mutex
 	Processor telegraf.Processor
 	Config    *ProcessorConfig
+
+	MetricsProcessed selfstat.Stat
+	MetricsEmitted   selfstat.Stat
+	ProcessTime      selfstat.Stat
+}
+
+func NewRunningProcessor(
+	name string,
+	processor telegraf.Processor,
+	config *ProcessorConfig,
+) *RunningProcessor {
+	return &RunningProcessor{
+		Name:      name,
+		Processor: processor,
+		Config:    config,
+		MetricsProcessed: selfstat.Register(
+			"process",
+			"metrics_processed",
+			map[string]string{"processor": config.Name},
+		),
+		MetricsEmitted: selfstat.Register(
+			"process",
+			"metrics_emitted",
+			map[string]string{"processor": config.Name},
+		),
+		ProcessTime: selfstat.RegisterTiming(
+			"process",
+			"process_time_ns",
+			map[string]string{"processor": config.Name},
+		),
+	}
 }
 
 type RunningProcessors []*RunningProcessor
@@ -48,7 +81,12 @@ func (rp *RunningProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 
 		// This metric should pass through the filter, so call the filter Apply
 		// function and append results to the output slice.
-		ret = append(ret, rp.Processor.Apply(metric)...)
+		start := time.Now()
+		out := rp.Processor.Apply(metric)
+		rp.ProcessTime.Incr(time.Since(start).Nanoseconds())
+		rp.MetricsProcessed.Incr(1)
+		rp.MetricsEmitted.Incr(int64(len(out)))
+		ret = append(ret, out...)
 	}
 
 	return ret
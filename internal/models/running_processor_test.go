@@ -166,10 +166,7 @@ func TestRunningProcessor_Apply(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rp := &RunningProcessor{
-				Processor: tt.args.Processor,
-				Config:    tt.args.Config,
-			}
+			rp := NewRunningProcessor("test", tt.args.Processor, tt.args.Config)
 			rp.Config.Filter.Compile()
 
 			actual := rp.Apply(tt.input...)
@@ -201,3 +198,31 @@ func TestRunningProcessor_Order(t *testing.T) {
 		RunningProcessors{rp1, rp2, rp3},
 		procs)
 }
+
+func TestRunningProcessor_Stats(t *testing.T) {
+	config := &ProcessorConfig{
+		Name: "TestRunningProcessor_Stats",
+		Filter: Filter{
+			NamePass: []string{"cpu"},
+		},
+	}
+	require.NoError(t, config.Filter.Compile())
+
+	// duplicates every metric it processes
+	processor := &MockProcessor{
+		ApplyF: func(in ...telegraf.Metric) []telegraf.Metric {
+			return append(in, in[0].Copy())
+		},
+	}
+	rp := NewRunningProcessor("stats", processor, config)
+
+	fields := map[string]interface{}{"value": 42.0}
+	actual := rp.Apply(
+		Metric("cpu", map[string]string{}, fields, time.Unix(0, 0)),
+		Metric("mem", map[string]string{}, fields, time.Unix(0, 0)),
+		Metric("cpu", map[string]string{}, fields, time.Unix(0, 0)),
+	)
+	require.Len(t, actual, 5)
+	require.Equal(t, int64(2), rp.MetricsProcessed.Get())
+	require.Equal(t, int64(4), rp.MetricsEmitted.Get())
+}
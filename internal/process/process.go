@@ -0,0 +1,269 @@
+// Package process manages a long-running child process, restarting it with
+// an exponential backoff whenever it exits unexpectedly.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// minRestartDelay is the delay doubled on consecutive restarts when the
+	// restart delay is shorter, so a zero delay still backs off.
+	minRestartDelay = 100 * time.Millisecond
+
+	// maxRestartDelay caps the backoff between consecutive restarts.
+	maxRestartDelay = 5 * time.Minute
+
+	// backoffResetAfter is how long a process must stay up before the
+	// restart delay is reset to its initial value.
+	backoffResetAfter = time.Minute
+
+	// stopTimeout is how long Stop waits for the process to exit after its
+	// stdin has been closed before killing it.
+	stopTimeout = 5 * time.Second
+)
+
+// Process is a long-running process manager.
+type Process struct {
+	// ReadStdoutFn and ReadStderrFn are called with the output streams of
+	// every started process.  They should read until EOF.
+	ReadStdoutFn func(io.Reader)
+	ReadStderrFn func(io.Reader)
+
+	// RestartDelay is the initial delay before restarting an exited
+	// process; it doubles on every consecutive failure.
+	RestartDelay time.Duration
+
+	// LogName prefixes the log messages of the manager.
+	LogName string
+
+	name string
+	args []string
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	// wmu serializes writes, which are made without holding mu so a
+	// blocked write does not prevent signalling the process.
+	wmu sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a new process manager for the command.  The process is not
+// started until Start is called.
+func New(command []string) (*Process, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no command")
+	}
+
+	return &Process{
+		name:         command[0],
+		args:         command[1:],
+		RestartDelay: 5 * time.Second,
+		LogName:      command[0],
+	}, nil
+}
+
+// Start starts the process and supervises it in the background until Stop
+// is called.
+func (p *Process) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	exited, err := p.cmdStart()
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.supervise(ctx, exited)
+	}()
+
+	return nil
+}
+
+// Stop closes the stdin of the process, giving it the chance to exit on its
+// own before killing it, and stops restarting it.
+func (p *Process) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	p.mu.Lock()
+	cmd, stdin := p.cmd, p.stdin
+	p.mu.Unlock()
+
+	if stdin != nil {
+		stdin.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		<-done
+	}
+}
+
+// Write writes to the stdin of the running process.
+func (p *Process) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.mu.Unlock()
+
+	if stdin == nil {
+		return 0, errors.New("process is not running")
+	}
+
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	return stdin.Write(b)
+}
+
+// Signal sends a signal to the running process.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil || p.cmd.Process == nil {
+		return errors.New("process is not running")
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+// cmdStart starts the process and returns a channel receiving the result of
+// waiting on it.
+func (p *Process) cmdStart() (<-chan error, error) {
+	cmd := exec.Command(p.name, p.args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error opening stdin pipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error opening stdout pipe: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error opening stderr pipe: %v", err)
+	}
+
+	log.Printf("D! [%s] Starting process: %s %s", p.LogName, p.name, p.args)
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting process: %v", err)
+	}
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	p.mu.Unlock()
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		read(p.ReadStdoutFn, stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		read(p.ReadStderrFn, stderr)
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		// All reads must complete before calling Wait.
+		readers.Wait()
+		exited <- cmd.Wait()
+
+		// The process may already have been replaced by a restart.
+		p.mu.Lock()
+		if p.cmd == cmd {
+			p.cmd = nil
+			p.stdin = nil
+		}
+		p.mu.Unlock()
+	}()
+
+	return exited, nil
+}
+
+func (p *Process) supervise(ctx context.Context, exited <-chan error) {
+	delay := p.RestartDelay
+	started := time.Now()
+
+	for {
+		if exited != nil {
+			err := <-exited
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				log.Printf("E! [%s] Process %s exited: %v", p.LogName, p.name, err)
+			} else {
+				log.Printf("E! [%s] Process %s exited", p.LogName, p.name)
+			}
+
+			if time.Since(started) > backoffResetAfter {
+				delay = p.RestartDelay
+			}
+		}
+
+		log.Printf("I! [%s] Restarting in %s...", p.LogName, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if delay < minRestartDelay {
+			delay = minRestartDelay
+		}
+		delay *= 2
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+
+		var err error
+		started = time.Now()
+		exited, err = p.cmdStart()
+		if err != nil {
+			log.Printf("E! [%s] %v", p.LogName, err)
+		}
+	}
+}
+
+func read(fn func(io.Reader), r io.Reader) {
+	if fn == nil {
+		io.Copy(ioutil.Discard, r)
+		return
+	}
+	fn(r)
+	// Drain anything left so the process never blocks on a full pipe.
+	io.Copy(ioutil.Discard, r)
+}
@@ -0,0 +1,107 @@
+// +build !windows
+
+package process
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartsAfterExit(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "echo started; exit 1"})
+	require.NoError(t, err)
+	p.RestartDelay = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	var lines int
+	p.ReadStdoutFn = func(r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			mu.Lock()
+			lines++
+			mu.Unlock()
+		}
+	}
+
+	require.NoError(t, p.Start())
+	defer p.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := lines
+		mu.Unlock()
+		if n >= 3 {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "process was not restarted")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteToStdin(t *testing.T) {
+	p, err := New([]string{"cat"})
+	require.NoError(t, err)
+
+	lineC := make(chan string, 1)
+	p.ReadStdoutFn = func(r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lineC <- scanner.Text()
+		}
+	}
+
+	require.NoError(t, p.Start())
+
+	_, err = p.Write([]byte("hello\n"))
+	require.NoError(t, err)
+
+	select {
+	case line := <-lineC:
+		require.Equal(t, "hello", line)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for output")
+	}
+
+	// cat exits once stdin is closed, so Stop should not need to kill it.
+	start := time.Now()
+	p.Stop()
+	require.True(t, time.Since(start) < stopTimeout)
+}
+
+func TestStartInvalidCommand(t *testing.T) {
+	p, err := New([]string{"/nonexistent/command"})
+	require.NoError(t, err)
+	require.Error(t, p.Start())
+}
+
+func TestRestartBacksOffWithoutDelay(t *testing.T) {
+	p, err := New([]string{"sh", "-c", "echo started; exit 1"})
+	require.NoError(t, err)
+	p.RestartDelay = 0
+
+	var mu sync.Mutex
+	var lines int
+	p.ReadStdoutFn = func(r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			mu.Lock()
+			lines++
+			mu.Unlock()
+		}
+	}
+
+	require.NoError(t, p.Start())
+	time.Sleep(500 * time.Millisecond)
+	p.Stop()
+
+	// Started, restarted right away, then after 200ms and 400ms more.
+	mu.Lock()
+	defer mu.Unlock()
+	require.True(t, lines >= 2 && lines <= 3, "started %d times", lines)
+}
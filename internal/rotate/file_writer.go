@@ -0,0 +1,242 @@
+// Package rotate implements a file writer which rotates the file by age or
+// size, keeping a limited number of archives.
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FilePerm is the permission of created files.
+	FilePerm = os.FileMode(0644)
+
+	// DateFormat is the timestamp format appended to archived files, it
+	// sorts lexically in time order.
+	DateFormat = "2006-01-02T15-04-05.000000000"
+)
+
+// FileWriter is an io.WriteCloser appending to a file, which is rotated when
+// it is older than the interval or would grow beyond the maximum size.
+type FileWriter struct {
+	filename    string
+	interval    time.Duration
+	maxSize     int64
+	maxArchives int
+	compress    bool
+
+	mu           sync.Mutex
+	closed       bool
+	current      *os.File
+	expireTime   time.Time
+	bytesWritten int64
+}
+
+// NewFileWriter opens filename for appending, creating it and its directory
+// if required.
+//
+// The file is rotated when it is older than interval, or would exceed
+// maxSize bytes, either of which may be 0 to disable that rotation.
+// Rotated files are renamed with a timestamp before the extension, ie:
+// metrics.2018-11-02T15-04-05.000000000.out, and compressed with gzip when
+// compress is set.  Only the newest maxArchives archives are kept, or all
+// archives when it is -1.
+func NewFileWriter(filename string, interval time.Duration, maxSize int64, maxArchives int, compress bool) (*FileWriter, error) {
+	w := &FileWriter{
+		filename:    filename,
+		interval:    interval,
+		maxSize:     maxSize,
+		maxArchives: maxArchives,
+		compress:    compress,
+	}
+
+	if err := w.openCurrent(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes p to the current file, rotating it first if required.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	// Reopen the file if it could not be reopened after a failed rotation.
+	if w.current == nil {
+		if err := w.openCurrent(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.needsRotation(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.current.Write(p)
+	w.bytesWritten += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.current == nil {
+		return nil
+	}
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
+
+func (w *FileWriter) openCurrent() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, FilePerm)
+	if err != nil {
+		return err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.current = f
+	w.bytesWritten = stat.Size()
+	w.expireTime = time.Now().Add(w.interval)
+	return nil
+}
+
+func (w *FileWriter) needsRotation(n int64) bool {
+	if w.interval > 0 && time.Now().After(w.expireTime) {
+		return true
+	}
+	// Never rotate an empty file, even if the write alone exceeds the size.
+	if w.maxSize > 0 && w.bytesWritten > 0 && w.bytesWritten+n > w.maxSize {
+		return true
+	}
+	return false
+}
+
+// rotate archives the current file and opens a new one.  The file is
+// reopened even if archiving fails, so later writes are not lost.
+func (w *FileWriter) rotate() error {
+	closeErr := w.current.Close()
+	w.current = nil
+
+	ext := filepath.Ext(w.filename)
+	base := strings.TrimSuffix(w.filename, ext)
+	archive := base + "." + time.Now().UTC().Format(DateFormat) + ext
+	renameErr := os.Rename(w.filename, archive)
+
+	if err := w.openCurrent(); err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if w.compress {
+		if err := compressFile(archive); err != nil {
+			return err
+		}
+	}
+	return w.purgeArchives(base, ext)
+}
+
+// purgeArchives removes the oldest archives beyond the maximum.
+func (w *FileWriter) purgeArchives(base, ext string) error {
+	if w.maxArchives < 0 {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return err
+	}
+
+	type archive struct {
+		stamp string
+		name  string
+	}
+
+	prefix := filepath.Base(base) + "."
+	var archives []archive
+	for _, file := range files {
+		// Check the timestamp, so other files with the same prefix are not
+		// removed.
+		name := file.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		if _, err := time.Parse(DateFormat, stamp); err != nil {
+			continue
+		}
+		archives = append(archives, archive{stamp: stamp, name: name})
+	}
+
+	if len(archives) <= w.maxArchives {
+		return nil
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].stamp < archives[j].stamp })
+	for _, a := range archives[:len(archives)-w.maxArchives] {
+		if err := os.Remove(filepath.Join(filepath.Dir(base), a.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressFile replaces the file with a gzip compressed copy.
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	in.Close()
+	return os.Remove(filename)
+}
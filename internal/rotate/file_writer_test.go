@@ -0,0 +1,150 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestFileWriterAppends(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "sub", "metrics.out")
+
+	w, err := NewFileWriter(filename, 0, 0, -1, false)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("a\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = NewFileWriter(filename, 0, 0, -1, false)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	buf, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\n", string(buf))
+
+	_, err = w.Write([]byte("c\n"))
+	require.Error(t, err)
+}
+
+func TestFileWriterRotatesBySize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "metrics.out")
+
+	w, err := NewFileWriter(filename, 0, 10, 2, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	for i := 0; i < 4; i++ {
+		_, err = w.Write([]byte("12345678\n"))
+		require.NoError(t, err)
+	}
+
+	// Three rotations, with the oldest archive removed.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	buf, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "12345678\n", string(buf))
+}
+
+func TestFileWriterRotatesByTime(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "metrics.out")
+
+	w, err := NewFileWriter(filename, time.Millisecond, 0, -1, true)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+
+	archives, err := filepath.Glob(filepath.Join(dir, "metrics.*.out.gz"))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+
+	f, err := os.Open(archives[0])
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, "first\n", string(buf))
+
+	buf, err = ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(buf))
+}
+
+func TestFileWriterKeepsUnrelatedFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "metrics.out")
+	other := filepath.Join(dir, "metrics.backup.out")
+	require.NoError(t, ioutil.WriteFile(other, []byte("keep"), FilePerm))
+
+	w, err := NewFileWriter(filename, 0, 1, 0, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("a\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("b\n"))
+	require.NoError(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	_, err = os.Stat(other)
+	require.NoError(t, err)
+}
+
+func TestFileWriterReopensAfterFailedRotation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "metrics.out")
+
+	// A non-empty directory named like an archive cannot be purged.
+	stuck := filepath.Join(dir, "metrics.2000-01-01T00-00-00.000000000.out")
+	require.NoError(t, os.MkdirAll(filepath.Join(stuck, "sub"), 0755))
+
+	w, err := NewFileWriter(filename, 0, 1, 0, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("a\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("b\n"))
+	require.Error(t, err)
+
+	require.NoError(t, os.RemoveAll(stuck))
+	_, err = w.Write([]byte("c\n"))
+	require.NoError(t, err)
+
+	buf, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "c\n", string(buf))
+}
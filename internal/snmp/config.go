@@ -0,0 +1,53 @@
+package snmp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soniah/gosnmp"
+)
+
+// SecLevel parses the SNMPv3 security level used by the sec_level option:
+// "noAuthNoPriv" (the default), "authNoPriv" or "authPriv".
+func SecLevel(level string) (gosnmp.SnmpV3MsgFlags, error) {
+	switch strings.ToLower(level) {
+	case "noauthnopriv", "":
+		return gosnmp.NoAuthNoPriv, nil
+	case "authnopriv":
+		return gosnmp.AuthNoPriv, nil
+	case "authpriv":
+		return gosnmp.AuthPriv, nil
+	default:
+		return 0, fmt.Errorf("invalid secLevel")
+	}
+}
+
+// AuthProtocol parses the SNMPv3 authentication protocol used by the
+// auth_protocol option: "MD5", "SHA" or "" for none.
+func AuthProtocol(protocol string) (gosnmp.SnmpV3AuthProtocol, error) {
+	switch strings.ToLower(protocol) {
+	case "md5":
+		return gosnmp.MD5, nil
+	case "sha":
+		return gosnmp.SHA, nil
+	case "":
+		return gosnmp.NoAuth, nil
+	default:
+		return 0, fmt.Errorf("invalid authProtocol")
+	}
+}
+
+// PrivProtocol parses the SNMPv3 privacy protocol used by the priv_protocol
+// option: "DES", "AES" or "" for none.
+func PrivProtocol(protocol string) (gosnmp.SnmpV3PrivProtocol, error) {
+	switch strings.ToLower(protocol) {
+	case "des":
+		return gosnmp.DES, nil
+	case "aes":
+		return gosnmp.AES, nil
+	case "":
+		return gosnmp.NoPriv, nil
+	default:
+		return 0, fmt.Errorf("invalid privProtocol")
+	}
+}
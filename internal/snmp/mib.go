@@ -0,0 +1,494 @@
+package snmp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Node is an object of the OID tree.
+type Node struct {
+	// Name and Module are empty for nodes without definition.
+	Name   string
+	Module string
+	// OID is the numeric OID, with a leading dot.
+	OID string
+	// Macro is the kind of definition, e.g. OBJECT-TYPE.
+	Macro string
+	// Syntax is the type of an OBJECT-TYPE as written in its definition,
+	// e.g. "INTEGER", "OCTET STRING" or the name of a textual convention.
+	Syntax string
+	// TextualConvention is the name of the textual convention of the syntax,
+	// or empty if the syntax is a base type.
+	TextualConvention string
+	// DisplayHint is the display hint of the textual convention.
+	DisplayHint string
+	// Enums holds the named values of an enumerated syntax.
+	Enums  map[int]string
+	Access string
+	// Index is the names of the index objects of a table entry.
+	Index []string
+
+	parent   *Node
+	number   int
+	children map[int]*Node
+	// name of the entry extended by a table entry
+	augments string
+}
+
+// Children returns the child nodes ordered by sub-identifier.
+func (n *Node) Children() []*Node {
+	numbers := make([]int, 0, len(n.children))
+	for k := range n.children {
+		numbers = append(numbers, k)
+	}
+	sort.Ints(numbers)
+
+	children := make([]*Node, 0, len(numbers))
+	for _, k := range numbers {
+		children = append(children, n.children[k])
+	}
+	return children
+}
+
+func (n *Node) child(number int) *Node {
+	c, ok := n.children[number]
+	if !ok {
+		c = &Node{
+			OID:      n.OID + "." + strconv.Itoa(number),
+			parent:   n,
+			number:   number,
+			children: make(map[int]*Node),
+		}
+		n.children[number] = c
+	}
+	return c
+}
+
+// MibTree is the OID tree of the MIB modules loaded from a set of
+// directories. It is safe for concurrent use.
+type MibTree struct {
+	sync.RWMutex
+
+	dirs    map[string]bool
+	modules map[string]*module
+
+	root  *Node
+	names map[string][]*Node
+}
+
+// NewMibTree returns a tree holding only the SMI base objects.
+func NewMibTree() *MibTree {
+	t := &MibTree{
+		dirs:    make(map[string]bool),
+		modules: make(map[string]*module),
+	}
+
+	modules, err := parseModules(baseModules)
+	if err != nil {
+		panic(err)
+	}
+	for _, m := range modules {
+		t.modules[m.name] = m
+	}
+	t.build()
+	return t
+}
+
+// LoadDir parses the MIB files of a directory and adds their modules to the
+// tree. Directories already loaded are skipped. Files which can not be parsed
+// are skipped and reported in the returned error once the others are loaded.
+func (t *MibTree) LoadDir(dir string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.dirs[dir] {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	t.dirs[dir] = true
+
+	var failed []string
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", path, err))
+			continue
+		}
+		modules, err := parseModules(string(src))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", path, err))
+			continue
+		}
+		for _, m := range modules {
+			t.modules[m.name] = m
+		}
+	}
+
+	t.build()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to parse MIB files: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// build resolves the OIDs of all modules into a new tree.
+func (t *MibTree) build() {
+	b := &builder{
+		modules:  t.modules,
+		root:     &Node{children: make(map[int]*Node)},
+		byName:   make(map[string][]*module),
+		resolved: make(map[*valueDef]*Node),
+		visiting: make(map[*valueDef]bool),
+	}
+	for i, name := range []string{"ccitt", "iso", "joint-iso-ccitt"} {
+		b.root.child(i).Name = name
+	}
+
+	// sorted for a deterministic result when an OID is defined twice
+	names := make([]string, 0, len(t.modules))
+	for name := range t.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for def := range t.modules[name].defs {
+			b.byName[def] = append(b.byName[def], t.modules[name])
+		}
+	}
+	for _, name := range names {
+		m := t.modules[name]
+		for _, v := range m.values {
+			b.resolve(m, v)
+		}
+	}
+
+	t.root = b.root
+	t.names = make(map[string][]*Node)
+	walk(t.root, func(n *Node) {
+		if n.Name != "" {
+			t.names[n.Name] = append(t.names[n.Name], n)
+		}
+	})
+
+	// table entries extending another entry share its index
+	walk(t.root, func(n *Node) {
+		if n.augments == "" {
+			return
+		}
+		if base := t.find(n.augments, t.modules[n.Module]); base != nil {
+			n.Index = base.Index
+		}
+	})
+}
+
+func walk(n *Node, f func(*Node)) {
+	f(n)
+	for _, c := range n.children {
+		walk(c, f)
+	}
+}
+
+// find returns the node of a name as seen from a module.
+func (t *MibTree) find(name string, from *module) *Node {
+	nodes := t.names[name]
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	if from != nil {
+		module := from.name
+		if imported, ok := from.imports[name]; ok {
+			module = imported
+		}
+		for _, n := range nodes {
+			if n.Module == module {
+				return n
+			}
+		}
+	}
+	return nodes[0]
+}
+
+type builder struct {
+	modules map[string]*module
+	root    *Node
+	// modules defining each value name
+	byName   map[string][]*module
+	resolved map[*valueDef]*Node
+	visiting map[*valueDef]bool
+}
+
+// resolve places a value definition in the tree, resolving the values it
+// refers to first.
+func (b *builder) resolve(m *module, v *valueDef) *Node {
+	if n, ok := b.resolved[v]; ok {
+		return n
+	}
+	if b.visiting[v] {
+		return nil
+	}
+	b.visiting[v] = true
+	defer delete(b.visiting, v)
+
+	var n *Node
+	if v.trapNumber >= 0 {
+		parent := b.lookup(m, v.enterprise)
+		if parent == nil {
+			return nil
+		}
+		n = parent.child(0).child(v.trapNumber)
+	} else {
+		for i, c := range v.oid {
+			switch {
+			case i == 0 && c.name != "":
+				n = b.lookup(m, c.name)
+				if n == nil {
+					return nil
+				}
+			case c.number < 0:
+				return nil
+			case n == nil:
+				n = b.root.child(c.number)
+			default:
+				n = n.child(c.number)
+			}
+		}
+	}
+
+	b.resolved[v] = n
+	if n.Module == "" {
+		b.define(n, m, v)
+	}
+	return n
+}
+
+// lookup returns the node of a name referred to by a module.
+func (b *builder) lookup(from *module, name string) *Node {
+	search := []string{from.name}
+	if imported, ok := from.imports[name]; ok {
+		search = []string{imported, from.name}
+	}
+
+	for _, modName := range search {
+		if m, ok := b.modules[modName]; ok {
+			if v, ok := m.defs[name]; ok {
+				if n := b.resolve(m, v); n != nil {
+					return n
+				}
+			}
+		}
+	}
+
+	for _, n := range b.root.children {
+		if n.Name == name {
+			return n
+		}
+	}
+
+	// not imported, as seen with SMIv1 modules, look in all modules
+	for _, m := range b.byName[name] {
+		if n := b.resolve(m, m.defs[name]); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (b *builder) define(n *Node, m *module, v *valueDef) {
+	n.Name = v.name
+	n.Module = m.name
+	n.Macro = v.macro
+	n.Access = v.access
+	n.Index = v.index
+	n.augments = v.augments
+
+	if v.syntax == nil {
+		return
+	}
+	n.Syntax = v.syntax.name
+	n.Enums = v.syntax.enums
+
+	// follow the chain of type assignments down to the base type
+	name := v.syntax.name
+	from := m
+	for i := 0; i < 8; i++ {
+		td, tm := b.typeDef(from, name)
+		if td == nil {
+			break
+		}
+		if n.TextualConvention == "" {
+			n.TextualConvention = td.name
+		}
+		if n.DisplayHint == "" {
+			n.DisplayHint = td.hint
+		}
+		if td.syntax == nil {
+			break
+		}
+		if n.Enums == nil {
+			n.Enums = td.syntax.enums
+		}
+		name, from = td.syntax.name, tm
+	}
+
+	// a reference to an unloaded module is still a textual convention
+	if n.TextualConvention == "" && !isBaseType(n.Syntax) {
+		n.TextualConvention = n.Syntax
+	}
+}
+
+func (b *builder) typeDef(from *module, name string) (*typeDef, *module) {
+	search := []string{from.name}
+	if imported, ok := from.imports[name]; ok {
+		search = []string{imported}
+	}
+	for _, modName := range search {
+		if m, ok := b.modules[modName]; ok {
+			if td, ok := m.types[name]; ok {
+				return td, m
+			}
+		}
+	}
+	return nil, nil
+}
+
+// isBaseType reports whether the syntax is an ASN.1 or SMI base type.
+func isBaseType(syntax string) bool {
+	switch syntax {
+	case "INTEGER", "OCTET STRING", "OBJECT IDENTIFIER", "BITS",
+		"Integer32", "Unsigned32", "Gauge32", "Counter32", "Counter64",
+		"TimeTicks", "IpAddress", "Opaque", "Counter", "Gauge",
+		"NetworkAddress":
+		return true
+	}
+	return strings.HasPrefix(syntax, "SEQUENCE")
+}
+
+// Lookup resolves an OID given by number, e.g. ".1.3.6.1.2.1.2.2.1.6.1", or by
+// name, e.g. "IF-MIB::ifPhysAddress.1" or "ifPhysAddress.1". It returns the
+// closest node with a definition, and the remaining sub-identifiers with a
+// leading dot.
+func (t *MibTree) Lookup(oid string) (*Node, string, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	oid = strings.TrimPrefix(oid, ".")
+	if oid == "" {
+		return nil, "", fmt.Errorf("empty OID")
+	}
+
+	if c := oid[0]; c >= '0' && c <= '9' {
+		return t.lookupNumeric(oid)
+	}
+
+	var module string
+	if i := strings.Index(oid, "::"); i != -1 {
+		module, oid = oid[:i], oid[i+2:]
+	}
+
+	name, suffix := oid, ""
+	if i := strings.Index(oid, "."); i != -1 {
+		name, suffix = oid[:i], oid[i:]
+	}
+	for _, s := range strings.Split(strings.TrimPrefix(suffix, "."), ".") {
+		if _, err := strconv.Atoi(s); err != nil && suffix != "" {
+			return nil, "", fmt.Errorf("invalid OID index %q", suffix)
+		}
+	}
+
+	for _, n := range t.names[name] {
+		if module == "" || n.Module == module {
+			return n, suffix, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown OID %s", name)
+}
+
+func (t *MibTree) lookupNumeric(oid string) (*Node, string, error) {
+	parts := strings.Split(oid, ".")
+
+	n := t.root
+	found, depth := (*Node)(nil), 0
+	for i, s := range parts {
+		number, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid OID %q", oid)
+		}
+		c, ok := n.children[number]
+		if !ok {
+			break
+		}
+		n = c
+		if n.Name != "" {
+			found, depth = n, i+1
+		}
+	}
+
+	if found == nil {
+		return nil, "", fmt.Errorf("unknown OID .%s", oid)
+	}
+
+	var suffix string
+	if depth < len(parts) {
+		suffix = "." + strings.Join(parts[depth:], ".")
+	}
+	return found, suffix, nil
+}
+
+// Table returns the entry of a table and its accessible columns.
+func (t *MibTree) Table(table *Node) (*Node, []*Node, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	entry, ok := table.children[1]
+	if !ok || entry.Name == "" {
+		return nil, nil, fmt.Errorf("%s is not a table", table.Name)
+	}
+
+	var columns []*Node
+	for _, c := range entry.Children() {
+		if c.Name != "" && c.Access != "not-accessible" {
+			columns = append(columns, c)
+		}
+	}
+	return entry, columns, nil
+}
+
+// baseModules defines the objects of the SMI which most modules depend on, so
+// that they resolve even when SNMPv2-SMI is not among the loaded MIBs.
+const baseModules = `
+SNMPv2-SMI DEFINITIONS ::= BEGIN
+org            OBJECT IDENTIFIER ::= { iso 3 }
+dod            OBJECT IDENTIFIER ::= { org 6 }
+internet       OBJECT IDENTIFIER ::= { dod 1 }
+directory      OBJECT IDENTIFIER ::= { internet 1 }
+mgmt           OBJECT IDENTIFIER ::= { internet 2 }
+mib-2          OBJECT IDENTIFIER ::= { mgmt 1 }
+transmission   OBJECT IDENTIFIER ::= { mib-2 10 }
+experimental   OBJECT IDENTIFIER ::= { internet 3 }
+private        OBJECT IDENTIFIER ::= { internet 4 }
+enterprises    OBJECT IDENTIFIER ::= { private 1 }
+security       OBJECT IDENTIFIER ::= { internet 5 }
+snmpV2         OBJECT IDENTIFIER ::= { internet 6 }
+snmpDomains    OBJECT IDENTIFIER ::= { snmpV2 1 }
+snmpProxys     OBJECT IDENTIFIER ::= { snmpV2 2 }
+snmpModules    OBJECT IDENTIFIER ::= { snmpV2 3 }
+zeroDotZero    OBJECT IDENTIFIER ::= { 0 0 }
+END
+`
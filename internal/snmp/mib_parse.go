@@ -0,0 +1,482 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// module holds the definitions of a MIB module as parsed, before the OIDs are
+// resolved into the tree.
+type module struct {
+	name    string
+	imports map[string]string // symbol -> module
+	values  []*valueDef
+	defs    map[string]*valueDef
+	types   map[string]*typeDef
+}
+
+// valueDef is an OBJECT IDENTIFIER value, such as an OBJECT-TYPE.
+type valueDef struct {
+	name       string
+	macro      string
+	syntax     *syntaxDef
+	access     string
+	index      []string
+	augments   string
+	enterprise string
+	// components of the OID value, names are resolved later
+	oid []oidComponent
+	// trapNumber is the value of a TRAP-TYPE, which has no OID value
+	trapNumber int
+}
+
+// typeDef is a type assignment, such as a TEXTUAL-CONVENTION.
+type typeDef struct {
+	name   string
+	hint   string
+	syntax *syntaxDef
+}
+
+type syntaxDef struct {
+	name  string
+	enums map[int]string
+}
+
+type oidComponent struct {
+	name   string
+	number int // -1 if unnamed
+}
+
+type token struct {
+	text string
+	line int
+	// quoted strings are kept apart from identifiers
+	quoted bool
+}
+
+// lex splits a MIB file into tokens, dropping comments.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			// comments end at the end of the line or at the next "--"
+			i += 2
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '-' && i+1 < len(src) && src[i+1] == '-' {
+					i += 2
+					break
+				}
+				i++
+			}
+		case c == '"':
+			start := line
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			if j == len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", start)
+			}
+			tokens = append(tokens, token{text: src[i+1 : j], line: start, quoted: true})
+			i = j + 1
+		case c == '\'':
+			// binary or hexadecimal string, e.g. '00'H
+			j := strings.IndexByte(src[i+1:], '\'')
+			if j == -1 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			j += i + 2
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			tokens = append(tokens, token{text: src[i:j], line: line, quoted: true})
+			i = j
+		case c == ':' && strings.HasPrefix(src[i:], "::="):
+			tokens = append(tokens, token{text: "::=", line: line})
+			i += 3
+		case c == '.' && strings.HasPrefix(src[i:], ".."):
+			tokens = append(tokens, token{text: "..", line: line})
+			i += 2
+		case isIdentChar(c) || (c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9'):
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				// an identifier ends where a comment starts
+				if src[j] == '-' && j+1 < len(src) && src[j+1] == '-' {
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, token{text: src[i:j], line: line})
+			i = j
+		default:
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() string {
+	if p.eof() {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *parser) next() string {
+	if p.eof() {
+		return ""
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	if t.quoted {
+		// never match a keyword
+		return "\"" + t.text
+	}
+	return t.text
+}
+
+func (p *parser) nextString() string {
+	if !p.eof() && p.tokens[p.pos].quoted {
+		t := p.tokens[p.pos]
+		p.pos++
+		return t.text
+	}
+	return ""
+}
+
+func (p *parser) expect(text string) error {
+	line := 0
+	if !p.eof() {
+		line = p.tokens[p.pos].line
+	}
+	if t := p.next(); t != text {
+		return fmt.Errorf("line %d: expected %q, got %q", line, text, t)
+	}
+	return nil
+}
+
+// skipBlock skips the balanced block opened by the token just read.
+func (p *parser) skipBlock(open, close string) {
+	depth := 1
+	for !p.eof() && depth > 0 {
+		switch p.next() {
+		case open:
+			depth++
+		case close:
+			depth--
+		}
+	}
+}
+
+// parseModules parses all the modules of a MIB file.
+func parseModules(src string) ([]*module, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	var modules []*module
+	for !p.eof() {
+		m, err := p.parseModule()
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+func (p *parser) parseModule() (*module, error) {
+	m := &module{
+		name:    p.next(),
+		imports: make(map[string]string),
+		defs:    make(map[string]*valueDef),
+		types:   make(map[string]*typeDef),
+	}
+
+	// skip the module OID and tag defaults
+	for !p.eof() && p.peek() != "DEFINITIONS" {
+		p.next()
+	}
+	if err := p.expect("DEFINITIONS"); err != nil {
+		return nil, err
+	}
+	for !p.eof() && p.peek() != "::=" {
+		p.next()
+	}
+	if err := p.expect("::="); err != nil {
+		return nil, err
+	}
+	if err := p.expect("BEGIN"); err != nil {
+		return nil, err
+	}
+
+	for {
+		if p.eof() {
+			return nil, fmt.Errorf("module %s: missing END", m.name)
+		}
+
+		t := p.next()
+		switch {
+		case t == "END":
+			return m, nil
+		case t == "IMPORTS":
+			p.parseImports(m)
+		case t == "EXPORTS":
+			for !p.eof() && p.next() != ";" {
+			}
+		case p.peek() == "MACRO":
+			for !p.eof() && p.next() != "END" {
+			}
+		case isTypeName(t) && p.peek() == "::=":
+			p.next()
+			m.types[t] = p.parseTypeAssignment(t)
+		case isValueName(t):
+			if v := p.parseValue(t); v != nil {
+				m.values = append(m.values, v)
+				m.defs[v.name] = v
+			}
+		}
+	}
+}
+
+func isTypeName(t string) bool {
+	return t != "" && unicode.IsUpper(rune(t[0]))
+}
+
+func isValueName(t string) bool {
+	return t != "" && unicode.IsLower(rune(t[0]))
+}
+
+func (p *parser) parseImports(m *module) {
+	var symbols []string
+	for !p.eof() {
+		t := p.next()
+		switch t {
+		case ";":
+			return
+		case ",":
+		case "FROM":
+			from := p.next()
+			for _, s := range symbols {
+				m.imports[s] = from
+			}
+			symbols = symbols[:0]
+		default:
+			symbols = append(symbols, t)
+		}
+	}
+}
+
+func (p *parser) parseTypeAssignment(name string) *typeDef {
+	td := &typeDef{name: name}
+	if p.peek() != "TEXTUAL-CONVENTION" {
+		td.syntax = p.parseSyntax()
+		return td
+	}
+
+	p.next()
+	for !p.eof() {
+		switch p.next() {
+		case "DISPLAY-HINT":
+			td.hint = p.nextString()
+		case "SYNTAX":
+			td.syntax = p.parseSyntax()
+			return td
+		}
+	}
+	return td
+}
+
+// parseSyntax parses a type, keeping its name and enumerated values.
+func (p *parser) parseSyntax() *syntaxDef {
+	if p.peek() == "[" {
+		p.next()
+		p.skipBlock("[", "]")
+	}
+	if t := p.peek(); t == "IMPLICIT" || t == "EXPLICIT" {
+		p.next()
+	}
+
+	s := &syntaxDef{name: p.next()}
+	switch s.name {
+	case "OCTET":
+		if p.peek() == "STRING" {
+			p.next()
+			s.name = "OCTET STRING"
+		}
+	case "OBJECT":
+		if p.peek() == "IDENTIFIER" {
+			p.next()
+			s.name = "OBJECT IDENTIFIER"
+		}
+	case "SEQUENCE":
+		if p.peek() == "OF" {
+			p.next()
+			s.name = "SEQUENCE OF " + p.next()
+		} else if p.peek() == "{" {
+			p.next()
+			p.skipBlock("{", "}")
+		}
+		return s
+	case "CHOICE":
+		if p.peek() == "{" {
+			p.next()
+			p.skipBlock("{", "}")
+		}
+		return s
+	}
+
+	if p.peek() == "{" {
+		p.next()
+		s.enums = p.parseEnums()
+	}
+	if p.peek() == "(" {
+		p.next()
+		p.skipBlock("(", ")")
+	}
+	return s
+}
+
+// parseEnums parses named numbers, e.g. { up(1), down(2) }.
+func (p *parser) parseEnums() map[int]string {
+	enums := make(map[int]string)
+	for !p.eof() {
+		t := p.next()
+		if t == "}" {
+			break
+		}
+		if p.peek() != "(" {
+			continue
+		}
+		p.next()
+		n, err := strconv.Atoi(p.next())
+		if err == nil {
+			enums[n] = t
+		}
+		if p.peek() == ")" {
+			p.next()
+		}
+	}
+	return enums
+}
+
+// parseValue parses a value assignment such as an OBJECT-TYPE, returning nil
+// if it is not an OBJECT IDENTIFIER value.
+func (p *parser) parseValue(name string) *valueDef {
+	v := &valueDef{name: name, trapNumber: -1}
+	if t := p.peek(); isTypeName(t) {
+		v.macro = t
+	}
+
+	for !p.eof() {
+		t := p.next()
+		switch t {
+		case "::=":
+			return p.parseValueOID(v)
+		case "END":
+			// not a value after all
+			p.pos--
+			return nil
+		case "SYNTAX":
+			v.syntax = p.parseSyntax()
+		case "MAX-ACCESS", "ACCESS":
+			v.access = p.next()
+		case "INDEX":
+			if p.next() != "{" {
+				continue
+			}
+			for !p.eof() {
+				t := p.next()
+				if t == "}" {
+					break
+				}
+				if t != "," && t != "IMPLIED" {
+					v.index = append(v.index, t)
+				}
+			}
+		case "AUGMENTS":
+			if p.next() != "{" {
+				continue
+			}
+			v.augments = p.next()
+			p.skipBlock("{", "}")
+		case "ENTERPRISE":
+			v.enterprise = p.next()
+		case "{":
+			p.skipBlock("{", "}")
+		case "(":
+			p.skipBlock("(", ")")
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseValueOID(v *valueDef) *valueDef {
+	t := p.next()
+	if n, err := strconv.Atoi(t); err == nil && v.macro == "TRAP-TYPE" {
+		v.trapNumber = n
+		return v
+	}
+	if t != "{" {
+		return nil
+	}
+
+	for !p.eof() {
+		t := p.next()
+		if t == "}" {
+			break
+		}
+
+		c := oidComponent{number: -1}
+		if n, err := strconv.Atoi(t); err == nil {
+			c.number = n
+		} else {
+			c.name = t
+			if p.peek() == "(" {
+				p.next()
+				if n, err := strconv.Atoi(p.next()); err == nil {
+					c.number = n
+				}
+				if p.peek() == ")" {
+					p.next()
+				}
+			}
+		}
+		v.oid = append(v.oid, c)
+	}
+
+	if len(v.oid) == 0 {
+		return nil
+	}
+	return v
+}
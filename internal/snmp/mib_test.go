@@ -0,0 +1,143 @@
+package snmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestTree(t *testing.T) *MibTree {
+	tree := NewMibTree()
+	err := tree.LoadDir("testdata")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "README")
+	return tree
+}
+
+func TestLookupName(t *testing.T) {
+	tree := loadTestTree(t)
+
+	tests := []struct {
+		oid    string
+		name   string
+		module string
+		suffix string
+		numOID string
+	}{
+		{"TEST-MIB::testDescr", "testDescr", "TEST-MIB", "", ".1.3.6.1.2.1.999.1.2.1.2"},
+		{"TEST-MIB::testDescr.5", "testDescr", "TEST-MIB", ".5", ".1.3.6.1.2.1.999.1.2.1.2"},
+		{"testDescr.5", "testDescr", "TEST-MIB", ".5", ".1.3.6.1.2.1.999.1.2.1.2"},
+		{".iso.2.3", "iso", "", ".2.3", ".1"},
+		{"enterprises", "enterprises", "SNMPv2-SMI", "", ".1.3.6.1.4.1"},
+		{"TEST-V1-MIB::testV1Trap", "testV1Trap", "TEST-V1-MIB", "", ".1.3.6.1.4.1.99999.0.3"},
+		{"testLinkDown", "testLinkDown", "TEST-MIB", "", ".1.3.6.1.2.1.999.0.1"},
+	}
+
+	for _, tt := range tests {
+		n, suffix, err := tree.Lookup(tt.oid)
+		require.NoError(t, err, tt.oid)
+		require.Equal(t, tt.name, n.Name, tt.oid)
+		require.Equal(t, tt.module, n.Module, tt.oid)
+		require.Equal(t, tt.suffix, suffix, tt.oid)
+		require.Equal(t, tt.numOID, n.OID, tt.oid)
+	}
+
+	for _, oid := range []string{"OTHER-MIB::testDescr", "unknownObject", "testDescr.x"} {
+		_, _, err := tree.Lookup(oid)
+		require.Error(t, err, oid)
+	}
+}
+
+func TestLookupNumeric(t *testing.T) {
+	tree := loadTestTree(t)
+
+	n, suffix, err := tree.Lookup(".1.3.6.1.2.1.999.1.2.1.3.7")
+	require.NoError(t, err)
+	require.Equal(t, "testPhysAddress", n.Name)
+	require.Equal(t, ".7", suffix)
+
+	n, suffix, err = tree.Lookup("1.3.6.1.2.1.999.1.1")
+	require.NoError(t, err)
+	require.Equal(t, "testNumber", n.Name)
+	require.Equal(t, "", suffix)
+
+	// unnamed nodes are reported under their closest named parent
+	n, suffix, err = tree.Lookup(".1.3.6.1.2.1.999.1.9.1")
+	require.NoError(t, err)
+	require.Equal(t, "testObjects", n.Name)
+	require.Equal(t, ".9.1", suffix)
+
+	n, suffix, err = tree.Lookup(".1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, "iso", n.Name)
+	require.Equal(t, "", n.Module)
+	require.Equal(t, ".2.3", suffix)
+
+	_, _, err = tree.Lookup(".3.1")
+	require.Error(t, err)
+}
+
+func TestSyntax(t *testing.T) {
+	tree := loadTestTree(t)
+
+	tests := []struct {
+		oid   string
+		tc    string
+		hint  string
+		enums map[int]string
+	}{
+		{"testNumber", "", "", nil},
+		{"testDescr", "DisplayString", "255a", nil},
+		{"testPhysAddress", "PhysAddress", "1x:", nil},
+		{"testAdminStatus", "", "", map[int]string{1: "up", 2: "down", 3: "testing"}},
+		{"testPromiscuous", "TruthValue", "", map[int]string{1: "true", 2: "false"}},
+		{"testAddress", "InetAddress", "", nil},
+	}
+
+	for _, tt := range tests {
+		n, _, err := tree.Lookup(tt.oid)
+		require.NoError(t, err, tt.oid)
+		require.Equal(t, tt.tc, n.TextualConvention, tt.oid)
+		require.Equal(t, tt.hint, n.DisplayHint, tt.oid)
+		require.Equal(t, tt.enums, n.Enums, tt.oid)
+	}
+}
+
+func TestTable(t *testing.T) {
+	tree := loadTestTree(t)
+
+	table, _, err := tree.Lookup("TEST-MIB::testTable")
+	require.NoError(t, err)
+	entry, columns, err := tree.Table(table)
+	require.NoError(t, err)
+	require.Equal(t, "testEntry", entry.Name)
+	require.Equal(t, []string{"testIndex"}, entry.Index)
+
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.Name)
+	}
+	require.Equal(t, []string{"testDescr", "testPhysAddress", "testAdminStatus", "testPromiscuous", "testAddress"}, names)
+
+	table, _, err = tree.Lookup("TEST-MIB::testXTable")
+	require.NoError(t, err)
+	entry, _, err = tree.Table(table)
+	require.NoError(t, err)
+	require.Equal(t, []string{"testIndex"}, entry.Index)
+
+	column, _, err := tree.Lookup("TEST-MIB::testDescr")
+	require.NoError(t, err)
+	_, _, err = tree.Table(column)
+	require.Error(t, err)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"TEST DEFINITIONS ::= BEGIN",
+		"TEST DEFINITIONS ::= BEGIN x ::= \"unterminated END",
+		"no definitions here",
+	} {
+		_, err := parseModules(src)
+		require.Error(t, err, src)
+	}
+}
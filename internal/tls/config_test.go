@@ -1,6 +1,8 @@
 package tls_test
 
 import (
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -224,3 +226,74 @@ func TestConnect(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 200, resp.StatusCode)
 }
+
+func TestConnectAllowedCNs(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		expErr  bool
+	}{
+		{
+			name:    "allowed",
+			allowed: []string{"other.localdomain", "client.localdomain"},
+		},
+		{
+			name:    "not allowed",
+			allowed: []string{"other.localdomain"},
+			expErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig := tls.ServerConfig{
+				TLSCert:           pki.ServerCertPath(),
+				TLSKey:            pki.ServerKeyPath(),
+				TLSAllowedCACerts: []string{pki.CACertPath()},
+				TLSAllowedCNs:     tt.allowed,
+			}
+			serverTLSConfig, err := serverConfig.TLSConfig()
+			require.NoError(t, err)
+
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			ts.TLS = serverTLSConfig
+			ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+			ts.StartTLS()
+			defer ts.Close()
+
+			clientConfig := tls.ClientConfig{
+				TLSCA:   pki.CACertPath(),
+				TLSCert: pki.ClientCertPath(),
+				TLSKey:  pki.ClientKeyPath(),
+			}
+			clientTLSConfig, err := clientConfig.TLSConfig()
+			require.NoError(t, err)
+
+			client := http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: clientTLSConfig,
+				},
+				Timeout: 10 * time.Second,
+			}
+
+			resp, err := client.Get(ts.URL)
+			if tt.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 200, resp.StatusCode)
+		})
+	}
+}
+
+func TestServerConfigAllowedCNsRequiresCA(t *testing.T) {
+	serverConfig := tls.ServerConfig{
+		TLSCert:       pki.ServerCertPath(),
+		TLSKey:        pki.ServerKeyPath(),
+		TLSAllowedCNs: []string{"client.localdomain"},
+	}
+	_, err := serverConfig.TLSConfig()
+	require.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package shim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// accumulator writes the metrics added by an input to stdout.
+type accumulator struct {
+	shim      *Shim
+	precision time.Duration
+}
+
+func (a *accumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addMetric(measurement, fields, tags, telegraf.Untyped, t...)
+}
+
+func (a *accumulator) AddGauge(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addMetric(measurement, fields, tags, telegraf.Gauge, t...)
+}
+
+func (a *accumulator) AddCounter(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addMetric(measurement, fields, tags, telegraf.Counter, t...)
+}
+
+func (a *accumulator) AddSummary(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addMetric(measurement, fields, tags, telegraf.Summary, t...)
+}
+
+func (a *accumulator) AddHistogram(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	a.addMetric(measurement, fields, tags, telegraf.Histogram, t...)
+}
+
+func (a *accumulator) SetPrecision(precision, interval time.Duration) {
+	if precision > 0 {
+		a.precision = precision
+	}
+}
+
+func (a *accumulator) AddError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(a.shim.stderr, "%v\n", err)
+}
+
+func (a *accumulator) addMetric(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	tp telegraf.ValueType,
+	t ...time.Time,
+) {
+	tm := time.Now()
+	if len(t) > 0 {
+		tm = t[0]
+	}
+	if a.precision > 0 {
+		tm = tm.Round(a.precision)
+	}
+
+	m, err := metric.New(measurement, tags, fields, tm, tp)
+	if err != nil {
+		a.AddError(err)
+		return
+	}
+	a.shim.writeMetric(m)
+}
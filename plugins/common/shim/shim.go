@@ -0,0 +1,299 @@
+// Package shim runs a Telegraf input, processor or output plugin as a
+// standalone program, exchanging metrics over stdin and stdout in influx
+// line protocol.  The resulting program can be used with the execd input,
+// processor and output plugins.
+//
+// A minimal main package looks like:
+//
+//	func main() {
+//		s := shim.New()
+//		if err := s.AddInput(&myinput.MyInput{}); err != nil {
+//			log.Fatal(err)
+//		}
+//		if err := s.Run(10 * time.Second); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+package shim
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	influxSerializer "github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+)
+
+// PollIntervalDisabled disables gathering on a timer; the input is then only
+// gathered when requested through stdin or a signal.
+const PollIntervalDisabled = time.Duration(0)
+
+// maxLineBytes is the largest line accepted on stdin.
+const maxLineBytes = 1024 * 1024
+
+// Shim allows you to wrap your plugin and run it as an external program.
+type Shim struct {
+	Input     telegraf.Input
+	Processor telegraf.Processor
+	Output    telegraf.Output
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	writeMu    sync.Mutex
+	serializer serializers.Serializer
+}
+
+// New creates a new shim reading from stdin and writing to stdout.
+func New() *Shim {
+	return &Shim{
+		stdin:      os.Stdin,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		serializer: influxSerializer.NewSerializer(),
+	}
+}
+
+// AddInput adds the input plugin to the shim.
+func (s *Shim) AddInput(input telegraf.Input) error {
+	if s.hasPlugin() {
+		return errors.New("only one plugin can be run by the shim")
+	}
+	s.Input = input
+	return nil
+}
+
+// AddProcessor adds the processor plugin to the shim.
+func (s *Shim) AddProcessor(processor telegraf.Processor) error {
+	if s.hasPlugin() {
+		return errors.New("only one plugin can be run by the shim")
+	}
+	s.Processor = processor
+	return nil
+}
+
+// AddOutput adds the output plugin to the shim.
+func (s *Shim) AddOutput(output telegraf.Output) error {
+	if s.hasPlugin() {
+		return errors.New("only one plugin can be run by the shim")
+	}
+	s.Output = output
+	return nil
+}
+
+func (s *Shim) hasPlugin() bool {
+	return s.Input != nil || s.Processor != nil || s.Output != nil
+}
+
+// LoadConfig creates the plugin from a Telegraf style configuration file,
+// such as:
+//
+//	[[inputs.my_input]]
+//	  option = "value"
+//
+// The plugin must have been registered by importing its package.
+func (s *Shim) LoadConfig(filePath string) error {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	tbl, err := toml.Parse(b)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", filePath, err)
+	}
+
+	for kind, val := range tbl.Fields {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing field %q as table", kind)
+		}
+
+		for name, val := range subTable.Fields {
+			var pluginTable *ast.Table
+			switch t := val.(type) {
+			case *ast.Table:
+				pluginTable = t
+			case []*ast.Table:
+				if len(t) != 1 {
+					return fmt.Errorf("only one %s.%s can be configured", kind, name)
+				}
+				pluginTable = t[0]
+			default:
+				return fmt.Errorf("unsupported config format: %s.%s", kind, name)
+			}
+
+			plugin, err := s.createPlugin(kind, name)
+			if err != nil {
+				return err
+			}
+			if err = toml.UnmarshalTable(pluginTable, plugin); err != nil {
+				return fmt.Errorf("error loading %s.%s: %v", kind, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Shim) createPlugin(kind, name string) (interface{}, error) {
+	switch kind {
+	case "inputs":
+		creator, ok := inputs.Inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("input %q is not registered", name)
+		}
+		input := creator()
+		return input, s.AddInput(input)
+	case "processors":
+		creator, ok := processors.Processors[name]
+		if !ok {
+			return nil, fmt.Errorf("processor %q is not registered", name)
+		}
+		processor := creator()
+		return processor, s.AddProcessor(processor)
+	case "outputs":
+		creator, ok := outputs.Outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("output %q is not registered", name)
+		}
+		output := creator()
+		return output, s.AddOutput(output)
+	}
+	return nil, fmt.Errorf("unsupported plugin type %q", kind)
+}
+
+// Run runs the plugin until stdin is closed or the program is interrupted.
+// Inputs are gathered every pollInterval, whenever a line is read from
+// stdin and, where supported, on SIGHUP, SIGUSR1 and SIGUSR2.
+func (s *Shim) Run(pollInterval time.Duration) error {
+	switch {
+	case s.Input != nil:
+		return s.runInput(pollInterval)
+	case s.Processor != nil:
+		return s.runProcessor()
+	case s.Output != nil:
+		return s.runOutput()
+	}
+	return errors.New("nothing to run")
+}
+
+func (s *Shim) runInput(pollInterval time.Duration) error {
+	acc := &accumulator{shim: s}
+
+	if si, ok := s.Input.(telegraf.ServiceInput); ok {
+		if err := si.Start(acc); err != nil {
+			return fmt.Errorf("failed to start input: %v", err)
+		}
+		defer si.Stop()
+	}
+
+	gatherC := make(chan struct{}, 1)
+	request := func() {
+		select {
+		case gatherC <- struct{}{}:
+		default:
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(s.stdin)
+		for scanner.Scan() {
+			request()
+		}
+	}()
+
+	stopSignals := notifySignals(request)
+	defer stopSignals()
+
+	var tick <-chan time.Time
+	if pollInterval != PollIntervalDisabled {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+		request()
+	}
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-tick:
+			request()
+		case <-gatherC:
+			if err := s.Input.Gather(acc); err != nil {
+				fmt.Fprintf(s.stderr, "failed to gather metrics: %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *Shim) runProcessor() error {
+	return s.readMetrics(func(m telegraf.Metric) {
+		for _, out := range s.Processor.Apply(m) {
+			s.writeMetric(out)
+		}
+	})
+}
+
+func (s *Shim) runOutput() error {
+	if err := s.Output.Connect(); err != nil {
+		return fmt.Errorf("failed to connect output: %v", err)
+	}
+	defer s.Output.Close()
+
+	return s.readMetrics(func(m telegraf.Metric) {
+		if err := s.Output.Write([]telegraf.Metric{m}); err != nil {
+			fmt.Fprintf(s.stderr, "failed to write metric: %v\n", err)
+		}
+	})
+}
+
+// readMetrics parses the metrics read from stdin until it is closed.
+func (s *Shim) readMetrics(fn func(telegraf.Metric)) error {
+	parser := influx.NewParser(influx.NewMetricHandler())
+
+	scanner := bufio.NewScanner(s.stdin)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		metrics, err := parser.Parse(scanner.Bytes())
+		if err != nil {
+			fmt.Fprintf(s.stderr, "failed to parse metric: %v\n", err)
+			continue
+		}
+		for _, m := range metrics {
+			fn(m)
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Shim) writeMetric(m telegraf.Metric) {
+	b, err := s.serializer.Serialize(m)
+	if err != nil {
+		fmt.Fprintf(s.stderr, "failed to serialize metric: %v\n", err)
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.stdout.Write(b); err != nil {
+		fmt.Fprintf(s.stderr, "failed to write metric: %v\n", err)
+	}
+}
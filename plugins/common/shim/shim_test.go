@@ -0,0 +1,140 @@
+package shim
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	serializer "github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+type testInput struct {
+	Value int64 `toml:"value"`
+}
+
+func (i *testInput) SampleConfig() string { return "" }
+func (i *testInput) Description() string  { return "" }
+func (i *testInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields("measurement", map[string]interface{}{"value": i.Value},
+		map[string]string{"tag": "a"}, time.Unix(0, 0))
+	return nil
+}
+
+type testProcessor struct{}
+
+func (p *testProcessor) SampleConfig() string { return "" }
+func (p *testProcessor) Description() string  { return "" }
+func (p *testProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		m.AddTag("processed", "true")
+	}
+	return in
+}
+
+type testOutput struct {
+	sync.Mutex
+	metrics []telegraf.Metric
+}
+
+func (o *testOutput) Connect() error       { return nil }
+func (o *testOutput) Close() error         { return nil }
+func (o *testOutput) SampleConfig() string { return "" }
+func (o *testOutput) Description() string  { return "" }
+func (o *testOutput) Write(metrics []telegraf.Metric) error {
+	o.Lock()
+	defer o.Unlock()
+	o.metrics = append(o.metrics, metrics...)
+	return nil
+}
+
+func newTestShim(stdin io.Reader, stdout io.Writer) *Shim {
+	return &Shim{
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     ioutil.Discard,
+		serializer: serializer.NewSerializer(),
+	}
+}
+
+func TestInputGatherOnStdin(t *testing.T) {
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	s := newTestShim(stdinReader, stdoutWriter)
+	require.NoError(t, s.AddInput(&testInput{Value: 42}))
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.Run(PollIntervalDisabled)
+	}()
+
+	r := bufio.NewReader(stdoutReader)
+	for i := 0; i < 2; i++ {
+		_, err := stdinWriter.Write([]byte("\n"))
+		require.NoError(t, err)
+
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "measurement,tag=a value=42i 0\n", line)
+	}
+
+	stdinWriter.Close()
+	require.NoError(t, <-errC)
+}
+
+func TestProcessor(t *testing.T) {
+	stdin := strings.NewReader("cpu,host=a usage=1 0\ncpu,host=b usage=2 0\n")
+	var stdout bytes.Buffer
+
+	s := newTestShim(stdin, &stdout)
+	require.NoError(t, s.AddProcessor(&testProcessor{}))
+	require.NoError(t, s.Run(PollIntervalDisabled))
+
+	require.Equal(t,
+		"cpu,host=a,processed=true usage=1 0\ncpu,host=b,processed=true usage=2 0\n",
+		stdout.String())
+}
+
+func TestOutput(t *testing.T) {
+	stdin := strings.NewReader("cpu,host=a usage=1 0\ninvalid\ncpu,host=b usage=2 0\n")
+
+	output := &testOutput{}
+	s := newTestShim(stdin, ioutil.Discard)
+	require.NoError(t, s.AddOutput(output))
+	require.NoError(t, s.Run(PollIntervalDisabled))
+
+	require.Len(t, output.metrics, 2)
+	require.Equal(t, "cpu", output.metrics[0].Name())
+	require.Equal(t, map[string]string{"host": "b"}, output.metrics[1].Tags())
+}
+
+func TestOnlyOnePlugin(t *testing.T) {
+	s := New()
+	require.NoError(t, s.AddInput(&testInput{}))
+	require.Error(t, s.AddOutput(&testOutput{}))
+}
+
+func TestLoadConfig(t *testing.T) {
+	inputs.Add("shim_test", func() telegraf.Input { return &testInput{} })
+	defer delete(inputs.Inputs, "shim_test")
+
+	f, err := ioutil.TempFile("", "shim")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("[[inputs.shim_test]]\n  value = 7\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s := New()
+	require.NoError(t, s.LoadConfig(f.Name()))
+	require.Equal(t, &testInput{Value: 7}, s.Input)
+}
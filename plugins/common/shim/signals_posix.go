@@ -0,0 +1,33 @@
+// +build !windows
+
+package shim
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySignals calls fn whenever SIGHUP, SIGUSR1 or SIGUSR2 is received
+// until the returned function is called.
+func notifySignals(fn func()) func() {
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigC:
+				fn()
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigC)
+		close(done)
+	}
+}
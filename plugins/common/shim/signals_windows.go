@@ -0,0 +1,9 @@
+// +build windows
+
+package shim
+
+// notifySignals is a no-op, gathering on signals is not supported on
+// windows.
+func notifySignals(fn func()) func() {
+	return func() {}
+}
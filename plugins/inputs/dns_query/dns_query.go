@@ -1,25 +1,32 @@
 package dns_query
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/miekg/dns"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
 type ResultType uint64
 
 const (
-	Success ResultType = 0
-	Timeout            = 1
-	Error              = 2
+	Success  ResultType = 0
+	Timeout             = 1
+	Error               = 2
+	Mismatch            = 3
 )
 
 type DnsQuery struct {
@@ -40,13 +47,25 @@ type DnsQuery struct {
 
 	// Dns query timeout in seconds. 0 means no timeout
 	Timeout int
+
+	// Whether to ask for the DNSSEC records and validation
+	DNSSEC bool `toml:"dnssec"`
+
+	// Values the answers are expected to have
+	Expected []string
+
+	tls.ClientConfig
+
+	httpClient *http.Client
 }
 
 var sampleConfig = `
   ## servers to query
   servers = ["8.8.8.8"]
 
-  ## Network is the network protocol name.
+  ## Network is the network protocol name: "udp", "tcp", "tcp-tls" for
+  ## DNS-over-TLS, or "https" for DNS-over-HTTPS. DNS-over-HTTPS servers may
+  ## be URLs, otherwise the query is posted to https://<server>:<port>/dns-query.
   # network = "udp"
 
   ## Domains or subdomains to query.
@@ -56,11 +75,27 @@ var sampleConfig = `
   ## Posible values: A, AAAA, CNAME, MX, NS, PTR, TXT, SOA, SPF, SRV.
   # record_type = "A"
 
-  ## Dns server port.
+  ## Dns server port, 53 by default, 853 for tcp-tls and 443 for https.
   # port = 53
 
   ## Query timeout in seconds.
   # timeout = 2
+
+  ## Ask for the DNSSEC records, and report whether the server validated the
+  ## answers.
+  # dnssec = false
+
+  ## Values expected in the answers, in the presentation format of their
+  ## records, such as "192.0.2.1" or "10 mail.example.org". The result is
+  ## "mismatch" when the answers are not exactly these values.
+  # expected = []
+
+  ## Optional TLS Config, for tcp-tls and https
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
 `
 
 func (d *DnsQuery) SampleConfig() string {
@@ -74,6 +109,17 @@ func (d *DnsQuery) Gather(acc telegraf.Accumulator) error {
 	var wg sync.WaitGroup
 	d.setDefaultValues()
 
+	if d.Network == "https" && d.httpClient == nil {
+		tlsCfg, err := d.ClientConfig.TLSConfig()
+		if err != nil {
+			return err
+		}
+		d.httpClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsCfg},
+			Timeout:   time.Duration(d.Timeout) * time.Second,
+		}
+	}
+
 	for _, domain := range d.Domains {
 		for _, server := range d.Servers {
 			wg.Add(1)
@@ -85,11 +131,11 @@ func (d *DnsQuery) Gather(acc telegraf.Accumulator) error {
 					"record_type": d.RecordType,
 				}
 
-				dnsQueryTime, err := d.getDnsQueryTime(domain, server)
+				r, dnsQueryTime, err := d.query(domain, server)
 				if err == nil {
-					setResult(Success, fields, tags)
+					d.setResponseFields(r, fields, tags)
 					fields["query_time_ms"] = dnsQueryTime
-				} else if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+				} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					setResult(Timeout, fields, tags)
 				} else if err != nil {
 					setResult(Error, fields, tags)
@@ -122,7 +168,14 @@ func (d *DnsQuery) setDefaultValues() {
 	}
 
 	if d.Port == 0 {
-		d.Port = 53
+		switch d.Network {
+		case "tcp-tls":
+			d.Port = 853
+		case "https":
+			d.Port = 443
+		default:
+			d.Port = 53
+		}
 	}
 
 	if d.Timeout == 0 {
@@ -130,30 +183,158 @@ func (d *DnsQuery) setDefaultValues() {
 	}
 }
 
-func (d *DnsQuery) getDnsQueryTime(domain string, server string) (float64, error) {
-	dnsQueryTime := float64(0)
-
-	c := new(dns.Client)
-	c.ReadTimeout = time.Duration(d.Timeout) * time.Second
-	c.Net = d.Network
-
+// query sends the query for a domain to a server, and returns the response
+// along with the query time in milliseconds.
+func (d *DnsQuery) query(domain string, server string) (*dns.Msg, float64, error) {
 	m := new(dns.Msg)
 	recordType, err := d.parseRecordType()
 	if err != nil {
-		return dnsQueryTime, err
+		return nil, 0, err
 	}
 	m.SetQuestion(dns.Fqdn(domain), recordType)
 	m.RecursionDesired = true
+	if d.DNSSEC {
+		m.SetEdns0(4096, true)
+		m.AuthenticatedData = true
+	}
+
+	var r *dns.Msg
+	var rtt time.Duration
+	if d.Network == "https" {
+		r, rtt, err = d.exchangeHTTPS(m, server)
+	} else {
+		c := new(dns.Client)
+		c.ReadTimeout = time.Duration(d.Timeout) * time.Second
+		c.Net = d.Network
+		if d.Network == "tcp-tls" {
+			c.TLSConfig, err = d.ClientConfig.TLSConfig()
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		r, rtt, err = c.Exchange(m, net.JoinHostPort(server, strconv.Itoa(d.Port)))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, float64(rtt.Nanoseconds()) / 1e6, nil
+}
+
+// exchangeHTTPS posts a query to a DNS-over-HTTPS server (RFC 8484).
+func (d *DnsQuery) exchangeHTTPS(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	u := server
+	if !strings.HasPrefix(server, "https://") {
+		u = "https://" + net.JoinHostPort(server, strconv.Itoa(d.Port)) + "/dns-query"
+	}
+
+	// the ID is 0 so that responses may be cached
+	m.Id = 0
+	query, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(query))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
 
-	r, rtt, err := c.Exchange(m, net.JoinHostPort(server, strconv.Itoa(d.Port)))
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
-		return dnsQueryTime, err
+		return nil, 0, err
 	}
-	if r.Rcode != dns.RcodeSuccess {
-		return dnsQueryTime, errors.New(fmt.Sprintf("Invalid answer name %s after %s query for %s\n", domain, d.RecordType, domain))
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s returned HTTP status %s", u, resp.Status)
+	}
+
+	r := new(dns.Msg)
+	if err := r.Unpack(body); err != nil {
+		return nil, 0, err
+	}
+	return r, rtt, nil
+}
+
+// setResponseFields sets the result and the fields describing a response.
+func (d *DnsQuery) setResponseFields(r *dns.Msg, fields map[string]interface{}, tags map[string]string) {
+	fields["rcode"] = dns.RcodeToString[r.Rcode]
+	fields["rcode_value"] = r.Rcode
+	fields["answers"] = len(r.Answer)
+	if d.DNSSEC {
+		// the server sets the AD bit when it validated the answers
+		if r.AuthenticatedData {
+			fields["dnssec"] = "secure"
+		} else {
+			fields["dnssec"] = "insecure"
+		}
+	}
+
+	switch {
+	case r.Rcode != dns.RcodeSuccess:
+		setResult(Error, fields, tags)
+	case len(d.Expected) > 0 && !d.answersMatch(r):
+		setResult(Mismatch, fields, tags)
+	default:
+		setResult(Success, fields, tags)
+	}
+}
+
+// answersMatch returns whether the values of the answers of the record type
+// are exactly the expected values.
+func (d *DnsQuery) answersMatch(r *dns.Msg) bool {
+	recordType, _ := d.parseRecordType()
+
+	var values []string
+	for _, rr := range r.Answer {
+		if recordType == dns.TypeANY || rr.Header().Rrtype == recordType {
+			values = append(values, normalizeValue(recordValue(rr)))
+		}
+	}
+	expected := make([]string, 0, len(d.Expected))
+	for _, value := range d.Expected {
+		expected = append(expected, normalizeValue(value))
 	}
-	dnsQueryTime = float64(rtt.Nanoseconds()) / 1e6
-	return dnsQueryTime, nil
+	if len(values) != len(expected) {
+		return false
+	}
+
+	sort.Strings(values)
+	sort.Strings(expected)
+	for i := range values {
+		if values[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordValue returns the value of a record in its presentation format,
+// without the name, class and TTL.
+func recordValue(rr dns.RR) string {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return rr.A.String()
+	case *dns.AAAA:
+		return rr.AAAA.String()
+	case *dns.TXT:
+		return strings.Join(rr.Txt, "")
+	case *dns.SPF:
+		return strings.Join(rr.Txt, "")
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// normalizeValue makes the values comparable regardless of the case and
+// trailing dot of the names.
+func normalizeValue(value string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(value), "."))
 }
 
 func (d *DnsQuery) parseRecordType() (uint16, error) {
@@ -199,6 +380,8 @@ func setResult(result ResultType, fields map[string]interface{}, tags map[string
 		tag = "timeout"
 	case Error:
 		tag = "error"
+	case Mismatch:
+		tag = "mismatch"
 	}
 
 	tags["result"] = tag
@@ -1,6 +1,11 @@
 package dns_query
 
 import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -11,6 +16,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var pki = testutil.NewPKI("../../../testutil/pki")
+
 var servers = []string{"8.8.8.8"}
 var domains = []string{"google.com"}
 
@@ -145,6 +152,14 @@ func TestSettingDefaultValues(t *testing.T) {
 	dnsConfig.setDefaultValues()
 
 	assert.Equal(t, "NS", dnsConfig.RecordType, "Default record type not equal 'NS'")
+
+	dnsConfig = DnsQuery{Network: "tcp-tls"}
+	dnsConfig.setDefaultValues()
+	assert.Equal(t, 853, dnsConfig.Port, "Default DNS-over-TLS port number not equal 853")
+
+	dnsConfig = DnsQuery{Network: "https"}
+	dnsConfig.setDefaultValues()
+	assert.Equal(t, 443, dnsConfig.Port, "Default DNS-over-HTTPS port number not equal 443")
 }
 
 func TestRecordTypeParser(t *testing.T) {
@@ -204,3 +219,261 @@ func TestRecordTypeParserError(t *testing.T) {
 	_, err = dnsConfig.parseRecordType()
 	assert.Error(t, err)
 }
+
+// testHandler answers the queries for example.org, and fails the others.
+func testHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	q := req.Question[0]
+	if q.Name != "example.org." {
+		m.Rcode = dns.RcodeNameError
+		w.WriteMsg(m)
+		return
+	}
+
+	var records []string
+	switch q.Qtype {
+	case dns.TypeA:
+		records = []string{"example.org. 300 IN A 192.0.2.2", "example.org. 300 IN A 192.0.2.1"}
+	case dns.TypeMX:
+		records = []string{"example.org. 300 IN MX 10 Mail.Example.org."}
+	case dns.TypeTXT:
+		records = []string{`example.org. 300 IN TXT "v=spf1 " "-all"`}
+	}
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			panic(err)
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	// pretend to have validated the answers of DNSSEC queries
+	if opt := req.IsEdns0(); opt != nil && opt.Do() {
+		m.AuthenticatedData = true
+	}
+	w.WriteMsg(m)
+}
+
+// startServer starts a DNS server on a loopback UDP port, and returns its
+// port.
+func startServer(t *testing.T) (*dns.Server, int) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           dns.HandlerFunc(testHandler),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go server.ActivateAndServe()
+	<-started
+	return server, pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestGatherLocalServer(t *testing.T) {
+	server, port := startServer(t)
+	defer server.Shutdown()
+
+	tests := []struct {
+		name       string
+		domain     string
+		recordType string
+		expected   []string
+		result     string
+		fields     map[string]interface{}
+	}{
+		{
+			name:       "A",
+			domain:     "example.org",
+			recordType: "A",
+			result:     "success",
+			fields:     map[string]interface{}{"rcode": "NOERROR", "rcode_value": 0, "answers": 2},
+		},
+		{
+			name:       "expected A",
+			domain:     "example.org",
+			recordType: "A",
+			expected:   []string{"192.0.2.1", "192.0.2.2"},
+			result:     "success",
+		},
+		{
+			name:       "drifted A",
+			domain:     "example.org",
+			recordType: "A",
+			expected:   []string{"192.0.2.1", "192.0.2.3"},
+			result:     "mismatch",
+			fields:     map[string]interface{}{"result_code": uint64(3)},
+		},
+		{
+			name:       "missing A",
+			domain:     "example.org",
+			recordType: "A",
+			expected:   []string{"192.0.2.1"},
+			result:     "mismatch",
+		},
+		{
+			name:       "expected MX",
+			domain:     "example.org",
+			recordType: "MX",
+			expected:   []string{"10 mail.example.org"},
+			result:     "success",
+		},
+		{
+			name:       "expected TXT",
+			domain:     "example.org",
+			recordType: "TXT",
+			expected:   []string{"v=spf1 -all"},
+			result:     "success",
+		},
+		{
+			name:       "NXDOMAIN",
+			domain:     "example.com",
+			recordType: "A",
+			result:     "error",
+			fields:     map[string]interface{}{"rcode": "NXDOMAIN", "rcode_value": 3, "answers": 0, "result_code": uint64(2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dnsConfig := DnsQuery{
+				Servers:    []string{"127.0.0.1"},
+				Domains:    []string{test.domain},
+				RecordType: test.recordType,
+				Port:       port,
+				Expected:   test.expected,
+			}
+			var acc testutil.Accumulator
+			require.NoError(t, acc.GatherError(dnsConfig.Gather))
+
+			metric, ok := acc.Get("dns_query")
+			require.True(t, ok)
+			assert.Equal(t, test.result, metric.Tags["result"])
+			assert.Contains(t, metric.Fields, "query_time_ms")
+			assert.NotContains(t, metric.Fields, "dnssec")
+			for k, v := range test.fields {
+				assert.Equal(t, v, metric.Fields[k], k)
+			}
+		})
+	}
+}
+
+func TestGatherDNSSEC(t *testing.T) {
+	server, port := startServer(t)
+	defer server.Shutdown()
+
+	dnsConfig := DnsQuery{
+		Servers:    []string{"127.0.0.1"},
+		Domains:    []string{"example.org"},
+		RecordType: "A",
+		Port:       port,
+		DNSSEC:     true,
+	}
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(dnsConfig.Gather))
+
+	metric, ok := acc.Get("dns_query")
+	require.True(t, ok)
+	assert.Equal(t, "secure", metric.Fields["dnssec"])
+}
+
+func TestGatherDNSOverTLS(t *testing.T) {
+	cert, err := tls.X509KeyPair([]byte(pki.ReadServerCert()), []byte(pki.ReadServerKey()))
+	require.NoError(t, err)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		Net:               "tcp-tls",
+		Listener:          ln,
+		Handler:           dns.HandlerFunc(testHandler),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go server.ActivateAndServe()
+	<-started
+	defer server.Shutdown()
+
+	dnsConfig := DnsQuery{
+		Servers:    []string{"127.0.0.1"},
+		Domains:    []string{"example.org"},
+		RecordType: "A",
+		Network:    "tcp-tls",
+		Port:       ln.Addr().(*net.TCPAddr).Port,
+		Expected:   []string{"192.0.2.1", "192.0.2.2"},
+	}
+	dnsConfig.TLSCA = pki.CACertPath()
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(dnsConfig.Gather))
+
+	metric, ok := acc.Get("dns_query")
+	require.True(t, ok)
+	assert.Equal(t, "success", metric.Tags["result"])
+	assert.Equal(t, 2, metric.Fields["answers"])
+}
+
+func TestGatherDNSOverHTTPS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/resolve" || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw := &responseRecorder{}
+		testHandler(rw, req)
+		out, err := rw.msg.Pack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(out)
+	}))
+	defer ts.Close()
+
+	dnsConfig := DnsQuery{
+		Servers:    []string{ts.URL + "/resolve"},
+		Domains:    []string{"example.org", "example.com"},
+		RecordType: "A",
+		Network:    "https",
+	}
+	dnsConfig.InsecureSkipVerify = true
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(dnsConfig.Gather))
+
+	results := make(map[string]string)
+	for _, m := range acc.Metrics {
+		results[m.Tags["domain"]] = m.Tags["result"]
+	}
+	assert.Equal(t, map[string]string{"example.org": "success", "example.com": "error"}, results)
+
+	// the server's certificate is not trusted
+	dnsConfig = DnsQuery{
+		Servers: []string{ts.URL + "/resolve"},
+		Network: "https",
+	}
+	acc = testutil.Accumulator{}
+	require.Error(t, acc.GatherError(dnsConfig.Gather))
+}
+
+// responseRecorder is a dns.ResponseWriter keeping the message written.
+type responseRecorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *responseRecorder) WriteMsg(m *dns.Msg) error {
+	r.msg = m
+	return nil
+}
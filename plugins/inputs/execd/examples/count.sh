@@ -0,0 +1,8 @@
+#!/bin/bash
+
+counter=0
+
+while IFS= read -r LINE; do
+    echo "counter_bash count=${counter}"
+    let counter=counter+1
+done
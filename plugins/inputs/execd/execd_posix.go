@@ -0,0 +1,33 @@
+// +build !windows
+
+package execd
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func checkSignal(signal string) error {
+	switch signal {
+	case "", "none", "STDIN", "SIGHUP", "SIGUSR1", "SIGUSR2":
+		return nil
+	}
+	return fmt.Errorf("unknown signal %q", signal)
+}
+
+func (e *Execd) signal() error {
+	var sig syscall.Signal
+	switch e.Signal {
+	case "SIGHUP":
+		sig = syscall.SIGHUP
+	case "SIGUSR1":
+		sig = syscall.SIGUSR1
+	case "SIGUSR2":
+		sig = syscall.SIGUSR2
+	}
+
+	if err := e.process.Signal(sig); err != nil {
+		return fmt.Errorf("error sending %s: %v", e.Signal, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// +build !windows
+
+package execd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const counterScript = `
+i=0
+while read line; do
+  i=$((i+1))
+  echo "counter_bash count=${i}"
+done
+`
+
+func TestExecdSignalStdin(t *testing.T) {
+	e := &Execd{
+		Command:      []string{"sh", "-c", counterScript},
+		Signal:       "STDIN",
+		RestartDelay: internal.Duration{Duration: 5 * time.Second},
+	}
+	e.SetParser(influx.NewParser(influx.NewMetricHandler()))
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(1)
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(2)
+
+	require.Len(t, acc.Metrics, 2)
+	require.Equal(t, map[string]interface{}{"count": float64(2)}, acc.Metrics[1].Fields)
+}
+
+func TestExecdSignalSIGHUP(t *testing.T) {
+	script := `trap 'echo "hup count=1"' HUP; echo ready; while true; do sleep 0.05; done`
+
+	e := &Execd{
+		Command:      []string{"sh", "-c", script},
+		Signal:       "SIGHUP",
+		RestartDelay: internal.Duration{Duration: 5 * time.Second},
+	}
+	e.SetParser(influx.NewParser(influx.NewMetricHandler()))
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	// "ready" is not valid line protocol and is reported as an error
+	// once the trap has been installed.
+	acc.WaitError(1)
+
+	require.NoError(t, e.Gather(acc))
+	acc.Wait(1)
+	require.True(t, acc.HasMeasurement("hup"))
+}
+
+func TestExecdInvalidSignal(t *testing.T) {
+	e := &Execd{
+		Command: []string{"cat"},
+		Signal:  "SIGKILL",
+	}
+	require.Error(t, e.Start(&testutil.Accumulator{}))
+}
@@ -0,0 +1,19 @@
+// +build windows
+
+package execd
+
+import (
+	"fmt"
+)
+
+func checkSignal(signal string) error {
+	switch signal {
+	case "", "none", "STDIN":
+		return nil
+	}
+	return fmt.Errorf("signal %q is not supported on windows", signal)
+}
+
+func (e *Execd) signal() error {
+	return checkSignal(e.Signal)
+}
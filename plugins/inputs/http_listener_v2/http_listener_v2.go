@@ -3,7 +3,6 @@ package http_listener_v2
 import (
 	"compress/gzip"
 	"crypto/subtle"
-	"crypto/tls"
 	"io/ioutil"
 	"log"
 	"net"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -35,6 +35,7 @@ type HTTPListenerV2 struct {
 	Port           int
 
 	tlsint.ServerConfig
+	listener.Config
 
 	BasicUsername string
 	BasicPassword string
@@ -76,6 +77,39 @@ const sampleConfig = `
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
 
+  ## Common names of the client certificates allowed to connect.
+  # tls_allowed_cns = ["client.example.org"]
+
+  ## Maximum number of concurrent connections.
+  ## 0 (default) is unlimited.
+  # max_connections = 1024
+
+  ## Maximum duration an idle keep-alive connection is kept open.
+  ## 0 (default) uses the read timeout.
+  # idle_timeout = "2m"
+
+  ## Period between keep alive probes.
+  ## 0 disables keep alive probes.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## Maximum number of requests per second and client address, requests
+  ## over the limit are rejected with a 429 error.
+  ## 0 (default) is unlimited.
+  # rate_limit = 0.0
+
+  ## Read the PROXY protocol header sent by a load balancer in front of the
+  ## listener, to recover the address of the client.
+  # proxy_protocol = false
+
+  ## Addresses or CIDR networks of the load balancers trusted to send the
+  ## PROXY protocol header. Other clients are handled as direct connections,
+  ## without reading a header. If empty, the header is read from all clients.
+  # proxy_protocol_trusted = ["10.0.0.0/8"]
+
+  ## Name of the tag holding the address of the client, not added if empty.
+  # client_address_tag = ""
+
   ## Optional username and password to accept for HTTP basic authentication.
   ## You probably want to make sure you have TLS configured above for this.
   # basic_username = "foobar"
@@ -132,17 +166,12 @@ func (h *HTTPListenerV2) Start(acc telegraf.Accumulator) error {
 		TLSConfig:    tlsConf,
 	}
 
-	var listener net.Listener
-	if tlsConf != nil {
-		listener, err = tls.Listen("tcp", h.ServiceAddress, tlsConf)
-	} else {
-		listener, err = net.Listen("tcp", h.ServiceAddress)
-	}
+	l, err := h.ListenHTTP("http_listener_v2", h.ServiceAddress, tlsConf, server)
 	if err != nil {
 		return err
 	}
-	h.listener = listener
-	h.Port = listener.Addr().(*net.TCPAddr).Port
+	h.listener = l
+	h.Port = l.Addr().(*net.TCPAddr).Port
 
 	h.wg.Add(1)
 	go func() {
@@ -218,7 +247,9 @@ func (h *HTTPListenerV2) serveWrite(res http.ResponseWriter, req *http.Request)
 		return
 	}
 	for _, m := range metrics {
-		h.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+		tags := m.Tags()
+		h.AddClientTag(tags, req.RemoteAddr)
+		h.acc.AddFields(m.Name(), m.Fields(), tags, m.Time())
 	}
 	res.WriteHeader(http.StatusNoContent)
 }
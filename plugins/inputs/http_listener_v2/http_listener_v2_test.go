@@ -227,6 +227,26 @@ func TestWriteHTTPNoNewline(t *testing.T) {
 	)
 }
 
+func TestWriteHTTPClientAddressTag(t *testing.T) {
+	listener := newTestHTTPListenerV2()
+	listener.ClientAddressTag = "source"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(createURL(listener, "http", "/write", "db=mydb"), "", bytes.NewBuffer([]byte(testMsg)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, 204, resp.StatusCode)
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(12)},
+		map[string]string{"host": "server01", "source": "127.0.0.1"},
+	)
+}
+
 func TestWriteHTTPExactMaxBodySize(t *testing.T) {
 	parser, _ := parsers.NewInfluxParser()
 
@@ -1,6 +1,7 @@
 package http_response
 
 import (
+	cryptotls "crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -8,31 +9,39 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/tidwall/gjson"
 )
 
 // HTTPResponse struct
 type HTTPResponse struct {
-	Address             string
-	HTTPProxy           string `toml:"http_proxy"`
+	Address             string   // deprecated in 1.9; use URLs
+	URLs                []string `toml:"urls"`
+	HTTPProxy           string   `toml:"http_proxy"`
 	Body                string
 	Method              string
 	ResponseTimeout     internal.Duration
 	Headers             map[string]string
 	FollowRedirects     bool
 	ResponseStringMatch string
+	ResponseHeaderMatch map[string]string `toml:"response_header_match"`
+	ResponseJSONMatch   map[string]string `toml:"response_json_match"`
 	tls.ClientConfig
 
 	compiledStringMatch *regexp.Regexp
+	compiledHeaderMatch map[string]*regexp.Regexp
+	compiledJSONMatch   map[string]*regexp.Regexp
 	client              *http.Client
 }
 
@@ -42,8 +51,8 @@ func (h *HTTPResponse) Description() string {
 }
 
 var sampleConfig = `
-  ## Server address (default http://localhost)
-  # address = "http://localhost"
+  ## List of urls to query (default http://localhost)
+  # urls = ["http://localhost"]
 
   ## Set http_proxy (telegraf uses the system wide proxy settings if it's is not set)
   # http_proxy = "http://localhost:8888"
@@ -77,6 +86,16 @@ var sampleConfig = `
   ## HTTP Request Headers (all values must be strings)
   # [inputs.http_response.headers]
   #   Host = "github.com"
+
+  ## Optional regex matches of response headers, by header name
+  # [inputs.http_response.response_header_match]
+  #   Content-Type = "^application/json"
+
+  ## Optional regex matches of values in a JSON body, by GJSON path
+  ## (https://github.com/tidwall/gjson#path-syntax)
+  # [inputs.http_response.response_json_match]
+  #   "status" = "^up$"
+  #   "checks.#" = "^[1-9]"
 `
 
 // SampleConfig returns the plugin SampleConfig
@@ -135,6 +154,10 @@ func setResult(result_string string, fields map[string]interface{}, tags map[str
 		"connection_failed":        3,
 		"timeout":                  4,
 		"dns_error":                5,
+		"response_header_mismatch": 6,
+		"response_json_mismatch":   7,
+		"tls_error":                8,
+		"address_error":            9,
 	}
 
 	tags["result"] = result_string
@@ -170,17 +193,74 @@ func setError(err error, fields map[string]interface{}, tags map[string]string)
 	return nil
 }
 
+// timings records when the phases of a request happened, from the
+// httptrace hooks. When redirects are followed they are the phases of the
+// last request.
+type timings struct {
+	sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	tlsErr       error
+}
+
+func (t *timings) clientTrace() *httptrace.ClientTrace {
+	set := func(field *time.Time) {
+		t.Lock()
+		*field = time.Now()
+		t.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { set(&t.connectDone) },
+		TLSHandshakeStart: func() { set(&t.tlsStart) },
+		TLSHandshakeDone: func(_ cryptotls.ConnectionState, err error) {
+			set(&t.tlsDone)
+			t.Lock()
+			t.tlsErr = err
+			t.Unlock()
+		},
+		GotFirstResponseByte: func() { set(&t.firstByte) },
+	}
+}
+
+// addFields adds the duration of the phases which happened, in seconds.
+func (t *timings) addFields(start time.Time, fields map[string]interface{}) {
+	t.Lock()
+	defer t.Unlock()
+	phases := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"dns_time", t.dnsStart, t.dnsDone},
+		{"connect_time", t.connectStart, t.connectDone},
+		{"tls_handshake_time", t.tlsStart, t.tlsDone},
+		{"first_byte_time", start, t.firstByte},
+	}
+	for _, phase := range phases {
+		if !phase.start.IsZero() && !phase.end.IsZero() {
+			fields[phase.name] = phase.end.Sub(phase.start).Seconds()
+		}
+	}
+}
+
 // HTTPGather gathers all fields and returns any errors it encounters
-func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string, error) {
+func (h *HTTPResponse) httpGather(u string) (map[string]interface{}, map[string]string, error) {
 	// Prepare fields and tags
 	fields := make(map[string]interface{})
-	tags := map[string]string{"server": h.Address, "method": h.Method}
+	tags := map[string]string{"server": u, "method": h.Method}
 
 	var body io.Reader
 	if h.Body != "" {
 		body = strings.NewReader(h.Body)
 	}
-	request, err := http.NewRequest(h.Method, h.Address, body)
+	request, err := http.NewRequest(h.Method, u, body)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -192,16 +272,20 @@ func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string,
 		}
 	}
 
+	trace := &timings{}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace.clientTrace()))
+
 	// Start Timer
 	start := time.Now()
 	resp, err := h.client.Do(request)
 	response_time := time.Since(start).Seconds()
+	trace.addFields(start, fields)
 
 	// If an error in returned, it means we are dealing with a network error, as
 	// HTTP error codes do not generate errors in the net/http library
 	if err != nil {
 		// Log error
-		log.Printf("D! Network error while polling %s: %s", h.Address, err.Error())
+		log.Printf("D! Network error while polling %s: %s", u, err.Error())
 
 		// Get error details
 		netErr := setError(err, fields, tags)
@@ -211,6 +295,16 @@ func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string,
 			return fields, tags, nil
 		}
 
+		// A failed handshake is reported by the trace rather than as an
+		// error of a specific type
+		trace.Lock()
+		tlsErr := trace.tlsErr
+		trace.Unlock()
+		if tlsErr != nil {
+			setResult("tls_error", fields, tags)
+			return fields, tags, nil
+		}
+
 		// Any error not recognized by `set_error` is considered a "connection_failed"
 		setResult("connection_failed", fields, tags)
 
@@ -240,31 +334,126 @@ func (h *HTTPResponse) httpGather() (map[string]interface{}, map[string]string,
 	tags["status_code"] = strconv.Itoa(resp.StatusCode)
 	fields["http_response_code"] = resp.StatusCode
 
-	// Check the response for a regex match.
-	if h.ResponseStringMatch != "" {
+	// Report when the served chain expires, which is when the certificate
+	// expiring the soonest does
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		expiry := resp.TLS.PeerCertificates[0].NotAfter
+		for _, cert := range resp.TLS.PeerCertificates[1:] {
+			if cert.NotAfter.Before(expiry) {
+				expiry = cert.NotAfter
+			}
+		}
+		fields["cert_expiry"] = int64(expiry.Sub(time.Now()).Seconds())
+	}
 
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("D! Failed to read body of HTTP Response : %s", err)
-			setResult("body_read_error", fields, tags)
+	// Read the whole body, keeping it when it has to be matched
+	var bodyBytes []byte
+	matchBody := h.ResponseStringMatch != "" || len(h.ResponseJSONMatch) > 0
+	if matchBody {
+		bodyBytes, err = ioutil.ReadAll(resp.Body)
+	} else {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+	}
+	if err != nil && matchBody {
+		log.Printf("D! Failed to read body of HTTP Response : %s", err)
+		setResult("body_read_error", fields, tags)
+		if h.ResponseStringMatch != "" {
 			fields["response_string_match"] = 0
-			return fields, tags, nil
 		}
+		if len(h.ResponseJSONMatch) > 0 {
+			fields["response_json_match"] = 0
+		}
+		return fields, tags, nil
+	}
+	if err == nil {
+		trace.Lock()
+		if !trace.firstByte.IsZero() {
+			fields["transfer_time"] = time.Since(trace.firstByte).Seconds()
+		}
+		trace.Unlock()
+	}
+
+	// The result is the first of the checks failing
+	result := "success"
 
+	// Check the response for a regex match.
+	if h.ResponseStringMatch != "" {
 		if h.compiledStringMatch.Match(bodyBytes) {
-			setResult("success", fields, tags)
 			fields["response_string_match"] = 1
 		} else {
-			setResult("response_string_mismatch", fields, tags)
+			result = "response_string_mismatch"
 			fields["response_string_match"] = 0
 		}
-	} else {
-		setResult("success", fields, tags)
 	}
 
+	// Check the response headers, which have to be present and match
+	if len(h.compiledHeaderMatch) > 0 {
+		fields["response_header_match"] = 1
+		for name, re := range h.compiledHeaderMatch {
+			if !matchHeader(resp.Header, name, re) {
+				fields["response_header_match"] = 0
+				if result == "success" {
+					result = "response_header_mismatch"
+				}
+				break
+			}
+		}
+	}
+
+	// Check the values of the JSON body
+	if len(h.compiledJSONMatch) > 0 {
+		fields["response_json_match"] = 1
+		for path, re := range h.compiledJSONMatch {
+			value := gjson.GetBytes(bodyBytes, path)
+			if !value.Exists() || !re.MatchString(value.String()) {
+				fields["response_json_match"] = 0
+				if result == "success" {
+					result = "response_json_mismatch"
+				}
+				break
+			}
+		}
+	}
+
+	setResult(result, fields, tags)
 	return fields, tags, nil
 }
 
+// matchHeader returns whether any value of a header matches.
+func matchHeader(header http.Header, name string, re *regexp.Regexp) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileMatches compiles the regexes of a set of matches.
+func compileMatches(option string, matches map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(matches))
+	for key, expr := range matches {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to compile regular expression of %s %s : %s", option, key, err)
+		}
+		compiled[key] = re
+	}
+	return compiled, nil
+}
+
+// urls returns the urls to query, including the deprecated address.
+func (h *HTTPResponse) urls() []string {
+	urls := h.URLs
+	if h.Address != "" {
+		urls = append(urls[:len(urls):len(urls)], h.Address)
+	}
+	if len(urls) == 0 {
+		urls = []string{"http://localhost"}
+	}
+	return urls
+}
+
 // Gather gets all metric fields and tags and returns any errors it encounters
 func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
 	// Compile the body regex if it exist
@@ -275,6 +464,20 @@ func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
 			return fmt.Errorf("Failed to compile regular expression %s : %s", h.ResponseStringMatch, err)
 		}
 	}
+	if h.compiledHeaderMatch == nil {
+		var err error
+		h.compiledHeaderMatch, err = compileMatches("response_header_match", h.ResponseHeaderMatch)
+		if err != nil {
+			return err
+		}
+	}
+	if h.compiledJSONMatch == nil {
+		var err error
+		h.compiledJSONMatch, err = compileMatches("response_json_match", h.ResponseJSONMatch)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Set default values
 	if h.ResponseTimeout.Duration < time.Second {
@@ -284,22 +487,23 @@ func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
 	if h.Method == "" {
 		h.Method = "GET"
 	}
-	if h.Address == "" {
-		h.Address = "http://localhost"
-	}
-	addr, err := url.Parse(h.Address)
-	if err != nil {
-		return err
-	}
-	if addr.Scheme != "http" && addr.Scheme != "https" {
-		return errors.New("Only http and https are supported")
+	urls := h.urls()
+	for _, u := range urls {
+		addr, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		if addr.Scheme != "http" && addr.Scheme != "https" {
+			return errors.New("Only http and https are supported")
+		}
 	}
 
-	// Prepare data
-	var fields map[string]interface{}
-	var tags map[string]string
-
 	if h.client == nil {
+		if h.Address != "" {
+			log.Println("W! DEPRECATED: the http_response address option has been deprecated " +
+				"in favor of the urls option")
+		}
+
 		client, err := h.createHttpClient()
 		if err != nil {
 			return err
@@ -307,14 +511,23 @@ func (h *HTTPResponse) Gather(acc telegraf.Accumulator) error {
 		h.client = client
 	}
 
-	// Gather data
-	fields, tags, err = h.httpGather()
-	if err != nil {
-		return err
+	// Gather data from all urls concurrently
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			fields, tags, err := h.httpGather(u)
+			if err != nil {
+				acc.AddError(err)
+				return
+			}
+
+			// Add metrics
+			acc.AddFields("http_response", fields, tags)
+		}(u)
 	}
-
-	// Add metrics
-	acc.AddFields("http_response", fields, tags)
+	wg.Wait()
 	return nil
 }
 
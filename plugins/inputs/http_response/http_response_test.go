@@ -686,3 +686,146 @@ func TestNetworkErrors(t *testing.T) {
 	absentTags = []string{"status_code"}
 	checkOutput(t, &acc, expectedFields, expectedTags, absentFields, absentTags)
 }
+
+func TestMultipleURLs(t *testing.T) {
+	mux := setUpTestMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	h := &HTTPResponse{
+		URLs:            []string{ts.URL + "/good", ts.URL + "/mustbepostmethod"},
+		Address:         ts.URL + "/jsonresponse",
+		ResponseTimeout: internal.Duration{Duration: time.Second * 20},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, h.Gather(&acc))
+	require.Len(t, acc.Metrics, 3)
+
+	codes := make(map[string]string)
+	for _, m := range acc.Metrics {
+		codes[m.Tags["server"]] = m.Tags["status_code"]
+		assert.Equal(t, "success", m.Tags["result"])
+		assert.Contains(t, m.Fields, "connect_time")
+		assert.Contains(t, m.Fields, "first_byte_time")
+		assert.Contains(t, m.Fields, "transfer_time")
+		assert.NotContains(t, m.Fields, "tls_handshake_time")
+		assert.NotContains(t, m.Fields, "cert_expiry")
+	}
+	assert.Equal(t, map[string]string{
+		ts.URL + "/good":             "200",
+		ts.URL + "/mustbepostmethod": "405",
+		ts.URL + "/jsonresponse":     "200",
+	}, codes)
+}
+
+func TestResponseHeaderMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Backend", "a")
+		w.Header().Add("X-Backend", "b")
+		fmt.Fprint(w, `{"status": "up"}`)
+	}))
+	defer ts.Close()
+
+	h := &HTTPResponse{
+		Address:         ts.URL,
+		ResponseTimeout: internal.Duration{Duration: time.Second * 20},
+		ResponseHeaderMatch: map[string]string{
+			"content-type": "^application/json",
+			"X-Backend":    "^b$",
+		},
+	}
+	var acc testutil.Accumulator
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"response_header_match": 1,
+		"result_code":           0,
+	}, map[string]interface{}{"result": "success"}, nil, nil)
+
+	// missing headers do not match
+	h = &HTTPResponse{
+		Address:             ts.URL,
+		ResponseTimeout:     internal.Duration{Duration: time.Second * 20},
+		ResponseHeaderMatch: map[string]string{"X-Missing": ".*"},
+	}
+	acc = testutil.Accumulator{}
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"response_header_match": 0,
+		"result_code":           6,
+	}, map[string]interface{}{"result": "response_header_mismatch"}, nil, nil)
+
+	h.ResponseHeaderMatch = map[string]string{"bad": "[["}
+	h.compiledHeaderMatch = nil
+	require.Error(t, h.Gather(&testutil.Accumulator{}))
+}
+
+func TestResponseJSONMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "up", "checks": [{"name": "db", "ok": true}, {"name": "cache", "ok": false}]}`)
+	}))
+	defer ts.Close()
+
+	h := &HTTPResponse{
+		Address:         ts.URL,
+		ResponseTimeout: internal.Duration{Duration: time.Second * 20},
+		ResponseJSONMatch: map[string]string{
+			"status":   "^up$",
+			"checks.#": "^2$",
+		},
+	}
+	var acc testutil.Accumulator
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"response_json_match": 1,
+		"result_code":         0,
+	}, map[string]interface{}{"result": "success"}, []string{"response_string_match"}, nil)
+
+	h = &HTTPResponse{
+		Address:             ts.URL,
+		ResponseTimeout:     internal.Duration{Duration: time.Second * 20},
+		ResponseStringMatch: "status",
+		ResponseJSONMatch:   map[string]string{"checks.1.ok": "true"},
+	}
+	acc = testutil.Accumulator{}
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"response_string_match": 1,
+		"response_json_match":   0,
+		"result_code":           7,
+	}, map[string]interface{}{"result": "response_json_mismatch"}, nil, nil)
+}
+
+func TestTLS(t *testing.T) {
+	mux := setUpTestMux()
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+
+	h := &HTTPResponse{
+		Address:         ts.URL + "/good",
+		ResponseTimeout: internal.Duration{Duration: time.Second * 20},
+	}
+	h.InsecureSkipVerify = true
+	var acc testutil.Accumulator
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"result_code":        0,
+		"tls_handshake_time": nil,
+		"cert_expiry":        nil,
+	}, nil, nil, nil)
+	expiry, ok := acc.Int64Field("http_response", "cert_expiry")
+	require.True(t, ok)
+	assert.True(t, expiry > 0)
+
+	// the test server's certificate is not trusted
+	h = &HTTPResponse{
+		Address:         ts.URL + "/good",
+		ResponseTimeout: internal.Duration{Duration: time.Second * 20},
+	}
+	acc = testutil.Accumulator{}
+	require.NoError(t, h.Gather(&acc))
+	checkOutput(t, &acc, map[string]interface{}{
+		"result_code": 8,
+	}, map[string]interface{}{"result": "tls_error"}, []string{"http_response_code", "cert_expiry"}, nil)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/subtle"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -15,6 +14,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
@@ -44,6 +44,7 @@ type HTTPListener struct {
 	Port           int
 
 	tlsint.ServerConfig
+	listener.Config
 
 	BasicUsername string
 	BasicPassword string
@@ -99,6 +100,39 @@ const sampleConfig = `
   tls_cert = "/etc/telegraf/cert.pem"
   tls_key = "/etc/telegraf/key.pem"
 
+  ## Common names of the client certificates allowed to connect.
+  # tls_allowed_cns = ["client.example.org"]
+
+  ## Maximum number of concurrent connections.
+  ## 0 (default) is unlimited.
+  # max_connections = 1024
+
+  ## Maximum duration an idle keep-alive connection is kept open.
+  ## 0 (default) uses the read timeout.
+  # idle_timeout = "2m"
+
+  ## Period between keep alive probes.
+  ## 0 disables keep alive probes.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## Maximum number of requests per second and client address, requests
+  ## over the limit are rejected with a 429 error.
+  ## 0 (default) is unlimited.
+  # rate_limit = 0.0
+
+  ## Read the PROXY protocol header sent by a load balancer in front of the
+  ## listener, to recover the address of the client.
+  # proxy_protocol = false
+
+  ## Addresses or CIDR networks of the load balancers trusted to send the
+  ## PROXY protocol header. Other clients are handled as direct connections,
+  ## without reading a header. If empty, the header is read from all clients.
+  # proxy_protocol_trusted = ["10.0.0.0/8"]
+
+  ## Name of the tag holding the address of the client, not added if empty.
+  # client_address_tag = ""
+
   ## Optional username and password to accept for HTTP basic authentication.
   ## You probably want to make sure you have TLS configured above for this.
   # basic_username = "foobar"
@@ -169,17 +203,12 @@ func (h *HTTPListener) Start(acc telegraf.Accumulator) error {
 		TLSConfig:    tlsConf,
 	}
 
-	var listener net.Listener
-	if tlsConf != nil {
-		listener, err = tls.Listen("tcp", h.ServiceAddress, tlsConf)
-	} else {
-		listener, err = net.Listen("tcp", h.ServiceAddress)
-	}
+	l, err := h.ListenHTTP("influxdb_listener", h.ServiceAddress, tlsConf, server)
 	if err != nil {
 		return err
 	}
-	h.listener = listener
-	h.Port = listener.Addr().(*net.TCPAddr).Port
+	h.listener = l
+	h.Port = l.Addr().(*net.TCPAddr).Port
 
 	h.handler = influx.NewMetricHandler()
 	h.parser = influx.NewParser(h.handler)
@@ -305,7 +334,7 @@ func (h *HTTPListener) serveWrite(res http.ResponseWriter, req *http.Request) {
 
 		if err == io.ErrUnexpectedEOF {
 			// finished reading the request body
-			err = h.parse(buf[:n+bufStart], now, precision)
+			err = h.parse(buf[:n+bufStart], now, precision, req.RemoteAddr)
 			if err != nil {
 				log.Println("D! "+err.Error(), bufStart+n)
 				return400 = true
@@ -335,7 +364,7 @@ func (h *HTTPListener) serveWrite(res http.ResponseWriter, req *http.Request) {
 			bufStart = 0
 			continue
 		}
-		if err := h.parse(buf[:i+1], now, precision); err != nil {
+		if err := h.parse(buf[:i+1], now, precision, req.RemoteAddr); err != nil {
 			log.Println("D! " + err.Error())
 			return400 = true
 		}
@@ -348,7 +377,7 @@ func (h *HTTPListener) serveWrite(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (h *HTTPListener) parse(b []byte, t time.Time, precision string) error {
+func (h *HTTPListener) parse(b []byte, t time.Time, precision, addr string) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -360,7 +389,9 @@ func (h *HTTPListener) parse(b []byte, t time.Time, precision string) error {
 	}
 
 	for _, m := range metrics {
-		h.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+		tags := m.Tags()
+		h.AddClientTag(tags, addr)
+		h.acc.AddFields(m.Name(), m.Fields(), tags, m.Time())
 	}
 
 	return nil
@@ -27,6 +27,10 @@ type HostPinger func(binary string, timeout float64, args ...string) (string, er
 type Ping struct {
 	wg sync.WaitGroup
 
+	// Method to ping with, "exec" to run the ping executable, or "native" to
+	// send the ICMP packets directly
+	Method string
+
 	// Interval at which to ping (ping -i <INTERVAL>)
 	PingInterval float64 `toml:"ping_interval"`
 
@@ -42,6 +46,12 @@ type Ping struct {
 	// Interface or source address to send ping from (ping -I/-S <INTERFACE/SRC_ADDR>)
 	Interface string
 
+	// Size of the ICMP payload, for the native method
+	Size int
+
+	// Time to live of the ICMP packets, for the native method
+	TTL int `toml:"ttl"`
+
 	// URLs to ping
 	Urls []string
 
@@ -64,6 +74,13 @@ const sampleConfig = `
   ## List of urls to ping
   urls = ["example.org"]
 
+  ## Method used to ping:
+  ##   exec:   run the ping executable and parse its output (default)
+  ##   native: send the ICMP packets directly, using unprivileged ICMP
+  ##           sockets where the system allows it and raw sockets otherwise,
+  ##           which need the CAP_NET_RAW capability.
+  # method = "exec"
+
   ## Number of pings to send per collection (ping -c <COUNT>)
   # count = 1
 
@@ -81,6 +98,11 @@ const sampleConfig = `
   ## on Darwin and Freebsd only source address possible: (ping -S <SRC_ADDR>)
   # interface = ""
 
+  ## Size of the ICMP payload in bytes, and time to live of the packets,
+  ## for the native method.
+  # size = 56
+  # ttl = 0
+
   ## Specify the ping executable binary, default is "ping"
   # binary = "ping"
 
@@ -94,6 +116,15 @@ func (_ *Ping) SampleConfig() string {
 }
 
 func (p *Ping) Gather(acc telegraf.Accumulator) error {
+	switch p.Method {
+	case "", "exec":
+	case "native":
+		gatherNative(p.nativePinger(), p.Urls, acc)
+		return nil
+	default:
+		return fmt.Errorf("invalid method %q", p.Method)
+	}
+
 	// Spin off a go routine for each url to ping
 	for _, url := range p.Urls {
 		p.wg.Add(1)
@@ -179,6 +210,24 @@ func (p *Ping) pingToURL(u string, acc telegraf.Accumulator) {
 	acc.AddFields("ping", fields, tags)
 }
 
+func (p *Ping) nativePinger() *nativePinger {
+	n := newNativePinger()
+	n.count = p.Count
+	if n.count < 1 {
+		n.count = 1
+	}
+	n.interval = time.Duration(p.PingInterval * float64(time.Second))
+	if n.interval <= 0 {
+		n.interval = time.Second
+	}
+	n.timeout = time.Duration(p.Timeout * float64(time.Second))
+	n.deadline = time.Duration(p.Deadline) * time.Second
+	n.size = p.Size
+	n.ttl = p.TTL
+	n.source = p.Interface
+	return n
+}
+
 func hostPinger(binary string, timeout float64, args ...string) (string, error) {
 	bin, err := exec.LookPath(binary)
 	if err != nil {
@@ -303,12 +352,14 @@ func init() {
 	inputs.Add("ping", func() telegraf.Input {
 		return &Ping{
 			pingHost:     hostPinger,
+			Method:       "exec",
 			PingInterval: 1.0,
 			Count:        1,
 			Timeout:      1.0,
 			Deadline:     10,
 			Binary:       "ping",
 			Arguments:    []string{},
+			Size:         56,
 		}
 	})
 }
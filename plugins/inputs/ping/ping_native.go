@@ -0,0 +1,450 @@
+package ping
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+
+	// defaultNativeTimeout is the time to wait for a reply when there is
+	// neither a timeout nor a deadline.
+	defaultNativeTimeout = 10 * time.Second
+)
+
+// packetConn is the part of icmp.PacketConn used to send echo requests, so
+// that tests can replace it.
+type packetConn interface {
+	ReadFrom(b []byte) (int, net.Addr, error)
+	WriteTo(b []byte, dst net.Addr) (int, error)
+	Close() error
+}
+
+// nativePinger sends ICMP echo requests to many hosts at once, sharing one
+// socket per address family.
+type nativePinger struct {
+	count    int
+	interval time.Duration
+	// timeout is the time to wait for each reply, 0 to wait until the
+	// deadline.
+	timeout  time.Duration
+	deadline time.Duration
+	size     int
+	ttl      int
+	source   string
+
+	// listen opens the socket of an address family, for tests to replace.
+	listen func(ipv6 bool) (packetConn, bool, error)
+
+	mu    sync.Mutex
+	conns map[bool]*icmpConn
+	id    int
+	seq   int
+	// targets of the echo requests in flight, by sequence number
+	pending map[int]*echoTarget
+}
+
+type icmpConn struct {
+	conn packetConn
+	// datagram sockets have their echo ID set by the kernel
+	datagram bool
+	proto    int
+}
+
+type echoTarget struct {
+	ip      net.IP
+	replies chan echoReply
+}
+
+type echoReply struct {
+	seq  int
+	time time.Time
+}
+
+// pingStats are the results of pinging a host.
+type pingStats struct {
+	transmitted int
+	received    int
+	// round trip times of the replies, in order of arrival
+	rtts []time.Duration
+}
+
+func newNativePinger() *nativePinger {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	n := &nativePinger{
+		conns:   make(map[bool]*icmpConn),
+		pending: make(map[int]*echoTarget),
+		id:      r.Intn(0xffff),
+		seq:     r.Intn(0xffff),
+	}
+	n.listen = n.listenICMP
+	return n
+}
+
+// listenICMP opens an unprivileged datagram socket where the system allows
+// it, and a raw socket otherwise.
+func (n *nativePinger) listenICMP(v6 bool) (packetConn, bool, error) {
+	datagram, raw := "udp4", "ip4:icmp"
+	if v6 {
+		datagram, raw = "udp6", "ip6:ipv6-icmp"
+	}
+	address, err := sourceAddress(n.source, v6)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn, err := icmp.ListenPacket(datagram, address)
+	isDatagram := err == nil
+	if err != nil {
+		conn, err = icmp.ListenPacket(raw, address)
+		if err != nil {
+			if os.IsPermission(err) {
+				return nil, false, fmt.Errorf("%s: unprivileged ICMP sockets are not allowed, give Telegraf the CAP_NET_RAW capability or allow its group in net.ipv4.ping_group_range", err)
+			}
+			return nil, false, err
+		}
+	}
+
+	if n.ttl > 0 {
+		if v6 {
+			err = conn.IPv6PacketConn().SetHopLimit(n.ttl)
+		} else {
+			err = conn.IPv4PacketConn().SetTTL(n.ttl)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+	}
+	return conn, isDatagram, nil
+}
+
+// sourceAddress returns the address to send from given an interface name or
+// an address, which is the unspecified address if none is given.
+func sourceAddress(source string, v6 bool) (string, error) {
+	if source == "" {
+		if v6 {
+			return "::", nil
+		}
+		return "0.0.0.0", nil
+	}
+	if net.ParseIP(source) != nil {
+		return source, nil
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && (ipnet.IP.To4() == nil) == v6 {
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no address of interface %s to send from", source)
+}
+
+// conn returns the socket of the address family of an IP, opening it and
+// starting to read replies the first time.
+func (n *nativePinger) conn(ip net.IP) (*icmpConn, error) {
+	v6 := ip.To4() == nil
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if c, ok := n.conns[v6]; ok {
+		return c, nil
+	}
+
+	conn, datagram, err := n.listen(v6)
+	if err != nil {
+		return nil, err
+	}
+	c := &icmpConn{conn: conn, datagram: datagram, proto: protocolICMP}
+	if v6 {
+		c.proto = protocolIPv6ICMP
+	}
+	n.conns[v6] = c
+	go n.read(c)
+	return c, nil
+}
+
+// read dispatches the echo replies of a socket until it is closed.
+func (n *nativePinger) read(c *icmpConn) {
+	buf := make([]byte, 65536)
+	for {
+		length, addr, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+
+		msg, err := icmp.ParseMessage(c.proto, buf[:length])
+		if err != nil {
+			continue
+		}
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || (!c.datagram && echo.ID != n.id) {
+			continue
+		}
+
+		n.mu.Lock()
+		target, ok := n.pending[echo.Seq]
+		n.mu.Unlock()
+		if !ok || !target.ip.Equal(addrIP(addr)) {
+			continue
+		}
+		select {
+		case target.replies <- echoReply{seq: echo.Seq, time: now}:
+		default:
+		}
+	}
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// ping sends the echo requests to an IP and collects the replies.
+func (n *nativePinger) ping(ip net.IP) (*pingStats, error) {
+	c, err := n.conn(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &echoTarget{ip: ip, replies: make(chan echoReply, n.count)}
+	n.mu.Lock()
+	seqs := make([]int, n.count)
+	for i := range seqs {
+		n.seq = (n.seq + 1) & 0xffff
+		seqs[i] = n.seq
+		n.pending[n.seq] = target
+	}
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		for _, seq := range seqs {
+			delete(n.pending, seq)
+		}
+		n.mu.Unlock()
+	}()
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if c.datagram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	msgType := icmp.Type(ipv4.ICMPTypeEcho)
+	if c.proto == protocolIPv6ICMP {
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+
+	timeout := n.timeout
+	if timeout == 0 && n.deadline == 0 {
+		timeout = defaultNativeTimeout
+	}
+
+	stats := &pingStats{}
+	// send times of the requests waiting for a reply
+	sent := make(map[int]time.Time)
+	start := time.Now()
+	var end time.Time
+	if n.deadline > 0 {
+		end = start.Add(n.deadline)
+	}
+	nextSend, lastSend := start, start
+
+	for {
+		now := time.Now()
+		if !end.IsZero() && !now.Before(end) {
+			return stats, nil
+		}
+
+		if stats.transmitted < n.count && !now.Before(nextSend) {
+			msg := icmp.Message{
+				Type: msgType,
+				Body: &icmp.Echo{
+					ID:   n.id,
+					Seq:  seqs[stats.transmitted],
+					Data: make([]byte, n.size),
+				},
+			}
+			b, err := msg.Marshal(nil)
+			if err != nil {
+				return nil, err
+			}
+			sent[seqs[stats.transmitted]] = now
+			if _, err := c.conn.WriteTo(b, dst); err != nil {
+				return nil, err
+			}
+			stats.transmitted++
+			lastSend, nextSend = now, now.Add(n.interval)
+		}
+
+		// wait for the replies until the next request is due, or the last
+		// one times out
+		var wait time.Time
+		if stats.transmitted < n.count {
+			wait = nextSend
+		} else {
+			if len(sent) == 0 {
+				return stats, nil
+			}
+			if timeout > 0 {
+				wait = lastSend.Add(timeout)
+				if !now.Before(wait) {
+					return stats, nil
+				}
+			}
+		}
+		if wait.IsZero() || (!end.IsZero() && end.Before(wait)) {
+			wait = end
+		}
+
+		timer := time.NewTimer(wait.Sub(now))
+		select {
+		case r := <-target.replies:
+			if sentAt, ok := sent[r.seq]; ok {
+				delete(sent, r.seq)
+				rtt := r.time.Sub(sentAt)
+				if timeout == 0 || rtt <= timeout {
+					stats.received++
+					stats.rtts = append(stats.rtts, rtt)
+				}
+			}
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// close closes the sockets, stopping their readers.
+func (n *nativePinger) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, c := range n.conns {
+		c.conn.Close()
+	}
+	n.conns = make(map[bool]*icmpConn)
+}
+
+// gatherNative pings the hosts concurrently with the native pinger.
+func gatherNative(n *nativePinger, urls []string, acc telegraf.Accumulator) {
+	defer n.close()
+
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			tags := map[string]string{"url": u}
+
+			ip, err := resolve(u)
+			if err != nil {
+				acc.AddError(err)
+				acc.AddFields("ping", map[string]interface{}{"result_code": 1}, tags)
+				return
+			}
+
+			stats, err := n.ping(ip)
+			if err != nil {
+				acc.AddError(fmt.Errorf("host %s: %s", u, err))
+				acc.AddFields("ping", map[string]interface{}{"result_code": 2}, tags)
+				return
+			}
+			acc.AddFields("ping", stats.fields(), tags)
+		}(u)
+	}
+	wg.Wait()
+}
+
+// resolve returns the address to ping for a host, preferring IPv4.
+func resolve(host string) (net.IP, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no address found")
+	}
+	return ips[0], nil
+}
+
+// fields returns the ping fields of the statistics, with the response times
+// in milliseconds.
+func (s *pingStats) fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"result_code":         0,
+		"packets_transmitted": s.transmitted,
+		"packets_received":    s.received,
+		"percent_packet_loss": 0.0,
+	}
+	if s.transmitted > 0 {
+		fields["percent_packet_loss"] = float64(s.transmitted-s.received) / float64(s.transmitted) * 100.0
+	}
+	if len(s.rtts) == 0 {
+		return fields
+	}
+
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	min, max, sum := s.rtts[0], s.rtts[0], time.Duration(0)
+	for _, rtt := range s.rtts {
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+		sum += rtt
+	}
+	avg := ms(sum) / float64(len(s.rtts))
+
+	var variance, jitter float64
+	for i, rtt := range s.rtts {
+		variance += math.Pow(ms(rtt)-avg, 2)
+		if i > 0 {
+			jitter += math.Abs(ms(rtt) - ms(s.rtts[i-1]))
+		}
+	}
+
+	fields["minimum_response_ms"] = ms(min)
+	fields["average_response_ms"] = avg
+	fields["maximum_response_ms"] = ms(max)
+	fields["standard_deviation_ms"] = math.Sqrt(variance / float64(len(s.rtts)))
+	if len(s.rtts) > 1 {
+		// mean difference between consecutive response times
+		fields["jitter_ms"] = jitter / float64(len(s.rtts)-1)
+	}
+	return fields
+}
@@ -0,0 +1,241 @@
+package ping
+
+import (
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+type fakePacket struct {
+	data []byte
+	from net.Addr
+}
+
+// fakeConn answers the echo requests written to it.
+type fakeConn struct {
+	proto   int
+	delay   time.Duration
+	drop    map[int]bool
+	from    net.IP
+	id      int
+	packets chan fakePacket
+	closed  chan struct{}
+}
+
+func newFakeConn(proto int) *fakeConn {
+	return &fakeConn{
+		proto:   proto,
+		id:      -1,
+		drop:    make(map[int]bool),
+		packets: make(chan fakePacket, 100),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	msg, err := icmp.ParseMessage(c.proto, b)
+	if err != nil {
+		return 0, err
+	}
+	echo := msg.Body.(*icmp.Echo)
+	if c.drop[echo.Seq] {
+		return len(b), nil
+	}
+
+	reply := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: echo.ID, Seq: echo.Seq, Data: echo.Data}}
+	if c.proto == protocolIPv6ICMP {
+		reply.Type = ipv6.ICMPTypeEchoReply
+	}
+	if c.id >= 0 {
+		reply.Body.(*icmp.Echo).ID = c.id
+	}
+	data, err := reply.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	from := addrIP(dst)
+	if c.from != nil {
+		from = c.from
+	}
+
+	time.AfterFunc(c.delay, func() {
+		c.packets <- fakePacket{data: data, from: &net.IPAddr{IP: from}}
+	})
+	return len(b), nil
+}
+
+func (c *fakeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-c.packets:
+		return copy(b, p.data), p.from, nil
+	case <-c.closed:
+		return 0, nil, errors.New("closed")
+	}
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func newTestPinger(conns map[bool]*fakeConn) *nativePinger {
+	n := newNativePinger()
+	n.count = 3
+	n.interval = 10 * time.Millisecond
+	n.timeout = 200 * time.Millisecond
+	n.listen = func(v6 bool) (packetConn, bool, error) {
+		c, ok := conns[v6]
+		if !ok {
+			return nil, false, errors.New("not supported")
+		}
+		return c, false, nil
+	}
+	return n
+}
+
+func TestNativePing(t *testing.T) {
+	conn := newFakeConn(protocolICMP)
+	conn.delay = 5 * time.Millisecond
+	n := newTestPinger(map[bool]*fakeConn{false: conn})
+	defer n.close()
+
+	stats, err := n.ping(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.transmitted)
+	assert.Equal(t, 3, stats.received)
+	require.Len(t, stats.rtts, 3)
+	for _, rtt := range stats.rtts {
+		assert.True(t, rtt >= conn.delay, rtt.String())
+	}
+}
+
+func TestNativePingLoss(t *testing.T) {
+	conn := newFakeConn(protocolICMP)
+	n := newTestPinger(map[bool]*fakeConn{false: conn})
+	n.count = 4
+	n.timeout = 50 * time.Millisecond
+	defer n.close()
+
+	conn.drop[(n.seq+2)&0xffff] = true
+	conn.drop[(n.seq+4)&0xffff] = true
+
+	start := time.Now()
+	stats, err := n.ping(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.transmitted)
+	assert.Equal(t, 2, stats.received)
+	// waits for the timeout of the last request
+	assert.True(t, time.Since(start) >= 80*time.Millisecond)
+}
+
+func TestNativePingIgnoresOtherReplies(t *testing.T) {
+	other := newFakeConn(protocolICMP)
+	other.from = net.ParseIP("192.0.2.99")
+	n := newTestPinger(map[bool]*fakeConn{false: other})
+	n.count = 1
+	n.timeout = 20 * time.Millisecond
+	stats, err := n.ping(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.received)
+	n.close()
+
+	// raw sockets receive the replies of other processes
+	foreign := newFakeConn(protocolICMP)
+	n = newTestPinger(map[bool]*fakeConn{false: foreign})
+	foreign.id = (n.id + 1) & 0xffff
+	n.count = 1
+	n.timeout = 20 * time.Millisecond
+	stats, err = n.ping(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.received)
+	n.close()
+}
+
+func TestNativePingDeadline(t *testing.T) {
+	conn := newFakeConn(protocolICMP)
+	conn.delay = time.Second
+	n := newTestPinger(map[bool]*fakeConn{false: conn})
+	n.count = 100
+	n.timeout = 0
+	n.deadline = 50 * time.Millisecond
+	defer n.close()
+
+	start := time.Now()
+	stats, err := n.ping(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.True(t, stats.transmitted < 100)
+	assert.Equal(t, 0, stats.received)
+}
+
+func TestGatherNative(t *testing.T) {
+	v4 := newFakeConn(protocolICMP)
+	v6 := newFakeConn(protocolIPv6ICMP)
+	n := newTestPinger(map[bool]*fakeConn{false: v4, true: v6})
+
+	var acc testutil.Accumulator
+	gatherNative(n, []string{"127.0.0.1", "::1", "nonexistent.invalid"}, &acc)
+
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		tags := map[string]string{"url": host}
+		assert.True(t, acc.HasPoint("ping", tags, "packets_transmitted", 3), host)
+		assert.True(t, acc.HasPoint("ping", tags, "packets_received", 3), host)
+		assert.True(t, acc.HasPoint("ping", tags, "percent_packet_loss", 0.0), host)
+		assert.True(t, acc.HasPoint("ping", tags, "result_code", 0), host)
+	}
+	assert.True(t, acc.HasPoint("ping", map[string]string{"url": "nonexistent.invalid"}, "result_code", 1))
+	assert.Len(t, acc.Errors, 1)
+}
+
+func TestPingStatsFields(t *testing.T) {
+	stats := &pingStats{
+		transmitted: 4,
+		received:    3,
+		rtts:        []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 20 * time.Millisecond},
+	}
+	fields := stats.fields()
+
+	assert.Equal(t, 4, fields["packets_transmitted"])
+	assert.Equal(t, 3, fields["packets_received"])
+	assert.Equal(t, 25.0, fields["percent_packet_loss"])
+	assert.Equal(t, 10.0, fields["minimum_response_ms"])
+	assert.Equal(t, 20.0, fields["average_response_ms"])
+	assert.Equal(t, 30.0, fields["maximum_response_ms"])
+	assert.InDelta(t, math.Sqrt(200.0/3), fields["standard_deviation_ms"], 1e-9)
+	assert.Equal(t, 15.0, fields["jitter_ms"])
+
+	fields = (&pingStats{transmitted: 2}).fields()
+	assert.Equal(t, 100.0, fields["percent_packet_loss"])
+	assert.NotContains(t, fields, "average_response_ms")
+}
+
+func TestNativePingLoopback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	n := newNativePinger()
+	n.count = 2
+	n.interval = 10 * time.Millisecond
+	n.timeout = time.Second
+	n.size = 56
+	n.ttl = 64
+	defer n.close()
+
+	if _, err := n.conn(net.ParseIP("127.0.0.1")); err != nil {
+		t.Skipf("ICMP sockets not available: %s", err)
+	}
+	stats, err := n.ping(net.ParseIP("127.0.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.transmitted)
+	assert.Equal(t, 2, stats.received)
+}
@@ -304,3 +304,12 @@ func TestPingBinary(t *testing.T) {
 	}
 	acc.GatherError(p.Gather)
 }
+
+func TestInvalidMethod(t *testing.T) {
+	var acc testutil.Accumulator
+	p := Ping{
+		Urls:   []string{"localhost"},
+		Method: "udp",
+	}
+	require.Error(t, acc.GatherError(p.Gather))
+}
@@ -26,12 +26,22 @@ type HostPinger func(binary string, timeout float64, args ...string) (string, er
 type Ping struct {
 	wg sync.WaitGroup
 
+	// Method to ping with, "exec" to run the ping executable, or "native" to
+	// send the ICMP packets directly
+	Method string
+
 	// Number of pings to send (ping -c <COUNT>)
 	Count int
 
 	// Ping timeout, in seconds. 0 means no timeout (ping -W <TIMEOUT>)
 	Timeout float64
 
+	// Size of the ICMP payload, for the native method
+	Size int
+
+	// Time to live of the ICMP packets, for the native method
+	TTL int `toml:"ttl"`
+
 	// URLs to ping
 	Urls []string
 
@@ -54,12 +64,23 @@ const sampleConfig = `
 	## List of urls to ping
 	urls = ["www.google.com"]
 
+	## Method used to ping:
+	##   exec:   run the ping executable and parse its output (default)
+	##   native: send the ICMP packets directly, which needs Administrator
+	##           privileges.
+	# method = "exec"
+
 	## number of pings to send per collection (ping -n <COUNT>)
 	# count = 1
 
 	## Ping timeout, in seconds. 0.0 means default timeout (ping -w <TIMEOUT>)
 	# timeout = 0.0
 
+	## Size of the ICMP payload in bytes, and time to live of the packets,
+	## for the native method.
+	# size = 56
+	# ttl = 0
+
 	## Specify the ping executable binary, default is "ping"
 	# binary = "ping"
 
@@ -77,6 +98,15 @@ func (p *Ping) Gather(acc telegraf.Accumulator) error {
 		p.Count = 1
 	}
 
+	switch p.Method {
+	case "", "exec":
+	case "native":
+		gatherNative(p.nativePinger(), p.Urls, acc)
+		return nil
+	default:
+		return fmt.Errorf("invalid method %q", p.Method)
+	}
+
 	// Spin off a go routine for each url to ping
 	for _, url := range p.Urls {
 		p.wg.Add(1)
@@ -247,13 +277,25 @@ func (p *Ping) timeout() float64 {
 	return 4 + 1
 }
 
+func (p *Ping) nativePinger() *nativePinger {
+	n := newNativePinger()
+	n.count = p.Count
+	n.interval = time.Second
+	n.timeout = time.Duration(p.timeout() * float64(time.Second))
+	n.size = p.Size
+	n.ttl = p.TTL
+	return n
+}
+
 func init() {
 	inputs.Add("ping", func() telegraf.Input {
 		return &Ping{
 			pingHost:  hostPinger,
+			Method:    "exec",
 			Count:     1,
 			Binary:    "ping",
 			Arguments: []string{},
+			Size:      56,
 		}
 	})
 }
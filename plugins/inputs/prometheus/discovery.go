@@ -0,0 +1,242 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// targetGroup is an entry of a file_sd or HTTP SD document: a list of
+// "host:port" targets sharing a set of labels.
+type targetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+// sdFile is a target file along with the state it was last read in.
+type sdFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// startTargetDiscovery reads the target files and endpoints, then refreshes
+// them every refresh interval until the context is done.
+func (p *Prometheus) startTargetDiscovery(ctx context.Context) error {
+	for _, pattern := range p.FileSD {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file_sd_files pattern %q: %s", pattern, err)
+		}
+	}
+	for _, u := range p.HTTPSD {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("invalid http_sd_urls URL %q: %s", u, err)
+		}
+	}
+
+	client, err := p.createHttpClient()
+	if err != nil {
+		return err
+	}
+	p.sdClient = client
+	p.sdFiles = make(map[string]sdFile)
+	p.sdTargets = make(map[string][]URLAndAddress)
+	p.refreshTargets(ctx)
+
+	interval := p.RefreshInterval.Duration
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.refreshTargets(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// refreshTargets reads the target files which changed since they were last
+// read, and queries the HTTP SD endpoints. A source failing keeps its
+// previous targets.
+func (p *Prometheus) refreshTargets(ctx context.Context) {
+	seen := make(map[string]bool)
+	for _, pattern := range p.FileSD {
+		files, _ := filepath.Glob(pattern)
+		for _, file := range files {
+			seen[file] = true
+			if err := p.readTargetFile(file); err != nil {
+				log.Printf("E! [inputs.prometheus] Reading targets from %s: %s", file, err)
+			}
+		}
+	}
+
+	// forget the files which are gone
+	p.lock.Lock()
+	for file := range p.sdFiles {
+		if !seen[file] {
+			delete(p.sdFiles, file)
+			delete(p.sdTargets, file)
+		}
+	}
+	p.lock.Unlock()
+
+	for _, u := range p.HTTPSD {
+		groups, err := p.fetchTargets(ctx, u)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("E! [inputs.prometheus] Reading targets from %s: %s", u, err)
+			}
+			continue
+		}
+		p.setTargets(u, groups)
+	}
+}
+
+func (p *Prometheus) readTargetFile(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	last, ok := p.sdFiles[file]
+	p.lock.Unlock()
+	if ok && last.modTime.Equal(info.ModTime()) && last.size == info.Size() {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var groups []targetGroup
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &groups)
+	default:
+		err = json.Unmarshal(data, &groups)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.setTargets(file, groups)
+	p.lock.Lock()
+	p.sdFiles[file] = sdFile{modTime: info.ModTime(), size: info.Size()}
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *Prometheus) fetchTargets(ctx context.Context, u string) ([]targetGroup, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.sdClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP status %s", u, resp.Status)
+	}
+
+	var groups []targetGroup
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// setTargets replaces the targets discovered from a source.
+func (p *Prometheus) setTargets(source string, groups []targetGroup) {
+	var targets []URLAndAddress
+	for _, group := range groups {
+		for _, target := range group.Targets {
+			u, err := targetURL(target, group.Labels)
+			if err != nil {
+				log.Printf("E! [inputs.prometheus] Invalid target %q from %s: %s", target, source, err)
+				continue
+			}
+
+			tags := make(map[string]string)
+			for k, v := range group.Labels {
+				// labels starting with "__" are reserved for the target
+				// configuration
+				if !strings.HasPrefix(k, "__") {
+					tags[k] = v
+				}
+			}
+			// the original URL gets its credentials stripped for the url
+			// tag, so it must not share the URL scraped
+			original := *u
+			targets = append(targets, URLAndAddress{URL: u, OriginalURL: &original, Tags: tags})
+		}
+	}
+
+	p.lock.Lock()
+	p.sdTargets[source] = targets
+	p.lock.Unlock()
+}
+
+// targetURL returns the URL to scrape a target from, using the __scheme__ and
+// __metrics_path__ labels. Targets may also be complete URLs.
+func targetURL(target string, labels map[string]string) (*url.URL, error) {
+	if strings.Contains(target, "://") {
+		return url.Parse(target)
+	}
+	if target == "" || strings.ContainsAny(target, "/?#") {
+		return nil, fmt.Errorf("expected host:port")
+	}
+
+	scheme := labels["__scheme__"]
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := labels["__metrics_path__"]
+	if path == "" {
+		path = "/metrics"
+	}
+	return &url.URL{Scheme: scheme, Host: target, Path: path}, nil
+}
+
+// discoveredTargets returns the targets of the files and endpoints, in a
+// stable order.
+func (p *Prometheus) discoveredTargets() []URLAndAddress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	sources := make([]string, 0, len(p.sdTargets))
+	for source := range p.sdTargets {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var targets []URLAndAddress
+	for _, source := range sources {
+		targets = append(targets, p.sdTargets[source]...)
+	}
+	return targets
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func targetURLs(p *Prometheus) []string {
+	var urls []string
+	for _, target := range p.discoveredTargets() {
+		urls = append(urls, target.URL.String())
+	}
+	return urls
+}
+
+func TestFileSD(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/custom" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, sampleTextFormat)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "file_sd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	jsonTargets := fmt.Sprintf(`[{"targets": [%q], "labels": {"env": "prod", "__metrics_path__": "/custom"}}]`, u.Host)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(jsonTargets), 0644))
+	yamlTargets := `
+- targets: ["127.0.0.1:1"]
+  labels:
+    env: dev
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.yml"), []byte(yamlTargets), 0644))
+
+	p := &Prometheus{
+		FileSD:       []string{filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml")},
+		ScrapeHealth: true,
+	}
+	var acc testutil.Accumulator
+	require.NoError(t, p.startDiscovery())
+	defer p.stopDiscovery()
+
+	require.Equal(t, []string{ts.URL + "/custom", "http://127.0.0.1:1/metrics"}, targetURLs(p))
+
+	require.Error(t, acc.GatherError(p.Gather))
+	assert.True(t, acc.HasFloatField("go_goroutines", "gauge"))
+	assert.Equal(t, "prod", acc.TagValue("go_goroutines", "env"))
+	assert.False(t, acc.HasTag("go_goroutines", "__metrics_path__"))
+
+	for _, m := range acc.Metrics {
+		if m.Measurement != "prometheus_scrape" {
+			continue
+		}
+		switch m.Tags["env"] {
+		case "prod":
+			assert.Equal(t, ts.URL+"/custom", m.Tags["url"])
+			assert.Equal(t, 1, m.Fields["up"])
+			assert.Equal(t, 9, m.Fields["samples_scraped"])
+			assert.Contains(t, m.Fields, "scrape_duration_seconds")
+		case "dev":
+			assert.Equal(t, 0, m.Fields["up"])
+			assert.Equal(t, 0, m.Fields["samples_scraped"])
+		default:
+			t.Errorf("unexpected tags %v", m.Tags)
+		}
+	}
+	assert.Equal(t, 2, countMeasurement(&acc, "prometheus_scrape"))
+
+	// changed and removed files are picked up on refresh
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"targets": ["10.0.0.1:9100", "10.0.0.2:9100"]}]`), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.yml")))
+	p.refreshTargets(context.Background())
+	require.Equal(t, []string{"http://10.0.0.1:9100/metrics", "http://10.0.0.2:9100/metrics"}, targetURLs(p))
+
+	// invalid files keep their previous targets
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"targets": `), 0644))
+	p.refreshTargets(context.Background())
+	require.Equal(t, []string{"http://10.0.0.1:9100/metrics", "http://10.0.0.2:9100/metrics"}, targetURLs(p))
+}
+
+func countMeasurement(acc *testutil.Accumulator, measurement string) int {
+	n := 0
+	for _, m := range acc.Metrics {
+		if m.Measurement == measurement {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHTTPSD(t *testing.T) {
+	body := `[{"targets": ["10.0.0.1:9100"], "labels": {"job": "node", "__scheme__": "https"}}, {"targets": ["http://10.0.0.2:8080/stats"]}]`
+	sd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer sd.Close()
+
+	p := &Prometheus{HTTPSD: []string{sd.URL}}
+	require.NoError(t, p.startDiscovery())
+	defer p.stopDiscovery()
+
+	targets := p.discoveredTargets()
+	require.Len(t, targets, 2)
+	assert.Equal(t, "https://10.0.0.1:9100/metrics", targets[0].URL.String())
+	assert.Equal(t, map[string]string{"job": "node"}, targets[0].Tags)
+	assert.Equal(t, "http://10.0.0.2:8080/stats", targets[1].URL.String())
+
+	// the endpoint failing keeps the targets
+	sd.Close()
+	p.refreshTargets(context.Background())
+	assert.Len(t, p.discoveredTargets(), 2)
+}
+
+func TestTargetURL(t *testing.T) {
+	_, err := targetURL("", nil)
+	require.Error(t, err)
+	_, err = targetURL("host:9100/metrics", nil)
+	require.Error(t, err)
+
+	u, err := targetURL("host:9100", map[string]string{"__metrics_path__": "/stats"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://host:9100/stats", u.String())
+}
+
+func TestDiscoveredTargetKeepsCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, "go_goroutines 15")
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	target := "http://user:secret@" + u.Host + "/metrics"
+
+	p := &Prometheus{sdTargets: make(map[string][]URLAndAddress)}
+	p.setTargets("test", []targetGroup{{Targets: []string{target}}})
+
+	// scraping twice must keep sending the credentials
+	for i := 0; i < 2; i++ {
+		var acc testutil.Accumulator
+		require.NoError(t, acc.GatherError(p.Gather))
+		require.True(t, acc.HasMeasurement("go_goroutines"))
+		require.Equal(t, "http://"+u.Host+"/metrics", acc.Metrics[0].Tags["url"])
+	}
+	require.Equal(t, target, p.discoveredTargets()[0].URL.String())
+}
@@ -0,0 +1,471 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// podWatchRetry is the delay before listing the pods again after a failure.
+var podWatchRetry = 5 * time.Second
+
+// kubernetesClient talks to the Kubernetes API server.
+type kubernetesClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newInClusterClient returns a client using the service account of the pod
+// Telegraf runs in.
+func newInClusterClient() (*kubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, set kube_config to use a kubeconfig file")
+	}
+
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("could not parse any PEM certificates from %s/ca.crt", serviceAccountDir)
+	}
+
+	return &kubernetesClient{
+		url:    "https://" + net.JoinHostPort(host, port),
+		token:  strings.TrimSpace(string(token)),
+		client: newKubernetesHTTPClient(&tls.Config{RootCAs: pool}),
+	}, nil
+}
+
+// kubeconfig holds the parts of a kubeconfig file used to reach the API server.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// newKubeconfigClient returns a client for the current context of a
+// kubeconfig file.
+func newKubeconfigClient(path string) (*kubernetesClient, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	var clusterName, userName string
+	for _, c := range config.Contexts {
+		if c.Name == config.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("context %q not found in %s", config.CurrentContext, path)
+	}
+
+	// relative paths are relative to the kubeconfig file
+	dir := filepath.Dir(path)
+	readData := func(file, data string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return ioutil.ReadFile(file)
+	}
+
+	k := &kubernetesClient{}
+	tlsConfig := &tls.Config{}
+	for _, c := range config.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		k.url = strings.TrimSuffix(c.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+
+		ca, err := readData(c.Cluster.CertificateAuthority, c.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, err
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("could not parse the certificate authority of cluster %q", clusterName)
+			}
+		}
+	}
+	if k.url == "" {
+		return nil, fmt.Errorf("cluster %q not found in %s", clusterName, path)
+	}
+
+	for _, u := range config.Users {
+		if u.Name != userName {
+			continue
+		}
+		k.token = u.User.Token
+		if u.User.TokenFile != "" {
+			token, err := readData(u.User.TokenFile, "")
+			if err != nil {
+				return nil, err
+			}
+			k.token = strings.TrimSpace(string(token))
+		}
+
+		cert, err := readData(u.User.ClientCertificate, u.User.ClientCertificateData)
+		if err != nil {
+			return nil, err
+		}
+		key, err := readData(u.User.ClientKey, u.User.ClientKeyData)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	k.client = newKubernetesHTTPClient(tlsConfig)
+	return k, nil
+}
+
+func newKubernetesHTTPClient(tlsConfig *tls.Config) *http.Client {
+	// no overall timeout as watches are long running requests
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+		},
+	}
+}
+
+// get requests a path of the API server, checking the response status.
+func (k *kubernetesClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("GET", k.url+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned HTTP status %s: %s", req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+type pod struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type podList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []pod `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type apiStatus struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// podsQuery returns the API path of the watched pods, and the query selecting
+// them.
+func (p *Prometheus) podsQuery() (string, url.Values, error) {
+	path := "/api/v1/pods"
+	if p.PodNamespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(p.PodNamespace) + "/pods"
+	}
+
+	query := url.Values{}
+	switch p.PodScrapeScope {
+	case "", "cluster":
+	case "node":
+		node := os.Getenv("NODE_NAME")
+		if node == "" {
+			return "", nil, errors.New("the NODE_NAME environment variable must be set when pod_scrape_scope is \"node\"")
+		}
+		query.Set("fieldSelector", "spec.nodeName="+node)
+	default:
+		return "", nil, fmt.Errorf("invalid pod_scrape_scope %q", p.PodScrapeScope)
+	}
+	return path, query, nil
+}
+
+// startPodDiscovery starts watching the pods to scrape until the context is
+// done.
+func (p *Prometheus) startPodDiscovery(ctx context.Context) error {
+	path, query, err := p.podsQuery()
+	if err != nil {
+		return err
+	}
+
+	if p.kubernetes == nil {
+		if p.KubeConfig != "" {
+			p.kubernetes, err = newKubeconfigClient(p.KubeConfig)
+		} else {
+			p.kubernetes, err = newInClusterClient()
+		}
+		if err != nil {
+			return fmt.Errorf("error creating Kubernetes client: %s", err)
+		}
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.watchPods(ctx, path, query)
+	}()
+	return nil
+}
+
+// watchPods keeps the pod targets up to date until the context is done,
+// listing the pods again whenever the watch ends.
+func (p *Prometheus) watchPods(ctx context.Context, path string, query url.Values) {
+	for {
+		version, err := p.listPods(ctx, path, query)
+		if err == nil {
+			err = p.watchPodEvents(ctx, path, query, version)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+
+		log.Printf("E! [inputs.prometheus] Watching Kubernetes pods: %s", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(podWatchRetry):
+		}
+	}
+}
+
+// listPods replaces the pod targets with the pods currently running,
+// returning the resource version to watch from.
+func (p *Prometheus) listPods(ctx context.Context, path string, query url.Values) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	resp, err := p.kubernetes.get(ctx, path, query)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list podList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("error decoding pod list: %s", err)
+	}
+
+	targets := make(map[string]URLAndAddress)
+	for _, pod := range list.Items {
+		if target, ok := p.podTarget(&pod); ok {
+			targets[podKey(&pod)] = target
+		}
+	}
+
+	p.lock.Lock()
+	p.kubernetesPods = targets
+	p.lock.Unlock()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watchPodEvents applies the pod changes following a resource version. It
+// returns without error when the watch expires.
+func (p *Prometheus) watchPodEvents(ctx context.Context, path string, query url.Values, version string) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("watch", "true")
+	q.Set("resourceVersion", version)
+	q.Set("timeoutSeconds", "300")
+
+	resp, err := p.kubernetes.get(ctx, path, q)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("error decoding pod event: %s", err)
+		}
+
+		if event.Type == "ERROR" {
+			var status apiStatus
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				// the resource version is too old, list again
+				return nil
+			}
+			return fmt.Errorf("watch error: %s", status.Message)
+		}
+
+		var pod pod
+		if err := json.Unmarshal(event.Object, &pod); err != nil {
+			return fmt.Errorf("error decoding pod: %s", err)
+		}
+		target, ok := p.podTarget(&pod)
+
+		p.lock.Lock()
+		if event.Type != "DELETED" && ok {
+			p.kubernetesPods[podKey(&pod)] = target
+		} else {
+			delete(p.kubernetesPods, podKey(&pod))
+		}
+		p.lock.Unlock()
+	}
+}
+
+func podKey(pod *pod) string {
+	return pod.Metadata.Namespace + "/" + pod.Metadata.Name
+}
+
+// podTarget returns the URL to scrape a pod from, if its annotations ask for
+// it to be scraped.
+func (p *Prometheus) podTarget(pod *pod) (URLAndAddress, bool) {
+	annotations := pod.Metadata.Annotations
+	if annotations["prometheus.io/scrape"] != "true" || pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+		return URLAndAddress{}, false
+	}
+
+	scheme := annotations["prometheus.io/scheme"]
+	if scheme == "" {
+		scheme = "http"
+	}
+	port := annotations["prometheus.io/port"]
+	if port == "" {
+		port = "9102"
+	}
+	path := annotations["prometheus.io/path"]
+	if path == "" {
+		path = "/metrics"
+	} else if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(pod.Status.PodIP, port),
+		Path:   path,
+	}
+
+	tags := map[string]string{
+		"namespace": pod.Metadata.Namespace,
+		"pod":       pod.Metadata.Name,
+	}
+	for _, label := range p.PodLabels {
+		if v, ok := pod.Metadata.Labels[label]; ok {
+			tags[label] = v
+		}
+	}
+
+	original := *u
+	return URLAndAddress{
+		URL:         u,
+		OriginalURL: &original,
+		Address:     pod.Status.PodIP,
+		Tags:        tags,
+	}, true
+}
@@ -0,0 +1,238 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const podTemplate = `{"metadata": {"name": %q, "namespace": "default", "labels": {"app": "web", "tier": "front"}, "annotations": %s}, "status": {"phase": "Running", "podIP": %q}}`
+
+// fakeAPIServer serves a pod list, then the given watch events.
+type fakeAPIServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	list    string
+	events  []string
+	queries []url.Values
+	auth    string
+}
+
+func newFakeAPIServer(list string, events ...string) *fakeAPIServer {
+	f := &fakeAPIServer{list: list, events: events}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.Lock()
+		f.queries = append(f.queries, r.URL.Query())
+		f.auth = r.Header.Get("Authorization")
+		f.Unlock()
+
+		if r.URL.Path != "/api/v1/pods" && r.URL.Path != "/api/v1/namespaces/default/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(w, f.list)
+			return
+		}
+
+		for _, event := range f.events {
+			fmt.Fprintln(w, event)
+		}
+		w.(http.Flusher).Flush()
+		// keep the watch open until the client goes away
+		<-r.Context().Done()
+	}))
+	return f
+}
+
+func writeKubeconfig(t *testing.T, server string) string {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600))
+
+	config := `
+apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: other
+  cluster:
+    server: http://127.0.0.1:1
+- name: test
+  cluster:
+    server: ` + server + `
+users:
+- name: test
+  user:
+    tokenFile: token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+`
+	path := filepath.Join(dir, "config")
+	require.NoError(t, ioutil.WriteFile(path, []byte(config), 0600))
+	return path
+}
+
+func podURLs(p *Prometheus) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var urls []string
+	for _, pod := range p.kubernetesPods {
+		urls = append(urls, pod.URL.String())
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func waitForPodURLs(t *testing.T, p *Prometheus, expected []string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if assert.ObjectsAreEqual(expected, podURLs(p)) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, expected, podURLs(p))
+}
+
+func TestPodDiscovery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sampleTextFormat)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	scraped := fmt.Sprintf(`{"prometheus.io/scrape": "true", "prometheus.io/port": %q}`, u.Port())
+	list := `{"metadata": {"resourceVersion": "10"}, "items": [` +
+		fmt.Sprintf(podTemplate, "scraped", scraped, "127.0.0.1") + `,` +
+		fmt.Sprintf(podTemplate, "ignored", `{}`, "10.0.0.2") + `]}`
+	api := newFakeAPIServer(list)
+	defer api.Close()
+
+	p := &Prometheus{
+		MonitorPods: true,
+		KubeConfig:  writeKubeconfig(t, api.URL),
+		PodLabels:   []string{"app", "missing"},
+	}
+	defer os.RemoveAll(filepath.Dir(p.KubeConfig))
+
+	var acc testutil.Accumulator
+	require.NoError(t, p.startDiscovery())
+	defer p.stopDiscovery()
+
+	waitForPodURLs(t, p, []string{ts.URL + "/metrics"})
+
+	require.NoError(t, acc.GatherError(p.Gather))
+	assert.True(t, acc.HasFloatField("go_goroutines", "gauge"))
+	assert.Equal(t, "default", acc.TagValue("go_goroutines", "namespace"))
+	assert.Equal(t, "scraped", acc.TagValue("go_goroutines", "pod"))
+	assert.Equal(t, "web", acc.TagValue("go_goroutines", "app"))
+	assert.Equal(t, "127.0.0.1", acc.TagValue("go_goroutines", "address"))
+	assert.False(t, acc.HasTag("go_goroutines", "tier"))
+
+	api.Lock()
+	defer api.Unlock()
+	assert.Equal(t, "Bearer secret", api.auth)
+	require.True(t, len(api.queries) >= 2)
+	assert.Equal(t, "", api.queries[0].Get("watch"))
+	assert.Equal(t, "true", api.queries[1].Get("watch"))
+	assert.Equal(t, "10", api.queries[1].Get("resourceVersion"))
+}
+
+func TestPodDiscoveryWatch(t *testing.T) {
+	annotations := `{"prometheus.io/scrape": "true", "prometheus.io/port": "8080", "prometheus.io/path": "stats", "prometheus.io/scheme": "https"}`
+	list := `{"metadata": {"resourceVersion": "10"}, "items": [` +
+		fmt.Sprintf(podTemplate, "a", `{"prometheus.io/scrape": "true"}`, "10.0.0.1") + `]}`
+	api := newFakeAPIServer(list,
+		`{"type": "ADDED", "object": `+fmt.Sprintf(podTemplate, "b", `{}`, "10.0.0.2")+`}`,
+		`{"type": "MODIFIED", "object": `+fmt.Sprintf(podTemplate, "c", annotations, "10.0.0.3")+`}`,
+		`{"type": "DELETED", "object": `+fmt.Sprintf(podTemplate, "a", `{"prometheus.io/scrape": "true"}`, "10.0.0.1")+`}`,
+	)
+	defer api.Close()
+
+	p := &Prometheus{
+		MonitorPods:  true,
+		PodNamespace: "default",
+		kubernetes:   &kubernetesClient{url: api.URL, client: http.DefaultClient},
+	}
+	require.NoError(t, p.startDiscovery())
+	defer p.stopDiscovery()
+
+	waitForPodURLs(t, p, []string{"https://10.0.0.3:8080/stats"})
+}
+
+func TestPodDiscoveryNodeScope(t *testing.T) {
+	api := newFakeAPIServer(`{"metadata": {"resourceVersion": "1"}, "items": []}`)
+	defer api.Close()
+
+	p := &Prometheus{
+		MonitorPods:    true,
+		PodScrapeScope: "node",
+		kubernetes:     &kubernetesClient{url: api.URL, client: http.DefaultClient},
+	}
+
+	os.Unsetenv("NODE_NAME")
+	require.Error(t, p.startDiscovery())
+
+	os.Setenv("NODE_NAME", "node-1")
+	defer os.Unsetenv("NODE_NAME")
+	require.NoError(t, p.startDiscovery())
+	defer p.stopDiscovery()
+
+	// the list request is sent before the watch
+	var query url.Values
+	for i := 0; i < 500 && query == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		api.Lock()
+		if len(api.queries) > 0 {
+			query = api.queries[0]
+		}
+		api.Unlock()
+	}
+	require.NotNil(t, query)
+	assert.Equal(t, "spec.nodeName=node-1", query.Get("fieldSelector"))
+}
+
+func TestPodDiscoveryInvalidConfig(t *testing.T) {
+	p := &Prometheus{MonitorPods: true, PodScrapeScope: "region"}
+	require.Error(t, p.startDiscovery())
+
+	p = &Prometheus{MonitorPods: true, KubeConfig: "/nonexistent/kubeconfig"}
+	require.Error(t, p.startDiscovery())
+}
+
+func TestPodDiscoveryStoppedOnStartError(t *testing.T) {
+	api := newFakeAPIServer(`{"metadata": {"resourceVersion": "1"}, "items": []}`)
+	defer api.Close()
+
+	p := &Prometheus{
+		MonitorPods: true,
+		FileSD:      []string{"["},
+		kubernetes:  &kubernetesClient{url: api.URL, client: http.DefaultClient},
+	}
+
+	// The pod watch is stopped when the target discovery fails to start, and
+	// the discovery is started again on the next gather.
+	var acc testutil.Accumulator
+	require.Error(t, acc.GatherError(p.Gather))
+	assert.False(t, p.discovering)
+	require.Error(t, acc.GatherError(p.Gather))
+}
@@ -1,6 +1,7 @@
 package prometheus
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -26,6 +27,21 @@ type Prometheus struct {
 	// An array of Kubernetes services to scrape metrics from.
 	KubernetesServices []string
 
+	// Discover the pods to scrape from their annotations.
+	MonitorPods    bool     `toml:"monitor_kubernetes_pods"`
+	PodNamespace   string   `toml:"monitor_kubernetes_pods_namespace"`
+	PodScrapeScope string   `toml:"pod_scrape_scope"`
+	PodLabels      []string `toml:"kubernetes_pod_labels"`
+	KubeConfig     string   `toml:"kube_config"`
+
+	// Discover targets from file_sd files and HTTP SD endpoints.
+	FileSD          []string          `toml:"file_sd_files"`
+	HTTPSD          []string          `toml:"http_sd_urls"`
+	RefreshInterval internal.Duration `toml:"refresh_interval"`
+
+	// Report the health of each scrape.
+	ScrapeHealth bool `toml:"scrape_health"`
+
 	// Bearer Token authorization file path
 	BearerToken string `toml:"bearer_token"`
 
@@ -34,6 +50,16 @@ type Prometheus struct {
 	tls.ClientConfig
 
 	client *http.Client
+
+	kubernetes     *kubernetesClient
+	lock           sync.Mutex
+	kubernetesPods map[string]URLAndAddress
+	sdClient       *http.Client
+	sdFiles        map[string]sdFile
+	sdTargets      map[string][]URLAndAddress
+	discovering    bool
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
 }
 
 var sampleConfig = `
@@ -43,6 +69,32 @@ var sampleConfig = `
   ## An array of Kubernetes services to scrape metrics from.
   # kubernetes_services = ["http://my-service-dns.my-namespace:9100/metrics"]
 
+  ## Scrape the pods annotated with prometheus.io/scrape = "true", using the
+  ## prometheus.io/port, prometheus.io/path and prometheus.io/scheme
+  ## annotations to build their URL. Telegraf connects to the Kubernetes API
+  ## with its service account, or with the current context of kube_config.
+  # monitor_kubernetes_pods = false
+  # kube_config = "/path/to/kubeconfig"
+  ## Restrict the pods to a namespace.
+  # monitor_kubernetes_pods_namespace = ""
+  ## Scrape the pods of the whole cluster, or only those of the node named
+  ## by the NODE_NAME environment variable.
+  # pod_scrape_scope = "cluster"
+  ## Pod labels to add as tags.
+  # kubernetes_pod_labels = []
+
+  ## Files listing targets in the file_sd format of Prometheus, as JSON
+  ## (.json) or YAML (.yml, .yaml). Glob patterns are allowed. The files
+  ## are read again when they change.
+  # file_sd_files = ["/etc/telegraf/targets/*.json"]
+  ## HTTP endpoints returning targets in the same format, as JSON.
+  # http_sd_urls = ["http://localhost:8080/targets"]
+  ## Interval to check the files and query the endpoints at.
+  # refresh_interval = "30s"
+
+  ## Report the health of each scrape in the prometheus_scrape measurement.
+  # scrape_health = false
+
   ## Use bearer token for authorization
   # bearer_token = /path/to/bearer/token
 
@@ -90,6 +142,7 @@ type URLAndAddress struct {
 	OriginalURL *url.URL
 	URL         *url.URL
 	Address     string
+	Tags        map[string]string
 }
 
 func (p *Prometheus) GetAllURLs() ([]URLAndAddress, error) {
@@ -118,6 +171,14 @@ func (p *Prometheus) GetAllURLs() ([]URLAndAddress, error) {
 			allURLs = append(allURLs, URLAndAddress{URL: serviceURL, Address: resolved, OriginalURL: URL})
 		}
 	}
+
+	p.lock.Lock()
+	for _, pod := range p.kubernetesPods {
+		allURLs = append(allURLs, pod)
+	}
+	p.lock.Unlock()
+
+	allURLs = append(allURLs, p.discoveredTargets()...)
 	return allURLs, nil
 }
 
@@ -132,6 +193,12 @@ func (p *Prometheus) Gather(acc telegraf.Accumulator) error {
 		p.client = client
 	}
 
+	if !p.discovering {
+		if err := p.startDiscovery(); err != nil {
+			return err
+		}
+	}
+
 	var wg sync.WaitGroup
 
 	allURLs, err := p.GetAllURLs()
@@ -142,7 +209,12 @@ func (p *Prometheus) Gather(acc telegraf.Accumulator) error {
 		wg.Add(1)
 		go func(serviceURL URLAndAddress) {
 			defer wg.Done()
-			acc.AddError(p.gatherURL(serviceURL, acc))
+			start := time.Now()
+			samples, err := p.gatherURL(serviceURL, acc)
+			acc.AddError(err)
+			if p.ScrapeHealth {
+				p.addScrapeHealth(serviceURL, acc, time.Since(start), samples, err)
+			}
 		}(URL)
 	}
 
@@ -177,7 +249,8 @@ func (p *Prometheus) createHttpClient() (*http.Client, error) {
 	return client, nil
 }
 
-func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error {
+// gatherURL scrapes a target, returning the number of samples it exposed.
+func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) (int, error) {
 	var req, err = http.NewRequest("GET", u.URL.String(), nil)
 	req.Header.Add("Accept", acceptHeader)
 	var token []byte
@@ -186,39 +259,46 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 	if p.BearerToken != "" {
 		token, err = ioutil.ReadFile(p.BearerToken)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		req.Header.Set("Authorization", "Bearer "+string(token))
 	}
 
 	resp, err = p.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error making HTTP request to %s: %s", u.URL, err)
+		return 0, fmt.Errorf("error making HTTP request to %s: %s", u.URL, err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s returned HTTP status %s", u.URL, resp.Status)
+		return 0, fmt.Errorf("%s returned HTTP status %s", u.URL, resp.Status)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error reading body: %s", err)
+		return 0, fmt.Errorf("error reading body: %s", err)
 	}
 
 	metrics, err := Parse(body, resp.Header)
 	if err != nil {
-		return fmt.Errorf("error reading metrics for %s: %s",
+		return 0, fmt.Errorf("error reading metrics for %s: %s",
 			u.URL, err)
 	}
+	// strip user and password from URL
+	original := *u.OriginalURL
+	original.User = nil
+
 	// Add (or not) collected metrics
+	samples := 0
 	for _, metric := range metrics {
+		samples += len(metric.FieldList())
 		tags := metric.Tags()
-		// strip user and password from URL
-		u.OriginalURL.User = nil
-		tags["url"] = u.OriginalURL.String()
+		tags["url"] = original.String()
 		if u.Address != "" {
 			tags["address"] = u.Address
 		}
+		for k, v := range u.Tags {
+			tags[k] = v
+		}
 
 		switch metric.Type() {
 		case telegraf.Counter:
@@ -234,9 +314,67 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 		}
 	}
 
+	return samples, nil
+}
+
+// addScrapeHealth adds the prometheus_scrape metric of a target.
+func (p *Prometheus) addScrapeHealth(u URLAndAddress, acc telegraf.Accumulator, duration time.Duration, samples int, err error) {
+	tags := map[string]string{}
+	for k, v := range u.Tags {
+		tags[k] = v
+	}
+	// strip user and password from URL
+	original := *u.OriginalURL
+	original.User = nil
+	tags["url"] = original.String()
+	if u.Address != "" {
+		tags["address"] = u.Address
+	}
+
+	up := 1
+	if err != nil {
+		up = 0
+	}
+	fields := map[string]interface{}{
+		"up":                      up,
+		"scrape_duration_seconds": duration.Seconds(),
+		"samples_scraped":         samples,
+	}
+	acc.AddFields("prometheus_scrape", fields, tags)
+}
+
+// startDiscovery starts the discovery of the pods and targets to scrape, if
+// configured.  It is retried on the next gather if it fails.
+func (p *Prometheus) startDiscovery() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	if p.MonitorPods {
+		if err := p.startPodDiscovery(ctx); err != nil {
+			p.stopDiscovery()
+			return err
+		}
+	}
+	if len(p.FileSD) > 0 || len(p.HTTPSD) > 0 {
+		if err := p.startTargetDiscovery(ctx); err != nil {
+			p.stopDiscovery()
+			return err
+		}
+	}
+
+	p.discovering = true
 	return nil
 }
 
+// stopDiscovery stops the discovery routines and waits for them to return.
+func (p *Prometheus) stopDiscovery() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	p.discovering = false
+}
+
 func init() {
 	inputs.Add("prometheus", func() telegraf.Input {
 		return &Prometheus{ResponseTimeout: internal.Duration{Duration: time.Second * 3}}
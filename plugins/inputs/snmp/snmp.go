@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"log"
 	"math"
 	"net"
 	"os/exec"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/snmp"
 	"github.com/influxdata/telegraf/plugins/inputs"
 
 	"github.com/soniah/gosnmp"
@@ -44,6 +46,10 @@ const sampleConfig = `
   #priv_protocol = ""         # Values: "DES", "AES", ""
   #priv_password = ""
 
+  ## Directories holding MIB files to resolve OIDs with; OIDs they do not
+  ## define are looked up with the net-snmp tools.
+  #path = ["/usr/share/snmp/mibs"]
+
   ## measurement name
   name = "system"
   [[inputs.snmp.field]]
@@ -128,6 +134,9 @@ type Snmp struct {
 	EngineBoots  uint32
 	EngineTime   uint32
 
+	// Directories of MIB files used to resolve OIDs in-process.
+	Path []string
+
 	Tables []Table `toml:"table"`
 
 	// Name & Fields are the elements of a Table.
@@ -138,6 +147,9 @@ type Snmp struct {
 
 	connectionCache []snmpConnection
 	initialized     bool
+
+	// mibs is the OID tree of the MIB files found in Path, nil if unset.
+	mibs *snmp.MibTree
 }
 
 func (s *Snmp) init() error {
@@ -147,14 +159,18 @@ func (s *Snmp) init() error {
 
 	s.connectionCache = make([]snmpConnection, len(s.Agents))
 
+	if len(s.Path) > 0 {
+		s.mibs = loadMibs(s.Path)
+	}
+
 	for i := range s.Tables {
-		if err := s.Tables[i].init(); err != nil {
+		if err := s.Tables[i].init(s.mibs); err != nil {
 			return Errorf(err, "initializing table %s", s.Tables[i].Name)
 		}
 	}
 
 	for i := range s.Fields {
-		if err := s.Fields[i].init(); err != nil {
+		if err := s.Fields[i].init(s.mibs); err != nil {
 			return Errorf(err, "initializing field %s", s.Fields[i].Name)
 		}
 	}
@@ -185,19 +201,20 @@ type Table struct {
 	initialized bool
 }
 
-// init() builds & initializes the nested fields.
-func (t *Table) init() error {
+// init() builds & initializes the nested fields, looking up the OIDs in
+// mibs before using the net-snmp tools.
+func (t *Table) init(mibs *snmp.MibTree) error {
 	if t.initialized {
 		return nil
 	}
 
-	if err := t.initBuild(); err != nil {
+	if err := t.initBuild(mibs); err != nil {
 		return err
 	}
 
 	// initialize all the nested fields
 	for i := range t.Fields {
-		if err := t.Fields[i].init(); err != nil {
+		if err := t.Fields[i].init(mibs); err != nil {
 			return Errorf(err, "initializing field %s", t.Fields[i].Name)
 		}
 	}
@@ -207,14 +224,14 @@ func (t *Table) init() error {
 }
 
 // initBuild initializes the table if it has an OID configured. If so, the
-// net-snmp tools will be used to look up the OID and auto-populate the table's
-// fields.
-func (t *Table) initBuild() error {
+// MIB files or the net-snmp tools will be used to look up the OID and
+// auto-populate the table's fields.
+func (t *Table) initBuild(mibs *snmp.MibTree) error {
 	if t.Oid == "" {
 		return nil
 	}
 
-	_, _, oidText, fields, err := snmpTable(t.Oid)
+	_, _, oidText, fields, err := snmpTable(mibs, t.Oid)
 	if err != nil {
 		return err
 	}
@@ -257,18 +274,23 @@ type Field struct {
 	//  "int" will conver the value into an integer.
 	//  "hwaddr" will convert a 6-byte string to a MAC address.
 	//  "ipaddr" will convert the value to an IPv4 or IPv6 address.
+	//  "string" will convert the value to text, without trailing NUL bytes.
+	//  "enum" will convert an integer to its name in the MIB files.
 	Conversion string
 
+	// enums holds the named values for the "enum" conversion.
+	enums map[int]string
+
 	initialized bool
 }
 
 // init() converts OID names to numbers, and sets the .Name attribute if unset.
-func (f *Field) init() error {
+func (f *Field) init(mibs *snmp.MibTree) error {
 	if f.initialized {
 		return nil
 	}
 
-	_, oidNum, oidText, conversion, err := snmpTranslate(f.Oid)
+	_, oidNum, oidText, conversion, err := snmpTranslate(mibs, f.Oid)
 	if err != nil {
 		return Errorf(err, "translating")
 	}
@@ -280,7 +302,14 @@ func (f *Field) init() error {
 		f.Conversion = conversion
 	}
 
-	//TODO use textual convention conversion from the MIB
+	if f.Conversion == "enum" {
+		if node, _ := mibLookup(mibs, f.Oid); node != nil {
+			f.enums = node.Enums
+		}
+		if len(f.enums) == 0 {
+			return fmt.Errorf("no enumerated values found in the MIB files for %s", f.Oid)
+		}
+	}
 
 	f.initialized = true
 	return nil
@@ -454,7 +483,7 @@ func (t Table) Build(gs snmpConnection, walk bool) (*RTable, error) {
 				return nil, Errorf(err, "performing get on field %s", f.Name)
 			} else if pkt != nil && len(pkt.Variables) > 0 && pkt.Variables[0].Type != gosnmp.NoSuchObject && pkt.Variables[0].Type != gosnmp.NoSuchInstance {
 				ent := pkt.Variables[0]
-				fv, err := fieldConvert(f.Conversion, ent.Value)
+				fv, err := f.convert(ent.Value)
 				if err != nil {
 					return nil, Errorf(err, "converting %q (OID %s) for field %s", ent.Value, ent.Name, f.Name)
 				}
@@ -487,7 +516,7 @@ func (t Table) Build(gs snmpConnection, walk bool) (*RTable, error) {
 					}, idx)
 				}
 
-				fv, err := fieldConvert(f.Conversion, ent.Value)
+				fv, err := f.convert(ent.Value)
 				if err != nil {
 					return Errorf(err, "converting %q (OID %s) for field %s", ent.Value, ent.Name, f.Name)
 				}
@@ -662,41 +691,23 @@ func (s *Snmp) getConnection(idx int) (snmpConnection, error) {
 		gs.SecurityParameters = sp
 		gs.SecurityModel = gosnmp.UserSecurityModel
 
-		switch strings.ToLower(s.SecLevel) {
-		case "noauthnopriv", "":
-			gs.MsgFlags = gosnmp.NoAuthNoPriv
-		case "authnopriv":
-			gs.MsgFlags = gosnmp.AuthNoPriv
-		case "authpriv":
-			gs.MsgFlags = gosnmp.AuthPriv
-		default:
-			return nil, fmt.Errorf("invalid secLevel")
+		gs.MsgFlags, err = snmp.SecLevel(s.SecLevel)
+		if err != nil {
+			return nil, err
 		}
 
 		sp.UserName = s.SecName
 
-		switch strings.ToLower(s.AuthProtocol) {
-		case "md5":
-			sp.AuthenticationProtocol = gosnmp.MD5
-		case "sha":
-			sp.AuthenticationProtocol = gosnmp.SHA
-		case "":
-			sp.AuthenticationProtocol = gosnmp.NoAuth
-		default:
-			return nil, fmt.Errorf("invalid authProtocol")
+		sp.AuthenticationProtocol, err = snmp.AuthProtocol(s.AuthProtocol)
+		if err != nil {
+			return nil, err
 		}
 
 		sp.AuthenticationPassphrase = s.AuthPassword
 
-		switch strings.ToLower(s.PrivProtocol) {
-		case "des":
-			sp.PrivacyProtocol = gosnmp.DES
-		case "aes":
-			sp.PrivacyProtocol = gosnmp.AES
-		case "":
-			sp.PrivacyProtocol = gosnmp.NoPriv
-		default:
-			return nil, fmt.Errorf("invalid privProtocol")
+		sp.PrivacyProtocol, err = snmp.PrivProtocol(s.PrivProtocol)
+		if err != nil {
+			return nil, err
 		}
 
 		sp.PrivacyPassphrase = s.PrivPassword
@@ -715,12 +726,47 @@ func (s *Snmp) getConnection(idx int) (snmpConnection, error) {
 	return gs, nil
 }
 
+// convert converts a value according to the conversion of the field.
+func (f *Field) convert(v interface{}) (interface{}, error) {
+	if f.Conversion == "enum" {
+		return enumConvert(f.enums, v), nil
+	}
+	return fieldConvert(f.Conversion, v)
+}
+
+// enumConvert converts an integer into its name, leaving values without a
+// name untouched.
+func enumConvert(enums map[int]string, v interface{}) interface{} {
+	var n int
+	switch vt := v.(type) {
+	case int:
+		n = vt
+	case int32:
+		n = int(vt)
+	case int64:
+		n = int(vt)
+	case uint:
+		n = int(vt)
+	case uint32:
+		n = int(vt)
+	case uint64:
+		n = int(vt)
+	default:
+		return v
+	}
+	if name, ok := enums[n]; ok {
+		return name
+	}
+	return v
+}
+
 // fieldConvert converts from any type according to the conv specification
 //  "float"/"float(0)" will convert the value into a float.
 //  "float(X)" will convert the value into a float, and then move the decimal before Xth right-most digit.
 //  "int" will convert the value into an integer.
 //  "hwaddr" will convert the value into a MAC address.
 //  "ipaddr" will convert the value into into an IP address.
+//  "string" will convert a byte slice into a string, trimming trailing NUL bytes.
 //  "" will convert a byte slice into a string.
 func fieldConvert(conv string, v interface{}) (interface{}, error) {
 	if conv == "" {
@@ -801,6 +847,16 @@ func fieldConvert(conv string, v interface{}) (interface{}, error) {
 		return v, nil
 	}
 
+	if conv == "string" {
+		switch vt := v.(type) {
+		case string:
+			v = strings.TrimRight(vt, "\x00")
+		case []byte:
+			v = strings.TrimRight(string(vt), "\x00")
+		}
+		return v, nil
+	}
+
 	if conv == "hwaddr" {
 		switch vt := v.(type) {
 		case string:
@@ -851,7 +907,13 @@ var snmpTableCachesLock sync.Mutex
 
 // snmpTable resolves the given OID as a table, providing information about the
 // table and fields within.
-func snmpTable(oid string) (mibName string, oidNum string, oidText string, fields []Field, err error) {
+func snmpTable(mibs *snmp.MibTree, oid string) (mibName string, oidNum string, oidText string, fields []Field, err error) {
+	if node, suffix := mibLookup(mibs, oid); node != nil && suffix == "" {
+		if fields, ok := mibTableFields(mibs, node); ok {
+			return node.Module, node.OID, node.Name, fields, nil
+		}
+	}
+
 	snmpTableCachesLock.Lock()
 	if snmpTableCaches == nil {
 		snmpTableCaches = map[string]snmpTableCache{}
@@ -869,7 +931,7 @@ func snmpTable(oid string) (mibName string, oidNum string, oidText string, field
 }
 
 func snmpTableCall(oid string) (mibName string, oidNum string, oidText string, fields []Field, err error) {
-	mibName, oidNum, oidText, _, err = snmpTranslate(oid)
+	mibName, oidNum, oidText, _, err = snmpTranslate(nil, oid)
 	if err != nil {
 		return "", "", "", nil, Errorf(err, "translating")
 	}
@@ -939,7 +1001,11 @@ var snmpTranslateCachesLock sync.Mutex
 var snmpTranslateCaches map[string]snmpTranslateCache
 
 // snmpTranslate resolves the given OID.
-func snmpTranslate(oid string) (mibName string, oidNum string, oidText string, conversion string, err error) {
+func snmpTranslate(mibs *snmp.MibTree, oid string) (mibName string, oidNum string, oidText string, conversion string, err error) {
+	if node, suffix := mibLookup(mibs, oid); node != nil {
+		return node.Module, node.OID + suffix, node.Name + suffix, mibConversion(node.TextualConvention), nil
+	}
+
 	snmpTranslateCachesLock.Lock()
 	if snmpTranslateCaches == nil {
 		snmpTranslateCaches = map[string]snmpTranslateCache{}
@@ -1006,13 +1072,7 @@ func snmpTranslateCall(oid string) (mibName string, oidNum string, oidText strin
 		line := scanner.Text()
 
 		if strings.HasPrefix(line, "  -- TEXTUAL CONVENTION ") {
-			tc := strings.TrimPrefix(line, "  -- TEXTUAL CONVENTION ")
-			switch tc {
-			case "MacAddress", "PhysAddress":
-				conversion = "hwaddr"
-			case "InetAddressIPv4", "InetAddressIPv6", "InetAddress", "IPSIpAddress":
-				conversion = "ipaddr"
-			}
+			conversion = tcConversion(strings.TrimPrefix(line, "  -- TEXTUAL CONVENTION "))
 		} else if strings.HasPrefix(line, "::= { ") {
 			objs := strings.TrimPrefix(line, "::= { ")
 			objs = strings.TrimSuffix(objs, " }")
@@ -1034,3 +1094,71 @@ func snmpTranslateCall(oid string) (mibName string, oidNum string, oidText strin
 
 	return mibName, oidNum, oidText, conversion, nil
 }
+
+// tcConversion returns the conversion for values of a textual convention.
+func tcConversion(tc string) string {
+	switch tc {
+	case "MacAddress", "PhysAddress":
+		return "hwaddr"
+	case "InetAddressIPv4", "InetAddressIPv6", "InetAddress", "IPSIpAddress":
+		return "ipaddr"
+	}
+	return ""
+}
+
+// mibConversion returns the conversion for values of a textual convention
+// found in the MIB files.  DisplayString values are only converted for these,
+// so the conversions of OIDs looked up with snmptranslate are unchanged.
+func mibConversion(tc string) string {
+	if tc == "DisplayString" {
+		return "string"
+	}
+	return tcConversion(tc)
+}
+
+// loadMibs parses the MIB files of the given directories into an OID tree.
+// The tree is consulted before the net-snmp tools, which remain the fallback
+// for OIDs it does not define.
+func loadMibs(paths []string) *snmp.MibTree {
+	mibs := snmp.NewMibTree()
+	for _, path := range paths {
+		if err := mibs.LoadDir(path); err != nil {
+			log.Printf("W! [inputs.snmp] Loading MIB files from %s: %s", path, err)
+		}
+	}
+	return mibs
+}
+
+// mibLookup resolves an OID with the loaded MIB files, returning the node
+// defining it and the remaining sub-identifiers, or nil if no MIB file
+// defines it.
+func mibLookup(mibs *snmp.MibTree, oid string) (*snmp.Node, string) {
+	if mibs == nil {
+		return nil, ""
+	}
+	node, suffix, err := mibs.Lookup(oid)
+	if err != nil || node.Module == "" {
+		return nil, ""
+	}
+	return node, suffix
+}
+
+// mibTableFields returns the columns of a table defined in the loaded MIB
+// files, with the index columns as tags.
+func mibTableFields(mibs *snmp.MibTree, table *snmp.Node) ([]Field, bool) {
+	entry, columns, err := mibs.Table(table)
+	if err != nil || len(columns) == 0 {
+		return nil, false
+	}
+
+	isIndex := map[string]bool{}
+	for _, name := range entry.Index {
+		isIndex[name] = true
+	}
+
+	var fields []Field
+	for _, c := range columns {
+		fields = append(fields, Field{Name: c.Name, Oid: c.Module + "::" + c.Name, IsTag: isIndex[c.Name]})
+	}
+	return fields, true
+}
@@ -148,7 +148,7 @@ func TestFieldInit(t *testing.T) {
 
 	for _, txl := range translations {
 		f := Field{Oid: txl.inputOid, Name: txl.inputName, Conversion: txl.inputConversion}
-		err := f.init()
+		err := f.init(nil)
 		if !assert.NoError(t, err, "inputOid='%s' inputName='%s'", txl.inputOid, txl.inputName) {
 			continue
 		}
@@ -165,7 +165,7 @@ func TestTableInit(t *testing.T) {
 			{Oid: "TEST::description", Name: "description", IsTag: true},
 		},
 	}
-	err := tbl.init()
+	err := tbl.init(nil)
 	require.NoError(t, err)
 
 	assert.Equal(t, "testTable", tbl.Name)
@@ -254,6 +254,60 @@ func TestSnmpInit_noTranslate(t *testing.T) {
 	assert.Equal(t, false, s.Tables[0].Fields[2].IsTag)
 }
 
+func TestSnmpInit_mibs(t *testing.T) {
+	// fail any use of the net-snmp tools
+	defer func(ec func(string, ...string) *exec.Cmd) { execCommand = ec }(execCommand)
+	execCommand = func(_ string, _ ...string) *exec.Cmd {
+		return exec.Command("snmptranslateExecErrNotFound")
+	}
+	snmpTranslateCaches = nil
+	snmpTableCaches = nil
+
+	s := &Snmp{
+		Path: []string{"testdata"},
+		Tables: []Table{
+			{Oid: "TEST::testTable"},
+		},
+		Fields: []Field{
+			{Oid: "TEST::hostname"},
+			{Oid: ".1.0.0.1.2.0", Conversion: "enum"},
+		},
+	}
+
+	err := s.init()
+	require.NoError(t, err)
+
+	assert.Equal(t, "testTable", s.Tables[0].Name)
+	assert.Len(t, s.Tables[0].Fields, 4)
+	assert.Contains(t, s.Tables[0].Fields, Field{Oid: ".1.0.0.0.1.1", Name: "server", IsTag: true, initialized: true})
+	assert.Contains(t, s.Tables[0].Fields, Field{Oid: ".1.0.0.0.1.2", Name: "connections", initialized: true})
+	assert.Contains(t, s.Tables[0].Fields, Field{Oid: ".1.0.0.0.1.3", Name: "latency", initialized: true})
+	assert.Contains(t, s.Tables[0].Fields, Field{Oid: ".1.0.0.0.1.4", Name: "description", initialized: true})
+
+	assert.Equal(t, Field{Oid: ".1.0.0.1.1", Name: "hostname", initialized: true}, s.Fields[0])
+
+	assert.Equal(t, ".1.0.0.1.2.0", s.Fields[1].Oid)
+	assert.Equal(t, "status.0", s.Fields[1].Name)
+	v, err := s.Fields[1].convert(2)
+	require.NoError(t, err)
+	assert.Equal(t, "down", v)
+	v, err = s.Fields[1].convert(5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, v)
+
+	// OIDs missing from the MIB files still resolve numerically
+	f := Field{Oid: ".1.1.1.1"}
+	require.NoError(t, f.init(s.mibs))
+	assert.Equal(t, ".1.1.1.1", f.Name)
+
+	f = Field{Oid: ".1.1.1.1", Conversion: "enum"}
+	require.Error(t, f.init(s.mibs))
+
+	// the MIB files are only known to the instance configured with them
+	f = Field{Oid: "TEST::hostname"}
+	require.Error(t, f.init(nil))
+}
+
 func TestGetSNMPConnection_v2(t *testing.T) {
 	s := &Snmp{
 		Agents:    []string{"1.2.3.4:567", "1.2.3.4"},
@@ -693,6 +747,8 @@ func TestFieldConvert(t *testing.T) {
 		{"abcdef", "hwaddr", "61:62:63:64:65:66"},
 		{[]byte("abcd"), "ipaddr", "97.98.99.100"},
 		{"abcd", "ipaddr", "97.98.99.100"},
+		{[]byte("foo\x00\x00"), "string", "foo"},
+		{"foo", "string", "foo"},
 		{[]byte("abcdefghijklmnop"), "ipaddr", "6162:6364:6566:6768:696a:6b6c:6d6e:6f70"},
 	}
 
@@ -705,10 +761,17 @@ func TestFieldConvert(t *testing.T) {
 	}
 }
 
+func TestMibConversion(t *testing.T) {
+	assert.Equal(t, "string", mibConversion("DisplayString"))
+	assert.Equal(t, "hwaddr", mibConversion("MacAddress"))
+	assert.Equal(t, "", tcConversion("DisplayString"))
+	assert.Equal(t, "ipaddr", tcConversion("InetAddress"))
+}
+
 func TestSnmpTranslateCache_miss(t *testing.T) {
 	snmpTranslateCaches = nil
 	oid := "IF-MIB::ifPhysAddress.1"
-	mibName, oidNum, oidText, conversion, err := snmpTranslate(oid)
+	mibName, oidNum, oidText, conversion, err := snmpTranslate(nil, oid)
 	assert.Len(t, snmpTranslateCaches, 1)
 	stc := snmpTranslateCaches[oid]
 	require.NotNil(t, stc)
@@ -729,7 +792,7 @@ func TestSnmpTranslateCache_hit(t *testing.T) {
 			err:        fmt.Errorf("e"),
 		},
 	}
-	mibName, oidNum, oidText, conversion, err := snmpTranslate("foo")
+	mibName, oidNum, oidText, conversion, err := snmpTranslate(nil, "foo")
 	assert.Equal(t, "a", mibName)
 	assert.Equal(t, "b", oidNum)
 	assert.Equal(t, "c", oidText)
@@ -741,7 +804,7 @@ func TestSnmpTranslateCache_hit(t *testing.T) {
 func TestSnmpTableCache_miss(t *testing.T) {
 	snmpTableCaches = nil
 	oid := ".1.0.0.0"
-	mibName, oidNum, oidText, fields, err := snmpTable(oid)
+	mibName, oidNum, oidText, fields, err := snmpTable(nil, oid)
 	assert.Len(t, snmpTableCaches, 1)
 	stc := snmpTableCaches[oid]
 	require.NotNil(t, stc)
@@ -762,7 +825,7 @@ func TestSnmpTableCache_hit(t *testing.T) {
 			err:     fmt.Errorf("e"),
 		},
 	}
-	mibName, oidNum, oidText, fields, err := snmpTable("foo")
+	mibName, oidNum, oidText, fields, err := snmpTable(nil, "foo")
 	assert.Equal(t, "a", mibName)
 	assert.Equal(t, "b", oidNum)
 	assert.Equal(t, "c", oidText)
@@ -0,0 +1,444 @@
+package snmp_trap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/snmp"
+	"github.com/influxdata/telegraf/plugins/inputs"
+
+	"github.com/soniah/gosnmp"
+)
+
+const (
+	// maximum size of an SNMP message over UDP
+	maxMessageSize = 65535
+
+	sysUpTimeOID = ".1.3.6.1.2.1.1.3.0"
+	trapOID      = ".1.3.6.1.6.3.1.1.4.1.0"
+	// prefix of the generic traps as mapped to SNMPv2 by RFC 3584
+	genericTrapPrefix = ".1.3.6.1.6.3.1.1.5."
+)
+
+const sampleConfig = `
+  ## Transport, local address, and port to listen on.  Transport must
+  ## be "udp://".  Omit local address to listen on all interfaces.
+  ##   example: "udp://127.0.0.1:1234"
+  # service_address = "udp://:162"
+
+  ## Timeout running snmptranslate command
+  # timeout = "5s"
+
+  ## SNMPv3 auth parameters, only traps of this user are accepted
+  #sec_name = "myuser"
+  #auth_protocol = "md5"      # Values: "MD5", "SHA", ""
+  #auth_password = "pass"
+  #sec_level = "authNoPriv"   # Values: "noAuthNoPriv", "authNoPriv", "authPriv"
+  #priv_protocol = ""         # Values: "DES", "AES", ""
+  #priv_password = ""
+`
+
+type mibEntry struct {
+	mibName string
+	oidText string
+}
+
+// SnmpTrap receives SNMP traps and informs.
+type SnmpTrap struct {
+	ServiceAddress string            `toml:"service_address"`
+	Timeout        internal.Duration `toml:"timeout"`
+
+	// Values: "noAuthNoPriv", "authNoPriv", "authPriv"
+	SecLevel string `toml:"sec_level"`
+	SecName  string `toml:"sec_name"`
+	// Values: "MD5", "SHA", "". Default: ""
+	AuthProtocol string `toml:"auth_protocol"`
+	AuthPassword string `toml:"auth_password"`
+	// Values: "DES", "AES", "". Default: ""
+	PrivProtocol string `toml:"priv_protocol"`
+	PrivPassword string `toml:"priv_password"`
+
+	acc    telegraf.Accumulator
+	conn   *net.UDPConn
+	params *gosnmp.GoSNMP
+	usm    *usm
+	wg     sync.WaitGroup
+
+	cache map[string]mibEntry
+	// translate resolves an OID, tests replace it to avoid snmptranslate.
+	translate func(oid string) (mibEntry, error)
+	now       func() time.Time
+}
+
+func (s *SnmpTrap) Description() string {
+	return "Receive SNMP traps and informs"
+}
+
+func (s *SnmpTrap) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SnmpTrap) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (s *SnmpTrap) Start(acc telegraf.Accumulator) error {
+	u, err := url.Parse(s.ServiceAddress)
+	if err != nil {
+		return fmt.Errorf("invalid service address: %s", s.ServiceAddress)
+	}
+	switch u.Scheme {
+	case "udp", "udp4", "udp6":
+	default:
+		return fmt.Errorf("unknown protocol '%s' in '%s'", u.Scheme, s.ServiceAddress)
+	}
+
+	s.params = &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	if s.SecName != "" {
+		if err := s.initUsm(); err != nil {
+			return err
+		}
+	}
+
+	addr, err := net.ResolveUDPAddr(u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+	s.conn, err = net.ListenUDP(u.Scheme, addr)
+	if err != nil {
+		return err
+	}
+
+	s.acc = acc
+	s.cache = make(map[string]mibEntry)
+
+	s.wg.Add(1)
+	go s.listen()
+
+	log.Printf("I! [inputs.snmp_trap] Listening on %s", s.conn.LocalAddr())
+	return nil
+}
+
+func (s *SnmpTrap) initUsm() error {
+	level, err := snmp.SecLevel(s.SecLevel)
+	if err != nil {
+		return err
+	}
+	authProtocol, err := snmp.AuthProtocol(s.AuthProtocol)
+	if err != nil {
+		return err
+	}
+	privProtocol, err := snmp.PrivProtocol(s.PrivProtocol)
+	if err != nil {
+		return err
+	}
+
+	if level&gosnmp.AuthNoPriv != 0 && authProtocol == gosnmp.NoAuth {
+		return fmt.Errorf("auth_protocol is required for sec_level %q", s.SecLevel)
+	}
+	if level == gosnmp.AuthPriv && privProtocol == gosnmp.NoPriv {
+		return fmt.Errorf("priv_protocol is required for sec_level %q", s.SecLevel)
+	}
+	if authProtocol != gosnmp.NoAuth && s.AuthPassword == "" {
+		return fmt.Errorf("auth_password is required")
+	}
+	if privProtocol != gosnmp.NoPriv && s.PrivPassword == "" {
+		return fmt.Errorf("priv_password is required")
+	}
+
+	s.usm = &usm{
+		userName:     s.SecName,
+		level:        level,
+		authProtocol: authProtocol,
+		privProtocol: privProtocol,
+	}
+	if authProtocol != gosnmp.NoAuth {
+		s.usm.authDigest = s.usm.digest(s.AuthPassword)
+	}
+	if privProtocol != gosnmp.NoPriv {
+		s.usm.privDigest = s.usm.digest(s.PrivPassword)
+	}
+
+	// Messages are checked and decrypted by the usm, gosnmp is left to
+	// decode them.
+	s.params.Version = gosnmp.Version3
+	s.params.SecurityModel = gosnmp.UserSecurityModel
+	s.params.MsgFlags = gosnmp.NoAuthNoPriv
+	s.params.SecurityParameters = &gosnmp.UsmSecurityParameters{
+		UserName:               s.SecName,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+		Logger:                 log.New(ioutil.Discard, "", 0),
+	}
+	return nil
+}
+
+func (s *SnmpTrap) Stop() {
+	s.conn.Close()
+	s.wg.Wait()
+}
+
+func (s *SnmpTrap) listen() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				s.acc.AddError(err)
+			}
+			return
+		}
+
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+		if err := s.handle(msg, addr); err != nil {
+			s.acc.AddError(fmt.Errorf("trap from %s: %s", addr.IP, err))
+		}
+	}
+}
+
+// handle decodes a trap or inform, acknowledges informs and adds the metric.
+func (s *SnmpTrap) handle(msg []byte, addr *net.UDPAddr) error {
+	version, err := messageVersion(msg)
+	if err != nil {
+		return err
+	}
+
+	var inform []byte
+	switch version {
+	case gosnmp.Version1, gosnmp.Version2c:
+		offset, err := pduOffset(msg)
+		if err != nil {
+			return err
+		}
+		if msg[offset] == tagInform {
+			// gosnmp only decodes traps; an inform carries the same
+			// fields, so decode it as one and answer with a response
+			// holding the original request id and varbinds.
+			inform = make([]byte, len(msg))
+			copy(inform, msg)
+			inform[offset] = tagResponse
+			msg[offset] = tagTrapV2
+		}
+	case gosnmp.Version3:
+		if s.usm == nil {
+			return fmt.Errorf("SNMPv3 message received but sec_name is not set")
+		}
+		h, err := s.usm.check(msg)
+		if err != nil {
+			return err
+		}
+		if msg, err = s.usm.decrypt(msg, h); err != nil {
+			return err
+		}
+		offset, err := scopedPDUOffset(msg)
+		if err != nil {
+			return err
+		}
+		if msg[offset] == tagInform {
+			if inform, err = s.usm.response(msg, offset); err != nil {
+				return err
+			}
+			msg[offset] = tagTrapV2
+		}
+	default:
+		return fmt.Errorf("unsupported version %d", version)
+	}
+
+	packet, err := s.unmarshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if inform != nil {
+		if _, err := s.conn.WriteToUDP(inform, addr); err != nil {
+			return fmt.Errorf("acknowledging inform: %s", err)
+		}
+	}
+
+	return s.addTrap(packet, addr)
+}
+
+// unmarshal decodes a message with gosnmp, which panics on some malformed
+// input.
+func (s *SnmpTrap) unmarshal(msg []byte) (packet *gosnmp.SnmpPacket, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed message: %v", r)
+		}
+	}()
+
+	packet = s.params.UnmarshalTrap(msg)
+	if packet == nil {
+		return nil, fmt.Errorf("unable to decode message")
+	}
+	return packet, nil
+}
+
+func (s *SnmpTrap) addTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) error {
+	fields := map[string]interface{}{
+		"source": addr.IP.String(),
+	}
+
+	var oid string
+	switch packet.Version {
+	case gosnmp.Version1:
+		fields["version"] = "1"
+		fields["community"] = packet.Community
+		fields["agent_address"] = packet.AgentAddress
+		fields["sysUpTimeInstance"] = uint64(packet.Timestamp)
+
+		if packet.GenericTrap == 6 {
+			oid = normalizeOID(packet.Enterprise) + ".0." + strconv.Itoa(packet.SpecificTrap)
+		} else {
+			oid = genericTrapPrefix + strconv.Itoa(packet.GenericTrap+1)
+		}
+	case gosnmp.Version2c:
+		fields["version"] = "2c"
+		fields["community"] = packet.Community
+	case gosnmp.Version3:
+		fields["version"] = "3"
+	}
+
+	for _, v := range packet.Variables {
+		name := normalizeOID(v.Name)
+
+		switch name {
+		case trapOID:
+			if value, ok := v.Value.(string); ok {
+				oid = normalizeOID(value)
+			}
+			continue
+		case sysUpTimeOID:
+			if value, ok := fieldValue(v.Value); ok {
+				fields["sysUpTimeInstance"] = value
+			}
+			continue
+		}
+
+		if v.Type == gosnmp.ObjectIdentifier {
+			if value, ok := v.Value.(string); ok {
+				v.Value = s.lookup(normalizeOID(value)).oidText
+			}
+		}
+
+		value, ok := fieldValue(v.Value)
+		if !ok {
+			continue
+		}
+		fields[s.lookup(name).oidText] = value
+	}
+
+	if oid == "" {
+		return fmt.Errorf("missing trap OID")
+	}
+
+	e := s.lookup(oid)
+	tags := map[string]string{
+		"oid":  oid,
+		"name": e.oidText,
+		"mib":  e.mibName,
+	}
+
+	s.acc.AddFields("snmp_trap", fields, tags, s.now())
+	return nil
+}
+
+// lookup resolves an OID to its MIB and name, falling back to the numeric
+// OID if it is unknown.
+func (s *SnmpTrap) lookup(oid string) mibEntry {
+	if e, ok := s.cache[oid]; ok {
+		return e
+	}
+
+	e, err := s.translate(oid)
+	if err != nil {
+		if err, ok := err.(*exec.Error); !ok || err.Err != exec.ErrNotFound {
+			s.acc.AddError(fmt.Errorf("translating %s: %s", oid, err))
+		}
+		e = mibEntry{oidText: oid}
+	}
+	s.cache[oid] = e
+	return e
+}
+
+func (s *SnmpTrap) snmptranslate(oid string) (mibEntry, error) {
+	cmd := exec.Command("snmptranslate", "-Td", "-Ob", "-m", "all", oid)
+	out, err := internal.CombinedOutputTimeout(cmd, s.Timeout.Duration)
+	if err != nil {
+		return mibEntry{}, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewBuffer(out))
+	scanner.Scan()
+	text := scanner.Text()
+
+	i := strings.Index(text, "::")
+	if i == -1 {
+		// not found in the MIBs
+		return mibEntry{oidText: oid}, nil
+	}
+	return mibEntry{mibName: text[:i], oidText: text[i+2:]}, nil
+}
+
+func normalizeOID(oid string) string {
+	if strings.HasPrefix(oid, ".") {
+		return oid
+	}
+	return "." + oid
+}
+
+// fieldValue converts a varbind value to a field value.
+func fieldValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v), true
+		}
+		return hex.EncodeToString(v), true
+	case string:
+		return v, true
+	case int:
+		return int64(v), true
+	case uint:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+func init() {
+	inputs.Add("snmp_trap", func() telegraf.Input {
+		s := &SnmpTrap{
+			ServiceAddress: "udp://:162",
+			Timeout:        internal.Duration{Duration: 5 * time.Second},
+			now:            time.Now,
+		}
+		s.translate = s.snmptranslate
+		return s
+	})
+}
@@ -0,0 +1,405 @@
+package snmp_trap
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/soniah/gosnmp"
+	"github.com/stretchr/testify/require"
+)
+
+var testMIB = map[string]mibEntry{
+	".1.3.6.1.6.3.1.1.5.1":    {"SNMPv2-MIB", "coldStart"},
+	".1.3.6.1.4.1.8072.4.0.2": {"NET-SNMP-AGENT-MIB", "nsNotifyShutdown"},
+	".1.3.6.1.2.1.1.1.0":      {"SNMPv2-MIB", "sysDescr.0"},
+	".1.3.6.1.2.1.1.7.0":      {"SNMPv2-MIB", "sysServices.0"},
+}
+
+func newTestSnmpTrap() *SnmpTrap {
+	return &SnmpTrap{
+		ServiceAddress: "udp://127.0.0.1:0",
+		translate: func(oid string) (mibEntry, error) {
+			if e, ok := testMIB[oid]; ok {
+				return e, nil
+			}
+			return mibEntry{oidText: oid}, nil
+		},
+		now: func() time.Time { return time.Unix(0, 0) },
+	}
+}
+
+func sendTrap(t *testing.T, s *SnmpTrap, version gosnmp.SnmpVersion, trap gosnmp.SnmpTrap) {
+	addr := s.conn.LocalAddr().(*net.UDPAddr)
+	client := &gosnmp.GoSNMP{
+		Target:    "127.0.0.1",
+		Port:      uint16(addr.Port),
+		Community: "public",
+		Version:   version,
+		Timeout:   time.Second,
+	}
+	require.NoError(t, client.Connect())
+	defer client.Conn.Close()
+
+	_, err := client.SendTrap(trap)
+	require.NoError(t, err)
+}
+
+func TestReceiveTrapV2c(t *testing.T) {
+	s := newTestSnmpTrap()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	sendTrap(t, s, gosnmp.Version2c, gosnmp.SnmpTrap{
+		Variables: []gosnmp.SnmpPDU{
+			{Name: sysUpTimeOID, Type: gosnmp.TimeTicks, Value: uint32(5803)},
+			{Name: trapOID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.8072.4.0.2"},
+			{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: "router"},
+		},
+	})
+	acc.Wait(1)
+
+	tags := map[string]string{
+		"oid":  ".1.3.6.1.4.1.8072.4.0.2",
+		"name": "nsNotifyShutdown",
+		"mib":  "NET-SNMP-AGENT-MIB",
+	}
+	fields := map[string]interface{}{
+		"source":            "127.0.0.1",
+		"version":           "2c",
+		"community":         "public",
+		"sysUpTimeInstance": uint64(5803),
+		"sysDescr.0":        "router",
+	}
+	acc.AssertContainsTaggedFields(t, "snmp_trap", fields, tags)
+}
+
+func TestReceiveTrapV1(t *testing.T) {
+	s := newTestSnmpTrap()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	sendTrap(t, s, gosnmp.Version1, gosnmp.SnmpTrap{
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.7.0", Type: gosnmp.Integer, Value: 72},
+		},
+		Enterprise:   ".1.3.6.1.4.1.8072",
+		AgentAddress: "10.0.0.1",
+		GenericTrap:  0,
+		Timestamp:    300,
+	})
+	acc.Wait(1)
+
+	tags := map[string]string{
+		"oid":  ".1.3.6.1.6.3.1.1.5.1",
+		"name": "coldStart",
+		"mib":  "SNMPv2-MIB",
+	}
+	fields := map[string]interface{}{
+		"source":            "127.0.0.1",
+		"version":           "1",
+		"community":         "public",
+		"agent_address":     "10.0.0.1",
+		"sysUpTimeInstance": uint64(300),
+		"sysServices.0":     int64(72),
+	}
+	acc.AssertContainsTaggedFields(t, "snmp_trap", fields, tags)
+}
+
+func TestReceiveInform(t *testing.T) {
+	s := newTestSnmpTrap()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	packet := &gosnmp.SnmpPacket{
+		Version:   gosnmp.Version2c,
+		Community: "public",
+		PDUType:   gosnmp.SNMPv2Trap,
+		RequestID: 42,
+		Variables: []gosnmp.SnmpPDU{
+			{Name: sysUpTimeOID, Type: gosnmp.TimeTicks, Value: uint32(1)},
+			{Name: trapOID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.1"},
+		},
+	}
+	msg, err := packet.MarshalMsg()
+	require.NoError(t, err)
+	offset, err := pduOffset(msg)
+	require.NoError(t, err)
+	msg[offset] = tagInform
+
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(msg)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	expected := append([]byte{}, msg...)
+	expected[offset] = tagResponse
+	require.Equal(t, expected, buf[:n])
+
+	acc.Wait(1)
+	require.True(t, acc.HasTag("snmp_trap", "name"))
+	require.Equal(t, "coldStart", acc.Metrics[0].Tags["name"])
+}
+
+// captured SNMPv3 authNoPriv trap of user "myuser" with MD5 password
+// "mypassword"
+var v3Trap = []byte{
+	0x30, 0x81, 0xd7, 0x02, 0x01, 0x03, 0x30, 0x11, 0x02, 0x04, 0x62, 0xaf,
+	0x5a, 0x8e, 0x02, 0x03, 0x00, 0xff, 0xe3, 0x04, 0x01, 0x01, 0x02, 0x01,
+	0x03, 0x04, 0x33, 0x30, 0x31, 0x04, 0x11, 0x80, 0x00, 0x1f, 0x88, 0x80,
+	0x77, 0xdf, 0xe4, 0x4f, 0xaa, 0x70, 0x02, 0x58, 0x00, 0x00, 0x00, 0x00,
+	0x02, 0x01, 0x0f, 0x02, 0x01, 0x00, 0x04, 0x06, 0x6d, 0x79, 0x75, 0x73,
+	0x65, 0x72, 0x04, 0x0c, 0xd8, 0xb6, 0x9c, 0xb8, 0x22, 0x91, 0xfc, 0x65,
+	0xb6, 0x84, 0xcb, 0xfe, 0x04, 0x00, 0x30, 0x81, 0x89, 0x04, 0x11, 0x80,
+	0x00, 0x1f, 0x88, 0x80, 0x77, 0xdf, 0xe4, 0x4f, 0xaa, 0x70, 0x02, 0x58,
+	0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0xa7, 0x72, 0x02, 0x04, 0x39, 0x19,
+	0x9c, 0x61, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x64, 0x30, 0x0f,
+	0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00, 0x43, 0x03,
+	0x15, 0x2f, 0xec, 0x30, 0x14, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x06, 0x03,
+	0x01, 0x01, 0x04, 0x01, 0x00, 0x06, 0x06, 0x2b, 0x06, 0x01, 0x02, 0x01,
+	0x01, 0x30, 0x16, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01,
+	0x00, 0x04, 0x0a, 0x72, 0x65, 0x64, 0x20, 0x6c, 0x61, 0x70, 0x74, 0x6f,
+	0x70, 0x30, 0x0d, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x07,
+	0x00, 0x02, 0x01, 0x05, 0x30, 0x14, 0x06, 0x07, 0x2b, 0x06, 0x01, 0x02,
+	0x01, 0x01, 0x02, 0x06, 0x09, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x02, 0x03,
+	0x04, 0x05,
+}
+
+func TestReceiveTrapV3(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.SecName = "myuser"
+	s.SecLevel = "authNoPriv"
+	s.AuthProtocol = "MD5"
+	s.AuthPassword = "mypassword"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(v3Trap)
+	require.NoError(t, err)
+	acc.Wait(1)
+
+	tags := map[string]string{
+		"oid":  ".1.3.6.1.2.1.1",
+		"name": ".1.3.6.1.2.1.1",
+		"mib":  "",
+	}
+	fields := map[string]interface{}{
+		"source":            "127.0.0.1",
+		"version":           "3",
+		"sysUpTimeInstance": uint64(1388524),
+		"sysDescr.0":        "red laptop",
+		"sysServices.0":     int64(5),
+		".1.3.6.1.2.1.1.2":  ".1.3.6.1.4.1.2.3.4.5",
+	}
+	acc.AssertContainsTaggedFields(t, "snmp_trap", fields, tags)
+}
+
+func TestReceiveTrapV3Rejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		secName  string
+		secLevel string
+		password string
+	}{
+		{"wrong password", "myuser", "authNoPriv", "otherpassword"},
+		{"unknown user", "otheruser", "authNoPriv", "mypassword"},
+		{"security level too low", "myuser", "authPriv", "mypassword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSnmpTrap()
+			s.SecName = tt.secName
+			s.SecLevel = tt.secLevel
+			s.AuthProtocol = "MD5"
+			s.AuthPassword = tt.password
+			s.PrivProtocol = "DES"
+			s.PrivPassword = "privpassword"
+			s.params = &gosnmp.GoSNMP{}
+			require.NoError(t, s.initUsm())
+
+			acc := &testutil.Accumulator{}
+			s.acc = acc
+			s.cache = make(map[string]mibEntry)
+
+			msg := append([]byte{}, v3Trap...)
+			require.Error(t, s.handle(msg, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+			require.Empty(t, acc.Metrics)
+		})
+	}
+}
+
+func TestReceiveInformV3(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.SecName = "myuser"
+	s.SecLevel = "authNoPriv"
+	s.AuthProtocol = "MD5"
+	s.AuthPassword = "mypassword"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	msg := append([]byte{}, v3Trap...)
+	offset, err := scopedPDUOffset(msg)
+	require.NoError(t, err)
+	msg[offset] = tagInform
+	h, err := parseUsmHeader(msg)
+	require.NoError(t, err)
+	copy(msg[h.authOffset:], s.usm.mac(msg, h))
+
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(msg)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	resp := buf[:n]
+	_, err = s.usm.check(resp)
+	require.NoError(t, err)
+	require.Equal(t, byte(tagResponse), resp[offset])
+
+	acc.Wait(1)
+	require.Equal(t, ".1.3.6.1.2.1.1", acc.Metrics[0].Tags["oid"])
+}
+
+// SNMPv3 authPriv notifications of user "myuser" with SHA password
+// "mypassword" and privacy password "myprivpassword"
+var (
+	v3TrapAES = []byte{
+		0x30, 0x81, 0xa5, 0x02, 0x01, 0x03, 0x30, 0x11, 0x02, 0x04, 0x00, 0x00,
+		0x00, 0x01, 0x02, 0x03, 0x00, 0xff, 0xff, 0x04, 0x01, 0x03, 0x02, 0x01,
+		0x03, 0x04, 0x3c, 0x30, 0x3a, 0x04, 0x11, 0x80, 0x00, 0x1f, 0x88, 0x80,
+		0x77, 0xdf, 0xe4, 0x4f, 0xaa, 0x70, 0x02, 0x58, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x01, 0x0f, 0x02, 0x02, 0x04, 0xd2, 0x04, 0x06, 0x6d, 0x79, 0x75,
+		0x73, 0x65, 0x72, 0x04, 0x0c, 0x3c, 0x26, 0x1b, 0x89, 0x0e, 0xb5, 0x8e,
+		0x41, 0xa7, 0xf1, 0x7b, 0xb3, 0x04, 0x08, 0x00, 0x00, 0x00, 0x0f, 0x01,
+		0x02, 0x03, 0x04, 0x04, 0x4f, 0x59, 0x2d, 0xe0, 0x50, 0xc1, 0x05, 0x4c,
+		0x79, 0xcf, 0x14, 0xf1, 0x44, 0x0f, 0x9e, 0xd4, 0x6f, 0xaf, 0xfd, 0x31,
+		0x9f, 0xb1, 0xe0, 0xe8, 0xf6, 0x1f, 0x16, 0xf5, 0xaa, 0x22, 0x60, 0x0a,
+		0x1e, 0x4e, 0xcb, 0x0c, 0xbd, 0x1f, 0x8c, 0x20, 0x2b, 0x33, 0x27, 0x59,
+		0xe1, 0xa4, 0x2c, 0xec, 0xbd, 0x85, 0xf5, 0x90, 0x2a, 0x6b, 0x62, 0x8b,
+		0x36, 0xfc, 0x9e, 0x0a, 0x6e, 0xfc, 0xd5, 0xa0, 0x70, 0x00, 0xd5, 0x5a,
+		0x1a, 0x38, 0x73, 0xa3, 0x83, 0x11, 0xbb, 0x53, 0x30, 0xb9, 0x70, 0x92,
+	}
+	v3InformDES = []byte{
+		0x30, 0x81, 0xa6, 0x02, 0x01, 0x03, 0x30, 0x11, 0x02, 0x04, 0x00, 0x00,
+		0x00, 0x01, 0x02, 0x03, 0x00, 0xff, 0xff, 0x04, 0x01, 0x03, 0x02, 0x01,
+		0x03, 0x04, 0x3c, 0x30, 0x3a, 0x04, 0x11, 0x80, 0x00, 0x1f, 0x88, 0x80,
+		0x77, 0xdf, 0xe4, 0x4f, 0xaa, 0x70, 0x02, 0x58, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x01, 0x0f, 0x02, 0x02, 0x04, 0xd2, 0x04, 0x06, 0x6d, 0x79, 0x75,
+		0x73, 0x65, 0x72, 0x04, 0x0c, 0x49, 0xb6, 0x2e, 0x00, 0xc9, 0xea, 0x2d,
+		0x71, 0x7d, 0x50, 0x72, 0x04, 0x04, 0x08, 0x00, 0x00, 0x00, 0x0f, 0x01,
+		0x02, 0x03, 0x04, 0x04, 0x50, 0x41, 0x7f, 0x5c, 0xf7, 0xef, 0x16, 0x97,
+		0x16, 0x69, 0x97, 0x2c, 0x79, 0x90, 0x92, 0x33, 0xfa, 0xb7, 0x27, 0x7f,
+		0xd0, 0x27, 0x20, 0x0a, 0x3f, 0x2d, 0x96, 0xbe, 0x61, 0x5c, 0x81, 0xc7,
+		0xec, 0x9c, 0xe6, 0x46, 0xe1, 0xb0, 0x94, 0x97, 0x40, 0x64, 0xe5, 0x82,
+		0x3f, 0xdd, 0x0b, 0xc4, 0x27, 0xdb, 0x79, 0x01, 0x33, 0x5c, 0x7c, 0x66,
+		0xcf, 0x37, 0x97, 0xaf, 0x8e, 0x6e, 0xd1, 0x1d, 0xb4, 0x85, 0x46, 0xfc,
+		0x62, 0x14, 0xbb, 0xc2, 0x24, 0x15, 0xde, 0xe5, 0xb4, 0xca, 0xfa, 0x18,
+		0xce,
+	}
+)
+
+func TestReceiveTrapV3Priv(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.SecName = "myuser"
+	s.SecLevel = "authPriv"
+	s.AuthProtocol = "SHA"
+	s.AuthPassword = "mypassword"
+	s.PrivProtocol = "AES"
+	s.PrivPassword = "myprivpassword"
+	s.params = &gosnmp.GoSNMP{}
+	require.NoError(t, s.initUsm())
+
+	acc := &testutil.Accumulator{}
+	s.acc = acc
+	s.cache = make(map[string]mibEntry)
+
+	msg := append([]byte{}, v3TrapAES...)
+	require.NoError(t, s.handle(msg, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, "coldStart", acc.Metrics[0].Tags["name"])
+}
+
+func TestReceiveInformV3Priv(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.SecName = "myuser"
+	s.SecLevel = "authPriv"
+	s.AuthProtocol = "SHA"
+	s.AuthPassword = "mypassword"
+	s.PrivProtocol = "DES"
+	s.PrivPassword = "myprivpassword"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(v3InformDES)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	h, err := s.usm.check(buf[:n])
+	require.NoError(t, err)
+	require.Equal(t, gosnmp.AuthPriv, h.flags)
+	resp, err := s.usm.decrypt(buf[:n], h)
+	require.NoError(t, err)
+	offset, err := scopedPDUOffset(resp)
+	require.NoError(t, err)
+	require.Equal(t, byte(tagResponse), resp[offset])
+
+	acc.Wait(1)
+	require.Equal(t, "coldStart", acc.Metrics[0].Tags["name"])
+}
+
+func TestInvalidConfig(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.ServiceAddress = "tcp://127.0.0.1:0"
+	require.Error(t, s.Start(&testutil.Accumulator{}))
+
+	s = newTestSnmpTrap()
+	s.SecName = "myuser"
+	s.SecLevel = "authNoPriv"
+	require.Error(t, s.Start(&testutil.Accumulator{}))
+}
+
+func TestMalformedMessage(t *testing.T) {
+	s := newTestSnmpTrap()
+	s.params = &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	s.acc = &testutil.Accumulator{}
+	s.cache = make(map[string]mibEntry)
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	for i := 0; i < len(v3Trap); i++ {
+		msg := append([]byte{}, v3Trap[:i]...)
+		require.Error(t, s.handle(msg, addr), strconv.Itoa(i))
+	}
+}
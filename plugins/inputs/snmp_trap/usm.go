@@ -0,0 +1,474 @@
+package snmp_trap
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/soniah/gosnmp"
+)
+
+// BER tags of the message elements read by this plugin.
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagSequence    = 0x30
+	tagInform      = 0xa6
+	tagTrapV2      = 0xa7
+	tagResponse    = 0xa2
+)
+
+// berReader walks the BER elements of an SNMP message.
+type berReader struct {
+	b   []byte
+	pos int
+}
+
+// header reads the tag and length of the element at the current position,
+// leaving the position at the start of its content.
+func (r *berReader) header(tag byte) (int, error) {
+	if r.pos+2 > len(r.b) {
+		return 0, fmt.Errorf("message truncated")
+	}
+	if r.b[r.pos] != tag {
+		return 0, fmt.Errorf("unexpected tag %#x, expected %#x", r.b[r.pos], tag)
+	}
+
+	length := int(r.b[r.pos+1])
+	r.pos += 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || r.pos+n > len(r.b) {
+			return 0, fmt.Errorf("invalid length")
+		}
+		length = 0
+		for _, c := range r.b[r.pos : r.pos+n] {
+			length = length<<8 | int(c)
+		}
+		r.pos += n
+	}
+
+	if r.pos+length > len(r.b) {
+		return 0, fmt.Errorf("message truncated")
+	}
+	return length, nil
+}
+
+// enter moves into the constructed element at the current position.
+func (r *berReader) enter(tag byte) error {
+	_, err := r.header(tag)
+	return err
+}
+
+// next returns the content of the element at the current position and moves
+// past it.
+func (r *berReader) next(tag byte) ([]byte, error) {
+	length, err := r.header(tag)
+	if err != nil {
+		return nil, err
+	}
+	content := r.b[r.pos : r.pos+length]
+	r.pos += length
+	return content, nil
+}
+
+// messageVersion returns the SNMP version of a message.
+func messageVersion(msg []byte) (gosnmp.SnmpVersion, error) {
+	r := &berReader{b: msg}
+	if err := r.enter(tagSequence); err != nil {
+		return 0, err
+	}
+	version, err := r.next(tagInteger)
+	if err != nil {
+		return 0, err
+	}
+	if len(version) != 1 {
+		return 0, fmt.Errorf("invalid version")
+	}
+	return gosnmp.SnmpVersion(version[0]), nil
+}
+
+// pduOffset returns the offset of the PDU in a community based message.
+func pduOffset(msg []byte) (int, error) {
+	r := &berReader{b: msg}
+	if err := r.enter(tagSequence); err != nil {
+		return 0, err
+	}
+	if _, err := r.next(tagInteger); err != nil {
+		return 0, err
+	}
+	if _, err := r.next(tagOctetString); err != nil {
+		return 0, err
+	}
+	if r.pos >= len(msg) {
+		return 0, fmt.Errorf("message truncated")
+	}
+	return r.pos, nil
+}
+
+// berLength encodes the length of a BER element.
+func berLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n < 0x100:
+		return []byte{0x81, byte(n)}
+	case n < 0x10000:
+		return []byte{0x82, byte(n >> 8), byte(n)}
+	default:
+		return []byte{0x83, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// usmHeader holds the parts of an SNMPv3 message header needed to check it
+// against the user based security model.
+type usmHeader struct {
+	flags       gosnmp.SnmpV3MsgFlags
+	engineID    string
+	engineBoots uint32
+	engineTime  uint32
+	userName    string
+	authParams  []byte
+	privParams  []byte
+
+	// offsets within the message of the content of the message sequence,
+	// of the flags, of the authentication parameters and of the scoped PDU
+	bodyOffset  int
+	flagsOffset int
+	authOffset  int
+	dataOffset  int
+}
+
+func parseUsmHeader(msg []byte) (*usmHeader, error) {
+	r := &berReader{b: msg}
+	if err := r.enter(tagSequence); err != nil {
+		return nil, err
+	}
+	h := &usmHeader{bodyOffset: r.pos}
+	if _, err := r.next(tagInteger); err != nil {
+		return nil, err
+	}
+
+	if err := r.enter(tagSequence); err != nil {
+		return nil, err
+	}
+	for _, tag := range []byte{tagInteger, tagInteger} {
+		if _, err := r.next(tag); err != nil {
+			return nil, err
+		}
+	}
+	flags, err := r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags) != 1 {
+		return nil, fmt.Errorf("invalid msgFlags")
+	}
+	h.flags = gosnmp.SnmpV3MsgFlags(flags[0])
+	h.flagsOffset = r.pos - 1
+	model, err := r.next(tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	if len(model) != 1 || gosnmp.SnmpV3SecurityModel(model[0]) != gosnmp.UserSecurityModel {
+		return nil, fmt.Errorf("unsupported security model")
+	}
+
+	if err := r.enter(tagOctetString); err != nil {
+		return nil, err
+	}
+	if err := r.enter(tagSequence); err != nil {
+		return nil, err
+	}
+
+	engineID, err := r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	h.engineID = string(engineID)
+
+	for _, v := range []*uint32{&h.engineBoots, &h.engineTime} {
+		b, err := r.next(tagInteger)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 5 {
+			return nil, fmt.Errorf("invalid engine boots or time")
+		}
+		for _, c := range b {
+			*v = *v<<8 | uint32(c)
+		}
+	}
+
+	userName, err := r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	h.userName = string(userName)
+
+	h.authParams, err = r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	h.authOffset = r.pos - len(h.authParams)
+
+	h.privParams, err = r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pos >= len(msg) {
+		return nil, fmt.Errorf("message truncated")
+	}
+	h.dataOffset = r.pos
+
+	return h, nil
+}
+
+// scopedPDUOffset returns the offset of the PDU in a decrypted SNMPv3
+// message.
+func scopedPDUOffset(msg []byte) (int, error) {
+	h, err := parseUsmHeader(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	r := &berReader{b: msg, pos: h.dataOffset}
+	if err := r.enter(tagSequence); err != nil {
+		return 0, err
+	}
+	for _, tag := range []byte{tagOctetString, tagOctetString} {
+		if _, err := r.next(tag); err != nil {
+			return 0, err
+		}
+	}
+	if r.pos >= len(msg) {
+		return 0, fmt.Errorf("message truncated")
+	}
+	return r.pos, nil
+}
+
+// replaceData returns a copy of the message with its scoped PDU replaced by
+// the given element.
+func replaceData(msg []byte, h *usmHeader, data []byte) []byte {
+	body := make([]byte, 0, h.dataOffset-h.bodyOffset+len(data))
+	body = append(body, msg[h.bodyOffset:h.dataOffset]...)
+	body = append(body, data...)
+
+	out := append([]byte{tagSequence}, berLength(len(body))...)
+	return append(out, body...)
+}
+
+// usm checks incoming SNMPv3 messages against the configured user.
+type usm struct {
+	userName     string
+	level        gosnmp.SnmpV3MsgFlags
+	authProtocol gosnmp.SnmpV3AuthProtocol
+	privProtocol gosnmp.SnmpV3PrivProtocol
+
+	// Digests of the passwords.  The engine ID of a message is not
+	// authenticated, so keys are localized to it per message rather than
+	// derived and kept for every engine ID seen.
+	authDigest []byte
+	privDigest []byte
+}
+
+// check verifies the user, the security level and the authentication code
+// of an SNMPv3 message.
+func (u *usm) check(msg []byte) (*usmHeader, error) {
+	h, err := parseUsmHeader(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.userName != u.userName {
+		return nil, fmt.Errorf("unknown user %q", h.userName)
+	}
+
+	level := h.flags & gosnmp.AuthPriv
+	if level < u.level {
+		return nil, fmt.Errorf("security level of user %q too low", h.userName)
+	}
+	if level&gosnmp.AuthNoPriv != 0 && u.authProtocol == gosnmp.NoAuth {
+		return nil, fmt.Errorf("authentication not configured for user %q", h.userName)
+	}
+	if level == gosnmp.AuthPriv && u.privProtocol == gosnmp.NoPriv {
+		return nil, fmt.Errorf("privacy not configured for user %q", h.userName)
+	}
+
+	if level&gosnmp.AuthNoPriv == 0 {
+		return h, nil
+	}
+
+	if len(h.authParams) != 12 {
+		return nil, fmt.Errorf("invalid authentication parameters")
+	}
+	if !hmac.Equal(u.mac(msg, h), h.authParams) {
+		return nil, fmt.Errorf("authentication failed for user %q", h.userName)
+	}
+	return h, nil
+}
+
+// mac computes the authentication code of a message, over the message with
+// the authentication parameters zeroed.
+func (u *usm) mac(msg []byte, h *usmHeader) []byte {
+	blank := make([]byte, len(msg))
+	copy(blank, msg)
+	copy(blank[h.authOffset:h.authOffset+12], make([]byte, 12))
+
+	mac := hmac.New(u.hash, u.localize(u.authDigest, h.engineID))
+	mac.Write(blank)
+	return mac.Sum(nil)[:12]
+}
+
+// decrypt returns the message with its scoped PDU decrypted.  Messages
+// without privacy are returned as they are.
+func (u *usm) decrypt(msg []byte, h *usmHeader) ([]byte, error) {
+	if h.flags&gosnmp.AuthPriv != gosnmp.AuthPriv {
+		return msg, nil
+	}
+
+	r := &berReader{b: msg, pos: h.dataOffset}
+	data, err := r.next(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := u.crypt(data, h, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop the padding following the scoped PDU
+	r = &berReader{b: plain}
+	length, err := r.header(tagSequence)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed for user %q", h.userName)
+	}
+	return replaceData(msg, h, plain[:r.pos+length]), nil
+}
+
+// response builds the response to a decrypted SNMPv3 inform, secured at the
+// level of the inform.
+func (u *usm) response(msg []byte, offset int) ([]byte, error) {
+	resp := make([]byte, len(msg))
+	copy(resp, msg)
+	resp[offset] = tagResponse
+
+	h, err := parseUsmHeader(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp[h.flagsOffset] &^= byte(gosnmp.Reportable)
+
+	if h.flags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		// the salt is written in place, it has the same length for the
+		// response
+		if _, err := rand.Read(h.privParams); err != nil {
+			return nil, err
+		}
+
+		plain := append([]byte{}, resp[h.dataOffset:]...)
+		if u.privProtocol != gosnmp.AES && len(plain)%des.BlockSize != 0 {
+			plain = append(plain, make([]byte, des.BlockSize-len(plain)%des.BlockSize)...)
+		}
+		data, err := u.crypt(plain, h, true)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(append([]byte{tagOctetString}, berLength(len(data))...), data...)
+		resp = replaceData(resp, h, data)
+		if h, err = parseUsmHeader(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.flags&gosnmp.AuthNoPriv != 0 {
+		copy(resp[h.authOffset:], u.mac(resp, h))
+	}
+	return resp, nil
+}
+
+// crypt encrypts or decrypts a scoped PDU with DES as described in RFC 3414
+// section 8, or with AES as described in RFC 3826 section 3.
+func (u *usm) crypt(data []byte, h *usmHeader, encrypt bool) ([]byte, error) {
+	if len(h.privParams) != 8 {
+		return nil, fmt.Errorf("invalid privacy parameters")
+	}
+
+	key := u.localize(u.privDigest, h.engineID)
+
+	out := make([]byte, len(data))
+	switch u.privProtocol {
+	case gosnmp.AES:
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint32(iv, h.engineBoots)
+		binary.BigEndian.PutUint32(iv[4:], h.engineTime)
+		copy(iv[8:], h.privParams)
+
+		if encrypt {
+			cipher.NewCFBEncrypter(block, iv).XORKeyStream(out, data)
+		} else {
+			cipher.NewCFBDecrypter(block, iv).XORKeyStream(out, data)
+		}
+	default:
+		if len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("invalid length of encrypted PDU")
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = key[8+i] ^ h.privParams[i]
+		}
+
+		if encrypt {
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		} else {
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+		}
+	}
+	return out, nil
+}
+
+func (u *usm) hash() hash.Hash {
+	if u.authProtocol == gosnmp.SHA {
+		return sha1.New()
+	}
+	return md5.New()
+}
+
+// digest hashes a password into a key as described in RFC 3414 appendix
+// A.2.
+func (u *usm) digest(password string) []byte {
+	h := u.hash()
+	buf := bytes.Repeat([]byte(password), 64/len(password)+2)
+	for i := 0; i < 1048576; i += 64 {
+		n := i % len(password)
+		h.Write(buf[n : n+64])
+	}
+	return h.Sum(nil)
+}
+
+// localize localizes a key to an engine ID.
+func (u *usm) localize(digest []byte, engineID string) []byte {
+	h := u.hash()
+	h.Write(digest)
+	h.Write([]byte(engineID))
+	h.Write(digest)
+	return h.Sum(nil)
+}
@@ -8,14 +8,10 @@ import (
 	"net"
 	"os"
 	"strings"
-	"sync"
-
-	"time"
-
-	"crypto/tls"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -30,14 +26,9 @@ type streamSocketListener struct {
 	*SocketListener
 
 	sockType string
-
-	connections    map[string]net.Conn
-	connectionsMtx sync.Mutex
 }
 
 func (ssl *streamSocketListener) listen() {
-	ssl.connections = map[string]net.Conn{}
-
 	for {
 		c, err := ssl.Accept()
 		if err != nil {
@@ -48,71 +39,21 @@ func (ssl *streamSocketListener) listen() {
 		}
 
 		if ssl.ReadBufferSize.Size > 0 {
-			if srb, ok := c.(setReadBufferer); ok {
-				srb.SetReadBuffer(int(ssl.ReadBufferSize.Size))
-			} else {
+			if srb, ok := c.(setReadBufferer); !ok || srb.SetReadBuffer(int(ssl.ReadBufferSize.Size)) != nil {
 				log.Printf("W! Unable to set read buffer on a %s socket", ssl.sockType)
 			}
 		}
 
-		ssl.connectionsMtx.Lock()
-		if ssl.MaxConnections > 0 && len(ssl.connections) >= ssl.MaxConnections {
-			ssl.connectionsMtx.Unlock()
-			c.Close()
-			continue
-		}
-		ssl.connections[c.RemoteAddr().String()] = c
-		ssl.connectionsMtx.Unlock()
-
-		if err := ssl.setKeepAlive(c); err != nil {
-			ssl.AddError(fmt.Errorf("unable to configure keep alive (%s): %s", ssl.ServiceAddress, err))
-		}
-
 		go ssl.read(c)
 	}
-
-	ssl.connectionsMtx.Lock()
-	for _, c := range ssl.connections {
-		c.Close()
-	}
-	ssl.connectionsMtx.Unlock()
-}
-
-func (ssl *streamSocketListener) setKeepAlive(c net.Conn) error {
-	if ssl.KeepAlivePeriod == nil {
-		return nil
-	}
-	tcpc, ok := c.(*net.TCPConn)
-	if !ok {
-		return fmt.Errorf("cannot set keep alive on a %s socket", strings.SplitN(ssl.ServiceAddress, "://", 2)[0])
-	}
-	if ssl.KeepAlivePeriod.Duration == 0 {
-		return tcpc.SetKeepAlive(false)
-	}
-	if err := tcpc.SetKeepAlive(true); err != nil {
-		return err
-	}
-	return tcpc.SetKeepAlivePeriod(ssl.KeepAlivePeriod.Duration)
-}
-
-func (ssl *streamSocketListener) removeConnection(c net.Conn) {
-	ssl.connectionsMtx.Lock()
-	delete(ssl.connections, c.RemoteAddr().String())
-	ssl.connectionsMtx.Unlock()
 }
 
 func (ssl *streamSocketListener) read(c net.Conn) {
-	defer ssl.removeConnection(c)
 	defer c.Close()
 
+	addr := listener.Address(c.RemoteAddr())
 	scnr := bufio.NewScanner(c)
-	for {
-		if ssl.ReadTimeout != nil && ssl.ReadTimeout.Duration > 0 {
-			c.SetReadDeadline(time.Now().Add(ssl.ReadTimeout.Duration))
-		}
-		if !scnr.Scan() {
-			break
-		}
+	for scnr.Scan() {
 		metrics, err := ssl.Parse(scnr.Bytes())
 		if err != nil {
 			ssl.AddError(fmt.Errorf("unable to parse incoming line: %s", err))
@@ -120,7 +61,9 @@ func (ssl *streamSocketListener) read(c net.Conn) {
 			continue
 		}
 		for _, m := range metrics {
-			ssl.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+			tags := m.Tags()
+			ssl.AddClientTag(tags, addr)
+			ssl.AddFields(m.Name(), m.Fields(), tags, m.Time())
 		}
 	}
 
@@ -141,7 +84,7 @@ type packetSocketListener struct {
 func (psl *packetSocketListener) listen() {
 	buf := make([]byte, 64*1024) // 64kb - maximum size of IP packet
 	for {
-		n, _, err := psl.ReadFrom(buf)
+		n, addr, err := psl.ReadFrom(buf)
 		if err != nil {
 			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
 				psl.AddError(err)
@@ -156,17 +99,18 @@ func (psl *packetSocketListener) listen() {
 			continue
 		}
 		for _, m := range metrics {
-			psl.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+			tags := m.Tags()
+			psl.AddClientTag(tags, listener.Address(addr))
+			psl.AddFields(m.Name(), m.Fields(), tags, m.Time())
 		}
 	}
 }
 
 type SocketListener struct {
-	ServiceAddress  string             `toml:"service_address"`
-	MaxConnections  int                `toml:"max_connections"`
-	ReadBufferSize  internal.Size      `toml:"read_buffer_size"`
-	ReadTimeout     *internal.Duration `toml:"read_timeout"`
-	KeepAlivePeriod *internal.Duration `toml:"keep_alive_period"`
+	ServiceAddress string             `toml:"service_address"`
+	ReadBufferSize internal.Size      `toml:"read_buffer_size"`
+	ReadTimeout    *internal.Duration `toml:"read_timeout"` // Deprecated in 1.9; use IdleTimeout
+	listener.Config
 	tlsint.ServerConfig
 
 	parsers.Parser
@@ -197,10 +141,28 @@ func (sl *SocketListener) SampleConfig() string {
   ## 0 (default) is unlimited.
   # max_connections = 1024
 
-  ## Read timeout.
+  ## Maximum duration a connection may stay idle before being closed.
   ## Only applies to stream sockets (e.g. TCP).
   ## 0 (default) is unlimited.
-  # read_timeout = "30s"
+  # idle_timeout = "30s"
+
+  ## Maximum number of connections, or of packets for datagram sockets, per
+  ## second and client address.
+  ## 0 (default) is unlimited.
+  # rate_limit = 0.0
+
+  ## Read the PROXY protocol header sent by a load balancer in front of the
+  ## listener, to recover the address of the client. Datagram sockets only
+  ## support version 2 of the protocol.
+  # proxy_protocol = false
+
+  ## Addresses or CIDR networks of the load balancers trusted to send the
+  ## PROXY protocol header. Other clients are handled as direct connections,
+  ## without reading a header. If empty, the header is read from all clients.
+  # proxy_protocol_trusted = ["10.0.0.0/8"]
+
+  ## Name of the tag holding the address of the client, not added if empty.
+  # client_address_tag = ""
 
   ## Optional TLS configuration.
   ## Only applies to stream sockets (e.g. TCP).
@@ -208,6 +170,8 @@ func (sl *SocketListener) SampleConfig() string {
   # tls_key  = "/etc/telegraf/key.pem"
   ## Enables client authentication if set.
   # tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
+  ## Common names of the client certificates allowed to connect.
+  # tls_allowed_cns = ["client.example.org"]
 
   ## Maximum socket buffer size (in bytes when no unit specified).
   ## For stream sockets, once the buffer fills up, the sender will start backing up.
@@ -239,6 +203,8 @@ func (sl *SocketListener) SetParser(parser parsers.Parser) {
 
 func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 	sl.Accumulator = acc
+	sl.SetReadTimeout(sl.ReadTimeout)
+
 	spl := strings.SplitN(sl.ServiceAddress, "://", 2)
 	if len(spl) != 2 {
 		return fmt.Errorf("invalid service address: %s", sl.ServiceAddress)
@@ -253,21 +219,12 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 
 	switch spl[0] {
 	case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
-		var (
-			err error
-			l   net.Listener
-		)
-
 		tlsCfg, err := sl.ServerConfig.TLSConfig()
 		if err != nil {
-			return nil
+			return err
 		}
 
-		if tlsCfg == nil {
-			l, err = net.Listen(spl[0], spl[1])
-		} else {
-			l, err = tls.Listen(spl[0], spl[1], tlsCfg)
-		}
+		l, err := sl.Listen("socket_listener", spl[0], spl[1], tlsCfg)
 		if err != nil {
 			return err
 		}
@@ -281,15 +238,13 @@ func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
 		sl.Closer = ssl
 		go ssl.listen()
 	case "udp", "udp4", "udp6", "ip", "ip4", "ip6", "unixgram":
-		pc, err := net.ListenPacket(spl[0], spl[1])
+		pc, err := sl.ListenPacket("socket_listener", spl[0], spl[1])
 		if err != nil {
 			return err
 		}
 
 		if sl.ReadBufferSize.Size > 0 {
-			if srb, ok := pc.(setReadBufferer); ok {
-				srb.SetReadBuffer(int(sl.ReadBufferSize.Size))
-			} else {
+			if err := pc.SetReadBuffer(int(sl.ReadBufferSize.Size)); err != nil {
 				log.Printf("W! Unable to set read buffer on a %s socket", spl[0])
 			}
 		}
@@ -161,6 +161,31 @@ func TestSocketListener_unixgram(t *testing.T) {
 	testSocketListener(t, sl, client)
 }
 
+func TestSocketListener_proxy_protocol(t *testing.T) {
+	defer testEmptyLog(t)()
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.ProxyProtocol = true
+	sl.ClientAddressTag = "source"
+
+	acc := &testutil.Accumulator{}
+	err := sl.Start(acc)
+	require.NoError(t, err)
+	defer sl.Stop()
+
+	client, err := net.Dial("tcp", sl.Closer.(net.Listener).Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 8094\r\ntest,foo=bar v=1i 123456789\n"))
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "test",
+		map[string]interface{}{"v": int64(1)},
+		map[string]string{"foo": "bar", "source": "192.0.2.1"})
+}
+
 func testSocketListener(t *testing.T, sl *SocketListener, client net.Conn) {
 	mstr12 := "test,foo=bar v=1i 123456789\ntest,foo=baz v=2i 123456790\n"
 	mstr3 := "test,foo=zab v=3i 123456791"
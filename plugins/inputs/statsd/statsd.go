@@ -17,6 +17,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/selfstat"
 )
@@ -33,6 +34,10 @@ const (
 	defaultSeparator           = "_"
 	defaultAllowPendingMessage = 10000
 	MaxTCPConnections          = 250
+
+	// defaultKeepAlivePeriod is the usual default of the operating systems,
+	// used when tcp_keep_alive is enabled without a period.
+	defaultKeepAlivePeriod = 2 * time.Hour
 )
 
 var dropwarn = "E! Error: statsd message queue full. " +
@@ -79,20 +84,14 @@ type Statsd struct {
 	ReadBufferSize int `toml:"read_buffer_size"`
 
 	sync.Mutex
-	// Lock for preventing a data race during resource cleanup
-	cleanup sync.Mutex
-	wg      sync.WaitGroup
-	// accept channel tracks how many active connections there are, if there
-	// is an available bool in accept, then we are below the maximum and can
-	// accept the connection
-	accept chan bool
+	wg sync.WaitGroup
 	// drops tracks the number of dropped metrics.
 	drops int
 	// malformed tracks the number of malformed packets
 	malformed int
 
 	// Channel for all incoming statsd packets
-	in   chan *bytes.Buffer
+	in   chan input
 	done chan struct{}
 
 	// Cache gauges, counters & sets so they can be aggregated as they arrive
@@ -106,23 +105,23 @@ type Statsd struct {
 	// bucket -> influx templates
 	Templates []string
 
-	// Protocol listeners
-	UDPlistener *net.UDPConn
-	TCPlistener *net.TCPListener
-
-	// track current connections so we can close them in Stop()
-	conns map[string]*net.TCPConn
+	// Protocol listeners, the TCP listener closes the open connections in
+	// Stop()
+	UDPlistener *listener.PacketListener
+	TCPlistener *listener.Listener
 
 	MaxTCPConnections int `toml:"max_tcp_connections"`
 
 	TCPKeepAlive       bool               `toml:"tcp_keep_alive"`
 	TCPKeepAlivePeriod *internal.Duration `toml:"tcp_keep_alive_period"`
 
+	listener.Config
+
 	graphiteParser *graphite.GraphiteParser
 
 	acc telegraf.Accumulator
 
-	MaxConnections     selfstat.Stat
+	MaxConnectionsStat selfstat.Stat
 	CurrentConnections selfstat.Stat
 	TotalConnections   selfstat.Stat
 	PacketsRecv        selfstat.Stat
@@ -132,6 +131,12 @@ type Statsd struct {
 	bufPool sync.Pool
 }
 
+// input is a packet or a line received from a client.
+type input struct {
+	buf  *bytes.Buffer
+	addr string
+}
+
 // One statsd metric, form is <bucket>:<value>|<mtype>|@<samplerate>
 type metric struct {
 	name       string
@@ -187,9 +192,31 @@ const sampleConfig = `
 
   ## Specifies the keep-alive period for an active network connection.
   ## Only applies to TCP sockets and will be ignored if tcp_keep_alive is false.
-  ## Defaults to the OS configuration.
+  ## Defaults to 2h, the usual OS configuration.
   # tcp_keep_alive_period = "2h"
 
+  ## Maximum duration a TCP connection may stay idle before being closed.
+  ## 0 (default) is unlimited.
+  # idle_timeout = "5m"
+
+  ## Maximum number of TCP connections, or of UDP packets, per second and
+  ## client address.
+  ## 0 (default) is unlimited.
+  # rate_limit = 0.0
+
+  ## Read the PROXY protocol header sent by a load balancer in front of the
+  ## server, to recover the address of the client. UDP packets only support
+  ## version 2 of the protocol.
+  # proxy_protocol = false
+
+  ## Addresses or CIDR networks of the load balancers trusted to send the
+  ## PROXY protocol header. Other clients are handled as direct connections,
+  ## without reading a header. If empty, the header is read from all clients.
+  # proxy_protocol_trusted = ["10.0.0.0/8"]
+
+  ## Name of the tag holding the address of the client, not added if empty.
+  # client_address_tag = ""
+
   ## Address and port to host UDP listener on
   service_address = ":8125"
 
@@ -309,25 +336,29 @@ func (s *Statsd) Start(_ telegraf.Accumulator) error {
 	tags := map[string]string{
 		"address": s.ServiceAddress,
 	}
-	s.MaxConnections = selfstat.Register("statsd", "tcp_max_connections", tags)
-	s.MaxConnections.Set(int64(s.MaxTCPConnections))
+	if s.MaxConnections == 0 {
+		s.MaxConnections = s.MaxTCPConnections
+	}
+	if s.TCPKeepAlive && s.KeepAlivePeriod == nil {
+		s.KeepAlivePeriod = s.TCPKeepAlivePeriod
+		if s.KeepAlivePeriod == nil {
+			s.KeepAlivePeriod = &internal.Duration{Duration: defaultKeepAlivePeriod}
+		}
+	}
+	s.MaxConnectionsStat = selfstat.Register("statsd", "tcp_max_connections", tags)
+	s.MaxConnectionsStat.Set(int64(s.MaxConnections))
 	s.CurrentConnections = selfstat.Register("statsd", "tcp_current_connections", tags)
 	s.TotalConnections = selfstat.Register("statsd", "tcp_total_connections", tags)
 	s.PacketsRecv = selfstat.Register("statsd", "tcp_packets_received", tags)
 	s.BytesRecv = selfstat.Register("statsd", "tcp_bytes_received", tags)
 
-	s.in = make(chan *bytes.Buffer, s.AllowedPendingMessages)
+	s.in = make(chan input, s.AllowedPendingMessages)
 	s.done = make(chan struct{})
-	s.accept = make(chan bool, s.MaxTCPConnections)
-	s.conns = make(map[string]*net.TCPConn)
 	s.bufPool = sync.Pool{
 		New: func() interface{} {
 			return new(bytes.Buffer)
 		},
 	}
-	for i := 0; i < s.MaxTCPConnections; i++ {
-		s.accept <- true
-	}
 
 	if s.ConvertNames {
 		log.Printf("I! WARNING statsd: convert_names config option is deprecated," +
@@ -338,6 +369,22 @@ func (s *Statsd) Start(_ telegraf.Accumulator) error {
 		s.MetricSeparator = defaultSeparator
 	}
 
+	var err error
+	if s.isUDP() {
+		s.UDPlistener, err = s.ListenPacket("statsd", s.Protocol, s.ServiceAddress)
+		if err != nil {
+			return err
+		}
+		log.Println("I! Statsd UDP listener listening on: ", s.UDPlistener.LocalAddr().String())
+	} else {
+		s.TCPlistener, err = s.Listen("statsd", "tcp", s.ServiceAddress, nil)
+		if err != nil {
+			return err
+		}
+		s.TCPlistener.Refuse = s.refuser
+		log.Println("I! TCP Statsd listening on: ", s.TCPlistener.Addr().String())
+	}
+
 	s.wg.Add(2)
 	// Start the UDP listener
 	if s.isUDP() {
@@ -351,68 +398,30 @@ func (s *Statsd) Start(_ telegraf.Accumulator) error {
 	return nil
 }
 
-// tcpListen() starts listening for udp packets on the configured port.
+// tcpListen() accepts the TCP connections on the configured port.
 func (s *Statsd) tcpListen() error {
 	defer s.wg.Done()
-	// Start listener
-	var err error
-	address, _ := net.ResolveTCPAddr("tcp", s.ServiceAddress)
-	s.TCPlistener, err = net.ListenTCP("tcp", address)
-	if err != nil {
-		log.Fatalf("ERROR: ListenTCP - %s", err)
-		return err
-	}
-	log.Println("I! TCP Statsd listening on: ", s.TCPlistener.Addr().String())
 	for {
 		select {
 		case <-s.done:
 			return nil
 		default:
-			// Accept connection:
-			conn, err := s.TCPlistener.AcceptTCP()
+			// Accept connection, the listener refuses the connections over
+			// the limit:
+			conn, err := s.TCPlistener.Accept()
 			if err != nil {
 				return err
 			}
 
-			if s.TCPKeepAlive {
-				if err = conn.SetKeepAlive(true); err != nil {
-					return err
-				}
-
-				if s.TCPKeepAlivePeriod != nil {
-					if err = conn.SetKeepAlivePeriod(s.TCPKeepAlivePeriod.Duration); err != nil {
-						return err
-					}
-				}
-			}
-
-			select {
-			case <-s.accept:
-				// not over connection limit, handle the connection properly.
-				s.wg.Add(1)
-				// generate a random id for this TCPConn
-				id := internal.RandomString(6)
-				s.remember(id, conn)
-				go s.handler(conn, id)
-			default:
-				// We are over the connection limit, refuse & close.
-				s.refuser(conn)
-			}
+			s.wg.Add(1)
+			go s.handler(conn)
 		}
 	}
 }
 
-// udpListen starts listening for udp packets on the configured port.
+// udpListen reads the udp packets on the configured port.
 func (s *Statsd) udpListen() error {
 	defer s.wg.Done()
-	var err error
-	address, _ := net.ResolveUDPAddr(s.Protocol, s.ServiceAddress)
-	s.UDPlistener, err = net.ListenUDP(s.Protocol, address)
-	if err != nil {
-		log.Fatalf("ERROR: ListenUDP - %s", err)
-	}
-	log.Println("I! Statsd UDP listener listening on: ", s.UDPlistener.LocalAddr().String())
-
 	if s.ReadBufferSize > 0 {
 		s.UDPlistener.SetReadBuffer(s.ReadBufferSize)
 	}
@@ -423,7 +432,7 @@ func (s *Statsd) udpListen() error {
 		case <-s.done:
 			return nil
 		default:
-			n, _, err := s.UDPlistener.ReadFromUDP(buf)
+			n, addr, err := s.UDPlistener.ReadFrom(buf)
 			if err != nil && !strings.Contains(err.Error(), "closed network") {
 				log.Printf("E! Error READ: %s\n", err.Error())
 				continue
@@ -433,7 +442,7 @@ func (s *Statsd) udpListen() error {
 			b.Write(buf[:n])
 
 			select {
-			case s.in <- b:
+			case s.in <- input{buf: b, addr: listener.Address(addr)}:
 			default:
 				s.drops++
 				if s.drops == 1 || s.AllowedPendingMessages == 0 || s.drops%s.AllowedPendingMessages == 0 {
@@ -445,7 +454,7 @@ func (s *Statsd) udpListen() error {
 }
 
 // parser monitors the s.in channel, if there is a packet ready, it parses the
-// packet into statsd strings and then calls parseStatsdLine, which parses a
+// packet into statsd strings and then calls parseStatsdLineFrom, which parses a
 // single statsd metric into a struct.
 func (s *Statsd) parser() error {
 	defer s.wg.Done()
@@ -453,13 +462,13 @@ func (s *Statsd) parser() error {
 		select {
 		case <-s.done:
 			return nil
-		case buf := <-s.in:
-			lines := strings.Split(buf.String(), "\n")
-			s.bufPool.Put(buf)
+		case in := <-s.in:
+			lines := strings.Split(in.buf.String(), "\n")
+			s.bufPool.Put(in.buf)
 			for _, line := range lines {
 				line = strings.TrimSpace(line)
 				if line != "" {
-					s.parseStatsdLine(line)
+					s.parseStatsdLineFrom(line, in.addr)
 				}
 			}
 		}
@@ -469,6 +478,11 @@ func (s *Statsd) parser() error {
 // parseStatsdLine will parse the given statsd line, validating it as it goes.
 // If the line is valid, it will be cached for the next call to Gather()
 func (s *Statsd) parseStatsdLine(line string) error {
+	return s.parseStatsdLineFrom(line, "")
+}
+
+// parseStatsdLineFrom parses a statsd line received from the client at addr.
+func (s *Statsd) parseStatsdLineFrom(line, addr string) error {
 	s.Lock()
 	defer s.Unlock()
 
@@ -613,6 +627,7 @@ func (s *Statsd) parseStatsdLine(line string) error {
 				m.tags[k] = v
 			}
 		}
+		s.AddClientTag(m.tags, addr)
 
 		// Make a unique key for the measurement name/tags
 		var tg []string
@@ -780,19 +795,17 @@ func (s *Statsd) aggregate(m metric) {
 }
 
 // handler handles a single TCP Connection
-func (s *Statsd) handler(conn *net.TCPConn, id string) {
+func (s *Statsd) handler(conn net.Conn) {
 	s.CurrentConnections.Incr(1)
 	s.TotalConnections.Incr(1)
 	// connection cleanup function
 	defer func() {
 		s.wg.Done()
 		conn.Close()
-		// Add one connection potential back to channel when this one closes
-		s.accept <- true
-		s.forget(id)
 		s.CurrentConnections.Incr(-1)
 	}()
 
+	addr := listener.Address(conn.RemoteAddr())
 	var n int
 	scanner := bufio.NewScanner(conn)
 	for {
@@ -816,7 +829,7 @@ func (s *Statsd) handler(conn *net.TCPConn, id string) {
 			b.WriteByte('\n')
 
 			select {
-			case s.in <- b:
+			case s.in <- input{buf: b, addr: addr}:
 			default:
 				s.drops++
 				if s.drops == 1 || s.drops%s.AllowedPendingMessages == 0 {
@@ -827,28 +840,13 @@ func (s *Statsd) handler(conn *net.TCPConn, id string) {
 	}
 }
 
-// refuser refuses a TCP connection
-func (s *Statsd) refuser(conn *net.TCPConn) {
-	conn.Close()
+// refuser logs the refusal of a TCP connection
+func (s *Statsd) refuser(conn net.Conn) {
 	log.Printf("I! Refused TCP Connection from %s", conn.RemoteAddr())
 	log.Printf("I! WARNING: Maximum TCP Connections reached, you may want to" +
 		" adjust max_tcp_connections")
 }
 
-// forget a TCP connection
-func (s *Statsd) forget(id string) {
-	s.cleanup.Lock()
-	defer s.cleanup.Unlock()
-	delete(s.conns, id)
-}
-
-// remember a TCP connection
-func (s *Statsd) remember(id string, conn *net.TCPConn) {
-	s.cleanup.Lock()
-	defer s.cleanup.Unlock()
-	s.conns[id] = conn
-}
-
 func (s *Statsd) Stop() {
 	s.Lock()
 	log.Println("I! Stopping the statsd service")
@@ -856,20 +854,8 @@ func (s *Statsd) Stop() {
 	if s.isUDP() {
 		s.UDPlistener.Close()
 	} else {
+		// closes all open TCP connections along with the listener
 		s.TCPlistener.Close()
-		// Close all open TCP connections
-		//  - get all conns from the s.conns map and put into slice
-		//  - this is so the forget() function doesnt conflict with looping
-		//    over the s.conns map
-		var conns []*net.TCPConn
-		s.cleanup.Lock()
-		for _, conn := range s.conns {
-			conns = append(conns, conn)
-		}
-		s.cleanup.Unlock()
-		for _, conn := range conns {
-			conn.Close()
-		}
 	}
 	s.Unlock()
 
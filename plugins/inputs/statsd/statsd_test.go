@@ -1,7 +1,6 @@
 package statsd
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"net"
@@ -17,8 +16,8 @@ const (
 	testMsg = "test.tcp.msg:100|c"
 )
 
-func newTestTcpListener() (*Statsd, chan *bytes.Buffer) {
-	in := make(chan *bytes.Buffer, 1500)
+func newTestTcpListener() (*Statsd, chan input) {
+	in := make(chan input, 1500)
 	listener := &Statsd{
 		Protocol:               "tcp",
 		ServiceAddress:         "localhost:8125",
@@ -35,7 +34,7 @@ func NewTestStatsd() *Statsd {
 
 	// Make data structures
 	s.done = make(chan struct{})
-	s.in = make(chan *bytes.Buffer, s.AllowedPendingMessages)
+	s.in = make(chan input, s.AllowedPendingMessages)
 	s.gauges = make(map[string]cachedgauge)
 	s.counters = make(map[string]cachedcounter)
 	s.sets = make(map[string]cachedset)
@@ -918,6 +917,30 @@ func TestParse_DataDogTags(t *testing.T) {
 	}
 }
 
+func TestParse_ClientAddressTag(t *testing.T) {
+	s := NewTestStatsd()
+	s.ClientAddressTag = "source"
+
+	lines := []string{
+		"my_counter:1|c",
+		"my_counter:2|c",
+	}
+	for i, line := range lines {
+		err := s.parseStatsdLineFrom(line, fmt.Sprintf("192.0.2.%d:8125", i+1))
+		if err != nil {
+			t.Errorf("Parsing line %s should not have resulted in an error\n", line)
+		}
+	}
+
+	// the counters of each client are aggregated separately
+	assert.Len(t, s.counters, 2)
+	sources := map[string]bool{}
+	for _, c := range s.counters {
+		sources[c.tags["source"]] = true
+	}
+	assert.Equal(t, map[string]bool{"192.0.2.1": true, "192.0.2.2": true}, sources)
+}
+
 func tagsForItem(m interface{}) map[string]string {
 	switch m.(type) {
 	case map[string]cachedcounter:
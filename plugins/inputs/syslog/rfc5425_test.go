@@ -348,7 +348,7 @@ func getTestCasesForRFC5425() []testCase5425 {
 
 func newTCPSyslogReceiver(address string, keepAlive *internal.Duration, maxConn int, bestEffort bool) *Syslog {
 	d := &internal.Duration{
-		Duration: defaultReadTimeout,
+		Duration: defaultIdleTimeout,
 	}
 	s := &Syslog{
 		Address: address,
@@ -444,3 +444,25 @@ func TestTimeIncrement_udp(t *testing.T) {
 		t.Fatalf("Got (+) / Want (-)\n %s", cmp.Diff(want, acc.Metrics[0]))
 	}
 }
+
+func TestClientAddressTag_udp(t *testing.T) {
+	receiver := newUDPSyslogReceiver("udp://"+address, false)
+	receiver.ClientAddressTag = "source"
+	acc := &testutil.Accumulator{}
+	require.NoError(t, receiver.Start(acc))
+	defer receiver.Stop()
+
+	conn, err := net.Dial("udp", address)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<1>1 - - - - - -"))
+	require.NoError(t, err)
+
+	acc.Wait(1)
+	require.Equal(t, map[string]string{
+		"severity": "alert",
+		"facility": "kern",
+		"source":   "127.0.0.1",
+	}, acc.Metrics[0].Tags)
+}
@@ -1,7 +1,6 @@
 package syslog
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -16,22 +15,22 @@ import (
 	"github.com/influxdata/go-syslog/rfc5425"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	tlsConfig "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
-const defaultReadTimeout = time.Second * 5
+const defaultIdleTimeout = time.Second * 5
 const ipMaxPacketSize = 64 * 1024
 
 // Syslog is a syslog plugin
 type Syslog struct {
 	tlsConfig.ServerConfig
-	Address         string `toml:"server"`
-	KeepAlivePeriod *internal.Duration
-	ReadTimeout     *internal.Duration
-	MaxConnections  int
-	BestEffort      bool
-	Separator       string `toml:"sdparam_separator"`
+	listener.Config
+	Address     string             `toml:"server"`
+	ReadTimeout *internal.Duration // Deprecated in 1.9; use IdleTimeout
+	BestEffort  bool
+	Separator   string `toml:"sdparam_separator"`
 
 	now      func() time.Time
 	lastTime time.Time
@@ -40,12 +39,8 @@ type Syslog struct {
 	wg sync.WaitGroup
 	io.Closer
 
-	isStream      bool
-	tcpListener   net.Listener
-	tlsConfig     *tls.Config
-	connections   map[string]net.Conn
-	connectionsMu sync.Mutex
-
+	isStream    bool
+	tcpListener net.Listener
 	udpListener net.PacketConn
 }
 
@@ -60,6 +55,8 @@ var sampleConfig = `
   # tls_allowed_cacerts = ["/etc/telegraf/ca.pem"]
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
+  ## Common names of the client certificates allowed to connect.
+  # tls_allowed_cns = ["client.example.org"]
 
   ## Period between keep alive probes.
   ## 0 disables keep alive probes.
@@ -72,9 +69,28 @@ var sampleConfig = `
   ## Only applies to stream sockets (e.g. TCP).
   # max_connections = 1024
 
-  ## Read timeout is the maximum time allowed for reading a single message (default = 5s).
+  ## Maximum duration a connection may stay idle before being closed (default = 5s).
+  ## 0 means unlimited.
+  ## Only applies to stream sockets (e.g. TCP).
+  # idle_timeout = "5s"
+
+  ## Maximum number of connections, or of messages for datagram sockets, per
+  ## second and client address (default = 0).
   ## 0 means unlimited.
-  # read_timeout = "5s"
+  # rate_limit = 0.0
+
+  ## Read the PROXY protocol header sent by a load balancer in front of the
+  ## receiver, to recover the address of the client (default = false).
+  ## Datagram sockets only support version 2 of the protocol.
+  # proxy_protocol = false
+
+  ## Addresses or CIDR networks of the load balancers trusted to send the
+  ## PROXY protocol header. Other clients are handled as direct connections,
+  ## without reading a header. If empty, the header is read from all clients.
+  # proxy_protocol_trusted = ["10.0.0.0/8"]
+
+  ## Name of the tag holding the address of the client, not added if empty.
+  # client_address_tag = ""
 
   ## Whether to parse in best effort mode or not (default = false).
   ## By default best effort parsing is off.
@@ -127,22 +143,24 @@ func (s *Syslog) Start(acc telegraf.Accumulator) error {
 		os.Remove(s.Address)
 	}
 
+	s.SetReadTimeout(s.ReadTimeout)
+
 	if s.isStream {
-		l, err := net.Listen(scheme, s.Address)
+		tlsConf, err := s.TLSConfig()
 		if err != nil {
 			return err
 		}
-		s.Closer = l
-		s.tcpListener = l
-		s.tlsConfig, err = s.TLSConfig()
+		l, err := s.Listen("syslog", scheme, s.Address, tlsConf)
 		if err != nil {
 			return err
 		}
+		s.Closer = l
+		s.tcpListener = l
 
 		s.wg.Add(1)
 		go s.listenStream(acc)
 	} else {
-		l, err := net.ListenPacket(scheme, s.Address)
+		l, err := s.ListenPacket("syslog", scheme, s.Address)
 		if err != nil {
 			return err
 		}
@@ -205,7 +223,7 @@ func (s *Syslog) listenPacket(acc telegraf.Accumulator) {
 	b := make([]byte, ipMaxPacketSize)
 	p := rfc5424.NewParser()
 	for {
-		n, _, err := s.udpListener.ReadFrom(b)
+		n, addr, err := s.udpListener.ReadFrom(b)
 		if err != nil {
 			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
 				acc.AddError(err)
@@ -215,7 +233,7 @@ func (s *Syslog) listenPacket(acc telegraf.Accumulator) {
 
 		message, err := p.Parse(b[:n], &s.BestEffort)
 		if message != nil {
-			acc.AddFields("syslog", fields(*message, s), tags(*message), s.time())
+			acc.AddFields("syslog", fields(*message, s), s.tags(*message, listener.Address(addr)), s.time())
 		}
 		if err != nil {
 			acc.AddError(err)
@@ -226,8 +244,6 @@ func (s *Syslog) listenPacket(acc telegraf.Accumulator) {
 func (s *Syslog) listenStream(acc telegraf.Accumulator) {
 	defer s.wg.Done()
 
-	s.connections = map[string]net.Conn{}
-
 	for {
 		conn, err := s.tcpListener.Accept()
 		if err != nil {
@@ -236,45 +252,13 @@ func (s *Syslog) listenStream(acc telegraf.Accumulator) {
 			}
 			break
 		}
-		var tcpConn, _ = conn.(*net.TCPConn)
-		if s.tlsConfig != nil {
-			conn = tls.Server(conn, s.tlsConfig)
-		}
-
-		s.connectionsMu.Lock()
-		if s.MaxConnections > 0 && len(s.connections) >= s.MaxConnections {
-			s.connectionsMu.Unlock()
-			conn.Close()
-			continue
-		}
-		s.connections[conn.RemoteAddr().String()] = conn
-		s.connectionsMu.Unlock()
-
-		if err := s.setKeepAlive(tcpConn); err != nil {
-			acc.AddError(fmt.Errorf("unable to configure keep alive (%s): %s", s.Address, err))
-		}
 
 		go s.handle(conn, acc)
 	}
-
-	s.connectionsMu.Lock()
-	for _, c := range s.connections {
-		c.Close()
-	}
-	s.connectionsMu.Unlock()
-}
-
-func (s *Syslog) removeConnection(c net.Conn) {
-	s.connectionsMu.Lock()
-	delete(s.connections, c.RemoteAddr().String())
-	s.connectionsMu.Unlock()
 }
 
 func (s *Syslog) handle(conn net.Conn, acc telegraf.Accumulator) {
-	defer func() {
-		s.removeConnection(conn)
-		conn.Close()
-	}()
+	defer conn.Close()
 
 	var p *rfc5425.Parser
 
@@ -284,33 +268,13 @@ func (s *Syslog) handle(conn net.Conn, acc telegraf.Accumulator) {
 		p = rfc5425.NewParser(conn)
 	}
 
-	if s.ReadTimeout != nil && s.ReadTimeout.Duration > 0 {
-		conn.SetReadDeadline(time.Now().Add(s.ReadTimeout.Duration))
-	}
-
+	addr := listener.Address(conn.RemoteAddr())
 	p.ParseExecuting(func(r *rfc5425.Result) {
-		s.store(*r, acc)
-		if s.ReadTimeout != nil && s.ReadTimeout.Duration > 0 {
-			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout.Duration))
-		}
+		s.store(*r, addr, acc)
 	})
 }
 
-func (s *Syslog) setKeepAlive(c *net.TCPConn) error {
-	if s.KeepAlivePeriod == nil {
-		return nil
-	}
-
-	if s.KeepAlivePeriod.Duration == 0 {
-		return c.SetKeepAlive(false)
-	}
-	if err := c.SetKeepAlive(true); err != nil {
-		return err
-	}
-	return c.SetKeepAlivePeriod(s.KeepAlivePeriod.Duration)
-}
-
-func (s *Syslog) store(res rfc5425.Result, acc telegraf.Accumulator) {
+func (s *Syslog) store(res rfc5425.Result, addr string, acc telegraf.Accumulator) {
 	if res.Error != nil {
 		acc.AddError(res.Error)
 	}
@@ -319,12 +283,13 @@ func (s *Syslog) store(res rfc5425.Result, acc telegraf.Accumulator) {
 	}
 	if res.Message != nil {
 		msg := *res.Message
-		acc.AddFields("syslog", fields(msg, s), tags(msg), s.time())
+		acc.AddFields("syslog", fields(msg, s), s.tags(msg, addr), s.time())
 	}
 }
 
-func tags(msg rfc5424.SyslogMessage) map[string]string {
+func (s *Syslog) tags(msg rfc5424.SyslogMessage, addr string) map[string]string {
 	ts := map[string]string{}
+	s.AddClientTag(ts, addr)
 
 	// Not checking assuming a minimally valid message
 	ts["severity"] = *msg.SeverityShortLevel()
@@ -412,8 +377,10 @@ func init() {
 	receiver := &Syslog{
 		Address: ":6514",
 		now:     getNanoNow,
-		ReadTimeout: &internal.Duration{
-			Duration: defaultReadTimeout,
+		Config: listener.Config{
+			IdleTimeout: internal.Duration{
+				Duration: defaultIdleTimeout,
+			},
 		},
 		Separator: "_",
 	}
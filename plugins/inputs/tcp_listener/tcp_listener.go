@@ -8,7 +8,7 @@ import (
 	"sync"
 
 	"github.com/influxdata/telegraf"
-	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/listener"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/selfstat"
@@ -18,32 +18,25 @@ type TcpListener struct {
 	ServiceAddress         string
 	AllowedPendingMessages int
 	MaxTCPConnections      int `toml:"max_tcp_connections"`
+	listener.Config
 
 	sync.Mutex
-	// Lock for preventing a data race during resource cleanup
-	cleanup sync.Mutex
-	wg      sync.WaitGroup
+	wg sync.WaitGroup
 
 	in   chan []byte
 	done chan struct{}
-	// accept channel tracks how many active connections there are, if there
-	// is an available bool in accept, then we are below the maximum and can
-	// accept the connection
-	accept chan bool
 	// drops tracks the number of dropped metrics.
 	drops int
 	// malformed tracks the number of malformed packets
 	malformed int
 
-	// track the listener here so we can close it in Stop()
-	listener *net.TCPListener
-	// track current connections so we can close them in Stop()
-	conns map[string]*net.TCPConn
+	// track the listener here so we can close it and its connections in Stop()
+	listener *listener.Listener
 
 	parser parsers.Parser
 	acc    telegraf.Accumulator
 
-	MaxConnections     selfstat.Stat
+	MaxConnectionsStat selfstat.Stat
 	CurrentConnections selfstat.Stat
 	TotalConnections   selfstat.Stat
 	PacketsRecv        selfstat.Stat
@@ -93,8 +86,11 @@ func (t *TcpListener) Start(acc telegraf.Accumulator) error {
 	tags := map[string]string{
 		"address": t.ServiceAddress,
 	}
-	t.MaxConnections = selfstat.Register("tcp_listener", "max_connections", tags)
-	t.MaxConnections.Set(int64(t.MaxTCPConnections))
+	t.MaxConnectionsStat = selfstat.Register("tcp_listener", "max_connections", tags)
+	if t.MaxConnections == 0 {
+		t.MaxConnections = t.MaxTCPConnections
+	}
+	t.MaxConnectionsStat.Set(int64(t.MaxConnections))
 	t.CurrentConnections = selfstat.Register("tcp_listener", "current_connections", tags)
 	t.TotalConnections = selfstat.Register("tcp_listener", "total_connections", tags)
 	t.PacketsRecv = selfstat.Register("tcp_listener", "packets_received", tags)
@@ -103,20 +99,15 @@ func (t *TcpListener) Start(acc telegraf.Accumulator) error {
 	t.acc = acc
 	t.in = make(chan []byte, t.AllowedPendingMessages)
 	t.done = make(chan struct{})
-	t.accept = make(chan bool, t.MaxTCPConnections)
-	t.conns = make(map[string]*net.TCPConn)
-	for i := 0; i < t.MaxTCPConnections; i++ {
-		t.accept <- true
-	}
 
 	// Start listener
 	var err error
-	address, _ := net.ResolveTCPAddr("tcp", t.ServiceAddress)
-	t.listener, err = net.ListenTCP("tcp", address)
+	t.listener, err = t.Listen("tcp_listener", "tcp", t.ServiceAddress, nil)
 	if err != nil {
 		log.Fatalf("ERROR: ListenUDP - %s", err)
 		return err
 	}
+	t.listener.Refuse = t.refuser
 	log.Println("I! TCP server listening on: ", t.listener.Addr().String())
 
 	t.wg.Add(2)
@@ -132,22 +123,9 @@ func (t *TcpListener) Stop() {
 	t.Lock()
 	defer t.Unlock()
 	close(t.done)
+	// closes all open TCP connections along with the listener
 	t.listener.Close()
 
-	// Close all open TCP connections
-	//  - get all conns from the t.conns map and put into slice
-	//  - this is so the forget() function doesnt conflict with looping
-	//    over the t.conns map
-	var conns []*net.TCPConn
-	t.cleanup.Lock()
-	for _, conn := range t.conns {
-		conns = append(conns, conn)
-	}
-	t.cleanup.Unlock()
-	for _, conn := range conns {
-		conn.Close()
-	}
-
 	t.wg.Wait()
 	close(t.in)
 	log.Println("I! Stopped TCP listener service on ", t.ServiceAddress)
@@ -162,51 +140,37 @@ func (t *TcpListener) tcpListen() error {
 		case <-t.done:
 			return nil
 		default:
-			// Accept connection:
-			conn, err := t.listener.AcceptTCP()
+			// Accept connection, the listener refuses the connections over
+			// the limit:
+			conn, err := t.listener.Accept()
 			if err != nil {
 				return err
 			}
 
-			select {
-			case <-t.accept:
-				// not over connection limit, handle the connection properly.
-				t.wg.Add(1)
-				// generate a random id for this TCPConn
-				id := internal.RandomString(6)
-				t.remember(id, conn)
-				go t.handler(conn, id)
-			default:
-				// We are over the connection limit, refuse & close.
-				t.refuser(conn)
-			}
+			t.wg.Add(1)
+			go t.handler(conn)
 		}
 	}
 }
 
-// refuser refuses a TCP connection
-func (t *TcpListener) refuser(conn *net.TCPConn) {
-	// Tell the connection why we are closing.
+// refuser tells a refused TCP connection why it is being closed
+func (t *TcpListener) refuser(conn net.Conn) {
 	fmt.Fprintf(conn, "Telegraf maximum concurrent TCP connections (%d)"+
 		" reached, closing.\nYou may want to increase max_tcp_connections in"+
-		" the Telegraf tcp listener configuration.\n", t.MaxTCPConnections)
-	conn.Close()
+		" the Telegraf tcp listener configuration.\n", t.MaxConnections)
 	log.Printf("I! Refused TCP Connection from %s", conn.RemoteAddr())
 	log.Printf("I! WARNING: Maximum TCP Connections reached, you may want to" +
 		" adjust max_tcp_connections")
 }
 
 // handler handles a single TCP Connection
-func (t *TcpListener) handler(conn *net.TCPConn, id string) {
+func (t *TcpListener) handler(conn net.Conn) {
 	t.CurrentConnections.Incr(1)
 	t.TotalConnections.Incr(1)
 	// connection cleanup function
 	defer func() {
 		t.wg.Done()
 		conn.Close()
-		// Add one connection potential back to channel when this one closes
-		t.accept <- true
-		t.forget(id)
 		t.CurrentConnections.Incr(-1)
 	}()
 
@@ -275,20 +239,6 @@ func (t *TcpListener) tcpParser() error {
 	}
 }
 
-// forget a TCP connection
-func (t *TcpListener) forget(id string) {
-	t.cleanup.Lock()
-	defer t.cleanup.Unlock()
-	delete(t.conns, id)
-}
-
-// remember a TCP connection
-func (t *TcpListener) remember(id string, conn *net.TCPConn) {
-	t.cleanup.Lock()
-	defer t.cleanup.Unlock()
-	t.conns[id] = conn
-}
-
 func init() {
 	inputs.Add("tcp_listener", func() telegraf.Input {
 		return &TcpListener{
@@ -0,0 +1,204 @@
+package x509_cert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// starttlsPorts are the default ports of the STARTTLS protocols.
+var starttlsPorts = map[string]string{
+	"smtp":     "25",
+	"imap":     "143",
+	"postgres": "5432",
+	"ldap":     "389",
+}
+
+// starttls asks the server of a protocol to switch the connection to TLS, so
+// that the handshake may follow.
+func starttls(protocol string, conn net.Conn) error {
+	switch protocol {
+	case "smtp":
+		return starttlsSMTP(conn)
+	case "imap":
+		return starttlsIMAP(conn)
+	case "postgres":
+		return starttlsPostgres(conn)
+	case "ldap":
+		return starttlsLDAP(conn)
+	}
+	return fmt.Errorf("unsupported protocol %s", protocol)
+}
+
+// readSMTPReply reads a possibly multi-line SMTP reply and checks its code.
+func readSMTPReply(r *bufio.Reader, code string) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, code) {
+			return fmt.Errorf("unexpected reply %q", strings.TrimSpace(line))
+		}
+		// the last line of a reply has a space after the code
+		if len(line) <= len(code) || line[len(code)] != '-' {
+			return nil
+		}
+	}
+}
+
+func starttlsSMTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if err := readSMTPReply(r, "220"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "EHLO telegraf\r\n"); err != nil {
+		return err
+	}
+	if err := readSMTPReply(r, "250"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	return readSMTPReply(r, "220")
+}
+
+func starttlsIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+
+	if _, err := io.WriteString(conn, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		// skip the untagged responses
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a1 OK") {
+			return fmt.Errorf("unexpected reply %q", strings.TrimSpace(line))
+		}
+		return nil
+	}
+}
+
+// postgresSSLRequest is the code of the message asking a PostgreSQL server
+// for SSL.
+const postgresSSLRequest = 80877103
+
+func starttlsPostgres(conn net.Conn) error {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint32(msg[0:4], 8)
+	binary.BigEndian.PutUint32(msg[4:8], postgresSSLRequest)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 'S' {
+		return fmt.Errorf("server does not support SSL")
+	}
+	return nil
+}
+
+// ldapStartTLSRequest is the BER encoding of the LDAP message with ID 1
+// requesting the StartTLS extended operation, 1.3.6.1.4.1.1466.20037.
+var ldapStartTLSRequest = append(
+	[]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16},
+	"1.3.6.1.4.1.1466.20037"...)
+
+func starttlsLDAP(conn net.Conn) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	// the reply is a sequence of the message ID and the extended response,
+	// which starts with the result code
+	msg, err := readBER(conn, 0x30)
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(msg)
+	if _, err := readBER(r, 0x02); err != nil {
+		return err
+	}
+	if _, err := readBERHeader(r, 0x78); err != nil {
+		return err
+	}
+	code, err := readBER(r, 0x0a)
+	if err != nil {
+		return err
+	}
+	if len(code) != 1 || code[0] != 0 {
+		return fmt.Errorf("StartTLS operation failed with result code %v", code)
+	}
+	return nil
+}
+
+// maxBERLength is the length of the largest LDAP reply read.
+const maxBERLength = 1 << 16
+
+// readBERHeader reads the tag and length of a BER element and returns its
+// length.
+func readBERHeader(r io.Reader, tag byte) (int, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if header[0] != tag {
+		return 0, fmt.Errorf("unexpected BER tag 0x%x, expected 0x%x", header[0], tag)
+	}
+
+	length := int(header[1])
+	if length&0x80 == 0 {
+		return length, nil
+	}
+	// long form, the low bits are the number of bytes of the length
+	size := length & 0x7f
+	if size == 0 || size > 4 {
+		return 0, fmt.Errorf("unsupported BER length")
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	length = 0
+	for _, b := range buf {
+		length = length<<8 | int(b)
+	}
+	if length > maxBERLength {
+		return 0, fmt.Errorf("BER element of %d bytes is too long", length)
+	}
+	return length, nil
+}
+
+// readBER reads a BER element and returns its content.
+func readBER(r io.Reader, tag byte) ([]byte, error) {
+	length, err := readBERHeader(r, tag)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
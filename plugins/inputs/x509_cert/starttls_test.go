@@ -0,0 +1,138 @@
+package x509_cert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectLine(r *bufio.Reader, expected string) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(line) != expected {
+		return fmt.Errorf("unexpected line %q", line)
+	}
+	return nil
+}
+
+func smtpServer(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	io.WriteString(conn, "220-mail.example.org ESMTP\r\n220 ready\r\n")
+	if err := expectLine(r, "EHLO telegraf"); err != nil {
+		return err
+	}
+	io.WriteString(conn, "250-mail.example.org\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+	if err := expectLine(r, "STARTTLS"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(conn, "220 2.0.0 Ready to start TLS\r\n")
+	return err
+}
+
+func imapServer(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	io.WriteString(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+	if err := expectLine(r, "a1 STARTTLS"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(conn, "* BYE not really\r\na1 OK Begin TLS negotiation now\r\n")
+	return err
+}
+
+func postgresServer(reply byte) func(net.Conn) error {
+	return func(conn net.Conn) error {
+		msg := make([]byte, 8)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return err
+		}
+		if !bytes.Equal(msg, []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}) {
+			return fmt.Errorf("unexpected SSLRequest %v", msg)
+		}
+		_, err := conn.Write([]byte{reply})
+		if reply != 'S' {
+			return fmt.Errorf("no SSL")
+		}
+		return err
+	}
+}
+
+func ldapServer(code byte) func(net.Conn) error {
+	return func(conn net.Conn) error {
+		msg := make([]byte, len(ldapStartTLSRequest))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return err
+		}
+		if !bytes.Equal(msg, ldapStartTLSRequest) {
+			return fmt.Errorf("unexpected request %v", msg)
+		}
+		// ExtendedResponse with the result code, empty matchedDN and message
+		_, err := conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, code, 0x04, 0x00, 0x04, 0x00})
+		if code != 0 {
+			return fmt.Errorf("failed")
+		}
+		return err
+	}
+}
+
+func TestGatherStartTLS(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		server func(net.Conn) error
+		error  bool
+	}{
+		{name: "smtp", scheme: "smtp", server: smtpServer},
+		{name: "imap", scheme: "imap", server: imapServer},
+		{name: "postgres", scheme: "postgres", server: postgresServer('S')},
+		{name: "postgres without ssl", scheme: "postgres", server: postgresServer('N'), error: true},
+		{name: "ldap", scheme: "ldap", server: ldapServer(0)},
+		{name: "ldap failure", scheme: "ldap", server: ldapServer(2), error: true},
+		{name: "protocol mismatch", scheme: "imap", server: smtpServer, error: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ln := serveTLS(t, serverTLSConfig(t), test.server)
+			defer ln.Close()
+
+			sc := X509Cert{
+				Sources: []string{test.scheme + "://" + ln.Addr().String()},
+				Timeout: internal.Duration{Duration: 5 * time.Second},
+			}
+			sc.InsecureSkipVerify = true
+
+			acc := testutil.Accumulator{}
+			err := sc.Gather(&acc)
+			if test.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, findMetric(&acc, "server.localdomain"))
+		})
+	}
+}
+
+func TestReadBERLongForm(t *testing.T) {
+	content := bytes.Repeat([]byte{1}, 300)
+	msg := append([]byte{0x04, 0x82, 0x01, 0x2c}, content...)
+
+	read, err := readBER(bytes.NewReader(msg), 0x04)
+	require.NoError(t, err)
+	assert.Equal(t, content, read)
+
+	_, err = readBER(bytes.NewReader(msg), 0x30)
+	require.Error(t, err)
+}
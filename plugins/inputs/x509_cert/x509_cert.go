@@ -10,37 +10,62 @@ import (
 	"io/ioutil"
 	"net"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/globpath"
 	_tls "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	"golang.org/x/crypto/ocsp"
 )
 
 const sampleConfig = `
-  ## List certificate sources
+  ## List certificate sources, which are files, globs of files such as
+  ## "/etc/ssl/certs/*.pem", or network endpoints. STARTTLS is used for the
+  ## smtp://, imap://, postgres:// and ldap:// endpoints.
   sources = ["/etc/ssl/certs/ssl-cert-snakeoil.pem", "tcp://example.org:443"]
 
   ## Timeout for SSL connection
   # timeout = "5s"
 
-  ## Optional TLS Config
+  ## Server name to send in the SNI extension and to verify the certificates
+  ## for, instead of the host of the endpoints
+  # server_name = ""
+
+  ## Optional TLS Config, the certificates are verified against the tls_ca
+  ## bundle, or the system bundle if unset
   # tls_ca = "/etc/telegraf/ca.pem"
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
 
-  ## Use TLS but skip chain & host verification
+  ## Skip the verification of the certificate chains
   # insecure_skip_verify = false
 `
 const description = "Reads metrics from a SSL certificate"
 
 // X509Cert holds the configuration of the plugin.
 type X509Cert struct {
-	Sources []string          `toml:"sources"`
-	Timeout internal.Duration `toml:"timeout"`
+	Sources    []string          `toml:"sources"`
+	Timeout    internal.Duration `toml:"timeout"`
+	ServerName string            `toml:"server_name"`
 	_tls.ClientConfig
+
+	tlsCfg    *tls.Config
+	globpaths map[string]*globpath.GlobPath
+}
+
+// certChain is the chain of certificates read from a source, leaf first.
+type certChain struct {
+	certs []*x509.Certificate
+	// serverName is the name the leaf is verified for, empty for files
+	serverName string
+	// remote is whether the chain was served by an endpoint, along with its
+	// stapled OCSP response if any
+	remote bool
+	ocsp   []byte
 }
 
 // Description returns description of the plugin.
@@ -53,7 +78,7 @@ func (c *X509Cert) SampleConfig() string {
 	return sampleConfig
 }
 
-func (c *X509Cert) getCert(location string, timeout time.Duration) ([]*x509.Certificate, error) {
+func (c *X509Cert) getCert(location string, timeout time.Duration) (*certChain, error) {
 	if strings.HasPrefix(location, "/") {
 		location = "file://" + location
 	}
@@ -70,52 +95,187 @@ func (c *X509Cert) getCert(location string, timeout time.Duration) ([]*x509.Cert
 	case "udp", "udp4", "udp6":
 		fallthrough
 	case "tcp", "tcp4", "tcp6":
-		tlsCfg, err := c.ClientConfig.TLSConfig()
+		ipConn, err := net.DialTimeout(u.Scheme, u.Host, timeout)
 		if err != nil {
 			return nil, err
 		}
+		defer ipConn.Close()
 
-		ipConn, err := net.DialTimeout(u.Scheme, u.Host, timeout)
+		return c.handshake(ipConn, u.Hostname(), timeout)
+	case "smtp", "imap", "postgres", "ldap":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), starttlsPorts[u.Scheme])
+		}
+		ipConn, err := net.DialTimeout("tcp", host, timeout)
 		if err != nil {
 			return nil, err
 		}
 		defer ipConn.Close()
 
-		if tlsCfg == nil {
-			tlsCfg = &tls.Config{}
+		if timeout > 0 {
+			ipConn.SetDeadline(time.Now().Add(timeout))
 		}
-		tlsCfg.ServerName = u.Hostname()
-		conn := tls.Client(ipConn, tlsCfg)
-		defer conn.Close()
-
-		hsErr := conn.Handshake()
-		if hsErr != nil {
-			return nil, hsErr
+		if err := starttls(u.Scheme, ipConn); err != nil {
+			return nil, fmt.Errorf("STARTTLS failed: %s", err)
 		}
-
-		certs := conn.ConnectionState().PeerCertificates
-
-		return certs, nil
+		return c.handshake(ipConn, u.Hostname(), timeout)
 	case "file":
 		content, err := ioutil.ReadFile(u.Path)
 		if err != nil {
 			return nil, err
 		}
 
-		block, _ := pem.Decode(content)
-		if block == nil {
+		chain := &certChain{}
+		for {
+			block, rest := pem.Decode(content)
+			if block == nil {
+				break
+			}
+			content = rest
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			chain.certs = append(chain.certs, cert)
+		}
+		if len(chain.certs) == 0 {
 			return nil, fmt.Errorf("failed to parse certificate PEM")
 		}
 
-		cert, err := x509.ParseCertificate(block.Bytes)
-		if err != nil {
-			return nil, err
+		return chain, nil
+	default:
+		return nil, fmt.Errorf("unsuported scheme '%s' in location %s\n", u.Scheme, location)
+	}
+}
+
+// handshake does the TLS handshake over a connection and returns the
+// certificates served. The chain is not verified by the handshake, so that
+// the certificates of invalid chains are reported too.
+func (c *X509Cert) handshake(ipConn net.Conn, host string, timeout time.Duration) (*certChain, error) {
+	tlsCfg := c.tlsCfg.Clone()
+	tlsCfg.InsecureSkipVerify = true
+	tlsCfg.ServerName = host
+	if c.ServerName != "" {
+		tlsCfg.ServerName = c.ServerName
+	}
+	conn := tls.Client(ipConn, tlsCfg)
+	defer conn.Close()
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	hsErr := conn.Handshake()
+	if hsErr != nil {
+		return nil, hsErr
+	}
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificate served")
+	}
+	return &certChain{
+		certs:      state.PeerCertificates,
+		serverName: tlsCfg.ServerName,
+		remote:     true,
+		ocsp:       state.OCSPResponse,
+	}, nil
+}
+
+// verify verifies a chain against the CA bundle, for the server name of the
+// chain if any.
+func (c *X509Cert) verify(chain *certChain, now time.Time) error {
+	opts := x509.VerifyOptions{
+		DNSName:       chain.serverName,
+		Roots:         c.tlsCfg.RootCAs,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+	}
+	for _, cert := range chain.certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := chain.certs[0].Verify(opts)
+	return err
+}
+
+// chainFields returns the fields about the whole chain, which are added to
+// the metric of its leaf certificate.
+func (c *X509Cert) chainFields(chain *certChain, now time.Time) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	if !c.InsecureSkipVerify {
+		if err := c.verify(chain, now); err != nil {
+			fields["verification"] = "invalid"
+			fields["verification_code"] = 1
+			fields["verification_error"] = err.Error()
+		} else {
+			fields["verification"] = "valid"
+			fields["verification_code"] = 0
 		}
+	}
+
+	if !chain.remote {
+		return fields
+	}
+	if len(chain.ocsp) == 0 {
+		fields["ocsp_stapled"] = 0
+		return fields
+	}
+	fields["ocsp_stapled"] = 1
 
-		return []*x509.Certificate{cert}, nil
+	var issuer *x509.Certificate
+	if len(chain.certs) > 1 {
+		issuer = chain.certs[1]
+	}
+	resp, err := ocsp.ParseResponse(chain.ocsp, issuer)
+	if err != nil {
+		fields["ocsp_status"] = "invalid"
+		return fields
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		fields["ocsp_status"] = "good"
+	case ocsp.Revoked:
+		fields["ocsp_status"] = "revoked"
 	default:
-		return nil, fmt.Errorf("unsuported scheme '%s' in location %s\n", u.Scheme, location)
+		fields["ocsp_status"] = "unknown"
+	}
+	if !resp.NextUpdate.IsZero() {
+		fields["ocsp_next_update"] = resp.NextUpdate.Unix()
+	}
+	return fields
+}
+
+// sources returns the sources of a location, which are the files matching it
+// when it is a glob.
+func (c *X509Cert) sources(location string) ([]string, error) {
+	path := strings.TrimPrefix(location, "file://")
+	if !strings.HasPrefix(path, "/") || !strings.ContainsAny(path, "*?[") {
+		return []string{location}, nil
+	}
+
+	g, ok := c.globpaths[path]
+	if !ok {
+		var err error
+		g, err = globpath.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not compile glob %v: %v", path, err)
+		}
+		c.globpaths[path] = g
+	}
+
+	var files []string
+	for file, info := range g.Match() {
+		if info.Mode().IsRegular() {
+			files = append(files, file)
+		}
 	}
+	sort.Strings(files)
+	return files, nil
 }
 
 func getFields(cert *x509.Certificate, now time.Time) map[string]interface{} {
@@ -163,17 +323,43 @@ func getTags(subject pkix.Name, location string) map[string]string {
 func (c *X509Cert) Gather(acc telegraf.Accumulator) error {
 	now := time.Now()
 
-	for _, location := range c.Sources {
-		certs, err := c.getCert(location, c.Timeout.Duration*time.Second)
+	if c.tlsCfg == nil {
+		tlsCfg, err := c.ClientConfig.TLSConfig()
 		if err != nil {
-			return fmt.Errorf("cannot get SSL cert '%s': %s", location, err.Error())
+			return err
 		}
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{}
+		}
+		c.tlsCfg = tlsCfg
+	}
+	if c.globpaths == nil {
+		c.globpaths = make(map[string]*globpath.GlobPath)
+	}
 
-		for _, cert := range certs {
-			fields := getFields(cert, now)
-			tags := getTags(cert.Subject, location)
+	for _, location := range c.Sources {
+		sources, err := c.sources(location)
+		if err != nil {
+			return err
+		}
 
-			acc.AddFields("x509_cert", fields, tags)
+		for _, source := range sources {
+			chain, err := c.getCert(source, c.Timeout.Duration)
+			if err != nil {
+				return fmt.Errorf("cannot get SSL cert '%s': %s", source, err.Error())
+			}
+
+			for i, cert := range chain.certs {
+				fields := getFields(cert, now)
+				tags := getTags(cert.Subject, source)
+				if i == 0 {
+					for k, v := range c.chainFields(chain, now) {
+						fields[k] = v
+					}
+				}
+
+				acc.AddFields("x509_cert", fields, tags)
+			}
 		}
 	}
 
@@ -184,7 +370,7 @@ func init() {
 	inputs.Add("x509_cert", func() telegraf.Input {
 		return &X509Cert{
 			Sources: []string{},
-			Timeout: internal.Duration{Duration: 5},
+			Timeout: internal.Duration{Duration: 5 * time.Second},
 		}
 	})
 }
@@ -1,19 +1,24 @@
 package x509_cert
 
 import (
+	"crypto"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
+	"golang.org/x/crypto/ocsp"
 )
 
 var pki = testutil.NewPKI("../../../testutil/pki")
@@ -47,13 +52,13 @@ func TestGatherRemote(t *testing.T) {
 		error   bool
 	}{
 		{name: "wrong port", server: ":99999", error: true},
-		{name: "no server", timeout: 5},
-		{name: "successful https", server: "https://example.org:443", timeout: 5},
-		{name: "successful file", server: "file://" + tmpfile.Name(), timeout: 5},
-		{name: "unsupported scheme", server: "foo://", timeout: 5, error: true},
-		{name: "no certificate", timeout: 5, unset: true, error: true},
+		{name: "no server", timeout: 5 * time.Second},
+		{name: "successful https", server: "https://example.org:443", timeout: 5 * time.Second},
+		{name: "successful file", server: "file://" + tmpfile.Name(), timeout: 5 * time.Second},
+		{name: "unsupported scheme", server: "foo://", timeout: 5 * time.Second, error: true},
+		{name: "no certificate", timeout: 5 * time.Second, unset: true, error: true},
 		{name: "closed connection", close: true, error: true},
-		{name: "no handshake", timeout: 5, noshake: true, error: true},
+		{name: "no handshake", timeout: 5 * time.Second, noshake: true, error: true},
 	}
 
 	pair, err := tls.X509KeyPair([]byte(pki.ReadServerCert()), []byte(pki.ReadServerKey()))
@@ -271,3 +276,173 @@ func TestGatherCert(t *testing.T) {
 
 	assert.True(t, acc.HasMeasurement("x509_cert"))
 }
+
+// serveTLS serves the TLS handshakes of the connections accepted, after a
+// protocol exchange if any.
+func serveTLS(t *testing.T, config *tls.Config, before func(net.Conn) error) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if before != nil {
+					if err := before(conn); err != nil {
+						return
+					}
+				}
+				tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+	return ln
+}
+
+func serverTLSConfig(t *testing.T) *tls.Config {
+	pair, err := tls.X509KeyPair([]byte(pki.ReadServerCert()), []byte(pki.ReadServerKey()))
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{pair}}
+}
+
+func findMetric(acc *testutil.Accumulator, commonName string) *testutil.Metric {
+	for _, m := range acc.Metrics {
+		if m.Tags["common_name"] == commonName {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestGatherVerification(t *testing.T) {
+	config := serverTLSConfig(t)
+	serverNames := make(chan string, 10)
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverNames <- hello.ServerName
+		return nil, nil
+	}
+	ln := serveTLS(t, config, nil)
+	defer ln.Close()
+
+	tests := []struct {
+		name         string
+		serverName   string
+		ca           string
+		verification string
+	}{
+		{name: "valid", serverName: "localhost", ca: pki.CACertPath(), verification: "valid"},
+		{name: "wrong name", serverName: "example.org", ca: pki.CACertPath(), verification: "invalid"},
+		{name: "unknown authority", serverName: "localhost", verification: "invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := X509Cert{
+				Sources:    []string{"tcp://" + ln.Addr().String()},
+				Timeout:    internal.Duration{Duration: 5 * time.Second},
+				ServerName: test.serverName,
+			}
+			sc.TLSCA = test.ca
+
+			acc := testutil.Accumulator{}
+			require.NoError(t, sc.Gather(&acc))
+			assert.Equal(t, test.serverName, <-serverNames)
+
+			m := findMetric(&acc, "server.localdomain")
+			require.NotNil(t, m)
+			assert.Equal(t, test.verification, m.Fields["verification"])
+			assert.Equal(t, 0, m.Fields["ocsp_stapled"])
+			if test.verification == "valid" {
+				assert.Equal(t, 0, m.Fields["verification_code"])
+				assert.NotContains(t, m.Fields, "verification_error")
+			} else {
+				assert.Equal(t, 1, m.Fields["verification_code"])
+				assert.Contains(t, m.Fields, "verification_error")
+			}
+		})
+	}
+}
+
+func TestGatherOCSPStapling(t *testing.T) {
+	caPair, err := tls.LoadX509KeyPair(pki.CACertPath(), "../../../testutil/pki/cakey.pem")
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caPair.Certificate[0])
+	require.NoError(t, err)
+
+	config := serverTLSConfig(t)
+	server, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	nextUpdate := time.Now().Add(time.Hour).Truncate(time.Second)
+	staple, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: server.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   nextUpdate,
+	}, caPair.PrivateKey.(crypto.Signer))
+	require.NoError(t, err)
+	config.Certificates[0].OCSPStaple = staple
+	// serve the issuer so that the response signature is checked
+	config.Certificates[0].Certificate = append(config.Certificates[0].Certificate, ca.Raw)
+
+	ln := serveTLS(t, config, nil)
+	defer ln.Close()
+
+	sc := X509Cert{
+		Sources: []string{"tcp://" + ln.Addr().String()},
+		Timeout: internal.Duration{Duration: 5 * time.Second},
+	}
+	sc.InsecureSkipVerify = true
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, sc.Gather(&acc))
+	require.Len(t, acc.Metrics, 2)
+
+	m := findMetric(&acc, "server.localdomain")
+	require.NotNil(t, m)
+	assert.Equal(t, 1, m.Fields["ocsp_stapled"])
+	assert.Equal(t, "good", m.Fields["ocsp_status"])
+	assert.Equal(t, nextUpdate.Unix(), m.Fields["ocsp_next_update"])
+	assert.NotContains(t, m.Fields, "verification")
+
+	m = findMetric(&acc, ca.Subject.CommonName)
+	require.NotNil(t, m)
+	assert.NotContains(t, m.Fields, "ocsp_stapled")
+}
+
+func TestGatherGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "x509_cert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	chain := pki.ReadServerCert() + pki.ReadCACert()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "chain.pem"), []byte(chain), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.pem"), []byte(pki.ReadCACert()), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.key"), []byte(pki.ReadServerKey()), 0640))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir.pem"), 0750))
+
+	sc := X509Cert{
+		Sources: []string{filepath.Join(dir, "*.pem")},
+	}
+	sc.TLSCA = pki.CACertPath()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, sc.Gather(&acc))
+	require.Len(t, acc.Metrics, 3)
+
+	sources := make(map[string]int)
+	for _, m := range acc.Metrics {
+		sources[m.Tags["source"]]++
+		if m.Tags["common_name"] == "server.localdomain" {
+			assert.Equal(t, "valid", m.Fields["verification"])
+			assert.NotContains(t, m.Fields, "ocsp_stapled")
+		}
+	}
+	assert.Equal(t, map[string]int{
+		filepath.Join(dir, "chain.pem"): 2,
+		filepath.Join(dir, "ca.pem"):    1,
+	}, sources)
+}
@@ -1,7 +1,9 @@
 package elasticsearch
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"net/http"
@@ -29,11 +31,34 @@ type Elasticsearch struct {
 	ManageTemplate      bool
 	TemplateName        string
 	OverwriteTemplate   bool
+	DataStream          bool
+	ForceDocumentID     bool `toml:"force_document_id"`
+	MaxRetries          int
+
+	ManageLifecyclePolicy    bool
+	LifecyclePolicyName      string
+	LifecyclePolicy          string
+	OverwriteLifecyclePolicy bool
 	tls.ClientConfig
 
 	Client *elastic.Client
 }
 
+// retryBackoff is the delay before the first retry of rejected documents,
+// doubled on each following attempt.
+const retryBackoff = 100 * time.Millisecond
+
+const defaultLifecyclePolicy = `{
+  "policy": {
+    "phases": {
+      "delete": {
+        "min_age": "30d",
+        "actions": { "delete": {} }
+      }
+    }
+  }
+}`
+
 var sampleConfig = `
   ## The full HTTP endpoint URL for your Elasticsearch instance
   ## Multiple urls can be specified as part of the same cluster,
@@ -68,6 +93,20 @@ var sampleConfig = `
   # default_tag_value = "none"
   index_name = "telegraf-%Y.%m.%d" # required.
 
+  ## Write to a data stream instead of an index, requires Elasticsearch 7.9
+  ## or later. The index_name is used as the data stream name; date
+  ## specifiers are not allowed since rollover is handled by Elasticsearch.
+  # data_stream = false
+
+  ## Set the document ID from the series hash and the metric timestamp, so
+  ## sending the same metric again does not create a duplicate document.
+  # force_document_id = false
+
+  ## Maximum number of times documents rejected with 429 Too Many Requests
+  ## or a 5xx server error are resent. Only the rejected documents of a bulk
+  ## request are resent.
+  # max_retries = 3
+
   ## Optional TLS Config
   # tls_ca = "/etc/telegraf/ca.pem"
   # tls_cert = "/etc/telegraf/cert.pem"
@@ -83,6 +122,21 @@ var sampleConfig = `
   template_name = "telegraf"
   ## Set to true if you want telegraf to overwrite an existing template
   overwrite_template = false
+
+  ## Lifecycle Policy Config
+  ## Set to true if you want telegraf to manage an index lifecycle policy,
+  ## requires Elasticsearch 6.6 or later. The policy is referenced from the
+  ## template settings when manage_template is also enabled.
+  # manage_lifecycle_policy = false
+  ## The lifecycle policy name
+  # lifecycle_policy_name = "telegraf"
+  ## The lifecycle policy body in JSON, by default indexes are deleted after
+  ## 30 days.
+  # lifecycle_policy = '''
+  # {"policy": {"phases": {"delete": {"min_age": "30d", "actions": {"delete": {}}}}}}
+  # '''
+  ## Set to true if you want telegraf to overwrite an existing policy
+  # overwrite_lifecycle_policy = false
 `
 
 func (a *Elasticsearch) Connect() error {
@@ -90,6 +144,10 @@ func (a *Elasticsearch) Connect() error {
 		return fmt.Errorf("Elasticsearch urls or index_name is not defined")
 	}
 
+	if a.DataStream && strings.Contains(a.IndexName, "%") {
+		return fmt.Errorf("Elasticsearch data stream name cannot contain date specifiers")
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), a.Timeout.Duration)
 	defer cancel()
 
@@ -142,15 +200,30 @@ func (a *Elasticsearch) Connect() error {
 	}
 
 	// quit if ES version is not supported
-	i, err := strconv.Atoi(strings.Split(esVersion, ".")[0])
-	if err != nil || i < 5 {
+	major, minor, err := parseVersion(esVersion)
+	if err != nil || major < 5 {
 		return fmt.Errorf("Elasticsearch version not supported: %s", esVersion)
 	}
 
 	log.Println("I! Elasticsearch version: " + esVersion)
 
+	if a.DataStream && !versionAtLeast(major, minor, 7, 9) {
+		return fmt.Errorf("Elasticsearch data streams are not supported by version %s", esVersion)
+	}
+
+	if a.ManageLifecyclePolicy && !versionAtLeast(major, minor, 6, 6) {
+		return fmt.Errorf("Elasticsearch lifecycle policies are not supported by version %s", esVersion)
+	}
+
 	a.Client = client
 
+	if a.ManageLifecyclePolicy {
+		err := a.manageLifecyclePolicy(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	if a.ManageTemplate {
 		err := a.manageTemplate(ctx)
 		if err != nil {
@@ -163,12 +236,34 @@ func (a *Elasticsearch) Connect() error {
 	return nil
 }
 
+// parseVersion returns the major and minor numbers of an Elasticsearch
+// version such as "7.10.2".
+func parseVersion(version string) (int, int, error) {
+	parts := strings.SplitN(version, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) < 2 {
+		return major, 0, nil
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
+
+func versionAtLeast(major, minor, wantMajor, wantMinor int) bool {
+	return major > wantMajor || (major == wantMajor && minor >= wantMinor)
+}
+
 func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
 
-	bulkRequest := a.Client.Bulk()
+	requests := make([]elastic.BulkableRequest, 0, len(metrics))
 
 	for _, metric := range metrics {
 		var name = metric.Name()
@@ -184,31 +279,100 @@ func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 		m["tag"] = metric.Tags()
 		m[name] = metric.Fields()
 
-		bulkRequest.Add(elastic.NewBulkIndexRequest().
+		br := elastic.NewBulkIndexRequest().
 			Index(indexName).
-			Type("metrics").
-			Doc(m))
+			Doc(m)
+
+		if a.DataStream {
+			// data streams only accept create operations without a type
+			br.OpType("create")
+		} else {
+			br.Type("metrics")
+		}
+
+		if a.ForceDocumentID {
+			br.Id(GetPointID(metric))
+		}
 
+		requests = append(requests, br)
 	}
 
+	for attempt := 0; ; attempt++ {
+		rejected, err := a.bulk(requests)
+		if err != nil {
+			return err
+		}
+
+		if len(rejected) == 0 {
+			return nil
+		}
+
+		if attempt >= a.MaxRetries {
+			return fmt.Errorf("Elasticsearch rejected %d metrics after %d retries", len(rejected), attempt)
+		}
+
+		log.Printf("W! Elasticsearch rejected %d metrics, retrying", len(rejected))
+		time.Sleep(retryBackoff << uint(attempt))
+		requests = rejected
+	}
+}
+
+// bulk sends the requests in a single bulk request and returns the requests
+// that failed with 429 Too Many Requests or a 5xx server error and can be
+// retried.  Items failing for any other reason are logged and dropped, as
+// resending them would fail again.
+func (a *Elasticsearch) bulk(requests []elastic.BulkableRequest) ([]elastic.BulkableRequest, error) {
+	bulkRequest := a.Client.Bulk()
+	bulkRequest.Add(requests...)
+
 	ctx, cancel := context.WithTimeout(context.Background(), a.Timeout.Duration)
 	defer cancel()
 
 	res, err := bulkRequest.Do(ctx)
 
 	if err != nil {
-		return fmt.Errorf("Error sending bulk request to Elasticsearch: %s", err)
+		return nil, fmt.Errorf("Error sending bulk request to Elasticsearch: %s", err)
 	}
 
-	if res.Errors {
-		for id, err := range res.Failed() {
-			log.Printf("E! Elasticsearch indexing failure, id: %d, error: %s, caused by: %s, %s", id, err.Error.Reason, err.Error.CausedBy["reason"], err.Error.CausedBy["type"])
+	if !res.Errors {
+		return nil, nil
+	}
+
+	var rejected []elastic.BulkableRequest
+
+	// response items are in the same order as the requests
+	for id, item := range res.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status <= 299 {
+				continue
+			}
+
+			if retryable(result.Status) && id < len(requests) {
+				rejected = append(rejected, requests[id])
+				continue
+			}
+
+			// the document was already written by an earlier attempt
+			if result.Status == http.StatusConflict && a.ForceDocumentID {
+				continue
+			}
+
+			if result.Error == nil {
+				log.Printf("E! Elasticsearch indexing failure, id: %d, status: %d", id, result.Status)
+				continue
+			}
+
+			log.Printf("E! Elasticsearch indexing failure, id: %d, error: %s, caused by: %s, %s", id, result.Error.Reason, result.Error.CausedBy["reason"], result.Error.CausedBy["type"])
 		}
-		return fmt.Errorf("W! Elasticsearch failed to index %d metrics", len(res.Failed()))
 	}
 
-	return nil
+	return rejected, nil
+}
 
+// retryable reports whether a document failing with the given status may be
+// accepted when it is sent again.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
 }
 
 func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
@@ -216,7 +380,7 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 		return fmt.Errorf("Elasticsearch template_name configuration not defined")
 	}
 
-	templateExists, errExists := a.Client.IndexTemplateExists(a.TemplateName).Do(ctx)
+	templateExists, errExists := a.templateExists(ctx)
 
 	if errExists != nil {
 		return fmt.Errorf("Elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, errExists)
@@ -238,23 +402,59 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 
 	if (a.OverwriteTemplate) || (!templateExists) || (templatePattern != "") {
 		// Create or update the template
-		tmpl := fmt.Sprintf(`
-			{
-				"template":"%s",
-				"settings": {
+		var errCreateTemplate error
+		if a.DataStream {
+			_, errCreateTemplate = a.Client.PerformRequest(ctx, "PUT",
+				"/_index_template/"+a.TemplateName, nil, a.dataStreamTemplate(templatePattern+"*"))
+		} else {
+			_, errCreateTemplate = a.Client.IndexPutTemplate(a.TemplateName).
+				BodyString(a.indexTemplate(templatePattern + "*")).Do(ctx)
+		}
+
+		if errCreateTemplate != nil {
+			return fmt.Errorf("Elasticsearch failed to create index template %s : %s", a.TemplateName, errCreateTemplate)
+		}
+
+		log.Printf("D! Elasticsearch template %s created or updated\n", a.TemplateName)
+
+	} else {
+
+		log.Println("D! Found existing Elasticsearch template. Skipping template management")
+
+	}
+	return nil
+}
+
+func (a *Elasticsearch) templateExists(ctx context.Context) (bool, error) {
+	if !a.DataStream {
+		return a.Client.IndexTemplateExists(a.TemplateName).Do(ctx)
+	}
+
+	// data streams use composable index templates
+	res, err := a.Client.PerformRequest(ctx, "HEAD", "/_index_template/"+a.TemplateName, nil, nil, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// indexSettings returns the index settings shared by both template kinds.
+func (a *Elasticsearch) indexSettings() string {
+	lifecycle := ""
+	if a.ManageLifecyclePolicy {
+		lifecycle = fmt.Sprintf(`,
+						"lifecycle.name": "%s"`, a.LifecyclePolicyName)
+	}
+
+	return fmt.Sprintf(`{
 					"index": {
 						"refresh_interval": "10s",
-						"mapping.total_fields.limit": 5000
+						"mapping.total_fields.limit": 5000%s
 					}
-				},
-				"mappings" : {
-					"_default_" : {
-						"_all": { "enabled": false	  },
-						"properties" : {
-							"@timestamp" : { "type" : "date" },
-							"measurement_name" : { "type" : "keyword" }
-						},
-						"dynamic_templates": [
+				}`, lifecycle)
+}
+
+const dynamicTemplates = `[
 							{
 								"tags": {
 									"match_mapping_type": "string",
@@ -291,23 +491,75 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 									}
 								}
 							}
-						]
+						]`
+
+func (a *Elasticsearch) indexTemplate(pattern string) string {
+	return fmt.Sprintf(`
+			{
+				"template":"%s",
+				"settings": %s,
+				"mappings" : {
+					"_default_" : {
+						"_all": { "enabled": false	  },
+						"properties" : {
+							"@timestamp" : { "type" : "date" },
+							"measurement_name" : { "type" : "keyword" }
+						},
+						"dynamic_templates": %s
 					}
 				}
-			}`, templatePattern+"*")
-		_, errCreateTemplate := a.Client.IndexPutTemplate(a.TemplateName).BodyString(tmpl).Do(ctx)
+			}`, pattern, a.indexSettings(), dynamicTemplates)
+}
 
-		if errCreateTemplate != nil {
-			return fmt.Errorf("Elasticsearch failed to create index template %s : %s", a.TemplateName, errCreateTemplate)
-		}
+func (a *Elasticsearch) dataStreamTemplate(pattern string) string {
+	return fmt.Sprintf(`
+			{
+				"index_patterns": ["%s"],
+				"data_stream": {},
+				"priority": 200,
+				"template": {
+					"settings": %s,
+					"mappings" : {
+						"properties" : {
+							"@timestamp" : { "type" : "date" },
+							"measurement_name" : { "type" : "keyword" }
+						},
+						"dynamic_templates": %s
+					}
+				}
+			}`, pattern, a.indexSettings(), dynamicTemplates)
+}
 
-		log.Printf("D! Elasticsearch template %s created or updated\n", a.TemplateName)
+func (a *Elasticsearch) manageLifecyclePolicy(ctx context.Context) error {
+	if a.LifecyclePolicyName == "" {
+		return fmt.Errorf("Elasticsearch lifecycle_policy_name configuration not defined")
+	}
 
-	} else {
+	path := "/_ilm/policy/" + a.LifecyclePolicyName
 
-		log.Println("D! Found existing Elasticsearch template. Skipping template management")
+	if !a.OverwriteLifecyclePolicy {
+		res, err := a.Client.PerformRequest(ctx, "GET", path, nil, nil, http.StatusNotFound)
+		if err != nil {
+			return fmt.Errorf("Elasticsearch lifecycle policy check failed, policy name: %s, error: %s", a.LifecyclePolicyName, err)
+		}
 
+		if res.StatusCode == http.StatusOK {
+			log.Println("D! Found existing Elasticsearch lifecycle policy. Skipping lifecycle policy management")
+			return nil
+		}
 	}
+
+	policy := a.LifecyclePolicy
+	if policy == "" {
+		policy = defaultLifecyclePolicy
+	}
+
+	_, err := a.Client.PerformRequest(ctx, "PUT", path, nil, policy)
+	if err != nil {
+		return fmt.Errorf("Elasticsearch failed to create lifecycle policy %s : %s", a.LifecyclePolicyName, err)
+	}
+
+	log.Printf("D! Elasticsearch lifecycle policy %s created or updated\n", a.LifecyclePolicyName)
 	return nil
 }
 
@@ -368,6 +620,16 @@ func (a *Elasticsearch) GetIndexName(indexName string, eventTime time.Time, tagK
 
 }
 
+// GetPointID generates a document ID from the series hash and the timestamp
+// of the metric, so a metric sent more than once maps to the same document.
+func GetPointID(m telegraf.Metric) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(strconv.FormatInt(m.Time().UnixNano(), 10))
+	buffer.WriteString(m.Name())
+	buffer.WriteString(strconv.FormatUint(m.HashID(), 10))
+	return fmt.Sprintf("%x", sha256.Sum256(buffer.Bytes()))
+}
+
 func getISOWeek(eventTime time.Time) string {
 	_, week := eventTime.ISOWeek()
 	return strconv.Itoa(week)
@@ -391,6 +653,8 @@ func init() {
 		return &Elasticsearch{
 			Timeout:             internal.Duration{Duration: time.Second * 5},
 			HealthCheckInterval: internal.Duration{Duration: time.Second * 10},
+			MaxRetries:          3,
+			LifecyclePolicyName: "telegraf",
 		}
 	})
 }
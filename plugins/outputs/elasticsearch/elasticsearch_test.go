@@ -1,8 +1,15 @@
 package elasticsearch
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -257,3 +264,223 @@ func TestGetIndexName(t *testing.T) {
 		}
 	}
 }
+
+// bulkServer is a fake Elasticsearch node recording the action lines of the
+// bulk requests it receives and answering them with the given statuses.
+type bulkServer struct {
+	sync.Mutex
+	version  string
+	statuses [][]int
+	actions  [][]map[string]map[string]interface{}
+}
+
+func (b *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		version := b.version
+		if version == "" {
+			version = "7.10.0"
+		}
+		fmt.Fprintf(w, `{"version": {"number": %q}}`, version)
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	var actions []map[string]map[string]interface{}
+	scanner := bufio.NewScanner(r.Body)
+	for i := 0; scanner.Scan(); i++ {
+		if i%2 == 1 {
+			continue
+		}
+		action := make(map[string]map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		actions = append(actions, action)
+	}
+
+	statuses := b.statuses[len(b.actions)]
+	b.actions = append(b.actions, actions)
+
+	var items []map[string]map[string]interface{}
+	hasErrors := false
+	for i, action := range actions {
+		for op := range action {
+			item := map[string]interface{}{"status": statuses[i]}
+			if statuses[i] > 299 {
+				hasErrors = true
+				item["error"] = map[string]interface{}{"type": "error", "reason": "rejected"}
+			}
+			items = append(items, map[string]map[string]interface{}{op: item})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": hasErrors, "items": items})
+}
+
+func newTestElasticsearch(url string) *Elasticsearch {
+	return &Elasticsearch{
+		URLs:       []string{url},
+		IndexName:  "test-%Y.%m.%d",
+		Timeout:    internal.Duration{Duration: time.Second * 5},
+		MaxRetries: 3,
+	}
+}
+
+func TestWriteRetriesRejectedDocuments(t *testing.T) {
+	handler := &bulkServer{statuses: [][]int{{201, 429}, {201}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	e := newTestElasticsearch(ts.URL)
+	require.NoError(t, e.Connect())
+
+	metrics := testutil.MockMetrics()
+	metrics = append(metrics, testutil.TestMetric(2.0, "test2"))
+	require.NoError(t, e.Write(metrics))
+
+	// only the rejected document is resent
+	require.Len(t, handler.actions, 2)
+	require.Len(t, handler.actions[0], 2)
+	require.Len(t, handler.actions[1], 1)
+	require.Equal(t, handler.actions[0][1], handler.actions[1][0])
+}
+
+func TestWriteRetriesExhausted(t *testing.T) {
+	handler := &bulkServer{statuses: [][]int{{429}, {429}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	e := newTestElasticsearch(ts.URL)
+	e.MaxRetries = 1
+	require.NoError(t, e.Connect())
+
+	require.Error(t, e.Write(testutil.MockMetrics()))
+	require.Len(t, handler.actions, 2)
+}
+
+func TestWriteRetriesServerErrors(t *testing.T) {
+	handler := &bulkServer{statuses: [][]int{{503, 201}, {500}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	e := newTestElasticsearch(ts.URL)
+	e.MaxRetries = 1
+	require.NoError(t, e.Connect())
+
+	metrics := testutil.MockMetrics()
+	metrics = append(metrics, testutil.TestMetric(2.0, "test2"))
+	require.Error(t, e.Write(metrics))
+	require.Len(t, handler.actions, 2)
+	require.Len(t, handler.actions[1], 1)
+	require.Equal(t, handler.actions[0][0], handler.actions[1][0])
+}
+
+func TestWriteDropsFailedDocuments(t *testing.T) {
+	handler := &bulkServer{statuses: [][]int{{400, 201}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	e := newTestElasticsearch(ts.URL)
+	require.NoError(t, e.Connect())
+
+	metrics := testutil.MockMetrics()
+	metrics = append(metrics, testutil.TestMetric(2.0, "test2"))
+	require.NoError(t, e.Write(metrics))
+	require.Len(t, handler.actions, 1)
+}
+
+func TestWriteDataStream(t *testing.T) {
+	handler := &bulkServer{statuses: [][]int{{201}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	e := newTestElasticsearch(ts.URL)
+	e.IndexName = "metrics-{{tag1}}"
+	e.DataStream = true
+	e.ForceDocumentID = true
+	require.NoError(t, e.Connect())
+
+	metrics := testutil.MockMetrics()
+	require.NoError(t, e.Write(metrics))
+
+	action, ok := handler.actions[0][0]["create"]
+	require.True(t, ok)
+	require.Equal(t, "metrics-value1", action["_index"])
+	require.Equal(t, GetPointID(metrics[0]), action["_id"])
+	require.NotContains(t, action, "_type")
+}
+
+func TestConnectVersionGates(t *testing.T) {
+	tests := []struct {
+		version         string
+		dataStream      bool
+		lifecyclePolicy bool
+		ok              bool
+	}{
+		{"7.8.1", true, false, false},
+		{"7.9.0", true, false, true},
+		{"8.0.0", true, false, true},
+		{"6.5.4", false, true, false},
+		{"6.6.0", false, true, true},
+		{"4.6.0", false, false, false},
+	}
+
+	for _, tt := range tests {
+		handler := &bulkServer{version: tt.version}
+		ts := httptest.NewServer(handler)
+
+		e := newTestElasticsearch(ts.URL)
+		e.IndexName = "metrics"
+		e.DataStream = tt.dataStream
+		e.ManageLifecyclePolicy = tt.lifecyclePolicy
+		err := e.Connect()
+		ts.Close()
+
+		if tt.ok {
+			require.False(t, err != nil && strings.Contains(err.Error(), "not supported"), tt.version)
+		} else {
+			require.Error(t, err, tt.version)
+		}
+	}
+}
+
+func TestDataStreamDateSpecifiers(t *testing.T) {
+	e := &Elasticsearch{
+		URLs:       []string{"http://localhost:9200"},
+		IndexName:  "metrics-%Y.%m.%d",
+		DataStream: true,
+	}
+	require.Error(t, e.Connect())
+}
+
+func TestGetPointID(t *testing.T) {
+	m1 := testutil.TestMetric(1.0, "test")
+	m2 := testutil.TestMetric(1.0, "test")
+	require.Equal(t, GetPointID(m1), GetPointID(m2))
+
+	m2.SetTime(m2.Time().Add(time.Second))
+	require.NotEqual(t, GetPointID(m1), GetPointID(m2))
+
+	m3 := testutil.TestMetric(1.0, "test")
+	m3.AddTag("tag2", "value2")
+	require.NotEqual(t, GetPointID(m1), GetPointID(m3))
+}
+
+func TestLifecyclePolicyTemplateSetting(t *testing.T) {
+	e := &Elasticsearch{
+		ManageLifecyclePolicy: true,
+		LifecyclePolicyName:   "telegraf",
+	}
+
+	var tmpl map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(e.dataStreamTemplate("metrics-*")), &tmpl))
+	settings := tmpl["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	require.Equal(t, "telegraf", settings["index"].(map[string]interface{})["lifecycle.name"])
+
+	require.NoError(t, json.Unmarshal([]byte(e.indexTemplate("telegraf-*")), &tmpl))
+	require.Equal(t, "telegraf-*", tmpl["template"])
+}
@@ -0,0 +1,241 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/process"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+const maxStderrBytes = 512
+
+var sampleConfig = `
+  ## Command to ingest metrics via stdin.
+  command = ["tee", "-a", "/dev/null"]
+
+  ## Timeout for command to complete.  In persistent mode, timeout for the
+  ## command to read the metrics of a write.
+  # timeout = "5s"
+
+  ## Start the command once and stream the metrics of every write to its
+  ## stdin instead of starting it for each write.  The command is restarted
+  ## after restart_delay if it exits.
+  # persistent = false
+  # restart_delay = "10s"
+
+  ## Whether the serializer should be given all metrics of a write at once
+  ## (batch format) or one at a time.
+  # use_batch_format = true
+
+  ## Data format to output.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+`
+
+// Exec defines the exec output plugin.
+type Exec struct {
+	Command        []string          `toml:"command"`
+	Timeout        internal.Duration `toml:"timeout"`
+	UseBatchFormat bool              `toml:"use_batch_format"`
+	Persistent     bool              `toml:"persistent"`
+	RestartDelay   internal.Duration `toml:"restart_delay"`
+
+	runner     Runner
+	serializer serializers.Serializer
+	process    *process.Process
+}
+
+// SetSerializer sets the serializer for the output.
+func (e *Exec) SetSerializer(serializer serializers.Serializer) {
+	e.serializer = serializer
+}
+
+// Connect satisfies the Output interface.
+func (e *Exec) Connect() error {
+	if len(e.Command) == 0 {
+		return fmt.Errorf("exec: command is required")
+	}
+
+	if !e.Persistent {
+		return nil
+	}
+
+	p, err := process.New(e.Command)
+	if err != nil {
+		return fmt.Errorf("exec: error creating process %s: %v", e.Command, err)
+	}
+	p.RestartDelay = e.RestartDelay.Duration
+	p.LogName = "outputs.exec"
+	p.ReadStdoutFn = e.cmdReadOut
+	p.ReadStderrFn = e.cmdReadErr
+
+	if err = p.Start(); err != nil {
+		return fmt.Errorf("exec: failed to start process %s: %v", e.Command, err)
+	}
+
+	e.process = p
+	return nil
+}
+
+// Close stops the persistent process, if any.
+func (e *Exec) Close() error {
+	if e.process != nil {
+		e.process.Stop()
+		e.process = nil
+	}
+	return nil
+}
+
+// Description describes the plugin.
+func (e *Exec) Description() string {
+	return "Send metrics to command as input over stdin"
+}
+
+// SampleConfig returns a sample configuration.
+func (e *Exec) SampleConfig() string {
+	return sampleConfig
+}
+
+// Write writes the metrics to the configured command.
+func (e *Exec) Write(metrics []telegraf.Metric) error {
+	var buffer bytes.Buffer
+	if e.UseBatchFormat {
+		serializedMetrics, err := e.serializer.SerializeBatch(metrics)
+		if err != nil {
+			return err
+		}
+		buffer.Write(serializedMetrics)
+	} else {
+		for _, metric := range metrics {
+			serializedMetric, err := e.serializer.Serialize(metric)
+			if err != nil {
+				return err
+			}
+			buffer.Write(serializedMetric)
+		}
+	}
+
+	if buffer.Len() <= 0 {
+		return nil
+	}
+
+	if e.process != nil {
+		return e.write(buffer.Bytes())
+	}
+
+	return e.runner.Run(e.Timeout.Duration, e.Command, &buffer)
+}
+
+// write writes to the stdin of the persistent process.  If the write does
+// not complete within the timeout the process is killed, which unblocks the
+// write, and restarted.
+func (e *Exec) write(b []byte) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.process.Write(b)
+		done <- err
+	}()
+
+	timer := time.NewTimer(e.Timeout.Duration)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("exec: error writing to command '%s': %v", e.Command, err)
+		}
+		return nil
+	case <-timer.C:
+		if err := e.process.Signal(os.Kill); err != nil {
+			log.Printf("E! [outputs.exec] Error killing command '%s': %v", e.Command, err)
+		}
+		return errors.New("exec: timeout writing to command, killed process")
+	}
+}
+
+func (e *Exec) cmdReadOut(out io.Reader) {
+	scanner := bufio.NewScanner(out)
+
+	for scanner.Scan() {
+		log.Printf("D! [outputs.exec] stdout from command '%s': %s", e.Command, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("E! [outputs.exec] Error reading stdout: %v", err)
+	}
+}
+
+func (e *Exec) cmdReadErr(out io.Reader) {
+	scanner := bufio.NewScanner(out)
+
+	for scanner.Scan() {
+		log.Printf("W! [outputs.exec] stderr from command '%s': %s", e.Command, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("E! [outputs.exec] Error reading stderr: %v", err)
+	}
+}
+
+// Runner provides an interface for running exec.Cmd.
+type Runner interface {
+	Run(time.Duration, []string, io.Reader) error
+}
+
+// CommandRunner runs a command with the ability to kill the process before
+// the timeout.
+type CommandRunner struct{}
+
+// Run runs the command, writing input to its stdin.  A non-zero exit code
+// or a timeout is reported as an error.
+func (c *CommandRunner) Run(timeout time.Duration, command []string, input io.Reader) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = input
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := internal.RunTimeout(cmd, timeout); err != nil {
+		s := stderr
+		if s.Len() > maxStderrBytes {
+			s.Truncate(maxStderrBytes)
+			s.WriteString("...")
+		}
+		if s.Len() > 0 {
+			return fmt.Errorf("exec: %s for command '%s': %s", err, command, bytes.TrimSpace(s.Bytes()))
+		}
+		return fmt.Errorf("exec: %s for command '%s'", err, command)
+	}
+
+	for _, line := range bytes.Split(bytes.TrimSpace(stderr.Bytes()), []byte("\n")) {
+		if len(line) > 0 {
+			log.Printf("W! [outputs.exec] stderr from command '%s': %s", command, line)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	outputs.Add("exec", func() telegraf.Output {
+		return &Exec{
+			runner:         &CommandRunner{},
+			UseBatchFormat: true,
+			Timeout:        internal.Duration{Duration: time.Second * 5},
+			RestartDelay:   internal.Duration{Duration: time.Second * 10},
+		}
+	})
+}
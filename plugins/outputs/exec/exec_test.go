@@ -0,0 +1,162 @@
+// +build !windows
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	tests := []struct {
+		name     string
+		command  []string
+		metrics  []telegraf.Metric
+		expected string
+		err      bool
+	}{
+		{
+			name:     "writes to stdin",
+			command:  []string{"sh", "-c", "cat > " + out},
+			metrics:  testutil.MockMetrics(),
+			expected: "test1,tag1=value1 value=1 1257894000000000000\n",
+		},
+		{
+			name:    "exit 1",
+			command: []string{"sh", "-c", "echo failed >&2; exit 1"},
+			metrics: testutil.MockMetrics(),
+			err:     true,
+		},
+		{
+			name:    "timeout",
+			command: []string{"sleep", "10"},
+			metrics: testutil.MockMetrics(),
+			err:     true,
+		},
+		{
+			name:    "no metrics",
+			command: []string{"false"},
+			metrics: []telegraf.Metric{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(out)
+
+			e := &Exec{
+				Command:        tt.command,
+				Timeout:        internal.Duration{Duration: 500 * time.Millisecond},
+				UseBatchFormat: true,
+				runner:         &CommandRunner{},
+			}
+			e.SetSerializer(influx.NewSerializer())
+			require.NoError(t, e.Connect())
+
+			err := e.Write(tt.metrics)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.expected != "" {
+				actual, err := ioutil.ReadFile(out)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, string(actual))
+			}
+		})
+	}
+}
+
+func TestExecStderrInError(t *testing.T) {
+	e := &Exec{
+		Command: []string{"sh", "-c", "echo broken pipe >&2; exit 2"},
+		Timeout: internal.Duration{Duration: time.Second},
+		runner:  &CommandRunner{},
+	}
+	e.SetSerializer(influx.NewSerializer())
+
+	err := e.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "broken pipe")
+}
+
+func TestConnectRequiresCommand(t *testing.T) {
+	e := &Exec{}
+	require.Error(t, e.Connect())
+}
+
+func TestExecPersistent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	e := &Exec{
+		Command:        []string{"sh", "-c", "cat > " + out},
+		Timeout:        internal.Duration{Duration: time.Second},
+		UseBatchFormat: true,
+		Persistent:     true,
+		RestartDelay:   internal.Duration{Duration: time.Second},
+		runner:         &CommandRunner{},
+	}
+	e.SetSerializer(influx.NewSerializer())
+	require.NoError(t, e.Connect())
+
+	require.NoError(t, e.Write(testutil.MockMetrics()))
+	require.NoError(t, e.Write(testutil.MockMetrics()))
+	require.NoError(t, e.Close())
+
+	// both writes went to the same process
+	actual, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	line := "test1,tag1=value1 value=1 1257894000000000000\n"
+	require.Equal(t, line+line, string(actual))
+}
+
+func TestExecPersistentRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	e := &Exec{
+		Command:        []string{"sh", "-c", "head -n 1 >> " + out},
+		Timeout:        internal.Duration{Duration: time.Second},
+		UseBatchFormat: true,
+		Persistent:     true,
+		RestartDelay:   internal.Duration{Duration: 10 * time.Millisecond},
+		runner:         &CommandRunner{},
+	}
+	e.SetSerializer(influx.NewSerializer())
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	line := "test1,tag1=value1 value=1 1257894000000000000\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		require.True(t, time.Now().Before(deadline), "process was not restarted")
+		if err := e.Write(testutil.MockMetrics()); err == nil {
+			actual, err := ioutil.ReadFile(out)
+			if err == nil && string(actual) == line+line {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,55 @@
+// +build !windows
+
+package execd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	e := &Execd{
+		Command:      []string{"sh", "-c", "cat > " + out},
+		RestartDelay: internal.Duration{Duration: 5 * time.Second},
+	}
+	e.SetSerializer(influx.NewSerializer())
+
+	require.NoError(t, e.Connect())
+	require.NoError(t, e.Write(testutil.MockMetrics()))
+	require.NoError(t, e.Close())
+
+	actual, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "test1,tag1=value1 value=1 1257894000000000000\n", string(actual))
+}
+
+func TestExecdWriteAfterExit(t *testing.T) {
+	e := &Execd{
+		Command:      []string{"true"},
+		RestartDelay: internal.Duration{Duration: time.Minute},
+	}
+	e.SetSerializer(influx.NewSerializer())
+
+	require.NoError(t, e.Connect())
+	defer e.Close()
+
+	// Writes fail while the process is waiting to be restarted.
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Write(testutil.MockMetrics()) == nil {
+		require.True(t, time.Now().Before(deadline), "write did not fail")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
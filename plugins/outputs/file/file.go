@@ -1,28 +1,91 @@
 package file
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/rotate"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 )
 
+// idleTimeout is how long a file selected by a path template is kept open
+// after the last write to it.
+const idleTimeout = 10 * time.Minute
+
+// strftime maps the supported strftime verbs to Go time layouts.
+var strftime = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+	'M': "04",
+	'S': "05",
+}
+
 type File struct {
-	Files []string
+	Files               []string
+	RotationInterval    internal.Duration `toml:"rotation_interval"`
+	RotationMaxSize     internal.Size     `toml:"rotation_max_size"`
+	RotationMaxArchives int               `toml:"rotation_max_archives"`
+	RotationCompress    bool              `toml:"rotation_compress"`
+	UseBatchFormat      bool              `toml:"use_batch_format"`
 
-	writers []io.Writer
-	closers []io.Closer
+	writers   []io.Writer
+	closers   []io.Closer
+	templates []*template.Template
+	open      map[string]*templateFile
 
 	serializer serializers.Serializer
 }
 
+// templateFile is a file selected by a path template.
+type templateFile struct {
+	writer   *rotate.FileWriter
+	lastUsed time.Time
+}
+
 var sampleConfig = `
   ## Files to write to, "stdout" is a specially handled file.
+  ##
+  ## File names may be templates to route each metric to a file based on its
+  ## name, tags and time, missing directories are created:
+  ##   {{.Name}}        - measurement name
+  ##   {{.Tag "host"}}  - value of the tag
+  ##   %Y %y %m %d %H %M %S - date and time of the metric in UTC
   files = ["stdout", "/tmp/metrics.out"]
 
+  ## The file will be rotated after the time interval specified.  When set
+  ## to 0 no time based rotation is performed.
+  # rotation_interval = "0h"
+
+  ## The logfile will be rotated when it becomes larger than the specified
+  ## size.  When set to 0 no size based rotation is performed.
+  # rotation_max_size = "0MB"
+
+  ## Maximum number of rotated archives to keep, any older files are deleted.
+  ## If set to -1, no archives are removed.
+  # rotation_max_archives = 5
+
+  ## Compress rotated files with gzip.
+  # rotation_compress = false
+
+  ## Use batch serialization format instead of line based delimiting, the
+  ## metrics of a write are serialized at once for each file.  Required by
+  ## formats describing a whole exposition, such as openmetrics.  Each write
+  ## is a complete exposition, so appending writes to a file results in one
+  ## exposition per flush.
+  # use_batch_format = false
+
   ## Data format to output.
   ## Each data format has its own unique set of configuration options, read
   ## more about them here:
@@ -39,28 +102,37 @@ func (f *File) Connect() error {
 		f.Files = []string{"stdout"}
 	}
 
+	f.open = make(map[string]*templateFile)
 	for _, file := range f.Files {
 		if file == "stdout" {
 			f.writers = append(f.writers, os.Stdout)
-		} else {
-			var of *os.File
-			var err error
-			if _, err := os.Stat(file); os.IsNotExist(err) {
-				of, err = os.Create(file)
-			} else {
-				of, err = os.OpenFile(file, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-			}
+			continue
+		}
 
+		if isTemplate(file) {
+			tmpl, err := parsePathTemplate(file)
 			if err != nil {
 				return err
 			}
-			f.writers = append(f.writers, of)
-			f.closers = append(f.closers, of)
+			f.templates = append(f.templates, tmpl)
+			continue
 		}
+
+		of, err := f.openFile(file)
+		if err != nil {
+			return err
+		}
+		f.writers = append(f.writers, of)
+		f.closers = append(f.closers, of)
 	}
 	return nil
 }
 
+func (f *File) openFile(filename string) (*rotate.FileWriter, error) {
+	return rotate.NewFileWriter(filename, f.RotationInterval.Duration,
+		f.RotationMaxSize.Size, f.RotationMaxArchives, f.RotationCompress)
+}
+
 func (f *File) Close() error {
 	var errS string
 	for _, c := range f.closers {
@@ -68,6 +140,12 @@ func (f *File) Close() error {
 			errS += err.Error() + "\n"
 		}
 	}
+	for path, tf := range f.open {
+		if err := tf.writer.Close(); err != nil {
+			errS += err.Error() + "\n"
+		}
+		delete(f.open, path)
+	}
 	if errS != "" {
 		return fmt.Errorf(errS)
 	}
@@ -83,7 +161,12 @@ func (f *File) Description() string {
 }
 
 func (f *File) Write(metrics []telegraf.Metric) error {
+	if f.UseBatchFormat {
+		return f.writeBatch(metrics)
+	}
+
 	var writeErr error = nil
+	now := time.Now()
 	for _, metric := range metrics {
 		b, err := f.serializer.Serialize(metric)
 		if err != nil {
@@ -96,12 +179,194 @@ func (f *File) Write(metrics []telegraf.Metric) error {
 				writeErr = fmt.Errorf("E! failed to write message: %s, %s", b, err)
 			}
 		}
+
+		for _, tmpl := range f.templates {
+			writer, err := f.templateWriter(tmpl, metric, now)
+			if err != nil {
+				writeErr = fmt.Errorf("E! failed to open file: %s", err)
+				continue
+			}
+			_, err = writer.Write(b)
+			if err != nil {
+				writeErr = fmt.Errorf("E! failed to write message: %s, %s", b, err)
+			}
+		}
 	}
+
+	f.closeIdle(now)
 	return writeErr
 }
 
+// writeBatch serializes the metrics written to each file at once.
+func (f *File) writeBatch(metrics []telegraf.Metric) error {
+	var writeErr error = nil
+	now := time.Now()
+
+	if len(f.writers) > 0 {
+		b, err := f.serializer.SerializeBatch(metrics)
+		if err != nil {
+			return fmt.Errorf("failed to serialize message: %s", err)
+		}
+		for _, writer := range f.writers {
+			_, err = writer.Write(b)
+			if err != nil && writer != os.Stdout {
+				writeErr = fmt.Errorf("E! failed to write message: %s, %s", b, err)
+			}
+		}
+	}
+
+	// group the metrics by the file selected for them, keeping their order
+	var writers []io.Writer
+	batches := make(map[io.Writer][]telegraf.Metric)
+	for _, tmpl := range f.templates {
+		for _, metric := range metrics {
+			writer, err := f.templateWriter(tmpl, metric, now)
+			if err != nil {
+				writeErr = fmt.Errorf("E! failed to open file: %s", err)
+				continue
+			}
+			if _, ok := batches[writer]; !ok {
+				writers = append(writers, writer)
+			}
+			batches[writer] = append(batches[writer], metric)
+		}
+	}
+
+	for _, writer := range writers {
+		b, err := f.serializer.SerializeBatch(batches[writer])
+		if err != nil {
+			return fmt.Errorf("failed to serialize message: %s", err)
+		}
+		_, err = writer.Write(b)
+		if err != nil {
+			writeErr = fmt.Errorf("E! failed to write message: %s, %s", b, err)
+		}
+	}
+
+	f.closeIdle(now)
+	return writeErr
+}
+
+// templateWriter returns the writer of the file selected by the template,
+// opening it if required.
+func (f *File) templateWriter(tmpl *template.Template, metric telegraf.Metric, now time.Time) (io.Writer, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &pathData{metric: metric}); err != nil {
+		return nil, err
+	}
+	path := buf.String()
+
+	tf, ok := f.open[path]
+	if !ok {
+		writer, err := f.openFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tf = &templateFile{writer: writer}
+		f.open[path] = tf
+	}
+	tf.lastUsed = now
+	return tf.writer, nil
+}
+
+// closeIdle closes the files selected by templates that have not been
+// written to recently, such as the file of the previous day.
+func (f *File) closeIdle(now time.Time) {
+	for path, tf := range f.open {
+		if now.Sub(tf.lastUsed) < idleTimeout {
+			continue
+		}
+		if err := tf.writer.Close(); err != nil {
+			log.Printf("E! [outputs.file] Error closing %s: %v", path, err)
+		}
+		delete(f.open, path)
+	}
+}
+
+// pathData is the data of the path templates.
+type pathData struct {
+	metric telegraf.Metric
+}
+
+// Name returns the measurement name.
+func (d *pathData) Name() string {
+	return sanitizePath(d.metric.Name())
+}
+
+// Tag returns the value of the tag, or an empty string if the metric does
+// not have the tag.
+func (d *pathData) Tag(key string) string {
+	value, _ := d.metric.GetTag(key)
+	return sanitizePath(value)
+}
+
+// Time returns the metric time in UTC.
+func (d *pathData) Time() time.Time {
+	return d.metric.Time().UTC()
+}
+
+// sanitizePath prevents values from adding path elements.
+func sanitizePath(s string) string {
+	s = strings.Replace(s, "/", "_", -1)
+	s = strings.Replace(s, `\`, "_", -1)
+	if s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// isTemplate reports whether the file name contains a template action or a
+// supported strftime verb.
+func isTemplate(file string) bool {
+	if strings.Contains(file, "{{") {
+		return true
+	}
+	for i := 0; i < len(file)-1; i++ {
+		if file[i] == '%' {
+			if _, ok := strftime[file[i+1]]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePathTemplate parses a file name template, strftime verbs are
+// converted to template actions formatting the metric time.  Any other % is
+// kept as is.
+func parsePathTemplate(file string) (*template.Template, error) {
+	var text bytes.Buffer
+	for i := 0; i < len(file); i++ {
+		if file[i] != '%' || i+1 == len(file) {
+			text.WriteByte(file[i])
+			continue
+		}
+
+		if file[i+1] == '%' {
+			text.WriteByte('%')
+			i++
+			continue
+		}
+		layout, ok := strftime[file[i+1]]
+		if !ok {
+			text.WriteByte('%')
+			continue
+		}
+		fmt.Fprintf(&text, `{{.Time.Format %q}}`, layout)
+		i++
+	}
+
+	tmpl, err := template.New("file").Option("missingkey=zero").Parse(text.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name template %q: %v", file, err)
+	}
+	return tmpl, nil
+}
+
 func init() {
 	outputs.Add("file", func() telegraf.Output {
-		return &File{}
+		return &File{
+			RotationMaxArchives: 5,
+		}
 	})
 }
@@ -5,10 +5,13 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
@@ -180,6 +183,105 @@ func TestFileStdout(t *testing.T) {
 	assert.Equal(t, expNewFile, out)
 }
 
+func TestFileTemplatedPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:      []string{filepath.Join(dir, `{{.Name}}`, `{{.Tag "host"}}`, "%Y-%m-%d.out")},
+		serializer: s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1.0}, time.Date(2018, 11, 2, 23, 0, 0, 0, time.UTC)),
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 2.0}, time.Date(2018, 11, 3, 1, 0, 0, 0, time.UTC)),
+		testutil.MustMetric("mem", map[string]string{"host": "../b"},
+			map[string]interface{}{"value": 3.0}, time.Date(2018, 11, 3, 1, 0, 0, 0, time.UTC)),
+	}
+	err = f.Write(metrics)
+	assert.NoError(t, err)
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	validateFile(filepath.Join(dir, "cpu", "a", "2018-11-02.out"), "cpu,host=a value=1 1541199600000000000\n", t)
+	validateFile(filepath.Join(dir, "cpu", "a", "2018-11-03.out"), "cpu,host=a value=2 1541206800000000000\n", t)
+	validateFile(filepath.Join(dir, "mem", ".._b", "2018-11-03.out"), "mem,host=../b value=3 1541206800000000000\n", t)
+}
+
+func TestFileInvalidTemplate(t *testing.T) {
+	f := File{Files: []string{"/tmp/{{.Name.out"}}
+	assert.Error(t, f.Connect())
+}
+
+func TestFileLiteralPercent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files: []string{
+			filepath.Join(dir, "100%Q.out"),
+			filepath.Join(dir, "%Y-50%Q-100%%.out"),
+		},
+		serializer: s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{},
+			map[string]interface{}{"value": 1.0}, time.Date(2018, 11, 2, 23, 0, 0, 0, time.UTC)),
+	}
+	err = f.Write(metrics)
+	assert.NoError(t, err)
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	validateFile(filepath.Join(dir, "100%Q.out"), "cpu value=1 1541199600000000000\n", t)
+	validateFile(filepath.Join(dir, "2018-50%Q-100%.out"), "cpu value=1 1541199600000000000\n", t)
+}
+
+func TestFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:               []string{filepath.Join(dir, "metrics.out")},
+		RotationMaxSize:     internal.Size{Size: 50},
+		RotationMaxArchives: 1,
+		serializer:          s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = f.Write(testutil.MockMetrics())
+		assert.NoError(t, err)
+	}
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	validateFile(filepath.Join(dir, "metrics.out"), expNewFile, t)
+}
+
 func createFile() *os.File {
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -204,3 +306,36 @@ func validateFile(fname, expS string, t *testing.T) {
 	}
 	assert.Equal(t, expS, string(buf))
 }
+
+func TestFileBatchFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := serializers.NewSerializer(&serializers.Config{DataFormat: "openmetrics"})
+	assert.NoError(t, err)
+	f := File{
+		Files:          []string{filepath.Join(dir, "metrics.out"), filepath.Join(dir, `{{.Tag "host"}}.out`)},
+		UseBatchFormat: true,
+		serializer:     s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1.0}, time.Unix(0, 0), telegraf.Gauge),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"value": 2.0}, time.Unix(0, 0), telegraf.Gauge),
+	}
+	err = f.Write(metrics)
+	assert.NoError(t, err)
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	validateFile(filepath.Join(dir, "metrics.out"), "# TYPE cpu gauge\n# HELP cpu Telegraf collected metric\ncpu{host=\"a\"} 1 0\ncpu{host=\"b\"} 2 0\n# EOF\n", t)
+	validateFile(filepath.Join(dir, "a.out"), "# TYPE cpu gauge\n# HELP cpu Telegraf collected metric\ncpu{host=\"a\"} 1 0\n# EOF\n", t)
+	validateFile(filepath.Join(dir, "b.out"), "# TYPE cpu gauge\n# HELP cpu Telegraf collected metric\ncpu{host=\"b\"} 2 0\n# EOF\n", t)
+}
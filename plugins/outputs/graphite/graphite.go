@@ -1,29 +1,70 @@
 package graphite
 
 import (
+	"bytes"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 )
 
+const (
+	// maxUDPPayload is the maximum size of a datagram sent in UDP mode,
+	// chosen to avoid IP fragmentation.
+	maxUDPPayload = 1400
+	// maxPicklePoints is the maximum number of points in a pickle message,
+	// the same limit carbon-relay uses.
+	maxPicklePoints = 500
+)
+
 type Graphite struct {
 	GraphiteTagSupport bool
 	// URL is only for backwards compatibility
-	Servers  []string
-	Prefix   string
-	Template string
-	Timeout  int
-	conns    []net.Conn
+	Servers          []string
+	Prefix           string
+	Template         string
+	Timeout          int
+	Protocol         string
+	Format           string
+	Distribution     string
+	FailureThreshold int
+	RetryInterval    internal.Duration
 	tlsint.ClientConfig
+
+	servers   []*server
+	ring      *hashRing
+	tlsConfig *tls.Config
+}
+
+// server tracks the connection and health of one Graphite server.  After
+// FailureThreshold consecutive failures the server is skipped until
+// RetryInterval has passed, then a single attempt decides whether it is
+// healthy again.
+type server struct {
+	address   string
+	conn      net.Conn
+	failures  int
+	downUntil time.Time
+}
+
+// point is a single serialized Graphite line.
+type point struct {
+	path      string
+	value     string
+	timestamp int64
+	line      []byte
 }
 
 var sampleConfig = `
@@ -31,6 +72,22 @@ var sampleConfig = `
   ## If multiple endpoints are configured, output will be load balanced.
   ## Only one of the endpoints will be written to with each iteration.
   servers = ["localhost:2003"]
+  ## Transport protocol, either "tcp" or "udp".
+  # protocol = "tcp"
+  ## Wire format, either "plaintext" or "pickle". The pickle protocol
+  ## requires tcp and usually listens on port 2004.
+  # format = "plaintext"
+  ## How metrics are spread over the servers:
+  ##   random          - each write goes to a single random server
+  ##   consistent_hash - each series always goes to the same server, picked
+  ##                     from a hash ring of the servers as carbon-relay does;
+  ##                     servers may be given as "host:port:instance" like
+  ##                     the carbon-relay destinations
+  # distribution = "random"
+  ## Number of consecutive failures after which a server is considered down,
+  ## and the time to wait before trying a server again once it is down.
+  # failure_threshold = 3
+  # retry_interval = "30s"
   ## Prefix metrics name
   prefix = ""
   ## Graphite output template
@@ -59,39 +116,98 @@ func (g *Graphite) Connect() error {
 	if len(g.Servers) == 0 {
 		g.Servers = append(g.Servers, "localhost:2003")
 	}
+	if g.Protocol == "" {
+		g.Protocol = "tcp"
+	}
+	if g.Format == "" {
+		g.Format = "plaintext"
+	}
+	if g.Distribution == "" {
+		g.Distribution = "random"
+	}
+	if g.FailureThreshold <= 0 {
+		g.FailureThreshold = 3
+	}
+	if g.RetryInterval.Duration <= 0 {
+		g.RetryInterval.Duration = 30 * time.Second
+	}
+
+	switch g.Protocol {
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("unknown protocol %q", g.Protocol)
+	}
+
+	switch g.Format {
+	case "plaintext":
+	case "pickle":
+		if g.Protocol != "tcp" {
+			return errors.New("the pickle format requires the tcp protocol")
+		}
+	default:
+		return fmt.Errorf("unknown format %q", g.Format)
+	}
+
+	switch g.Distribution {
+	case "random":
+	case "consistent_hash":
+		ring, err := newHashRing(g.Servers)
+		if err != nil {
+			return err
+		}
+		g.ring = ring
+	default:
+		return fmt.Errorf("unknown distribution %q", g.Distribution)
+	}
 
 	// Set tls config
 	tlsConfig, err := g.ClientConfig.TLSConfig()
 	if err != nil {
 		return err
 	}
+	if tlsConfig != nil && g.Protocol == "udp" {
+		return errors.New("TLS is not supported with the udp protocol")
+	}
+	g.tlsConfig = tlsConfig
 
 	// Get Connections
-	var conns []net.Conn
-	for _, server := range g.Servers {
-		// Dialer with timeout
-		d := net.Dialer{Timeout: time.Duration(g.Timeout) * time.Second}
-
-		// Get secure connection if tls config is set
-		var conn net.Conn
-		if tlsConfig != nil {
-			conn, err = tls.DialWithDialer(&d, "tcp", server, tlsConfig)
-		} else {
-			conn, err = d.Dial("tcp", server)
+	g.Close()
+	g.servers = make([]*server, 0, len(g.Servers))
+	for _, destination := range g.Servers {
+		address, _ := splitInstance(destination)
+		srv := &server{address: address}
+		if err := g.dial(srv); err != nil {
+			log.Printf("E! Graphite: failed to connect to %s: %s", address, err)
 		}
+		g.servers = append(g.servers, srv)
+	}
+	return nil
+}
 
-		if err == nil {
-			conns = append(conns, conn)
-		}
+func (g *Graphite) dial(srv *server) error {
+	// Dialer with timeout
+	d := net.Dialer{Timeout: time.Duration(g.Timeout) * time.Second}
+
+	// Get secure connection if tls config is set
+	var conn net.Conn
+	var err error
+	if g.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&d, "tcp", srv.address, g.tlsConfig)
+	} else {
+		conn, err = d.Dial(g.Protocol, srv.address)
 	}
-	g.conns = conns
+	if err != nil {
+		return err
+	}
+
+	srv.conn = conn
 	return nil
 }
 
 func (g *Graphite) Close() error {
 	// Closing all connections
-	for _, conn := range g.conns {
-		conn.Close()
+	for _, srv := range g.servers {
+		srv.close()
 	}
 	return nil
 }
@@ -129,63 +245,248 @@ func checkEOF(conn net.Conn) {
 	}
 }
 
-// Choose a random server in the cluster to write to until a successful write
-// occurs, logging each unsuccessful. If all servers fail, return error.
+// Write serializes the metrics and sends them to the servers according to
+// the distribution.
 func (g *Graphite) Write(metrics []telegraf.Metric) error {
 	// Prepare data
-	var batch []byte
 	s, err := serializers.NewGraphiteSerializer(g.Prefix, g.Template, g.GraphiteTagSupport)
 	if err != nil {
 		return err
 	}
 
+	var points []point
 	for _, metric := range metrics {
 		buf, err := s.Serialize(metric)
 		if err != nil {
 			log.Printf("E! Error serializing some metrics to graphite: %s", err.Error())
 		}
-		batch = append(batch, buf...)
+		points = append(points, parsePoints(buf)...)
 	}
 
-	err = g.send(batch)
-
-	// try to reconnect and retry to send
-	if err != nil {
-		log.Println("E! Graphite: Reconnecting and retrying: ")
-		g.Connect()
-		err = g.send(batch)
+	if len(points) == 0 {
+		return nil
 	}
 
-	return err
+	if g.Distribution == "consistent_hash" {
+		return g.sendConsistent(points)
+	}
+	return g.send(points)
 }
 
-func (g *Graphite) send(batch []byte) error {
+// Choose a random server in the cluster to write to until a successful write
+// occurs, logging each unsuccessful. If all servers fail, return error.
+func (g *Graphite) send(points []point) error {
 	// This will get set to nil if a successful write occurs
 	err := errors.New("Could not write to any Graphite server in cluster\n")
 
 	// Send data to a random server
-	p := rand.Perm(len(g.conns))
+	p := rand.Perm(len(g.servers))
 	for _, n := range p {
-		if g.Timeout > 0 {
-			g.conns[n].SetWriteDeadline(time.Now().Add(time.Duration(g.Timeout) * time.Second))
-		}
-		checkEOF(g.conns[n])
-		if _, e := g.conns[n].Write(batch); e != nil {
-			// Error
-			log.Println("E! Graphite Error: " + e.Error())
-			// Close explicitly
-			g.conns[n].Close()
-			// Let's try the next one
-		} else {
+		if g.sendTo(g.servers[n], points) == nil {
 			// Success
 			err = nil
 			break
 		}
+		// Let's try the next one
 	}
 
 	return err
 }
 
+// sendConsistent sends each point to the server owning its path on the hash
+// ring.  The points of a server that could not be written to are sent to
+// the next server on the ring of each point's own path.
+func (g *Graphite) sendConsistent(points []point) error {
+	failed := 0
+	down := make(map[int]bool)
+	for len(points) > 0 {
+		var order []int
+		groups := make(map[int][]point)
+		for _, p := range points {
+			n, ok := g.owner(p.path, down)
+			if !ok {
+				failed++
+				continue
+			}
+			if _, ok := groups[n]; !ok {
+				order = append(order, n)
+			}
+			groups[n] = append(groups[n], p)
+		}
+
+		points = nil
+		for _, n := range order {
+			if g.sendTo(g.servers[n], groups[n]) != nil {
+				down[n] = true
+				points = append(points, groups[n]...)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Could not write %d points to any Graphite server in cluster", failed)
+	}
+	return nil
+}
+
+// owner returns the first server on the ring of path that is not down.
+func (g *Graphite) owner(path string, down map[int]bool) (int, bool) {
+	for _, n := range g.ring.get(path) {
+		if !down[n] {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// sendTo writes the points to a single server, reconnecting and retrying
+// once if the write fails, and updates the health of the server.
+func (g *Graphite) sendTo(srv *server, points []point) error {
+	now := time.Now()
+	if !srv.available(now) {
+		return fmt.Errorf("server %s is down", srv.address)
+	}
+
+	payloads := g.encode(points)
+
+	n, err := g.write(srv, payloads)
+	if err != nil {
+		log.Println("E! Graphite Error: " + err.Error())
+		log.Printf("E! Graphite: Reconnecting to %s and retrying", srv.address)
+		// only the payloads that were not written are retried, so that
+		// datagrams already sent are not duplicated
+		_, err = g.write(srv, payloads[n:])
+	}
+
+	if err != nil {
+		log.Println("E! Graphite Error: " + err.Error())
+		if srv.fail(now, g.FailureThreshold, g.RetryInterval.Duration) {
+			log.Printf("W! Graphite: server %s is down after %d failures, retrying in %s",
+				srv.address, srv.failures, g.RetryInterval.Duration)
+		}
+		return err
+	}
+
+	if srv.failures >= g.FailureThreshold {
+		log.Printf("I! Graphite: server %s is up again", srv.address)
+	}
+	srv.succeed()
+	return nil
+}
+
+// write writes the payloads to the server, returning the number of payloads
+// written in full.
+func (g *Graphite) write(srv *server, payloads [][]byte) (int, error) {
+	if srv.conn == nil {
+		if err := g.dial(srv); err != nil {
+			return 0, err
+		}
+	}
+
+	if g.Protocol == "tcp" {
+		checkEOF(srv.conn)
+	}
+
+	for i, payload := range payloads {
+		if g.Timeout > 0 {
+			srv.conn.SetWriteDeadline(time.Now().Add(time.Duration(g.Timeout) * time.Second))
+		}
+		if _, err := srv.conn.Write(payload); err != nil {
+			// Close explicitly
+			srv.close()
+			return i, err
+		}
+	}
+	return len(payloads), nil
+}
+
+// encode splits the points into the payloads to write: one payload for a
+// plaintext TCP stream, datagrams of at most maxUDPPayload bytes for UDP and
+// pickle messages of at most maxPicklePoints points.
+func (g *Graphite) encode(points []point) [][]byte {
+	var payloads [][]byte
+
+	switch {
+	case g.Format == "pickle":
+		for len(points) > 0 {
+			n := len(points)
+			if n > maxPicklePoints {
+				n = maxPicklePoints
+			}
+			payloads = append(payloads, encodePickle(points[:n]))
+			points = points[n:]
+		}
+	case g.Protocol == "udp":
+		var buf bytes.Buffer
+		for _, p := range points {
+			if buf.Len() > 0 && buf.Len()+len(p.line) > maxUDPPayload {
+				payloads = append(payloads, append([]byte(nil), buf.Bytes()...))
+				buf.Reset()
+			}
+			buf.Write(p.line)
+		}
+		payloads = append(payloads, buf.Bytes())
+	default:
+		var buf bytes.Buffer
+		for _, p := range points {
+			buf.Write(p.line)
+		}
+		payloads = append(payloads, buf.Bytes())
+	}
+
+	return payloads
+}
+
+// parsePoints splits the serializer output into its lines.
+func parsePoints(buf []byte) []point {
+	var points []point
+	for _, line := range bytes.SplitAfter(buf, []byte("\n")) {
+		parts := strings.Fields(string(line))
+		if len(parts) != 3 {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		points = append(points, point{
+			path:      parts[0],
+			value:     parts[1],
+			timestamp: timestamp,
+			line:      line,
+		})
+	}
+	return points
+}
+
+func (s *server) available(now time.Time) bool {
+	return now.After(s.downUntil)
+}
+
+// fail records a failure and returns true if it marked the server as down.
+func (s *server) fail(now time.Time, threshold int, retryInterval time.Duration) bool {
+	s.failures++
+	if s.failures < threshold {
+		return false
+	}
+	s.downUntil = now.Add(retryInterval)
+	return true
+}
+
+func (s *server) succeed() {
+	s.failures = 0
+	s.downUntil = time.Time{}
+}
+
+func (s *server) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
 func init() {
 	outputs.Add("graphite", func() telegraf.Output {
 		return &Graphite{}
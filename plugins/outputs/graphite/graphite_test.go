@@ -2,13 +2,16 @@ package graphite
 
 import (
 	"bufio"
+	"fmt"
 	"net"
 	"net/textproto"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/metric"
 
 	"github.com/stretchr/testify/assert"
@@ -217,3 +220,316 @@ func TCPServer2WithTags(t *testing.T, wg *sync.WaitGroup) {
 		tcpServer.Close()
 	}()
 }
+
+func TestEncodePickle(t *testing.T) {
+	points := parsePoints([]byte("my.prefix.mymeasurement 3.14 1289430000\n"))
+	require.Len(t, points, 1)
+
+	expected := []byte{0, 0, 0, 0x32,
+		0x80, 2, ']', '(',
+		'X', 23, 0, 0, 0}
+	expected = append(expected, "my.prefix.mymeasurement"...)
+	expected = append(expected, 'J', 0xf0, 0x23, 0xdb, 0x4c)
+	expected = append(expected, 'G', 0x40, 0x09, 0x1e, 0xb8, 0x51, 0xeb, 0x85, 0x1f)
+	expected = append(expected, 0x86, 0x86, 'e', '.')
+
+	require.Equal(t, expected, encodePickle(points))
+}
+
+func TestHashRing(t *testing.T) {
+	servers := []string{"127.0.0.1:2003:a", "127.0.0.1:2103:b", "127.0.0.1:2203:c"}
+	r, err := newHashRing(servers)
+	require.NoError(t, err)
+	require.Len(t, r.entries, 3*ringReplicas)
+
+	owners := make(map[int]int)
+	for i := 0; i < 300; i++ {
+		order := r.get(fmt.Sprintf("my.prefix.host%d.cpu", i))
+		require.Len(t, order, 3)
+		require.ElementsMatch(t, []int{0, 1, 2}, order)
+		owners[order[0]]++
+	}
+	// every server owns part of the keys
+	require.Len(t, owners, 3)
+
+	// the owners picked by carbon-relay for the same destinations
+	carbon := []int{2, 2, 2, 2, 2, 0, 2, 0}
+	for i, owner := range carbon {
+		require.Equal(t, owner, r.get(fmt.Sprintf("my.prefix.host%d.cpu", i))[0])
+	}
+
+	r, err = newHashRing([]string{"10.0.0.1:2004", "10.0.0.2:2004"})
+	require.NoError(t, err)
+	carbon = []int{0, 1, 0, 1, 0, 1, 1, 1}
+	for i, owner := range carbon {
+		require.Equal(t, owner, r.get(fmt.Sprintf("my.prefix.host%d.cpu", i))[0])
+	}
+
+	_, err = newHashRing([]string{"localhost"})
+	require.Error(t, err)
+}
+
+// listen starts a TCP server collecting the received lines.
+func listen(t *testing.T) (net.Listener, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tp := textproto.NewReader(bufio.NewReader(conn))
+				for {
+					line, err := tp.ReadLine()
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return l, lines
+}
+
+func TestGraphiteConsistentHash(t *testing.T) {
+	l1, lines1 := listen(t)
+	defer l1.Close()
+	l2, lines2 := listen(t)
+	defer l2.Close()
+
+	g := Graphite{
+		Servers:      []string{l1.Addr().String() + ":a", l2.Addr().String() + ":b"},
+		Distribution: "consistent_hash",
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 20; i++ {
+		m, _ := metric.New(
+			"cpu",
+			map[string]string{"host": fmt.Sprintf("host%d", i)},
+			map[string]interface{}{"value": float64(i)},
+			time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC),
+		)
+		metrics = append(metrics, m)
+	}
+	require.NoError(t, g.Write(metrics))
+
+	received := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		select {
+		case line := <-lines1:
+			path := strings.Fields(line)[0]
+			received[path]++
+			require.Equal(t, 0, g.ring.get(path)[0])
+		case line := <-lines2:
+			path := strings.Fields(line)[0]
+			received[path]++
+			require.Equal(t, 1, g.ring.get(path)[0])
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for metrics")
+		}
+	}
+	require.Len(t, received, 20)
+}
+
+func TestGraphiteConsistentHashFailover(t *testing.T) {
+	l1, lines1 := listen(t)
+	defer l1.Close()
+	l3, lines3 := listen(t)
+	defer l3.Close()
+
+	// reserve a port nobody listens on
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddr := down.Addr().String()
+	down.Close()
+
+	g := Graphite{
+		Servers:      []string{l1.Addr().String() + ":a", downAddr + ":b", l3.Addr().String() + ":c"},
+		Distribution: "consistent_hash",
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 20; i++ {
+		m, _ := metric.New(
+			"cpu",
+			map[string]string{"host": fmt.Sprintf("host%d", i)},
+			map[string]interface{}{"value": float64(i)},
+			time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC),
+		)
+		metrics = append(metrics, m)
+	}
+	require.NoError(t, g.Write(metrics))
+
+	// each path goes to the next server on its own ring when its owner is
+	// down
+	expected := func(path string) int {
+		n, ok := g.owner(path, map[int]bool{1: true})
+		require.True(t, ok)
+		return n
+	}
+	rerouted := 0
+	received := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		select {
+		case line := <-lines1:
+			path := strings.Fields(line)[0]
+			received[path]++
+			if g.ring.get(path)[0] == 1 {
+				rerouted++
+			}
+			require.Equal(t, 0, expected(path))
+		case line := <-lines3:
+			path := strings.Fields(line)[0]
+			received[path]++
+			if g.ring.get(path)[0] == 1 {
+				rerouted++
+			}
+			require.Equal(t, 2, expected(path))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for metrics")
+		}
+	}
+	require.Len(t, received, 20)
+	require.NotZero(t, rerouted)
+}
+
+func TestGraphiteCircuitBreaker(t *testing.T) {
+	l, lines := listen(t)
+	defer l.Close()
+
+	// reserve a port nobody listens on
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddr := down.Addr().String()
+	down.Close()
+
+	g := Graphite{
+		Servers:          []string{downAddr, l.Addr().String()},
+		FailureThreshold: 1,
+		RetryInterval:    internal.Duration{Duration: time.Hour},
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	m, _ := metric.New(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1.0},
+		time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC),
+	)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, g.Write([]telegraf.Metric{m}))
+		select {
+		case line := <-lines:
+			require.Equal(t, "a.cpu 1 1289430000", line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for metrics")
+		}
+	}
+
+	// the unreachable server failed at most once before being skipped
+	require.False(t, g.servers[0].available(time.Now()))
+	require.Equal(t, 1, g.servers[0].failures)
+}
+
+func TestGraphiteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	g := Graphite{
+		Servers:  []string{conn.LocalAddr().String()},
+		Protocol: "udp",
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	m, _ := metric.New(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1.0},
+		time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, g.Write([]telegraf.Metric{m}))
+
+	buf := make([]byte, maxUDPPayload)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "a.cpu 1 1289430000\n", string(buf[:n]))
+}
+
+// failingConn accepts a number of writes then fails.
+type failingConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if c.writes == 0 {
+		return 0, fmt.Errorf("write failed")
+	}
+	c.writes--
+	return len(b), nil
+}
+
+func (c *failingConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *failingConn) Close() error { return nil }
+
+func TestGraphiteUDPRetryPartialWrite(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	g := Graphite{
+		Servers:  []string{conn.LocalAddr().String()},
+		Protocol: "udp",
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	var points []point
+	for i := 0; i < 3*maxUDPPayload/100; i++ {
+		points = append(points, point{line: []byte(fmt.Sprintf("%098d\n", i))})
+	}
+	payloads := g.encode(points)
+	require.True(t, len(payloads) > 2)
+
+	// the first datagram is sent before the connection fails
+	g.servers[0].close()
+	g.servers[0].conn = &failingConn{writes: 1}
+	require.NoError(t, g.sendTo(g.servers[0], points))
+
+	buf := make([]byte, maxUDPPayload)
+	for _, payload := range payloads[1:] {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, string(payload), string(buf[:n]))
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = conn.ReadFrom(buf)
+	require.Error(t, err, "datagram sent twice")
+}
+
+func TestGraphiteInvalidConfig(t *testing.T) {
+	g := Graphite{Protocol: "udp", Format: "pickle"}
+	require.Error(t, g.Connect())
+
+	g = Graphite{Distribution: "round_robin"}
+	require.Error(t, g.Connect())
+}
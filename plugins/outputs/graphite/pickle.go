@@ -0,0 +1,60 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// Pickle protocol 2 opcodes, see Lib/pickletools.py in the Python sources.
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleAppends    = 'e'
+	pickleBinUnicode = 'X'
+	pickleBinInt     = 'J'
+	pickleLong1      = 0x8a
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleStop       = '.'
+)
+
+// encodePickle encodes the points as a carbon pickle message: a 4 byte big
+// endian length header followed by a pickled list of
+// (path, (timestamp, value)) tuples.
+func encodePickle(points []point) []byte {
+	var body bytes.Buffer
+	body.Write([]byte{pickleProto, 2, pickleEmptyList, pickleMark})
+
+	for _, p := range points {
+		value, err := strconv.ParseFloat(p.value, 64)
+		if err != nil {
+			continue
+		}
+
+		body.WriteByte(pickleBinUnicode)
+		binary.Write(&body, binary.LittleEndian, uint32(len(p.path)))
+		body.WriteString(p.path)
+
+		if p.timestamp >= math.MinInt32 && p.timestamp <= math.MaxInt32 {
+			body.WriteByte(pickleBinInt)
+			binary.Write(&body, binary.LittleEndian, int32(p.timestamp))
+		} else {
+			body.Write([]byte{pickleLong1, 8})
+			binary.Write(&body, binary.LittleEndian, p.timestamp)
+		}
+
+		body.WriteByte(pickleBinFloat)
+		binary.Write(&body, binary.BigEndian, math.Float64bits(value))
+
+		body.Write([]byte{pickleTuple2, pickleTuple2})
+	}
+
+	body.Write([]byte{pickleAppends, pickleStop})
+
+	msg := make([]byte, 4, 4+body.Len())
+	binary.BigEndian.PutUint32(msg, uint32(body.Len()))
+	return append(msg, body.Bytes()...)
+}
@@ -0,0 +1,123 @@
+package graphite
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ringReplicas is the number of positions of each server on the hash ring,
+// the same as carbon-relay uses.
+const ringReplicas = 100
+
+// hashRing distributes metric paths over servers the way carbon-relay's
+// consistent hashing does: every server is placed on a ring of 16 bit
+// positions derived from the MD5 hash of "<key>:<replica>", where the key is
+// the Python representation of the (host, instance) tuple of the server, and
+// a path is owned by the first server at or after the position of its own
+// hash.
+type hashRing struct {
+	entries []ringEntry
+	size    int
+}
+
+type ringEntry struct {
+	position uint16
+	host     string
+	instance string
+	server   int
+}
+
+// newHashRing builds the ring of the servers, given as carbon destinations
+// of the form "host:port" or "host:port:instance".
+func newHashRing(servers []string) (*hashRing, error) {
+	r := &hashRing{size: len(servers)}
+	for i, s := range servers {
+		address, instance := splitInstance(s)
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server %q: %s", s, err)
+		}
+
+		key := carbonKey(host, instance)
+		for replica := 0; replica < ringReplicas; replica++ {
+			r.entries = append(r.entries, ringEntry{
+				position: ringPosition(fmt.Sprintf("%s:%d", key, replica)),
+				host:     host,
+				instance: instance,
+				server:   i,
+			})
+		}
+	}
+
+	// carbon-relay sorts the (position, (host, instance)) tuples of the ring
+	sort.Slice(r.entries, func(i, j int) bool {
+		a, b := r.entries[i], r.entries[j]
+		if a.position != b.position {
+			return a.position < b.position
+		}
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.instance != b.instance {
+			return a.instance < b.instance
+		}
+		return a.server < b.server
+	})
+	return r, nil
+}
+
+// splitInstance separates the optional carbon instance name from a server
+// address of the form "host:port:instance".
+func splitInstance(server string) (string, string) {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, ""
+	}
+	i := strings.LastIndex(server, ":")
+	if i < 0 {
+		return server, ""
+	}
+	return server[:i], server[i+1:]
+}
+
+// carbonKey formats the ring key of a server as carbon-relay does, which is
+// str((host, instance)) in Python; the port is not part of it.
+func carbonKey(host, instance string) string {
+	if instance == "" {
+		return fmt.Sprintf("('%s', None)", host)
+	}
+	return fmt.Sprintf("('%s', '%s')", host, instance)
+}
+
+func ringPosition(key string) uint16 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint16(sum[:2])
+}
+
+// get returns the indexes of all servers in the order they are met on the
+// ring starting at the position of key; the first one owns the key and the
+// others are its fallbacks.
+func (r *hashRing) get(key string) []int {
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	position := ringPosition(key)
+	start := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].position >= position
+	})
+
+	seen := make(map[int]bool, r.size)
+	servers := make([]int, 0, r.size)
+	for i := 0; i < len(r.entries) && len(servers) < r.size; i++ {
+		entry := r.entries[(start+i)%len(r.entries)]
+		if !seen[entry.server] {
+			seen[entry.server] = true
+			servers = append(servers, entry.server)
+		}
+	}
+	return servers
+}
@@ -1,10 +1,14 @@
 package kafka
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/influxdata/telegraf"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
@@ -36,6 +40,12 @@ type (
 
 		Version string `toml:"version"`
 
+		HeaderTags       []string `toml:"header_tags"`
+		ProducerMode     string   `toml:"producer_mode"`
+		MaxInFlight      int      `toml:"max_in_flight"`
+		IdempotentWrites bool     `toml:"idempotent_writes"`
+		BatchFormat      bool     `toml:"batch_format"`
+
 		// Legacy TLS config options
 		// TLS client certificate
 		Certificate string
@@ -51,8 +61,17 @@ type (
 		// SASL Password
 		SASLPassword string `toml:"sasl_password"`
 
-		tlsConfig tls.Config
-		producer  sarama.SyncProducer
+		tlsConfig     tls.Config
+		producer      sarama.SyncProducer
+		asyncProducer sarama.AsyncProducer
+
+		// inFlight bounds the number of messages not yet acknowledged by
+		// the brokers in async mode.
+		inFlight chan struct{}
+		wg       sync.WaitGroup
+
+		topicTmpl      *template.Template
+		routingKeyTmpl *template.Template
 
 		serializer serializers.Serializer
 	}
@@ -66,7 +85,9 @@ type (
 var sampleConfig = `
   ## URLs of kafka brokers
   brokers = ["localhost:9092"]
-  ## Kafka topic for producer messages
+  ## Kafka topic for producer messages.  The topic may be a template using
+  ## the metric name and tags, for example:
+  ##   topic = "telegraf_{{ .Name }}_{{ .Tag \"host\" }}"
   topic = "telegraf"
 
   ## Optional Client id
@@ -111,11 +132,37 @@ var sampleConfig = `
 
   ## Static routing key.  Used when no routing_tag is set or as a fallback
   ## when the tag specified in routing tag is not found.  If set to "random",
-  ## a random value will be generated for each message.
+  ## a random value will be generated for each message.  The key may be a
+  ## template like the topic.
   ##   ex: routing_key = "random"
   ##       routing_key = "telegraf"
+  ##       routing_key = "{{ .Tag \"region\" }}/{{ .Tag \"host\" }}"
   # routing_key = ""
 
+  ## Tags to add as Kafka record headers, keyed by the tag name.  Record
+  ## headers require version to be set to at least "0.11.0.0".
+  # header_tags = []
+
+  ## Producer mode, either "sync" or "async".  In sync mode each write waits
+  ## until all messages have been acknowledged and failed writes are retried.
+  ## In async mode writes return once the messages are queued; delivery
+  ## errors are logged and the messages are dropped.
+  # producer_mode = "sync"
+
+  ## Maximum number of unacknowledged messages in async mode, writes block
+  ## once the limit is reached.
+  # max_in_flight = 10000
+
+  ## Enable the idempotent producer, ensuring that exactly one copy of each
+  ## message is written.  Requires version to be set to at least "0.11.0.0"
+  ## and required_acks = -1.
+  # idempotent_writes = false
+
+  ## If true, the metrics of a write sharing the same topic, routing key and
+  ## headers are serialized into a single message using the batch format of
+  ## the data format.
+  # batch_format = false
+
   ## CompressionCodec represents the various compression codecs recognized by
   ## Kafka in messages.
   ##  0 : No compression
@@ -176,13 +223,18 @@ func ValidateTopicSuffixMethod(method string) error {
 }
 
 func (k *Kafka) GetTopicName(metric telegraf.Metric) string {
+	topic := k.Topic
+	if k.topicTmpl != nil {
+		topic = executeTemplate(k.topicTmpl, metric)
+	}
+
 	var topicName string
 	switch k.TopicSuffix.Method {
 	case "measurement":
-		topicName = k.Topic + k.TopicSuffix.Separator + metric.Name()
+		topicName = topic + k.TopicSuffix.Separator + metric.Name()
 	case "tags":
 		var topicNameComponents []string
-		topicNameComponents = append(topicNameComponents, k.Topic)
+		topicNameComponents = append(topicNameComponents, topic)
 		for _, tag := range k.TopicSuffix.Keys {
 			tagValue := metric.Tags()[tag]
 			if tagValue != "" {
@@ -191,11 +243,59 @@ func (k *Kafka) GetTopicName(metric telegraf.Metric) string {
 		}
 		topicName = strings.Join(topicNameComponents, k.TopicSuffix.Separator)
 	default:
-		topicName = k.Topic
+		topicName = topic
 	}
 	return topicName
 }
 
+// templateData is the data of the topic and routing key templates.
+type templateData struct {
+	metric telegraf.Metric
+}
+
+// Name returns the measurement name.
+func (d templateData) Name() string {
+	return d.metric.Name()
+}
+
+// Tag returns the value of the tag, or an empty string if it is not set.
+func (d templateData) Tag(key string) string {
+	v, _ := d.metric.GetTag(key)
+	return v
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template %q: %v", name, text, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, metric telegraf.Metric) string {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData{metric: metric}); err != nil {
+		log.Printf("E! [outputs.kafka] Error executing %s template: %v", tmpl.Name(), err)
+	}
+	return buf.String()
+}
+
+// parseTemplates parses the topic and routing key when they are templates.
+func (k *Kafka) parseTemplates() error {
+	var err error
+	if k.topicTmpl, err = parseTemplate("topic", k.Topic); err != nil {
+		return err
+	}
+	if k.routingKeyTmpl, err = parseTemplate("routing_key", k.RoutingKey); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (k *Kafka) SetSerializer(serializer serializers.Serializer) {
 	k.serializer = serializer
 }
@@ -205,6 +305,17 @@ func (k *Kafka) Connect() error {
 	if err != nil {
 		return err
 	}
+
+	if err := k.parseTemplates(); err != nil {
+		return err
+	}
+
+	switch k.ProducerMode {
+	case "", "sync", "async":
+	default:
+		return fmt.Errorf("unknown producer mode %q", k.ProducerMode)
+	}
+
 	config := sarama.NewConfig()
 
 	if k.Version != "" {
@@ -215,6 +326,10 @@ func (k *Kafka) Connect() error {
 		config.Version = version
 	}
 
+	if len(k.HeaderTags) > 0 && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return fmt.Errorf("header_tags requires version 0.11.0.0 or later")
+	}
+
 	if k.ClientID != "" {
 		config.ClientID = k.ClientID
 	} else {
@@ -230,6 +345,14 @@ func (k *Kafka) Connect() error {
 		config.Producer.MaxMessageBytes = k.MaxMessageBytes
 	}
 
+	if k.IdempotentWrites {
+		if k.RequiredAcks != int(sarama.WaitForAll) {
+			return fmt.Errorf("idempotent_writes requires required_acks = -1")
+		}
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+
 	// Legacy support ssl config
 	if k.Certificate != "" {
 		k.TLSCert = k.Certificate
@@ -253,6 +376,16 @@ func (k *Kafka) Connect() error {
 		config.Net.SASL.Enable = true
 	}
 
+	if k.ProducerMode == "async" {
+		producer, err := sarama.NewAsyncProducer(k.Brokers, config)
+		if err != nil {
+			return err
+		}
+		k.asyncProducer = producer
+		k.startAsync()
+		return nil
+	}
+
 	producer, err := sarama.NewSyncProducer(k.Brokers, config)
 	if err != nil {
 		return err
@@ -261,7 +394,43 @@ func (k *Kafka) Connect() error {
 	return nil
 }
 
+// startAsync consumes the results of the async producer, releasing a slot
+// of the in-flight limit for every acknowledged or failed message.
+func (k *Kafka) startAsync() {
+	maxInFlight := k.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 10000
+	}
+	k.inFlight = make(chan struct{}, maxInFlight)
+
+	k.wg.Add(2)
+	go func() {
+		defer k.wg.Done()
+		for range k.asyncProducer.Successes() {
+			<-k.inFlight
+		}
+	}()
+	go func() {
+		defer k.wg.Done()
+		for err := range k.asyncProducer.Errors() {
+			<-k.inFlight
+			if err.Err == sarama.ErrMessageSizeTooLarge {
+				log.Printf("E! Error writing to output [kafka]: Message too large, consider increasing `max_message_bytes`; dropping message")
+				continue
+			}
+			log.Printf("E! Error writing to output [kafka]: %v", err)
+		}
+	}()
+}
+
 func (k *Kafka) Close() error {
+	if k.asyncProducer != nil {
+		// Close flushes the buffered messages and closes the result
+		// channels once they have been delivered.
+		err := k.asyncProducer.Close()
+		k.wg.Wait()
+		return err
+	}
 	return k.producer.Close()
 }
 
@@ -286,29 +455,116 @@ func (k *Kafka) routingKey(metric telegraf.Metric) string {
 		return u.String()
 	}
 
+	if k.routingKeyTmpl != nil {
+		return executeTemplate(k.routingKeyTmpl, metric)
+	}
+
 	return k.RoutingKey
 }
 
-func (k *Kafka) Write(metrics []telegraf.Metric) error {
+// headers returns the record headers of the metric, sorted by key.
+func (k *Kafka) headers(metric telegraf.Metric) []sarama.RecordHeader {
+	var headers []sarama.RecordHeader
+	for _, key := range k.HeaderTags {
+		if value, ok := metric.GetTag(key); ok {
+			headers = append(headers, sarama.RecordHeader{
+				Key:   []byte(key),
+				Value: []byte(value),
+			})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return bytes.Compare(headers[i].Key, headers[j].Key) < 0
+	})
+	return headers
+}
+
+func (k *Kafka) newMessage(metric telegraf.Metric, value []byte) *sarama.ProducerMessage {
+	m := &sarama.ProducerMessage{
+		Topic:   k.GetTopicName(metric),
+		Value:   sarama.ByteEncoder(value),
+		Headers: k.headers(metric),
+	}
+	key := k.routingKey(metric)
+	if key != "" {
+		m.Key = sarama.StringEncoder(key)
+	}
+	return m
+}
+
+// buildMessages serializes the metrics into producer messages.
+func (k *Kafka) buildMessages(metrics []telegraf.Metric) ([]*sarama.ProducerMessage, error) {
+	if k.BatchFormat {
+		return k.buildBatchMessages(metrics)
+	}
+
 	msgs := make([]*sarama.ProducerMessage, 0, len(metrics))
 	for _, metric := range metrics {
 		buf, err := k.serializer.Serialize(metric)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		msgs = append(msgs, k.newMessage(metric, buf))
+	}
+	return msgs, nil
+}
+
+// buildBatchMessages serializes the metrics sharing a topic, routing key and
+// headers into a single message.
+func (k *Kafka) buildBatchMessages(metrics []telegraf.Metric) ([]*sarama.ProducerMessage, error) {
+	var msgs []*sarama.ProducerMessage
+	batches := make(map[string][]telegraf.Metric)
+	index := make(map[string]*sarama.ProducerMessage)
+
+	for _, metric := range metrics {
+		m := k.newMessage(metric, nil)
+
+		var id bytes.Buffer
+		id.WriteString(m.Topic)
+		id.WriteByte(0)
+		if m.Key != nil {
+			key, _ := m.Key.Encode()
+			id.Write(key)
+		}
+		for _, h := range m.Headers {
+			id.WriteByte(0)
+			id.Write(h.Key)
+			id.WriteByte('=')
+			id.Write(h.Value)
 		}
 
-		m := &sarama.ProducerMessage{
-			Topic: k.GetTopicName(metric),
-			Value: sarama.ByteEncoder(buf),
+		if _, ok := index[id.String()]; !ok {
+			index[id.String()] = m
+			msgs = append(msgs, m)
 		}
-		key := k.routingKey(metric)
-		if key != "" {
-			m.Key = sarama.StringEncoder(key)
+		batches[id.String()] = append(batches[id.String()], metric)
+	}
+
+	for id, m := range index {
+		buf, err := k.serializer.SerializeBatch(batches[id])
+		if err != nil {
+			return nil, err
+		}
+		m.Value = sarama.ByteEncoder(buf)
+	}
+	return msgs, nil
+}
+
+func (k *Kafka) Write(metrics []telegraf.Metric) error {
+	msgs, err := k.buildMessages(metrics)
+	if err != nil {
+		return err
+	}
+
+	if k.asyncProducer != nil {
+		for _, m := range msgs {
+			k.inFlight <- struct{}{}
+			k.asyncProducer.Input() <- m
 		}
-		msgs = append(msgs, m)
+		return nil
 	}
 
-	err := k.producer.SendMessages(msgs)
+	err = k.producer.SendMessages(msgs)
 	if err != nil {
 		// We could have many errors, return only the first encountered.
 		if errs, ok := err.(sarama.ProducerErrors); ok {
@@ -331,6 +587,8 @@ func init() {
 		return &Kafka{
 			MaxRetry:     3,
 			RequiredAcks: -1,
+			ProducerMode: "sync",
+			MaxInFlight:  10000,
 		}
 	})
 }
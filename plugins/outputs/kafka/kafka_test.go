@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers"
@@ -155,3 +156,218 @@ func TestRoutingKey(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplates(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "server01", "region": "us-east"},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0),
+	)
+
+	k := &Kafka{
+		Topic:       `telegraf_{{ .Name }}_{{ .Tag "region" }}`,
+		RoutingKey:  `{{ .Tag "host" }}{{ .Tag "missing" }}`,
+		TopicSuffix: TopicSuffix{Method: "measurement", Separator: "."},
+	}
+	require.NoError(t, k.parseTemplates())
+
+	require.Equal(t, "telegraf_cpu_us-east.cpu", k.GetTopicName(m))
+	require.Equal(t, "server01", k.routingKey(m))
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	k := &Kafka{Topic: "telegraf_{{ .Name"}
+	require.Error(t, k.parseTemplates())
+}
+
+func TestBuildMessages(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a", "dc": "1"},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"value": 2.0},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a", "dc": "1"},
+			map[string]interface{}{"value": 3.0},
+			time.Unix(1, 0),
+		),
+	}
+
+	t.Run("one message per metric", func(t *testing.T) {
+		k := &Kafka{
+			Topic:      "{{ .Name }}",
+			RoutingTag: "host",
+			HeaderTags: []string{"host", "dc"},
+			serializer: s,
+		}
+		require.NoError(t, k.parseTemplates())
+
+		msgs, err := k.buildMessages(metrics)
+		require.NoError(t, err)
+		require.Len(t, msgs, 3)
+
+		require.Equal(t, "cpu", msgs[0].Topic)
+		require.Equal(t, sarama.StringEncoder("a"), msgs[0].Key)
+		require.Equal(t, []sarama.RecordHeader{
+			{Key: []byte("dc"), Value: []byte("1")},
+			{Key: []byte("host"), Value: []byte("a")},
+		}, msgs[0].Headers)
+		require.Equal(t, sarama.ByteEncoder("cpu,dc=1,host=a value=1 0\n"), msgs[0].Value)
+
+		require.Equal(t, "mem", msgs[1].Topic)
+		require.Equal(t, []sarama.RecordHeader{
+			{Key: []byte("host"), Value: []byte("b")},
+		}, msgs[1].Headers)
+	})
+
+	t.Run("batch format", func(t *testing.T) {
+		k := &Kafka{
+			Topic:       "{{ .Name }}",
+			RoutingTag:  "host",
+			BatchFormat: true,
+			serializer:  s,
+		}
+		require.NoError(t, k.parseTemplates())
+
+		msgs, err := k.buildMessages(metrics)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+
+		require.Equal(t, "cpu", msgs[0].Topic)
+		require.Equal(t, sarama.StringEncoder("a"), msgs[0].Key)
+		require.Equal(t,
+			sarama.ByteEncoder("cpu,dc=1,host=a value=1 0\ncpu,dc=1,host=a value=3 1000000000\n"),
+			msgs[0].Value)
+		require.Equal(t, "mem", msgs[1].Topic)
+		require.Equal(t, sarama.ByteEncoder("mem,host=b value=2 0\n"), msgs[1].Value)
+	})
+}
+
+func TestConnectValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		kafka *Kafka
+	}{
+		{
+			name:  "unknown producer mode",
+			kafka: &Kafka{ProducerMode: "batch"},
+		},
+		{
+			name:  "headers require version",
+			kafka: &Kafka{HeaderTags: []string{"host"}},
+		},
+		{
+			name:  "idempotent writes require acks",
+			kafka: &Kafka{IdempotentWrites: true, Version: "1.0.0", RequiredAcks: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.kafka.Connect())
+		})
+	}
+}
+
+func newMockBroker(t *testing.T, produce *sarama.MockProduceResponse) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("Test", 0, broker.BrokerID()),
+		"InitProducerIDRequest": sarama.NewMockWrapper(&sarama.InitProducerIDResponse{ProducerID: 1}),
+		"ProduceRequest":        produce,
+	})
+	return broker
+}
+
+// writeAsync writes the metrics one at a time with a single message in
+// flight, so each write waits for the result of the previous message.
+func writeAsync(t *testing.T, k *Kafka, metrics []telegraf.Metric) {
+	done := make(chan error, 1)
+	go func() {
+		for _, m := range metrics {
+			if err := k.Write([]telegraf.Metric{m}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked on in-flight messages")
+	}
+}
+
+func produceRequests(broker *sarama.MockBroker) int {
+	n := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWriteAsync(t *testing.T) {
+	broker := newMockBroker(t, sarama.NewMockProduceResponse(t).SetVersion(3))
+	defer broker.Close()
+
+	s, _ := serializers.NewInfluxSerializer()
+	k := &Kafka{
+		Brokers:          []string{broker.Addr()},
+		Topic:            "Test",
+		Version:          "0.11.0.0",
+		RequiredAcks:     -1,
+		MaxRetry:         3,
+		ProducerMode:     "async",
+		MaxInFlight:      1,
+		IdempotentWrites: true,
+		serializer:       s,
+	}
+	require.NoError(t, k.Connect())
+
+	writeAsync(t, k, testutil.MockMetrics()[:1])
+	writeAsync(t, k, testutil.MockMetrics()[:1])
+	require.NoError(t, k.Close())
+
+	require.Equal(t, 2, produceRequests(broker))
+}
+
+func TestWriteAsyncErrors(t *testing.T) {
+	produce := sarama.NewMockProduceResponse(t).SetError("Test", 0, sarama.ErrMessageSizeTooLarge)
+	broker := newMockBroker(t, produce)
+	defer broker.Close()
+
+	s, _ := serializers.NewInfluxSerializer()
+	k := &Kafka{
+		Brokers:      []string{broker.Addr()},
+		Topic:        "Test",
+		RequiredAcks: -1,
+		ProducerMode: "async",
+		MaxInFlight:  1,
+		serializer:   s,
+	}
+	require.NoError(t, k.Connect())
+
+	// Failed messages release their slot as well.
+	m := testutil.MockMetrics()[0]
+	writeAsync(t, k, []telegraf.Metric{m, m, m})
+	require.NoError(t, k.Close())
+
+	require.Equal(t, 3, produceRequests(broker))
+}
@@ -0,0 +1,345 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// invalidLabelCharRE matches the characters not allowed in Loki label names,
+// which follow the Prometheus data model.
+var invalidLabelCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var sampleConfig = `
+  ## The domain of Loki
+  domain = "https://loki.domain.tld"
+
+  ## Endpoint to write api
+  # endpoint = "/loki/api/v1/push"
+
+  ## Connection timeout, defaults to "5s" if not set.
+  # timeout = "5s"
+
+  ## Basic auth credential
+  # username = "loki"
+  # password = "pass"
+
+  ## Tenant sent in the X-Scope-OrgID header for multi-tenant setups
+  # tenant_id = ""
+
+  ## Additional HTTP headers
+  # http_headers = {"X-Custom-Header" = "value"}
+
+  ## If true, the request body is compressed with gzip
+  # gzip_request = false
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+`
+
+const (
+	defaultEndpoint      = "/loki/api/v1/push"
+	defaultClientTimeout = 5 * time.Second
+)
+
+type Loki struct {
+	Domain      string            `toml:"domain"`
+	Endpoint    string            `toml:"endpoint"`
+	Timeout     internal.Duration `toml:"timeout"`
+	Username    string            `toml:"username"`
+	Password    string            `toml:"password"`
+	TenantID    string            `toml:"tenant_id"`
+	Headers     map[string]string `toml:"http_headers"`
+	GZipRequest bool              `toml:"gzip_request"`
+	tls.ClientConfig
+
+	url    string
+	client *http.Client
+}
+
+// Request is the body of a Loki push request.
+type Request struct {
+	Streams []Stream `json:"streams"`
+}
+
+// Stream is a set of log lines sharing the same labels.
+type Stream struct {
+	Labels map[string]string `json:"stream"`
+	Logs   []Log             `json:"values"`
+}
+
+// Log is a single entry in the form [timestamp in ns, line].
+type Log [2]string
+
+type streamEntry struct {
+	timestamp int64
+	line      string
+}
+
+func (l *Loki) SampleConfig() string {
+	return sampleConfig
+}
+
+func (l *Loki) Description() string {
+	return "Send logs to Loki"
+}
+
+func (l *Loki) Connect() error {
+	if l.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+
+	if l.Endpoint == "" {
+		l.Endpoint = defaultEndpoint
+	}
+	l.url = strings.TrimSuffix(l.Domain, "/") + l.Endpoint
+
+	if l.Timeout.Duration == 0 {
+		l.Timeout.Duration = defaultClientTimeout
+	}
+
+	tlsCfg, err := l.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	l.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: l.Timeout.Duration,
+	}
+
+	return nil
+}
+
+func (l *Loki) Close() error {
+	return nil
+}
+
+func (l *Loki) Write(metrics []telegraf.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	reqBody, err := json.Marshal(l.buildRequest(metrics))
+	if err != nil {
+		return err
+	}
+
+	return l.write(reqBody)
+}
+
+// buildRequest groups the metrics into streams keyed by their tag set and
+// sorts the entries of every stream by timestamp.
+func (l *Loki) buildRequest(metrics []telegraf.Metric) *Request {
+	entries := make(map[string][]streamEntry)
+	labels := make(map[string]map[string]string)
+	var keys []string
+
+	for _, m := range metrics {
+		line := logfmt(m.FieldList())
+		if line == "" {
+			continue
+		}
+
+		tags := labelSet(m)
+		tags["__name"] = m.Name()
+
+		key := streamKey(tags)
+		if _, ok := labels[key]; !ok {
+			labels[key] = tags
+			keys = append(keys, key)
+		}
+		entries[key] = append(entries[key], streamEntry{
+			timestamp: m.Time().UnixNano(),
+			line:      line,
+		})
+	}
+
+	req := &Request{Streams: make([]Stream, 0, len(keys))}
+	for _, key := range keys {
+		es := entries[key]
+		sort.SliceStable(es, func(i, j int) bool {
+			return es[i].timestamp < es[j].timestamp
+		})
+
+		logs := make([]Log, 0, len(es))
+		for _, e := range es {
+			logs = append(logs, Log{strconv.FormatInt(e.timestamp, 10), e.line})
+		}
+		req.Streams = append(req.Streams, Stream{Labels: labels[key], Logs: logs})
+	}
+
+	return req
+}
+
+func (l *Loki) write(reqBody []byte) error {
+	var reqBodyBuffer io.Reader = bytes.NewBuffer(reqBody)
+
+	var err error
+	if l.GZipRequest {
+		reqBodyBuffer, err = internal.CompressWithGzip(reqBodyBuffer)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.url, reqBodyBuffer)
+	if err != nil {
+		return err
+	}
+
+	if l.Username != "" || l.Password != "" {
+		req.SetBasicAuth(l.Username, l.Password)
+	}
+
+	for k, v := range l.Headers {
+		req.Header.Set(k, v)
+	}
+
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	req.Header.Set("Content-Type", "application/json")
+	if l.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.TenantID)
+	}
+	if l.GZipRequest {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("when writing to [%s] received status code: %d: %s",
+			l.url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// labelSet converts the tags of the metric to labels.  When several tag keys
+// convert to the same label name, a key that is a valid name as is takes
+// precedence, then the first key in sorted order.
+func labelSet(m telegraf.Metric) map[string]string {
+	tags := make(map[string]string, len(m.TagList())+1)
+	var sanitized []*telegraf.Tag
+	for _, tag := range m.TagList() {
+		if sanitizeLabel(tag.Key) != tag.Key {
+			sanitized = append(sanitized, tag)
+			continue
+		}
+		tags[tag.Key] = tag.Value
+	}
+	for _, tag := range sanitized {
+		name := sanitizeLabel(tag.Key)
+		if _, ok := tags[name]; ok {
+			log.Printf("D! [outputs.loki] Dropping tag %q of metric %q: label %q is already set", tag.Key, m.Name(), name)
+			continue
+		}
+		tags[name] = tag.Value
+	}
+	return tags
+}
+
+// sanitizeLabel converts a tag key into a valid label name, matching
+// [a-zA-Z_][a-zA-Z0-9_]*, by replacing invalid characters with an underscore.
+func sanitizeLabel(key string) string {
+	name := invalidLabelCharRE.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func streamKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteByte(0)
+		buf.WriteString(tags[k])
+		buf.WriteByte(0)
+	}
+	return buf.String()
+}
+
+// logfmt renders the fields as a logfmt line sorted by key.
+func logfmt(fields []*telegraf.Field) string {
+	sorted := make([]*telegraf.Field, len(fields))
+	copy(sorted, fields)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	var buf bytes.Buffer
+	for _, f := range sorted {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(f.Value))
+	}
+	return buf.String()
+}
+
+func logfmtValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func init() {
+	outputs.Add("loki", func() telegraf.Output {
+		return &Loki{
+			Endpoint: defaultEndpoint,
+			Timeout:  internal.Duration{Duration: defaultClientTimeout},
+		}
+	})
+}
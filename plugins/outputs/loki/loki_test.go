@@ -0,0 +1,189 @@
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func getMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{"host": "a", "appname": "sshd"},
+			map[string]interface{}{"message": "session opened", "severity_code": int64(6)},
+			time.Unix(0, 2),
+		),
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"message": "disk full"},
+			time.Unix(0, 3),
+		),
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{"appname": "sshd", "host": "a"},
+			map[string]interface{}{"message": "invalid=user", "ok": false},
+			time.Unix(0, 1),
+		),
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	plugin := &Loki{}
+	req := plugin.buildRequest(getMetrics())
+
+	expected := &Request{
+		Streams: []Stream{
+			{
+				Labels: map[string]string{"__name": "syslog", "appname": "sshd", "host": "a"},
+				Logs: []Log{
+					{"1", `message="invalid=user" ok=false`},
+					{"2", `message="session opened" severity_code=6`},
+				},
+			},
+			{
+				Labels: map[string]string{"__name": "syslog", "host": "b"},
+				Logs: []Log{
+					{"3", `message="disk full"`},
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, req)
+}
+
+func TestBuildRequestSanitizesLabels(t *testing.T) {
+	plugin := &Loki{}
+	req := plugin.buildRequest([]telegraf.Metric{
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{"host.name": "a", "9zone": "eu-west", "app-name": "sshd"},
+			map[string]interface{}{"message": "session opened"},
+			time.Unix(0, 1),
+		),
+	})
+
+	require.Len(t, req.Streams, 1)
+	require.Equal(t, map[string]string{
+		"__name":    "syslog",
+		"host_name": "a",
+		"_9zone":    "eu-west",
+		"app_name":  "sshd",
+	}, req.Streams[0].Labels)
+}
+
+func TestBuildRequestLabelCollision(t *testing.T) {
+	l := &Loki{}
+	req := l.buildRequest([]telegraf.Metric{
+		testutil.MustMetric(
+			"syslog",
+			map[string]string{"host-name": "a", "host.name": "b", "host_name": "c", "app-id": "d", "app.id": "e"},
+			map[string]interface{}{"message": "session opened"},
+			time.Unix(0, 1),
+		),
+	})
+
+	require.Len(t, req.Streams, 1)
+	require.Equal(t, map[string]string{
+		"__name":    "syslog",
+		"host_name": "c",
+		"app_id":    "d",
+	}, req.Streams[0].Labels)
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *Loki
+		status int
+		check  func(t *testing.T, r *http.Request)
+		err    bool
+	}{
+		{
+			name:   "basic auth and tenant",
+			plugin: &Loki{Username: "user", Password: "pass", TenantID: "team-a"},
+			status: http.StatusNoContent,
+			check: func(t *testing.T, r *http.Request) {
+				username, password, ok := r.BasicAuth()
+				require.True(t, ok)
+				require.Equal(t, "user", username)
+				require.Equal(t, "pass", password)
+				require.Equal(t, "team-a", r.Header.Get("X-Scope-OrgID"))
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			},
+		},
+		{
+			name:   "custom headers",
+			plugin: &Loki{Headers: map[string]string{"X-Test": "value"}},
+			status: http.StatusNoContent,
+			check: func(t *testing.T, r *http.Request) {
+				require.Equal(t, "value", r.Header.Get("X-Test"))
+				require.Empty(t, r.Header.Get("X-Scope-OrgID"))
+			},
+		},
+		{
+			name:   "gzip",
+			plugin: &Loki{GZipRequest: true},
+			status: http.StatusNoContent,
+			check: func(t *testing.T, r *http.Request) {
+				require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			},
+		},
+		{
+			name:   "error status",
+			plugin: &Loki{},
+			status: http.StatusBadRequest,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, defaultEndpoint, r.URL.Path)
+				require.Equal(t, http.MethodPost, r.Method)
+				if tt.check != nil {
+					tt.check(t, r)
+				}
+
+				var body io.Reader = r.Body
+				if r.Header.Get("Content-Encoding") == "gzip" {
+					gz, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					body = gz
+				}
+
+				var req Request
+				require.NoError(t, json.NewDecoder(body).Decode(&req))
+				require.Len(t, req.Streams, 2)
+
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			tt.plugin.Domain = ts.URL
+			require.NoError(t, tt.plugin.Connect())
+
+			err := tt.plugin.Write(getMetrics())
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMissingDomain(t *testing.T) {
+	plugin := &Loki{}
+	require.Error(t, plugin.Connect())
+}
@@ -0,0 +1,232 @@
+package prometheus_client
+
+import (
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/serializers/openmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// exemplarFunc returns the exemplar of the bucket with the given upper bound
+// of a histogram series, or nil if there is none.
+type exemplarFunc func(family string, labels []*dto.LabelPair, upperBound float64) *Exemplar
+
+// handler serves the registry in the Prometheus text format, or in the
+// OpenMetrics format if enabled and accepted by the scraper.
+func (p *PrometheusClient) handler(registry *prometheus.Registry) http.Handler {
+	promHandler := promhttp.HandlerFor(
+		registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+
+	if !p.EnableOpenMetrics {
+		return promHandler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsOpenMetrics(r.Header.Get("Accept")) {
+			promHandler.ServeHTTP(w, r)
+			return
+		}
+
+		families, err := registry.Gather()
+		if err != nil {
+			log.Printf("E! [outputs.prometheus_client] error gathering metrics: %s", err)
+			if len(families) == 0 {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", openMetricsContentType)
+		if err := writeOpenMetrics(w, families, p.exemplar); err != nil {
+			log.Printf("E! [outputs.prometheus_client] error writing metrics: %s", err)
+		}
+	})
+}
+
+func acceptsOpenMetrics(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.Split(part, ";")[0])
+		if mediaType == "application/openmetrics-text" {
+			return true
+		}
+	}
+	return false
+}
+
+// exemplar looks up the exemplar of a histogram bucket written to the output.
+func (p *PrometheusClient) exemplar(family string, labels []*dto.LabelPair, upperBound float64) *Exemplar {
+	p.Lock()
+	defer p.Unlock()
+
+	fam, ok := p.fam[family]
+	if !ok {
+		return nil
+	}
+
+	for _, sample := range fam.Samples {
+		if len(sample.Exemplars) == 0 || !labelsMatch(sample.Labels, labels) {
+			continue
+		}
+		return sample.Exemplars[upperBound]
+	}
+	return nil
+}
+
+// labelsMatch reports whether the label pairs of a collected metric belong to
+// the sample labels; labels unset on the sample are collected as empty.
+func labelsMatch(sampleLabels map[string]string, labels []*dto.LabelPair) bool {
+	n := 0
+	for _, pair := range labels {
+		if sampleLabels[pair.GetName()] != pair.GetValue() {
+			return false
+		}
+		if pair.GetValue() != "" {
+			n++
+		}
+	}
+
+	for _, v := range sampleLabels {
+		if v != "" {
+			n--
+		}
+	}
+	return n == 0
+}
+
+// writeOpenMetrics writes the metric families in the OpenMetrics text format.
+func writeOpenMetrics(w io.Writer, families []*dto.MetricFamily, exemplar exemplarFunc) error {
+	out := make([]*openmetrics.Family, 0, len(families))
+	for _, mf := range families {
+		f := &openmetrics.Family{
+			Name: mf.GetName(),
+			Help: mf.GetHelp(),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			// counter samples carry the _total suffix, the family does not
+			f.Name = strings.TrimSuffix(f.Name, "_total")
+			f.Type = "counter"
+		case dto.MetricType_GAUGE:
+			f.Type = "gauge"
+		case dto.MetricType_SUMMARY:
+			f.Type = "summary"
+		case dto.MetricType_HISTOGRAM:
+			f.Type = "histogram"
+		default:
+			f.Type = "unknown"
+		}
+
+		for _, m := range mf.Metric {
+			ts := time.Time{}
+			if m.TimestampMs != nil {
+				ts = time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond))
+			}
+			add := func(suffix string, extra *openmetrics.Label, value float64, e *Exemplar) {
+				f.Samples = append(f.Samples, &openmetrics.Sample{
+					Suffix:    suffix,
+					Labels:    sampleLabels(m.Label, extra),
+					Value:     value,
+					Timestamp: ts,
+					Exemplar:  toExemplar(e),
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("_total", nil, m.GetCounter().GetValue(), nil)
+			case dto.MetricType_GAUGE:
+				add("", nil, m.GetGauge().GetValue(), nil)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add("", &openmetrics.Label{Name: quantileLabel, Value: formatFloat(q.GetQuantile())}, q.GetValue(), nil)
+				}
+				add("_sum", nil, s.GetSampleSum(), nil)
+				add("_count", nil, float64(s.GetSampleCount()), nil)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				hasInf := false
+				for _, b := range h.Bucket {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						hasInf = true
+					}
+					add("_bucket", &openmetrics.Label{Name: bucketLabel, Value: formatFloat(b.GetUpperBound())},
+						float64(b.GetCumulativeCount()), exemplar(mf.GetName(), m.Label, b.GetUpperBound()))
+				}
+				if !hasInf {
+					add("_bucket", &openmetrics.Label{Name: bucketLabel, Value: "+Inf"},
+						float64(h.GetSampleCount()), exemplar(mf.GetName(), m.Label, math.Inf(1)))
+				}
+				add("_sum", nil, h.GetSampleSum(), nil)
+				add("_count", nil, float64(h.GetSampleCount()), nil)
+			default:
+				add("", nil, m.GetUntyped().GetValue(), nil)
+			}
+		}
+		out = append(out, f)
+	}
+
+	return openmetrics.WriteFamilies(w, out)
+}
+
+// sampleLabels converts the label pairs of a collected metric, with an
+// additional label such as le or quantile if extra is set.
+func sampleLabels(pairs []*dto.LabelPair, extra *openmetrics.Label) []openmetrics.Label {
+	labels := make([]openmetrics.Label, 0, len(pairs)+1)
+	for _, pair := range pairs {
+		labels = append(labels, openmetrics.Label{Name: pair.GetName(), Value: pair.GetValue()})
+	}
+	if extra != nil {
+		labels = append(labels, *extra)
+	}
+	return labels
+}
+
+// toExemplar converts an exemplar of a sample, with its labels sorted.
+func toExemplar(e *Exemplar) *openmetrics.Exemplar {
+	if e == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(e.Labels))
+	for k := range e.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labels := make([]openmetrics.Label, 0, len(keys))
+	for _, k := range keys {
+		labels = append(labels, openmetrics.Label{Name: k, Value: e.Labels[k]})
+	}
+	return &openmetrics.Exemplar{
+		Labels:    labels,
+		Value:     e.Value,
+		Timestamp: e.Timestamp,
+	}
+}
+
+// formatFloat formats the upper bound or quantile of a label.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,73 @@
+package prometheus_client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsOpenMetrics(t *testing.T) {
+	require.True(t, acceptsOpenMetrics(
+		"application/openmetrics-text; version=0.0.1,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"))
+	require.False(t, acceptsOpenMetrics("text/plain;version=0.0.4;q=0.5,*/*;q=0.1"))
+	require.False(t, acceptsOpenMetrics(""))
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	client := NewClient()
+	client.CollectorsExclude = []string{"gocollector", "process"}
+
+	now := time.Unix(1520879607, 789000000)
+	var metrics []telegraf.Metric
+	for _, b := range []struct {
+		le     string
+		fields map[string]interface{}
+	}{
+		{"0.5", map[string]interface{}{
+			"latency_bucket":            int64(2),
+			"latency_exemplar":          0.3,
+			"latency_exemplar_trace_id": "abc",
+		}},
+		{"+Inf", map[string]interface{}{
+			"latency_bucket": int64(3),
+			"latency_sum":    1.5,
+		}},
+	} {
+		m, err := metric.New("http", map[string]string{"le": b.le}, b.fields, now)
+		require.NoError(t, err)
+		metrics = append(metrics, m)
+	}
+
+	m, err := metric.New("requests", map[string]string{"path": `/a"b`},
+		map[string]interface{}{"value": 7.0}, now, telegraf.Counter)
+	require.NoError(t, err)
+	metrics = append(metrics, m)
+
+	require.NoError(t, client.Write(metrics))
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(client))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeOpenMetrics(&buf, families, client.exemplar))
+
+	expected := `# TYPE http_latency histogram
+# HELP http_latency Telegraf collected metric
+http_latency_bucket{le="0.5"} 2 # {trace_id="abc"} 0.3 1520879607.789
+http_latency_bucket{le="+Inf"} 3
+http_latency_sum 1.5
+http_latency_count 3
+# TYPE requests counter
+# HELP requests Telegraf collected metric
+requests_total{path="/a\"b"} 7
+# EOF
+`
+	require.Equal(t, expected, buf.String())
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/subtle"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
@@ -19,11 +20,20 @@ import (
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 
+const (
+	// bucketLabel is the tag holding the upper bound of a histogram bucket,
+	// as used by the histogram aggregator.
+	bucketLabel = "le"
+	// quantileLabel is the tag holding the quantile of a summary.
+	quantileLabel = "quantile"
+	// exemplarSuffix marks the fields holding the exemplar of a bucket.
+	exemplarSuffix = "_exemplar"
+)
+
 // SampleID uniquely identifies a Sample
 type SampleID string
 
@@ -38,12 +48,25 @@ type Sample struct {
 	// Histograms and Summaries need a count and a sum
 	Count uint64
 	Sum   float64
+	// Exemplars are the exemplars of a histogram by bucket upper bound.
+	Exemplars map[float64]*Exemplar
+	// bounds are the bucket upper bounds or quantiles reported since the
+	// buckets were last reset.
+	bounds map[float64]bool
 	// Expiration is the deadline that this Sample is valid until.
 	Expiration time.Time
 	// Timestamp time when sample was collected
 	Timestamp time.Time
 }
 
+// Exemplar is an example observation of a histogram bucket, exposed when
+// using the OpenMetrics format.
+type Exemplar struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
 // MetricFamily contains the data required to build valid prometheus Metrics.
 type MetricFamily struct {
 	// Samples are the Sample belonging to this MetricFamily.
@@ -67,6 +90,8 @@ type PrometheusClient struct {
 	CollectorsExclude  []string          `toml:"collectors_exclude"`
 	StringAsLabel      bool              `toml:"string_as_label"`
 	UseTimestamps      bool              `toml:"use_time_stamps"`
+	EnableOpenMetrics  bool              `toml:"enable_openmetrics"`
+	QuantileAsSummary  bool              `toml:"quantile_as_summary"`
 
 	server *http.Server
 
@@ -103,6 +128,14 @@ var sampleConfig = `
   ## Unless set to false all string metrics will be sent as labels.
   # string_as_label = true
 
+  ## Expose metrics in the OpenMetrics format, including histogram
+  ## exemplars, to scrapers asking for it in the Accept header.
+  # enable_openmetrics = false
+
+  ## Merge untyped metrics with a "quantile" tag, such as the output of a
+  ## quantile aggregator, into summaries.
+  # quantile_as_summary = false
+
   ## If set, enable TLS with the given certificate.
   # tls_cert = "/etc/ssl/telegraf.crt"
   # tls_key = "/etc/ssl/telegraf.key"
@@ -189,8 +222,7 @@ func (p *PrometheusClient) Start() error {
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle(p.Path, p.auth(promhttp.HandlerFor(
-		registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})))
+	mux.Handle(p.Path, p.auth(p.handler(registry)))
 
 	p.server = &http.Server{
 		Addr:    p.Listen,
@@ -377,6 +409,13 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 			labels[sanitize(k)] = v
 		}
 
+		// Histograms and summaries built by aggregators are split over one
+		// metric per bucket or quantile and merged into a single sample.
+		if kind := p.aggregateType(point); kind != telegraf.Untyped {
+			p.addAggregatePoint(point, kind, labels, now)
+			continue
+		}
+
 		// Prometheus doesn't have a string value type, so convert string
 		// fields to labels if enabled.
 		if p.StringAsLabel {
@@ -476,52 +515,266 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 		default:
 			for fn, fv := range point.Fields() {
 				// Ignore string and bool fields.
-				var value float64
-				switch fv := fv.(type) {
-				case int64:
-					value = float64(fv)
-				case uint64:
-					value = float64(fv)
-				case float64:
-					value = fv
-				default:
+				value, ok := toFloat(fv)
+				if !ok {
 					continue
 				}
 
-				sample := &Sample{
-					Labels:     labels,
-					Value:      value,
-					Expiration: now.Add(p.ExpirationInterval.Duration),
-					Timestamp:  point.Time(),
-				}
+				p.addField(point, labels, sampleID, fn, value, now)
+			}
+		}
+	}
+	return nil
+}
 
-				// Special handling of value field; supports passthrough from
-				// the prometheus input.
-				var mname string
-				switch point.Type() {
-				case telegraf.Counter:
-					if fn == "counter" {
-						mname = sanitize(point.Name())
-					}
-				case telegraf.Gauge:
-					if fn == "gauge" {
-						mname = sanitize(point.Name())
-					}
-				}
-				if mname == "" {
-					if fn == "value" {
-						mname = sanitize(point.Name())
-					} else {
-						mname = sanitize(fmt.Sprintf("%s_%s", point.Name(), fn))
-					}
-				}
+// addField adds a single numeric field as a sample of its own family.
+func (p *PrometheusClient) addField(point telegraf.Metric, labels map[string]string, sampleID SampleID, fn string, value float64, now time.Time) {
+	sample := &Sample{
+		Labels:     labels,
+		Value:      value,
+		Expiration: now.Add(p.ExpirationInterval.Duration),
+		Timestamp:  point.Time(),
+	}
 
-				p.addMetricFamily(point, sample, mname, sampleID)
+	// Special handling of value field; supports passthrough from
+	// the prometheus input.
+	var mname string
+	switch point.Type() {
+	case telegraf.Counter:
+		if fn == "counter" {
+			mname = sanitize(point.Name())
+		}
+	case telegraf.Gauge:
+		if fn == "gauge" {
+			mname = sanitize(point.Name())
+		}
+	}
+	if mname == "" {
+		if fn == "value" {
+			mname = sanitize(point.Name())
+		} else {
+			mname = sanitize(fmt.Sprintf("%s_%s", point.Name(), fn))
+		}
+	}
 
+	p.addMetricFamily(point, sample, mname, sampleID)
+}
+
+// aggregateType returns telegraf.Histogram for an untyped metric holding a
+// single bucket of a histogram, laid out as by the histogram aggregator with
+// an "le" tag and "_bucket" fields, and telegraf.Summary for an untyped
+// metric with a "quantile" tag if quantile_as_summary is set.  Any other
+// metric is reported as telegraf.Untyped.
+func (p *PrometheusClient) aggregateType(point telegraf.Metric) telegraf.ValueType {
+	if point.Type() != telegraf.Untyped {
+		return telegraf.Untyped
+	}
+	if point.HasTag(bucketLabel) {
+		for _, field := range point.FieldList() {
+			if strings.HasSuffix(field.Key, "_bucket") {
+				return telegraf.Histogram
 			}
 		}
 	}
-	return nil
+	if p.QuantileAsSummary && point.HasTag(quantileLabel) {
+		return telegraf.Summary
+	}
+	return telegraf.Untyped
+}
+
+// addAggregatePoint merges a metric holding one bucket or quantile into the
+// histogram or summary sample of each of its fields.  Bucket counts are read
+// from fields with the "_bucket" suffix, quantile values from fields without
+// a suffix, and the sum and count from the "_sum" and "_count" fields.  The
+// histogram count defaults to the count of the +Inf bucket.
+func (p *PrometheusClient) addAggregatePoint(point telegraf.Metric, kind telegraf.ValueType, labels map[string]string, now time.Time) {
+	label := bucketLabel
+	if kind == telegraf.Summary {
+		label = quantileLabel
+	}
+
+	bound, err := strconv.ParseFloat(point.Tags()[label], 64)
+	if err != nil {
+		log.Printf("E! [outputs.prometheus_client] invalid %s tag on %s: %s",
+			label, point.Name(), point.Tags()[label])
+		return
+	}
+
+	tags := point.Tags()
+	delete(tags, label)
+	sampleID := CreateSampleID(tags)
+
+	sampleLabels := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != sanitize(label) {
+			sampleLabels[k] = v
+		}
+	}
+
+	exemplars := make(map[string]*Exemplar)
+	samples := make(map[string]*Sample)
+	for fn, fv := range point.Fields() {
+		if base, label, ok := splitExemplarField(fn); ok && kind == telegraf.Histogram {
+			addExemplarField(exemplars, point, base, label, fv)
+			continue
+		}
+
+		// String fields are only used as labels.
+		if value, ok := fv.(string); ok && p.StringAsLabel {
+			sampleLabels[sanitize(fn)] = value
+		}
+	}
+
+	for fn, fv := range point.Fields() {
+		if _, _, ok := splitExemplarField(fn); ok && kind == telegraf.Histogram {
+			continue
+		}
+
+		value, ok := toFloat(fv)
+		if !ok {
+			continue
+		}
+
+		var base string
+		switch {
+		case strings.HasSuffix(fn, "_sum"):
+			base = strings.TrimSuffix(fn, "_sum")
+		case strings.HasSuffix(fn, "_count"):
+			base = strings.TrimSuffix(fn, "_count")
+		case kind == telegraf.Histogram && strings.HasSuffix(fn, "_bucket"):
+			base = strings.TrimSuffix(fn, "_bucket")
+		case kind == telegraf.Summary:
+			base = fn
+		default:
+			p.addField(point, labels, CreateSampleID(point.Tags()), fn, value, now)
+			continue
+		}
+
+		mname := sanitize(fmt.Sprintf("%s_%s", point.Name(), base))
+		if base == "value" {
+			mname = sanitize(point.Name())
+		}
+
+		// Each metric updates the sample once, even with several fields.
+		sample, ok := samples[mname]
+		if !ok {
+			sample = p.aggregateSample(mname, kind, sampleLabels, sampleID, bound, point.Time())
+			samples[mname] = sample
+		}
+		sample.Expiration = now.Add(p.ExpirationInterval.Duration)
+
+		switch {
+		case strings.HasSuffix(fn, "_sum"):
+			sample.Sum = value
+		case strings.HasSuffix(fn, "_count"):
+			sample.Count = uint64(value)
+		case kind == telegraf.Summary:
+			sample.SummaryValue[bound] = value
+		case math.IsInf(bound, 1):
+			sample.Count = uint64(value)
+			if e, ok := exemplars[base]; ok {
+				sample.Exemplars[bound] = e
+			}
+		default:
+			sample.HistogramValue[bound] = uint64(value)
+			if e, ok := exemplars[base]; ok {
+				sample.Exemplars[bound] = e
+			}
+		}
+	}
+}
+
+// aggregateSample returns the histogram or summary sample for sampleID in
+// the named family, creating both if needed, to be updated with the bucket or
+// quantile bound.  Every collection reports each bound once, so the buckets
+// or quantiles of a sample are reset when a bound is reported again, and the
+// ones no longer reported are not exposed.
+func (p *PrometheusClient) aggregateSample(mname string, kind telegraf.ValueType, labels map[string]string, sampleID SampleID, bound float64, ts time.Time) *Sample {
+	fam, ok := p.fam[mname]
+	if !ok {
+		fam = &MetricFamily{
+			Samples:           make(map[SampleID]*Sample),
+			TelegrafValueType: kind,
+			LabelSet:          make(map[string]int),
+		}
+		p.fam[mname] = fam
+	}
+
+	sample, ok := fam.Samples[sampleID]
+	if !ok || sample.HistogramValue == nil || sample.SummaryValue == nil {
+		sample = &Sample{
+			Labels:         labels,
+			HistogramValue: make(map[float64]uint64),
+			SummaryValue:   make(map[float64]float64),
+			Exemplars:      make(map[float64]*Exemplar),
+			bounds:         make(map[float64]bool),
+		}
+		addSample(fam, sample, sampleID)
+	}
+
+	if sample.bounds[bound] {
+		sample.HistogramValue = make(map[float64]uint64)
+		sample.SummaryValue = make(map[float64]float64)
+		sample.Exemplars = make(map[float64]*Exemplar)
+		sample.bounds = make(map[float64]bool)
+		sample.Sum = 0
+		sample.Count = 0
+	}
+	sample.bounds[bound] = true
+	sample.Timestamp = ts
+	return sample
+}
+
+// splitExemplarField splits the name of an exemplar field into the field the
+// exemplar belongs to and the exemplar label it holds.  The "<field>_exemplar"
+// field holds the observed value, and string fields named
+// "<field>_exemplar_<label>" hold the exemplar labels.
+func splitExemplarField(fn string) (string, string, bool) {
+	if strings.HasSuffix(fn, exemplarSuffix) {
+		return strings.TrimSuffix(fn, exemplarSuffix), "", true
+	}
+
+	i := strings.Index(fn, exemplarSuffix+"_")
+	if i <= 0 {
+		return "", "", false
+	}
+	return fn[:i], fn[i+len(exemplarSuffix)+1:], true
+}
+
+// addExemplarField adds a field to the exemplar of the histogram base.
+func addExemplarField(exemplars map[string]*Exemplar, point telegraf.Metric, base, label string, fv interface{}) {
+	e, ok := exemplars[base]
+	if !ok {
+		e = &Exemplar{
+			Labels:    make(map[string]string),
+			Timestamp: point.Time(),
+		}
+		exemplars[base] = e
+	}
+
+	if label == "" {
+		if value, ok := toFloat(fv); ok {
+			e.Value = value
+		}
+		return
+	}
+
+	if value, ok := fv.(string); ok {
+		e.Labels[sanitize(label)] = value
+	}
+}
+
+func toFloat(fv interface{}) (float64, bool) {
+	switch fv := fv.(type) {
+	case int64:
+		return float64(fv), true
+	case uint64:
+		return float64(fv), true
+	case float64:
+		return fv, true
+	default:
+		return 0, false
+	}
 }
 
 func init() {
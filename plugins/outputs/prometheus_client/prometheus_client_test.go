@@ -691,3 +691,160 @@ func setupPrometheus() (*PrometheusClient, *prometheus_input.Prometheus, error)
 
 	return pTesting, p, nil
 }
+
+func TestWrite_AggregatorHistogram(t *testing.T) {
+	client := NewClient()
+
+	now := time.Now()
+	var metrics []telegraf.Metric
+	for le, count := range map[string]int64{"10": 1, "20": 3, "+Inf": 4} {
+		m, err := metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0", "le": le},
+			map[string]interface{}{"usage_idle_bucket": count},
+			now)
+		require.NoError(t, err)
+		metrics = append(metrics, m)
+	}
+
+	err := client.Write(metrics)
+	require.NoError(t, err)
+
+	_, ok := client.fam["cpu_usage_idle_bucket"]
+	require.False(t, ok)
+
+	fam, ok := client.fam["cpu_usage_idle"]
+	require.True(t, ok)
+	require.Equal(t, telegraf.Histogram, fam.TelegrafValueType)
+	require.Equal(t, 1, len(fam.Samples))
+	require.Equal(t, map[string]int{"cpu": 1}, fam.LabelSet)
+
+	sample, ok := fam.Samples[CreateSampleID(map[string]string{"cpu": "cpu0"})]
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"cpu": "cpu0"}, sample.Labels)
+	require.Equal(t, map[float64]uint64{10: 1, 20: 3}, sample.HistogramValue)
+	require.Equal(t, uint64(4), sample.Count)
+}
+
+func TestWrite_AggregatorHistogramReset(t *testing.T) {
+	client := NewClient()
+
+	type bucket struct {
+		le    string
+		count int64
+	}
+	write := func(ts time.Time, buckets []bucket, sum float64) {
+		var metrics []telegraf.Metric
+		for _, b := range buckets {
+			fields := map[string]interface{}{"usage_idle_bucket": b.count}
+			if b.le == "+Inf" {
+				fields["usage_idle_sum"] = sum
+			}
+			m, err := metric.New("cpu", map[string]string{"le": b.le}, fields, ts)
+			require.NoError(t, err)
+			metrics = append(metrics, m)
+		}
+		require.NoError(t, client.Write(metrics))
+	}
+
+	// The second collection replaces the first one, even with an older
+	// timestamp and its buckets in another order.
+	now := time.Now()
+	write(now, []bucket{{"10", 1}, {"20", 3}, {"+Inf", 4}}, 52.5)
+	write(now.Add(-time.Minute), []bucket{{"+Inf", 6}, {"20", 5}}, 80)
+
+	sample := client.fam["cpu_usage_idle"].Samples[CreateSampleID(map[string]string{})]
+	require.Equal(t, map[float64]uint64{20: 5}, sample.HistogramValue)
+	require.Equal(t, uint64(6), sample.Count)
+	require.Equal(t, 80.0, sample.Sum)
+}
+
+func TestWrite_UntypedLeAndQuantileTags(t *testing.T) {
+	client := NewClient()
+
+	now := time.Now()
+	m1, err := metric.New(
+		"latency",
+		map[string]string{"le": "0.5"},
+		map[string]interface{}{"value": 3.0},
+		now)
+	require.NoError(t, err)
+	m2, err := metric.New(
+		"response",
+		map[string]string{"quantile": "0.99"},
+		map[string]interface{}{"time": 12.0},
+		now)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Write([]telegraf.Metric{m1, m2}))
+
+	fam, ok := client.fam["latency"]
+	require.True(t, ok)
+	require.Equal(t, telegraf.Untyped, fam.TelegrafValueType)
+	require.Equal(t, map[string]int{"le": 1}, fam.LabelSet)
+
+	fam, ok = client.fam["response_time"]
+	require.True(t, ok)
+	require.Equal(t, telegraf.Untyped, fam.TelegrafValueType)
+	require.Equal(t, map[string]int{"quantile": 1}, fam.LabelSet)
+}
+
+func TestWrite_AggregatorHistogramExemplar(t *testing.T) {
+	client := NewClient()
+
+	now := time.Now()
+	m, err := metric.New(
+		"http",
+		map[string]string{"le": "0.5"},
+		map[string]interface{}{
+			"latency_bucket":            int64(2),
+			"latency_sum":               0.7,
+			"latency_exemplar":          0.3,
+			"latency_exemplar_trace_id": "abc",
+		},
+		now)
+	require.NoError(t, err)
+
+	err = client.Write([]telegraf.Metric{m})
+	require.NoError(t, err)
+
+	fam, ok := client.fam["http_latency"]
+	require.True(t, ok)
+
+	sample := fam.Samples[CreateSampleID(map[string]string{})]
+	require.Equal(t, 0.7, sample.Sum)
+	require.Equal(t, map[string]string{}, sample.Labels)
+	require.Equal(t, &Exemplar{
+		Labels:    map[string]string{"trace_id": "abc"},
+		Value:     0.3,
+		Timestamp: now,
+	}, sample.Exemplars[0.5])
+}
+
+func TestWrite_AggregatorSummary(t *testing.T) {
+	client := NewClient()
+	client.QuantileAsSummary = true
+
+	now := time.Now()
+	var metrics []telegraf.Metric
+	for q, v := range map[string]float64{"0.5": 12, "0.99": 40} {
+		m, err := metric.New(
+			"cpu",
+			map[string]string{"quantile": q},
+			map[string]interface{}{"usage_idle": v, "usage_idle_count": int64(8)},
+			now)
+		require.NoError(t, err)
+		metrics = append(metrics, m)
+	}
+
+	err := client.Write(metrics)
+	require.NoError(t, err)
+
+	fam, ok := client.fam["cpu_usage_idle"]
+	require.True(t, ok)
+	require.Equal(t, telegraf.Summary, fam.TelegrafValueType)
+
+	sample := fam.Samples[CreateSampleID(map[string]string{})]
+	require.Equal(t, map[float64]float64{0.5: 12, 0.99: 40}, sample.SummaryValue)
+	require.Equal(t, uint64(8), sample.Count)
+}
@@ -0,0 +1,222 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dialect holds the differences in SQL syntax and types between the
+// supported databases.
+type dialect struct {
+	// driver is the name of the database/sql driver.
+	driver string
+
+	// quote is the character used to quote identifiers.
+	quote string
+
+	// maxParams is the maximum number of bind parameters in a statement.
+	maxParams int
+
+	timestampType string
+	intType       string
+	uintType      string
+	floatType     string
+	boolType      string
+	stringType    string
+
+	// numbered placeholders, ie: $1, are used instead of ?.
+	numbered bool
+
+	// insertIgnore is the prefix and suffix of an insert statement that
+	// ignores rows with a conflicting primary key.
+	insertIgnorePrefix string
+	insertIgnoreSuffix string
+}
+
+var dialects = map[string]*dialect{
+	"postgres": {
+		driver:             "pgx",
+		quote:              `"`,
+		maxParams:          65535,
+		timestampType:      "TIMESTAMPTZ",
+		intType:            "BIGINT",
+		uintType:           "NUMERIC(20,0)",
+		floatType:          "DOUBLE PRECISION",
+		boolType:           "BOOLEAN",
+		stringType:         "TEXT",
+		numbered:           true,
+		insertIgnorePrefix: "INSERT INTO",
+		insertIgnoreSuffix: " ON CONFLICT DO NOTHING",
+	},
+	"mysql": {
+		driver:             "mysql",
+		quote:              "`",
+		maxParams:          65535,
+		timestampType:      "TIMESTAMP(6)",
+		intType:            "BIGINT",
+		uintType:           "BIGINT UNSIGNED",
+		floatType:          "DOUBLE",
+		boolType:           "BOOL",
+		stringType:         "TEXT",
+		insertIgnorePrefix: "INSERT IGNORE INTO",
+	},
+	"sqlite3": {
+		driver:             "sqlite3",
+		quote:              `"`,
+		maxParams:          999,
+		timestampType:      "TIMESTAMP",
+		intType:            "INTEGER",
+		uintType:           "INTEGER",
+		floatType:          "REAL",
+		boolType:           "BOOLEAN",
+		stringType:         "TEXT",
+		insertIgnorePrefix: "INSERT OR IGNORE INTO",
+	},
+}
+
+func init() {
+	dialects["pgx"] = dialects["postgres"]
+	dialects["sqlite"] = dialects["sqlite3"]
+}
+
+// quoteIdent quotes a table or column name.
+func (d *dialect) quoteIdent(name string) string {
+	return d.quote + strings.Replace(name, d.quote, d.quote+d.quote, -1) + d.quote
+}
+
+// placeholders returns the bind parameters for a row of n values, numbered
+// from offset when the dialect uses numbered parameters.
+func (d *dialect) placeholders(offset, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		if d.numbered {
+			params[i] = "$" + strconv.Itoa(offset+i+1)
+		} else {
+			params[i] = "?"
+		}
+	}
+	return "(" + strings.Join(params, ",") + ")"
+}
+
+// columnType returns the column type for a field value.
+func (d *dialect) columnType(value interface{}) (string, error) {
+	switch value.(type) {
+	case int64:
+		return d.intType, nil
+	case uint64:
+		return d.uintType, nil
+	case float64:
+		return d.floatType, nil
+	case bool:
+		return d.boolType, nil
+	case string:
+		return d.stringType, nil
+	default:
+		return "", fmt.Errorf("unsupported field type %T", value)
+	}
+}
+
+// columnKind is the kind of values stored in a column.
+type columnKind int
+
+const (
+	kindUnknown columnKind = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindNumeric
+	kindBool
+	kindString
+)
+
+// kindOf returns the kind of a column from its database type name, as
+// reported by the driver or used when creating the column.
+func kindOf(typ string) columnKind {
+	typ = strings.ToUpper(typ)
+	switch {
+	case typ == "":
+		return kindUnknown
+	case strings.Contains(typ, "UNSIGNED"):
+		return kindUint
+	case strings.Contains(typ, "INT"):
+		return kindInt
+	case strings.Contains(typ, "NUMERIC"), strings.Contains(typ, "DECIMAL"):
+		return kindNumeric
+	case strings.Contains(typ, "FLOAT"), strings.Contains(typ, "DOUBLE"), strings.Contains(typ, "REAL"):
+		return kindFloat
+	case strings.Contains(typ, "BOOL"):
+		return kindBool
+	case strings.Contains(typ, "CHAR"), strings.Contains(typ, "TEXT"), strings.Contains(typ, "CLOB"):
+		return kindString
+	default:
+		return kindUnknown
+	}
+}
+
+// convertTo converts a field value to the kind of its column.  It returns
+// false if the value cannot be stored in the column without losing it.
+func convertTo(kind columnKind, value interface{}) (interface{}, bool) {
+	if b, ok := value.(bool); ok && kind != kindBool && kind != kindString && kind != kindUnknown {
+		if b {
+			value = int64(1)
+		} else {
+			value = int64(0)
+		}
+	}
+
+	switch kind {
+	case kindInt:
+		switch v := value.(type) {
+		case int64:
+			return v, true
+		case uint64:
+			return int64(v), v <= math.MaxInt64
+		case float64:
+			return int64(v), v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64
+		}
+	case kindUint:
+		switch v := value.(type) {
+		case int64:
+			return uint64(v), v >= 0
+		case uint64:
+			return v, true
+		case float64:
+			return uint64(v), v == math.Trunc(v) && v >= 0 && v < math.MaxUint64
+		}
+	case kindFloat:
+		switch v := value.(type) {
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+	case kindNumeric:
+		switch value.(type) {
+		case int64, uint64, float64:
+			return value, true
+		}
+	case kindBool:
+		v, ok := value.(bool)
+		return v, ok
+	case kindString:
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), true
+		case uint64:
+			return strconv.FormatUint(v, 10), true
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		case string:
+			return v, true
+		}
+	default:
+		return value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,790 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/stdlib"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	insertValues = "values"
+	insertCopy   = "copy"
+
+	tagIDColumn = "tag_id"
+
+	// maxCachedTagSets limits the number of tag sets remembered for each
+	// tag table before the cache is reset.
+	maxCachedTagSets = 100000
+)
+
+var sampleConfig = `
+  ## Database driver, one of: postgres, mysql or sqlite3.
+  driver = "postgres"
+
+  ## Data source name passed to the driver.
+  ##   postgres: https://godoc.org/github.com/jackc/pgx#ParseDSN
+  ##   mysql:    https://github.com/go-sql-driver/mysql#dsn-data-source-name
+  ##   sqlite3:  https://github.com/mattn/go-sqlite3#connection-string
+  data_source_name = "postgres://telegraf@localhost/telegraf?sslmode=disable"
+
+  ## Timeout for each write.
+  # timeout = "5s"
+
+  ## Prefix added to the table names, the table of each measurement is named
+  ## after the measurement.
+  # table_prefix = ""
+
+  ## Name of the timestamp column.
+  # timestamp_column = "timestamp"
+
+  ## Create tables for new measurements.
+  # table_create = true
+
+  ## Add columns for new tags and fields.  When disabled, values without a
+  ## column are dropped.
+  # schema_update = true
+
+  ## Insert method, "values" to use multi-row INSERT statements or "copy" to
+  ## use COPY, which is only supported by postgres.
+  # insert_method = "values"
+
+  ## Maximum number of rows in a single INSERT statement.
+  # batch_size = 1000
+
+  ## Store tags in a separate table for each measurement, named with the
+  ## measurement table and the suffix.  The metric table references the tag
+  ## set with a "tag_id" column.
+  # tags_as_foreign_keys = false
+  # tag_table_suffix = "_tag"
+
+  ## Convert newly created tables to TimescaleDB hypertables, postgres only.
+  # timescale_hypertable = false
+  # timescale_chunk_time_interval = "7 days"
+`
+
+type SQL struct {
+	Driver                     string            `toml:"driver"`
+	DataSourceName             string            `toml:"data_source_name"`
+	Timeout                    internal.Duration `toml:"timeout"`
+	TablePrefix                string            `toml:"table_prefix"`
+	TimestampColumn            string            `toml:"timestamp_column"`
+	TableCreate                bool              `toml:"table_create"`
+	SchemaUpdate               bool              `toml:"schema_update"`
+	InsertMethod               string            `toml:"insert_method"`
+	BatchSize                  int               `toml:"batch_size"`
+	TagsAsForeignKeys          bool              `toml:"tags_as_foreign_keys"`
+	TagTableSuffix             string            `toml:"tag_table_suffix"`
+	TimescaleHypertable        bool              `toml:"timescale_hypertable"`
+	TimescaleChunkTimeInterval string            `toml:"timescale_chunk_time_interval"`
+
+	db      *gosql.DB
+	dialect *dialect
+	tables  map[string]*table
+}
+
+// table caches what is known about a table in the database.
+type table struct {
+	name    string
+	columns map[string]bool
+	// kinds of the columns, values are converted to them
+	kinds map[string]columnKind
+	// tag sets known to be in a tag table, by their id
+	tagSets map[int64]string
+}
+
+// row is a metric converted to the columns of its table.
+type row struct {
+	tagID  int64
+	tagSet string
+	tags   map[string]string
+	values map[string]interface{}
+	time   time.Time
+}
+
+func (s *SQL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SQL) Description() string {
+	return "Send metrics to a SQL database"
+}
+
+func (s *SQL) Connect() error {
+	d, ok := dialects[s.Driver]
+	if !ok {
+		return fmt.Errorf("unsupported driver %q", s.Driver)
+	}
+
+	switch s.InsertMethod {
+	case "":
+		s.InsertMethod = insertValues
+	case insertValues:
+	case insertCopy:
+		if d.driver != "pgx" {
+			return fmt.Errorf("insert_method %q is only supported by postgres", s.InsertMethod)
+		}
+	default:
+		return fmt.Errorf("unsupported insert_method %q", s.InsertMethod)
+	}
+
+	if s.TimescaleHypertable && d.driver != "pgx" {
+		return fmt.Errorf("timescale_hypertable is only supported by postgres")
+	}
+
+	if !registered(d.driver) {
+		return fmt.Errorf("driver %q is not available, sqlite3 requires telegraf built with cgo", s.Driver)
+	}
+
+	db, err := gosql.Open(d.driver, s.DataSourceName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout.Duration)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	s.dialect = d
+	s.tables = make(map[string]*table)
+	return nil
+}
+
+func (s *SQL) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func (s *SQL) Write(metrics []telegraf.Metric) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout.Duration)
+	defer cancel()
+
+	// Group the metrics by measurement, keeping the order of the first
+	// occurrence.
+	var names []string
+	byName := make(map[string][]telegraf.Metric)
+	for _, m := range metrics {
+		if _, ok := byName[m.Name()]; !ok {
+			names = append(names, m.Name())
+		}
+		byName[m.Name()] = append(byName[m.Name()], m)
+	}
+
+	for _, name := range names {
+		if err := s.writeMeasurement(ctx, name, byName[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) writeMeasurement(ctx context.Context, name string, metrics []telegraf.Metric) error {
+	tableName := s.TablePrefix + name
+
+	// Collect the columns and the type of the first value of each.
+	columnTypes := make(map[string]string)
+	tagColumns := make(map[string]bool)
+	rows := make([]*row, 0, len(metrics))
+	for _, m := range metrics {
+		r := &row{
+			tags:   m.Tags(),
+			values: make(map[string]interface{}),
+			time:   m.Time(),
+		}
+		for _, tag := range m.TagList() {
+			if s.reserved(tag.Key) {
+				log.Printf("D! [outputs.sql] Dropping tag %q: name of a reserved column", tag.Key)
+				delete(r.tags, tag.Key)
+				continue
+			}
+			tagColumns[tag.Key] = true
+			if !s.TagsAsForeignKeys {
+				r.values[tag.Key] = tag.Value
+				if _, ok := columnTypes[tag.Key]; !ok {
+					columnTypes[tag.Key] = s.dialect.stringType
+				}
+			}
+		}
+		for _, field := range m.FieldList() {
+			if s.reserved(field.Key) {
+				log.Printf("D! [outputs.sql] Dropping field %q: name of a reserved column", field.Key)
+				continue
+			}
+			if _, ok := r.tags[field.Key]; ok && !s.TagsAsForeignKeys {
+				log.Printf("W! [outputs.sql] Dropping field %q of measurement %q: name of a tag column", field.Key, name)
+				continue
+			}
+			typ, err := s.dialect.columnType(field.Value)
+			if err != nil {
+				log.Printf("D! [outputs.sql] Dropping field %q: %v", field.Key, err)
+				continue
+			}
+			if _, ok := columnTypes[field.Key]; !ok {
+				columnTypes[field.Key] = typ
+			}
+			r.values[field.Key] = field.Value
+		}
+		if s.TagsAsForeignKeys {
+			r.tagSet = tagSetKey(m.TagList())
+			r.tagID = tagSetID(r.tagSet)
+			r.values[tagIDColumn] = r.tagID
+			columnTypes[tagIDColumn] = s.dialect.intType
+		}
+		rows = append(rows, r)
+	}
+
+	if s.TagsAsForeignKeys {
+		tagTypes := make(map[string]string, len(tagColumns))
+		for key := range tagColumns {
+			tagTypes[key] = s.dialect.stringType
+		}
+		tagTable, err := s.ensureTable(ctx, tableName+s.tagTableSuffix(), tagTypes, true)
+		if err != nil {
+			return err
+		}
+		rows, err = s.writeTagSets(ctx, tagTable, rows)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+	}
+
+	t, err := s.ensureTable(ctx, tableName, columnTypes, false)
+	if err != nil {
+		return err
+	}
+
+	// Use the columns of the table in a stable order, with the timestamp
+	// first.
+	columns := []string{s.timestampColumn()}
+	for column := range columnTypes {
+		if t.columns[column] {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns[1:])
+
+	values := make([][]interface{}, 0, len(rows))
+	for _, r := range rows {
+		v := make([]interface{}, len(columns))
+		v[0] = r.time
+		for i, column := range columns[1:] {
+			value, ok := r.values[column]
+			if !ok {
+				continue
+			}
+			converted, ok := convertTo(t.kinds[column], value)
+			if !ok {
+				log.Printf("W! [outputs.sql] Dropping value %v of column %q in table %q: %T does not fit the column type",
+					value, column, t.name, value)
+				continue
+			}
+			v[i+1] = convertValue(converted)
+		}
+		values = append(values, v)
+	}
+
+	if s.InsertMethod == insertCopy {
+		return s.copyRows(ctx, t.name, columns, values)
+	}
+	return s.insertRows(ctx, s.insertPrefix(t.name, columns), "", columns, values)
+}
+
+// ensureTable creates the table or adds missing columns as required and
+// returns the cached table.
+func (s *SQL) ensureTable(ctx context.Context, name string, columnTypes map[string]string, tagTable bool) (*table, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		if s.TableCreate {
+			if err := s.createTable(ctx, name, columnTypes, tagTable); err != nil {
+				return nil, err
+			}
+		}
+
+		columns, kinds, err := s.tableColumns(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		t = &table{
+			name:    name,
+			columns: columns,
+			kinds:   kinds,
+			tagSets: make(map[int64]string),
+		}
+		s.tables[name] = t
+	}
+
+	var missing []string
+	for column := range columnTypes {
+		if !t.columns[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) == 0 {
+		return t, nil
+	}
+
+	// The columns may have been added by another writer.
+	columns, kinds, err := s.tableColumns(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	t.columns = columns
+	t.kinds = kinds
+
+	sort.Strings(missing)
+	for _, column := range missing {
+		if t.columns[column] {
+			continue
+		}
+		if !s.SchemaUpdate {
+			log.Printf("D! [outputs.sql] Dropping values for missing column %q of table %q", column, name)
+			continue
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+			s.dialect.quoteIdent(name), s.dialect.quoteIdent(column), columnTypes[column])
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("adding column %q to table %q: %v", column, name, err)
+		}
+		t.columns[column] = true
+		t.kinds[column] = kindOf(columnTypes[column])
+	}
+	return t, nil
+}
+
+func (s *SQL) createTable(ctx context.Context, name string, columnTypes map[string]string, tagTable bool) error {
+	var defs []string
+	if tagTable {
+		defs = append(defs, s.dialect.quoteIdent(tagIDColumn)+" "+s.dialect.intType+" PRIMARY KEY")
+	} else {
+		defs = append(defs, s.dialect.quoteIdent(s.timestampColumn())+" "+s.dialect.timestampType+" NOT NULL")
+	}
+
+	columns := make([]string, 0, len(columnTypes))
+	for column := range columnTypes {
+		if tagTable && column == tagIDColumn {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		defs = append(defs, s.dialect.quoteIdent(column)+" "+columnTypes[column])
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		s.dialect.quoteIdent(name), strings.Join(defs, ", "))
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("creating table %q: %v", name, err)
+	}
+
+	if s.TimescaleHypertable && !tagTable {
+		interval := s.TimescaleChunkTimeInterval
+		if interval == "" {
+			interval = "7 days"
+		}
+		stmt := "SELECT create_hypertable($1, $2, chunk_time_interval => $3::interval, if_not_exists => TRUE)"
+		if _, err := s.db.ExecContext(ctx, stmt, s.dialect.quoteIdent(name), s.timestampColumn(), interval); err != nil {
+			return fmt.Errorf("creating hypertable %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// tableColumns returns the names and the kinds of the columns of the table.
+// The kind is unknown if the driver doesn't report the column type.
+func (s *SQL) tableColumns(ctx context.Context, name string) (map[string]bool, map[string]columnKind, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM "+s.dialect.quoteIdent(name)+" WHERE 1=0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading columns of table %q: %v", name, err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string]bool, len(types))
+	kinds := make(map[string]columnKind, len(types))
+	for _, typ := range types {
+		columns[typ.Name()] = true
+		kinds[typ.Name()] = kindOf(typ.DatabaseTypeName())
+	}
+	return columns, kinds, nil
+}
+
+// writeTagSets inserts the tag sets not yet known to be in the tag table and
+// returns the rows, without those whose tag id collides with another tag set.
+func (s *SQL) writeTagSets(ctx context.Context, t *table, rows []*row) ([]*row, error) {
+	if len(t.tagSets) > maxCachedTagSets {
+		t.tagSets = make(map[int64]string)
+	}
+
+	colliding := make(map[int64]bool)
+	tagSets := make(map[int64]*row)
+	for _, r := range rows {
+		if set, ok := t.tagSets[r.tagID]; ok {
+			if set != r.tagSet {
+				colliding[r.tagID] = true
+			}
+			continue
+		}
+		if other, ok := tagSets[r.tagID]; ok && other.tagSet != r.tagSet {
+			colliding[r.tagID] = true
+			continue
+		}
+		tagSets[r.tagID] = r
+	}
+
+	if len(tagSets) > 0 {
+		if err := s.insertTagSets(ctx, t, tagSets); err != nil {
+			return nil, err
+		}
+
+		// The row of a tag set that was already in the table is kept, so
+		// read the new sets back to find tag ids shared by different sets.
+		stored, err := s.readTagSets(ctx, t, tagSets)
+		if err != nil {
+			return nil, err
+		}
+		for id, r := range tagSets {
+			if colliding[id] || !equalTags(t, stored[id], r.tags) {
+				colliding[id] = true
+				continue
+			}
+			t.tagSets[id] = r.tagSet
+		}
+	}
+
+	if len(colliding) == 0 {
+		return rows, nil
+	}
+
+	kept := rows[:0]
+	for _, r := range rows {
+		if colliding[r.tagID] {
+			log.Printf("E! [outputs.sql] Dropping metric of table %q: tag id %d of tags %v collides with another tag set",
+				t.name, r.tagID, r.tags)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// insertTagSets inserts the tag sets into the tag table, ignoring those
+// already in it.
+func (s *SQL) insertTagSets(ctx context.Context, t *table, tagSets map[int64]*row) error {
+	// Insert the sets grouped by their keys, so each statement has a fixed
+	// set of columns.
+	byColumns := make(map[string][][]interface{})
+	columnsByKey := make(map[string][]string)
+	var keys []string
+	for id, r := range tagSets {
+		columns := []string{tagIDColumn}
+		for key := range r.tags {
+			if t.columns[key] {
+				columns = append(columns, key)
+			}
+		}
+		sort.Strings(columns[1:])
+
+		values := make([]interface{}, len(columns))
+		values[0] = id
+		for i, column := range columns[1:] {
+			values[i+1] = r.tags[column]
+		}
+
+		key := strings.Join(columns, "\x00")
+		if _, ok := byColumns[key]; !ok {
+			keys = append(keys, key)
+			columnsByKey[key] = columns
+		}
+		byColumns[key] = append(byColumns[key], values)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		columns := columnsByKey[key]
+		prefix := s.dialect.insertIgnorePrefix + " " + s.columnList(t.name, columns) + " VALUES "
+		err := s.insertRows(ctx, prefix, s.dialect.insertIgnoreSuffix, columns, byColumns[key])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTagSets returns the tags stored in the tag table for the tag ids.
+func (s *SQL) readTagSets(ctx context.Context, t *table, tagSets map[int64]*row) (map[int64]map[string]string, error) {
+	ids := make([]interface{}, 0, len(tagSets))
+	for id := range tagSets {
+		ids = append(ids, id)
+	}
+
+	stored := make(map[int64]map[string]string, len(ids))
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > s.dialect.maxParams {
+			n = s.dialect.maxParams
+		}
+
+		placeholders := s.dialect.placeholders(0, n)
+		query := fmt.Sprintf("SELECT * FROM %s WHERE %s IN %s",
+			s.dialect.quoteIdent(t.name), s.dialect.quoteIdent(tagIDColumn), placeholders)
+		if err := s.scanTagSets(ctx, query, ids[:n], stored); err != nil {
+			return nil, fmt.Errorf("reading tag sets of table %q: %v", t.name, err)
+		}
+		ids = ids[n:]
+	}
+	return stored, nil
+}
+
+func (s *SQL) scanTagSets(ctx context.Context, query string, args []interface{}, stored map[int64]map[string]string) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]gosql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		var id int64
+		tags := make(map[string]string)
+		for i, column := range columns {
+			if column == tagIDColumn {
+				if id, err = strconv.ParseInt(values[i].String, 10, 64); err != nil {
+					return err
+				}
+				continue
+			}
+			if values[i].Valid {
+				tags[column] = values[i].String
+			}
+		}
+		stored[id] = tags
+	}
+	return rows.Err()
+}
+
+// equalTags reports whether the stored tags match the tags of a metric,
+// considering only the columns of the tag table.
+func equalTags(t *table, stored, tags map[string]string) bool {
+	if stored == nil {
+		return false
+	}
+	n := 0
+	for key, value := range tags {
+		if !t.columns[key] {
+			continue
+		}
+		if v, ok := stored[key]; !ok || v != value {
+			return false
+		}
+		n++
+	}
+	return n == len(stored)
+}
+
+// insertRows inserts the values with multi-row INSERT statements of at most
+// batch_size rows.
+func (s *SQL) insertRows(ctx context.Context, prefix, suffix string, columns []string, values [][]interface{}) error {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	limit := s.dialect.maxParams / len(columns)
+	if limit == 0 {
+		return fmt.Errorf("%d columns exceed the limit of %d parameters in a statement", len(columns), s.dialect.maxParams)
+	}
+	if batchSize > limit {
+		batchSize = limit
+	}
+
+	for len(values) > 0 {
+		n := batchSize
+		if n > len(values) {
+			n = len(values)
+		}
+
+		var stmt bytes.Buffer
+		args := make([]interface{}, 0, n*len(columns))
+		stmt.WriteString(prefix)
+		for i, v := range values[:n] {
+			if i > 0 {
+				stmt.WriteString(",")
+			}
+			stmt.WriteString(s.dialect.placeholders(len(args), len(v)))
+			args = append(args, v...)
+		}
+		stmt.WriteString(suffix)
+
+		if _, err := s.db.ExecContext(ctx, stmt.String(), args...); err != nil {
+			return err
+		}
+		values = values[n:]
+	}
+	return nil
+}
+
+// copyRows inserts the values using the postgres COPY protocol.
+func (s *SQL) copyRows(ctx context.Context, tableName string, columns []string, values [][]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conn, err := stdlib.AcquireConn(s.db)
+	if err != nil {
+		return err
+	}
+	defer stdlib.ReleaseConn(s.db, conn)
+
+	src := &copySource{ctx: ctx, CopyFromSource: pgx.CopyFromRows(values)}
+	_, err = conn.CopyFrom(pgx.Identifier{tableName}, columns, src)
+	return err
+}
+
+// copySource fails the COPY once the context is done, as pgx does not take
+// a context for it.
+type copySource struct {
+	ctx context.Context
+	pgx.CopyFromSource
+}
+
+func (c *copySource) Next() bool {
+	return c.ctx.Err() == nil && c.CopyFromSource.Next()
+}
+
+func (c *copySource) Err() error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.CopyFromSource.Err()
+}
+
+func (s *SQL) insertPrefix(tableName string, columns []string) string {
+	return "INSERT INTO " + s.columnList(tableName, columns) + " VALUES "
+}
+
+func (s *SQL) columnList(tableName string, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = s.dialect.quoteIdent(column)
+	}
+	return s.dialect.quoteIdent(tableName) + " (" + strings.Join(quoted, ",") + ")"
+}
+
+func (s *SQL) timestampColumn() string {
+	if s.TimestampColumn == "" {
+		return "timestamp"
+	}
+	return s.TimestampColumn
+}
+
+// reserved reports whether a tag or field has the name of a column written
+// by the plugin.
+func (s *SQL) reserved(key string) bool {
+	return key == s.timestampColumn() || (s.TagsAsForeignKeys && key == tagIDColumn)
+}
+
+func (s *SQL) tagTableSuffix() string {
+	if s.TagTableSuffix == "" {
+		return "_tag"
+	}
+	return s.TagTableSuffix
+}
+
+// convertValue converts values not supported by database/sql.
+func convertValue(v interface{}) interface{} {
+	if u, ok := v.(uint64); ok {
+		if u > math.MaxInt64 {
+			return strconv.FormatUint(u, 10)
+		}
+		return int64(u)
+	}
+	return v
+}
+
+// registered reports whether a database/sql driver is registered.
+func registered(driver string) bool {
+	for _, name := range gosql.Drivers() {
+		if name == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// tagSetKey returns a string identifying a set of tags.
+func tagSetKey(tags []*telegraf.Tag) string {
+	var buf bytes.Buffer
+	for _, tag := range tags {
+		buf.WriteString(tag.Key)
+		buf.WriteByte(0)
+		buf.WriteString(tag.Value)
+		buf.WriteByte(0)
+	}
+	return buf.String()
+}
+
+// tagSetID returns a stable identifier for a set of tags.  Different sets may
+// share an identifier, which writeTagSets detects.
+func tagSetID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+func init() {
+	outputs.Add("sql", func() telegraf.Output {
+		return &SQL{
+			Timeout:         internal.Duration{Duration: time.Second * 5},
+			TimestampColumn: "timestamp",
+			TableCreate:     true,
+			SchemaUpdate:    true,
+			InsertMethod:    insertValues,
+			BatchSize:       1000,
+			TagTableSuffix:  "_tag",
+		}
+	})
+}
@@ -0,0 +1,6 @@
+// +build cgo
+
+package sql
+
+// The sqlite3 driver is only available when built with cgo.
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,302 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	tlsint "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+const (
+	octetCounting  = "octet-counting"
+	nonTransparent = "non-transparent"
+)
+
+var trailers = map[string][]byte{
+	"LF":  {'\n'},
+	"NUL": {0},
+}
+
+type Syslog struct {
+	Address             string
+	KeepAlivePeriod     *internal.Duration
+	Framing             string `toml:"framing"`
+	Trailer             string `toml:"trailer"`
+	Separator           string `toml:"sdparam_separator"`
+	DefaultSdid         string `toml:"default_sdid"`
+	Sdids               []string
+	DefaultSeverityCode uint8  `toml:"default_severity_code"`
+	DefaultFacilityCode uint8  `toml:"default_facility_code"`
+	DefaultAppname      string `toml:"default_appname"`
+	SeverityKey         string `toml:"severity_key"`
+	FacilityKey         string `toml:"facility_key"`
+	HostnameKey         string `toml:"hostname_key"`
+	AppnameKey          string `toml:"appname_key"`
+	ProcIDKey           string `toml:"procid_key"`
+	MsgIDKey            string `toml:"msgid_key"`
+	MessageKey          string `toml:"message_key"`
+	tlsint.ClientConfig
+
+	net.Conn
+	mapper   *mapper
+	isStream bool
+}
+
+var sampleConfig = `
+  ## URL to connect to
+  ## ex: address = "tcp://127.0.0.1:8094"
+  ## ex: address = "tcp4://127.0.0.1:8094"
+  ## ex: address = "tcp6://127.0.0.1:8094"
+  ## ex: address = "tcp6://[2001:db8::1]:8094"
+  ## ex: address = "udp://127.0.0.1:8094"
+  ## ex: address = "udp4://127.0.0.1:8094"
+  ## ex: address = "udp6://127.0.0.1:8094"
+  ## If no port is specified, 6514 is used for tcp (RFC5425#section-4.1) and
+  ## 514 for udp (RFC5426#section-3.3).
+  address = "tcp://127.0.0.1:6514"
+
+  ## Optional TLS Config, only applies to stream sockets (e.g. TCP).
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Period between keep alive probes.
+  ## Only applies to TCP sockets.
+  ## 0 disables keep alive probes.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## The framing technique used to delimit messages on stream sockets,
+  ## either "octet-counting" (RFC5425#section-4.3.1, RFC6587#section-3.4.1)
+  ## or "non-transparent" (RFC6587#section-3.4.2).
+  # framing = "octet-counting"
+
+  ## The trailer appended to messages when using non-transparent framing,
+  ## either "LF" or "NUL".
+  # trailer = "LF"
+
+  ## SD-PARAMs settings
+  ## Syslog messages can contain key/value pairs within zero or more
+  ## structured data sections.  Fields whose name starts with one of the
+  ## sdids followed by the sdparam_separator are written as parameters of
+  ## that SD-ID; the default_sdid collects all other fields.
+  # sdparam_separator = "_"
+  # default_sdid = "default@32473"
+  # sdids = ["foo@123", "bar@456"]
+
+  ## Values used when the metric does not provide them.
+  # default_severity_code = 5
+  # default_facility_code = 1
+  # default_appname = "Telegraf"
+
+  ## Names of the tags or fields providing the message header values and
+  ## the free-form message.  Tags are searched before fields.
+  # severity_key = "severity_code"
+  # facility_key = "facility_code"
+  # hostname_key = "hostname"
+  # appname_key = "appname"
+  # procid_key = "procid"
+  # msgid_key = "msgid"
+  # message_key = "message"
+`
+
+func (s *Syslog) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Syslog) Description() string {
+	return "Configuration for Syslog server to send metrics to"
+}
+
+func (s *Syslog) Connect() error {
+	switch s.Framing {
+	case octetCounting, nonTransparent:
+	default:
+		return fmt.Errorf("unknown framing %q", s.Framing)
+	}
+	if _, ok := trailers[s.Trailer]; !ok {
+		return fmt.Errorf("unknown trailer %q", s.Trailer)
+	}
+
+	scheme, host, err := getAddressParts(s.Address)
+	if err != nil {
+		return err
+	}
+
+	switch scheme {
+	case "tcp", "tcp4", "tcp6":
+		s.isStream = true
+	case "udp", "udp4", "udp6":
+		s.isStream = false
+	default:
+		return fmt.Errorf("unknown protocol '%s' in '%s'", scheme, s.Address)
+	}
+
+	tlsCfg, err := s.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{}
+	if s.KeepAlivePeriod != nil {
+		if !s.isStream {
+			log.Printf("W! [outputs.syslog] unable to configure keep alive (%s): cannot set keep alive on a %s socket", s.Address, scheme)
+		} else if s.KeepAlivePeriod.Duration == 0 {
+			// a negative period disables keep alive probes
+			dialer.KeepAlive = -1
+		} else {
+			dialer.KeepAlive = s.KeepAlivePeriod.Duration
+		}
+	}
+
+	var c net.Conn
+	if tlsCfg == nil || !s.isStream {
+		c, err = dialer.Dial(scheme, host)
+	} else {
+		c, err = tls.DialWithDialer(dialer, scheme, host, tlsCfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.Conn = c
+	return nil
+}
+
+func (s *Syslog) Close() error {
+	if s.Conn == nil {
+		return nil
+	}
+	err := s.Conn.Close()
+	s.Conn = nil
+	return err
+}
+
+func (s *Syslog) Write(metrics []telegraf.Metric) error {
+	if s.Conn == nil {
+		// previous write failed with permanent error and socket was closed.
+		if err := s.Connect(); err != nil {
+			return err
+		}
+	}
+
+	for _, metric := range metrics {
+		msg, err := s.getMapper().mapMetric(metric)
+		if err != nil {
+			log.Printf("E! [outputs.syslog] Unable to create syslog message: %v", err)
+			continue
+		}
+
+		b, err := msg.MarshalBinary()
+		if err != nil {
+			log.Printf("E! [outputs.syslog] Unable to serialize syslog message: %v", err)
+			continue
+		}
+
+		if _, err := s.Conn.Write(s.frame(b)); err != nil {
+			if err, ok := err.(net.Error); !ok || !err.Temporary() {
+				s.Close()
+				return fmt.Errorf("closing connection: %v", err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// frame delimits a message for the transport in use.  Datagrams carry a
+// single message each and are sent as is (RFC5426).
+func (s *Syslog) frame(msg []byte) []byte {
+	if !s.isStream {
+		return msg
+	}
+
+	if s.Framing == octetCounting {
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return append(msg, trailers[s.Trailer]...)
+}
+
+func (s *Syslog) getMapper() *mapper {
+	if s.mapper == nil {
+		s.mapper = &mapper{
+			DefaultSdid:         s.DefaultSdid,
+			Sdids:               s.Sdids,
+			Separator:           s.Separator,
+			DefaultSeverityCode: s.DefaultSeverityCode,
+			DefaultFacilityCode: s.DefaultFacilityCode,
+			DefaultAppname:      s.DefaultAppname,
+			SeverityKey:         s.SeverityKey,
+			FacilityKey:         s.FacilityKey,
+			HostnameKey:         s.HostnameKey,
+			AppnameKey:          s.AppnameKey,
+			ProcIDKey:           s.ProcIDKey,
+			MsgIDKey:            s.MsgIDKey,
+			MessageKey:          s.MessageKey,
+		}
+	}
+	return s.mapper
+}
+
+func getAddressParts(a string) (string, string, error) {
+	parts := strings.SplitN(a, "://", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("missing protocol within address '%s'", a)
+	}
+
+	u, err := url.Parse(a)
+	if err != nil {
+		return "", "", err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+
+	return u.Scheme, net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// defaultPort returns the port assigned to syslog over the transport of the
+// scheme.
+func defaultPort(scheme string) string {
+	if strings.HasPrefix(scheme, "udp") {
+		// RFC5426#section-3.3
+		return "514"
+	}
+	// RFC5425#section-4.1
+	return "6514"
+}
+
+func newSyslog() *Syslog {
+	return &Syslog{
+		Framing:             octetCounting,
+		Trailer:             "LF",
+		Separator:           "_",
+		DefaultSeverityCode: 5, // notice
+		DefaultFacilityCode: 1, // user-level
+		DefaultAppname:      "Telegraf",
+		SeverityKey:         "severity_code",
+		FacilityKey:         "facility_code",
+		HostnameKey:         "hostname",
+		AppnameKey:          "appname",
+		ProcIDKey:           "procid",
+		MsgIDKey:            "msgid",
+		MessageKey:          "message",
+	}
+}
+
+func init() {
+	outputs.Add("syslog", func() telegraf.Output { return newSyslog() })
+}
@@ -0,0 +1,267 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	nilValue = "-"
+
+	maxHostnameLen = 255
+	maxAppnameLen  = 48
+	maxProcIDLen   = 128
+	maxMsgIDLen    = 32
+	maxSDNameLen   = 32
+)
+
+// message is a RFC5424 syslog message built from a metric.
+type message struct {
+	facility  uint8
+	severity  uint8
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procID    string
+	msgID     string
+	sdParams  map[string][]sdParam
+	msg       string
+}
+
+type sdParam struct {
+	name  string
+	value string
+}
+
+// mapper converts metrics into syslog messages.
+type mapper struct {
+	DefaultSdid         string
+	Sdids               []string
+	Separator           string
+	DefaultSeverityCode uint8
+	DefaultFacilityCode uint8
+	DefaultAppname      string
+	SeverityKey         string
+	FacilityKey         string
+	HostnameKey         string
+	AppnameKey          string
+	ProcIDKey           string
+	MsgIDKey            string
+	MessageKey          string
+}
+
+func (sm *mapper) mapMetric(metric telegraf.Metric) (*message, error) {
+	msg := &message{
+		facility:  sm.DefaultFacilityCode,
+		severity:  sm.DefaultSeverityCode,
+		timestamp: metric.Time(),
+		appname:   sm.DefaultAppname,
+		sdParams:  make(map[string][]sdParam),
+	}
+
+	if v, ok := lookup(metric, sm.SeverityKey); ok {
+		code, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || code > 7 {
+			return nil, fmt.Errorf("invalid severity code %q", v)
+		}
+		msg.severity = uint8(code)
+	}
+
+	if v, ok := lookup(metric, sm.FacilityKey); ok {
+		code, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || code > 23 {
+			return nil, fmt.Errorf("invalid facility code %q", v)
+		}
+		msg.facility = uint8(code)
+	}
+
+	if v, ok := lookup(metric, sm.HostnameKey); ok {
+		msg.hostname = v
+	} else if v, ok := metric.GetTag("source"); ok {
+		msg.hostname = v
+	} else if v, ok := metric.GetTag("host"); ok {
+		msg.hostname = v
+	}
+
+	if v, ok := lookup(metric, sm.AppnameKey); ok {
+		msg.appname = v
+	}
+	if v, ok := lookup(metric, sm.ProcIDKey); ok {
+		msg.procID = v
+	}
+	if v, ok := lookup(metric, sm.MsgIDKey); ok {
+		msg.msgID = v
+	}
+	if v, ok := lookup(metric, sm.MessageKey); ok {
+		msg.msg = v
+	}
+
+	used := map[string]bool{
+		sm.SeverityKey: true,
+		sm.FacilityKey: true,
+		sm.HostnameKey: true,
+		sm.AppnameKey:  true,
+		sm.ProcIDKey:   true,
+		sm.MsgIDKey:    true,
+		sm.MessageKey:  true,
+	}
+
+	for _, field := range metric.FieldList() {
+		if used[field.Key] {
+			continue
+		}
+
+		sdid, name := sm.splitSdid(field.Key)
+		if sdid == "" {
+			continue
+		}
+		msg.sdParams[sdid] = append(msg.sdParams[sdid], sdParam{
+			name:  name,
+			value: formatValue(field.Value),
+		})
+	}
+
+	return msg, nil
+}
+
+// splitSdid returns the SD-ID and parameter name of a field key.  Keys not
+// prefixed by one of the configured sdids belong to the default sdid, if set.
+func (sm *mapper) splitSdid(key string) (string, string) {
+	for _, sdid := range sm.Sdids {
+		prefix := sdid + sm.Separator
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return sdid, key[len(prefix):]
+		}
+	}
+
+	if sm.DefaultSdid != "" {
+		return sm.DefaultSdid, key
+	}
+	return "", ""
+}
+
+// lookup returns the value of key, searching the tags before the fields.
+func lookup(metric telegraf.Metric, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if v, ok := metric.GetTag(key); ok {
+		return v, true
+	}
+	if v, ok := metric.GetField(key); ok {
+		return formatValue(v), true
+	}
+	return "", false
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// MarshalBinary encodes the message according to RFC5424.
+func (m *message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<%d>1 ", int(m.facility)*8+int(m.severity))
+	buf.WriteString(m.timestamp.Format("2006-01-02T15:04:05.999999Z07:00"))
+	buf.WriteByte(' ')
+	buf.WriteString(headerValue(m.hostname, maxHostnameLen))
+	buf.WriteByte(' ')
+	buf.WriteString(headerValue(m.appname, maxAppnameLen))
+	buf.WriteByte(' ')
+	buf.WriteString(headerValue(m.procID, maxProcIDLen))
+	buf.WriteByte(' ')
+	buf.WriteString(headerValue(m.msgID, maxMsgIDLen))
+	buf.WriteByte(' ')
+
+	if len(m.sdParams) == 0 {
+		buf.WriteString(nilValue)
+	} else {
+		sdids := make([]string, 0, len(m.sdParams))
+		for sdid := range m.sdParams {
+			sdids = append(sdids, sdid)
+		}
+		sort.Strings(sdids)
+
+		for _, sdid := range sdids {
+			params := m.sdParams[sdid]
+			sort.Slice(params, func(i, j int) bool {
+				return params[i].name < params[j].name
+			})
+
+			buf.WriteByte('[')
+			buf.WriteString(sdName(sdid))
+			for _, p := range params {
+				buf.WriteByte(' ')
+				buf.WriteString(sdName(p.name))
+				buf.WriteString(`="`)
+				buf.WriteString(sdEscaper.Replace(p.value))
+				buf.WriteByte('"')
+			}
+			buf.WriteByte(']')
+		}
+	}
+
+	if m.msg != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(m.msg)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// headerValue restricts a header field to printable US-ASCII characters
+// and truncates it to its maximum length.
+func headerValue(s string, max int) string {
+	s = printable(s, nil)
+	if s == "" {
+		return nilValue
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// sdName converts s to a valid SD-NAME.
+func sdName(s string) string {
+	s = printable(s, func(r rune) bool {
+		return r == '=' || r == ']' || r == '"'
+	})
+	if len(s) > maxSDNameLen {
+		s = s[:maxSDNameLen]
+	}
+	return s
+}
+
+func printable(s string, exclude func(rune) bool) string {
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		if exclude != nil && exclude(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
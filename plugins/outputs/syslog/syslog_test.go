@@ -0,0 +1,183 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func getMetric() telegraf.Metric {
+	return testutil.MustMetric(
+		"syslog",
+		map[string]string{
+			"hostname": "example.org",
+			"appname":  "sshd",
+		},
+		map[string]interface{}{
+			"severity_code":  int64(3),
+			"facility_code":  int64(4),
+			"procid":         "42",
+			"msgid":          "LOGIN",
+			"message":        "session opened",
+			"origin@123_ip":  "10.0.0.1",
+			"origin@123_foo": `a"b]c`,
+			"other":          int64(1),
+		},
+		time.Date(2019, 1, 2, 3, 4, 5, 6000, time.UTC),
+	)
+}
+
+func TestMapMetric(t *testing.T) {
+	s := newSyslog()
+	s.Sdids = []string{"origin@123"}
+
+	msg, err := s.getMapper().mapMetric(getMetric())
+	require.NoError(t, err)
+
+	b, err := msg.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t,
+		`<35>1 2019-01-02T03:04:05.000006Z example.org sshd 42 LOGIN [origin@123 foo="a\"b\]c" ip="10.0.0.1"] session opened`,
+		string(b))
+}
+
+func TestMapMetricDefaults(t *testing.T) {
+	s := newSyslog()
+	s.DefaultSdid = "default@32473"
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"usage idle": 99.5},
+		time.Unix(0, 0).UTC(),
+	)
+
+	msg, err := s.getMapper().mapMetric(m)
+	require.NoError(t, err)
+
+	b, err := msg.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t,
+		`<13>1 1970-01-01T00:00:00Z server01 Telegraf - - [default@32473 usageidle="99.5"]`,
+		string(b))
+}
+
+func TestMapMetricInvalidSeverity(t *testing.T) {
+	s := newSyslog()
+
+	m := testutil.MustMetric(
+		"syslog",
+		map[string]string{},
+		map[string]interface{}{"severity_code": int64(8)},
+		time.Unix(0, 0),
+	)
+
+	_, err := s.getMapper().mapMetric(m)
+	require.Error(t, err)
+}
+
+func TestWriteTCP(t *testing.T) {
+	tests := []struct {
+		name     string
+		framing  string
+		trailer  string
+		expected string
+	}{
+		{
+			name:     "octet counting",
+			framing:  octetCounting,
+			trailer:  "LF",
+			expected: "57 <13>1 1970-01-01T00:00:00Z - Telegraf - - - first message",
+		},
+		{
+			name:     "non-transparent",
+			framing:  nonTransparent,
+			trailer:  "NUL",
+			expected: "<13>1 1970-01-01T00:00:00Z - Telegraf - - - first message\x00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer listener.Close()
+
+			s := newSyslog()
+			s.Address = "tcp://" + listener.Addr().String()
+			s.Framing = tt.framing
+			s.Trailer = tt.trailer
+			require.NoError(t, s.Connect())
+			defer s.Close()
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			m := testutil.MustMetric(
+				"syslog",
+				map[string]string{},
+				map[string]interface{}{"message": "first message"},
+				time.Unix(0, 0).UTC(),
+			)
+			require.NoError(t, s.Write([]telegraf.Metric{m}))
+
+			buf := make([]byte, len(tt.expected))
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = bufio.NewReader(conn).Read(buf)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(buf))
+		})
+	}
+}
+
+func TestWriteUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newSyslog()
+	s.Address = "udp://" + listener.LocalAddr().String()
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	m := testutil.MustMetric(
+		"syslog",
+		map[string]string{},
+		map[string]interface{}{"message": "first message"},
+		time.Unix(0, 0).UTC(),
+	)
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+
+	buf := make([]byte, 1024)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "<13>1 1970-01-01T00:00:00Z - Telegraf - - - first message", string(buf[:n]))
+}
+
+func TestConnectInvalidFraming(t *testing.T) {
+	s := newSyslog()
+	s.Address = "tcp://127.0.0.1:6514"
+	s.Framing = "unknown"
+	require.Error(t, s.Connect())
+}
+
+func TestGetAddressPartsDefaultPort(t *testing.T) {
+	for address, expected := range map[string]string{
+		"tcp://127.0.0.1":      "127.0.0.1:6514",
+		"tcp6://[::1]":         "[::1]:6514",
+		"udp://127.0.0.1":      "127.0.0.1:514",
+		"udp4://127.0.0.1":     "127.0.0.1:514",
+		"udp://127.0.0.1:1514": "127.0.0.1:1514",
+	} {
+		_, host, err := getAddressParts(address)
+		require.NoError(t, err)
+		require.Equal(t, expected, host, address)
+	}
+}
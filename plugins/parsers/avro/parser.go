@@ -0,0 +1,242 @@
+package avro
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/avro"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Parser decodes messages in the schema registry wire format, an Avro
+// encoded record prefixed by the ID of its schema, into metrics.
+type Parser struct {
+	MetricName      string
+	Measurement     string
+	Tags            []string
+	Fields          []string
+	Timestamp       string
+	TimestampFormat string
+	DefaultTags     map[string]string
+	Registry        *avro.Registry
+	TimeFunc        func() time.Time
+}
+
+// NewParser creates a parser using the given registry to resolve schemas.
+func NewParser(metricName string, registry *avro.Registry) *Parser {
+	return &Parser{
+		MetricName: metricName,
+		Registry:   registry,
+		TimeFunc:   time.Now,
+	}
+}
+
+// LoadSchemaFiles adds schemas to the registry from local files.  The base
+// name of each file must be the numeric schema ID, such as "42.avsc".
+func LoadSchemaFiles(registry *avro.Registry, files []string) error {
+	for _, file := range files {
+		base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		id, err := strconv.Atoi(base)
+		if err != nil {
+			return fmt.Errorf("schema file %q is not named after its schema id", file)
+		}
+
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		schema, err := avro.ParseSchema(string(text))
+		if err != nil {
+			return fmt.Errorf("schema file %q: %v", file, err)
+		}
+		registry.Add(id, schema)
+	}
+	return nil
+}
+
+// Parse decodes a single message into a metric.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if len(buf) == 0 {
+		return []telegraf.Metric{}, nil
+	}
+
+	id, datum, err := avro.SplitHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := p.Registry.SchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := avro.Decode(schema, datum)
+	if err != nil {
+		return nil, err
+	}
+
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema %d is not a record", id)
+	}
+
+	m, err := p.createMetric(schema, record)
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{m}, nil
+}
+
+// ParseLine decodes a single message into a metric.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: avro", line)
+	}
+	return metrics[0], nil
+}
+
+// SetDefaultTags adds tags to the metrics outputs of Parse and ParseLine.
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) createMetric(schema *avro.Schema, record map[string]interface{}) (telegraf.Metric, error) {
+	name := p.MetricName
+	if p.Measurement != "" {
+		if v, ok := record[p.Measurement].(string); ok && v != "" {
+			name = v
+		}
+	}
+	if name == "" {
+		name = schema.Name
+	}
+
+	tm := p.TimeFunc()
+	if p.Timestamp != "" {
+		v, ok := record[p.Timestamp]
+		if !ok || v == nil {
+			return nil, fmt.Errorf("timestamp field %q not found", p.Timestamp)
+		}
+		var err error
+		tm, err = parseTimestamp(v, p.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for _, key := range p.Tags {
+		v, ok := record[key]
+		if !ok || v == nil {
+			continue
+		}
+		tags[key] = toTagValue(v)
+	}
+
+	fields := make(map[string]interface{})
+	if len(p.Fields) > 0 {
+		for _, key := range p.Fields {
+			if v, ok := record[key]; ok {
+				flatten(fields, key, v)
+			}
+		}
+	} else {
+		for key, v := range record {
+			if key == p.Measurement || key == p.Timestamp || contains(p.Tags, key) {
+				continue
+			}
+			flatten(fields, key, v)
+		}
+	}
+
+	return metric.New(name, tags, fields, tm)
+}
+
+// flatten adds value to fields, nested records, maps and arrays are added
+// with their key or index joined to the prefix by an underscore.
+func flatten(fields map[string]interface{}, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, item := range v {
+			flatten(fields, prefix+"_"+k, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(fields, prefix+"_"+strconv.Itoa(i), item)
+		}
+	case []byte:
+		fields[prefix] = string(v)
+	case time.Time:
+		fields[prefix] = v.UnixNano()
+	default:
+		fields[prefix] = v
+	}
+}
+
+func parseTimestamp(value interface{}, format string) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		switch strings.ToLower(format) {
+		case "", "unix":
+			return time.Unix(v, 0).UTC(), nil
+		case "unix_ms":
+			return time.Unix(0, v*int64(time.Millisecond)).UTC(), nil
+		case "unix_us":
+			return time.Unix(0, v*int64(time.Microsecond)).UTC(), nil
+		case "unix_ns":
+			return time.Unix(0, v).UTC(), nil
+		}
+	case float64:
+		switch strings.ToLower(format) {
+		case "", "unix":
+			return time.Unix(0, int64(v*float64(time.Second))).UTC(), nil
+		case "unix_ms":
+			return time.Unix(0, int64(v*float64(time.Millisecond))).UTC(), nil
+		}
+	case string:
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		return time.Parse(format, v)
+	}
+	return time.Time{}, fmt.Errorf("cannot parse timestamp %v with format %q", value, format)
+}
+
+func toTagValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package avro
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/avro"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const queueSchema = `{
+  "type": "record",
+  "name": "queue",
+  "fields": [
+    {"name": "name", "type": "string"},
+    {"name": "broker", "type": "string"},
+    {"name": "depth", "type": "long"},
+    {"name": "rate", "type": ["null", "double"], "default": null},
+    {"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+  ]
+}`
+
+func encode(t *testing.T, id int, schema string, value map[string]interface{}) []byte {
+	s, err := avro.ParseSchema(schema)
+	require.NoError(t, err)
+	buf, err := avro.Encode(s, avro.AppendHeader(nil, id), value)
+	require.NoError(t, err)
+	return buf
+}
+
+func queueRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"name":   "orders",
+		"broker": "b1",
+		"depth":  int64(17),
+		"rate":   2.5,
+		"time":   time.Unix(42, 0),
+	}
+}
+
+func TestParseWithSchemaFiles(t *testing.T) {
+	registry := avro.NewRegistry("", time.Second)
+	err := LoadSchemaFiles(registry, []string{"testdata/42.avsc"})
+	require.NoError(t, err)
+
+	parser := NewParser("avro", registry)
+	parser.Tags = []string{"name", "broker"}
+	parser.Timestamp = "time"
+
+	metrics, err := parser.Parse(encode(t, 42, queueSchema, queueRecord()))
+	require.NoError(t, err)
+
+	expected := testutil.MustMetric(
+		"avro",
+		map[string]string{
+			"name":   "orders",
+			"broker": "b1",
+		},
+		map[string]interface{}{
+			"depth": int64(17),
+			"rate":  2.5,
+		},
+		time.Unix(42, 0),
+	)
+	require.Len(t, metrics, 1)
+	testutil.RequireMetricEqual(t, expected, metrics[0])
+}
+
+func TestParseWithRegistry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "/schemas/ids/7", r.URL.Path)
+		fmt.Fprintf(w, `{"schema": %q}`, queueSchema)
+	}))
+	defer ts.Close()
+
+	parser := NewParser("", avro.NewRegistry(ts.URL, time.Second))
+	parser.Measurement = "name"
+	parser.Tags = []string{"broker"}
+	parser.Fields = []string{"depth"}
+	parser.TimeFunc = func() time.Time { return time.Unix(0, 0) }
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 2; i++ {
+		var err error
+		metrics, err = parser.Parse(encode(t, 7, queueSchema, queueRecord()))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, requests)
+
+	expected := testutil.MustMetric(
+		"orders",
+		map[string]string{
+			"broker": "b1",
+		},
+		map[string]interface{}{
+			"depth": int64(17),
+		},
+		time.Unix(0, 0),
+	)
+	require.Len(t, metrics, 1)
+	testutil.RequireMetricEqual(t, expected, metrics[0])
+}
+
+func TestParseUnknownSchema(t *testing.T) {
+	parser := NewParser("avro", avro.NewRegistry("", time.Second))
+	_, err := parser.Parse(encode(t, 1, queueSchema, queueRecord()))
+	require.Error(t, err)
+}
+
+func TestParseNestedRecord(t *testing.T) {
+	schema := `{
+	  "type": "record",
+	  "name": "outer",
+	  "fields": [
+	    {"name": "id", "type": "string"},
+	    {"name": "ts", "type": "long"},
+	    {"name": "inner", "type": {"type": "record", "name": "inner", "fields": [
+	      {"name": "a", "type": "long"},
+	      {"name": "b", "type": "boolean"}
+	    ]}},
+	    {"name": "list", "type": {"type": "array", "items": "double"}}
+	  ]
+	}`
+	s, err := avro.ParseSchema(schema)
+	require.NoError(t, err)
+	registry := avro.NewRegistry("", time.Second)
+	registry.Add(3, s)
+
+	parser := NewParser("", registry)
+	parser.Tags = []string{"id"}
+	parser.Timestamp = "ts"
+	parser.TimestampFormat = "unix_ms"
+
+	metrics, err := parser.Parse(encode(t, 3, schema, map[string]interface{}{
+		"id":    "x",
+		"ts":    int64(1500),
+		"inner": map[string]interface{}{"a": int64(1), "b": true},
+		"list":  []interface{}{1.5, 2.5},
+	}))
+	require.NoError(t, err)
+
+	expected := testutil.MustMetric(
+		"outer",
+		map[string]string{
+			"id": "x",
+		},
+		map[string]interface{}{
+			"inner_a": int64(1),
+			"inner_b": true,
+			"list_0":  1.5,
+			"list_1":  2.5,
+		},
+		time.Unix(1, 500000000),
+	)
+	require.Len(t, metrics, 1)
+	testutil.RequireMetricEqual(t, expected, metrics[0])
+}
@@ -0,0 +1,370 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/tidwall/gjson"
+)
+
+// JSONObject configures the object mode of the parser, in which a part of
+// the document is selected and each selected object, as well as each
+// element of the arrays chosen for expansion, is converted to a metric.
+type JSONObject struct {
+	// Path is a GJSON path selecting an object or array of objects, the
+	// whole document is used if it is empty.
+	Path string
+
+	// MeasurementName is the name of the metrics, defaults to the parser
+	// metric name.
+	MeasurementName string
+	// MeasurementNameKey is the key of a value to use as the name.
+	MeasurementNameKey string
+
+	// TimestampKey is the key of the metric time, parsed according to
+	// TimestampFormat.
+	TimestampKey    string
+	TimestampFormat string
+
+	// ExpandArrays are globs matching the keys of arrays which should be
+	// expanded into a metric for each element.  The elements inherit the
+	// keys of their parent objects.
+	ExpandArrays []string
+
+	// TagKeys are globs matching the keys added as tags.
+	TagKeys []string
+
+	// IncludedKeys and ExcludedKeys are globs selecting the keys added as
+	// fields, by default all keys are added.
+	IncludedKeys []string
+	ExcludedKeys []string
+
+	// FieldTypes maps key globs to the type the field is converted to, one
+	// of int, uint, float, bool or string.
+	FieldTypes map[string]string
+
+	expandFilter filter.Filter
+	tagFilter    filter.Filter
+	fieldFilter  filter.Filter
+	typeFilters  []typeFilter
+}
+
+type typeFilter struct {
+	filter filter.Filter
+	typ    string
+}
+
+// Init compiles the globs of the object configuration.
+func (o *JSONObject) Init() error {
+	var err error
+	if o.expandFilter, err = filter.Compile(o.ExpandArrays); err != nil {
+		return fmt.Errorf("invalid expand_arrays: %v", err)
+	}
+	if o.tagFilter, err = filter.Compile(o.TagKeys); err != nil {
+		return fmt.Errorf("invalid tag_keys: %v", err)
+	}
+	if o.fieldFilter, err = filter.NewIncludeExcludeFilter(o.IncludedKeys, o.ExcludedKeys); err != nil {
+		return fmt.Errorf("invalid included_keys or excluded_keys: %v", err)
+	}
+
+	// Sort the globs so the first matching type is deterministic.
+	keys := make([]string, 0, len(o.FieldTypes))
+	for key := range o.FieldTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	o.typeFilters = nil
+	for _, key := range keys {
+		typ := o.FieldTypes[key]
+		switch typ {
+		case "int", "uint", "float", "bool", "string":
+		default:
+			return fmt.Errorf("invalid type %q for field %q", typ, key)
+		}
+		f, err := filter.Compile([]string{key})
+		if err != nil {
+			return fmt.Errorf("invalid field_types: %v", err)
+		}
+		o.typeFilters = append(o.typeFilters, typeFilter{filter: f, typ: typ})
+	}
+	return nil
+}
+
+func (p *JSONParser) parseObjects(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	for i := range p.Objects {
+		o := &p.Objects[i]
+
+		raw := buf
+		if o.Path != "" {
+			result := gjson.GetBytes(buf, o.Path)
+			if !result.Exists() {
+				continue
+			}
+			raw = []byte(result.Raw)
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("unable to parse out as JSON, %s", err)
+		}
+
+		// The elements of a top level array are always separate metrics.
+		var rows []map[string]interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			for _, elem := range v {
+				rows = append(rows, o.expand("", elem, []map[string]interface{}{{}})...)
+			}
+		case map[string]interface{}:
+			rows = o.expand("", v, []map[string]interface{}{{}})
+		default:
+			return nil, fmt.Errorf("path %q must lead to a JSON object or array of objects", o.Path)
+		}
+
+		for _, row := range rows {
+			m, err := p.objectMetric(o, row)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// expand adds the flattened value to every row.  Arrays matching the expand
+// filter multiply the rows, with one copy of each row for each element.
+func (o *JSONObject) expand(key string, value interface{}, rows []map[string]interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Iterate in a stable order so expanded metrics are reproducible.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rows = o.expand(joinKey(key, k), v[k], rows)
+		}
+	case []interface{}:
+		if key != "" && o.expandFilter != nil && o.expandFilter.Match(key) {
+			if len(v) == 0 {
+				return rows
+			}
+			expanded := make([]map[string]interface{}, 0, len(rows)*len(v))
+			for _, row := range rows {
+				for _, elem := range v {
+					// Keys of expanded objects are relative to the element,
+					// other values keep the name of the array.
+					elemKey := key
+					if _, ok := elem.(map[string]interface{}); ok {
+						elemKey = ""
+					}
+					expanded = append(expanded, o.expand(elemKey, elem, []map[string]interface{}{copyRow(row)})...)
+				}
+			}
+			return expanded
+		}
+		for i, elem := range v {
+			rows = o.expand(joinKey(key, strconv.Itoa(i)), elem, rows)
+		}
+	case nil:
+	default:
+		for _, row := range rows {
+			row[key] = v
+		}
+	}
+	return rows
+}
+
+func (p *JSONParser) objectMetric(o *JSONObject, row map[string]interface{}) (telegraf.Metric, error) {
+	name := o.MeasurementName
+	if name == "" {
+		name = p.MetricName
+	}
+	if o.MeasurementNameKey != "" {
+		if v, ok := row[o.MeasurementNameKey].(string); ok && v != "" {
+			name = v
+		}
+	}
+
+	nTime := time.Now().UTC()
+	if o.TimestampKey != "" {
+		v, ok := row[o.TimestampKey]
+		if !ok {
+			return nil, fmt.Errorf("JSON time key %q could not be found", o.TimestampKey)
+		}
+		var err error
+		nTime, err = parseTime(v, o.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+
+	fields := make(map[string]interface{})
+	for key, value := range row {
+		if key == o.MeasurementNameKey || key == o.TimestampKey {
+			continue
+		}
+
+		if o.tagFilter != nil && o.tagFilter.Match(key) {
+			tags[key] = toTagValue(value)
+			continue
+		}
+
+		if o.fieldFilter != nil && !o.fieldFilter.Match(key) {
+			continue
+		}
+
+		for _, tf := range o.typeFilters {
+			if tf.filter.Match(key) {
+				var err error
+				value, err = convertType(value, tf.typ)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %v", key, err)
+				}
+				break
+			}
+		}
+		fields[key] = value
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return metric.New(name, tags, fields, nTime)
+}
+
+// parseTime parses a time value, format must be unix, unix_ms or a time
+// layout.
+func parseTime(value interface{}, format string) (time.Time, error) {
+	if format == "" {
+		return time.Time{}, fmt.Errorf("use of timestamp key requires a timestamp format")
+	}
+
+	var nTime time.Time
+	if strings.EqualFold(format, "unix") || strings.EqualFold(format, "unix_ms") {
+		var err error
+		nTime, err = parseUnixTimestamp(value, format)
+		if err != nil {
+			return time.Time{}, err
+		}
+	} else {
+		timeStr, ok := value.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("time: %v could not be converted to string", value)
+		}
+		var err error
+		nTime, err = time.Parse(format, timeStr)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	//if the year is 0, set to current year
+	if nTime.Year() == 0 {
+		nTime = nTime.AddDate(time.Now().Year(), 0, 0)
+	}
+	return nTime, nil
+}
+
+func convertType(value interface{}, typ string) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		switch typ {
+		case "int":
+			return int64(v), nil
+		case "uint":
+			if v < 0 {
+				return nil, fmt.Errorf("cannot convert negative value %v to uint", v)
+			}
+			return uint64(v), nil
+		case "float":
+			return v, nil
+		case "bool":
+			return v != 0, nil
+		case "string":
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case string:
+		switch typ {
+		case "int":
+			return strconv.ParseInt(v, 10, 64)
+		case "uint":
+			return strconv.ParseUint(v, 10, 64)
+		case "float":
+			return strconv.ParseFloat(v, 64)
+		case "bool":
+			return strconv.ParseBool(v)
+		case "string":
+			return v, nil
+		}
+	case bool:
+		switch typ {
+		case "int":
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case "uint":
+			if v {
+				return uint64(1), nil
+			}
+			return uint64(0), nil
+		case "float":
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		case "bool":
+			return v, nil
+		case "string":
+			return strconv.FormatBool(v), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to %s", value, typ)
+}
+
+func toTagValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,164 @@
+package json
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const queuesJSON = `
+{
+    "broker": "rabbit-1",
+    "time": 1541185200,
+    "stats": {
+        "uptime": 3600
+    },
+    "queues": [
+        {
+            "name": "orders",
+            "depth": 12,
+            "durable": true,
+            "consumers": [{"id": "a", "prefetch": 10}, {"id": "b", "prefetch": 20}]
+        },
+        {
+            "name": "events",
+            "depth": 0,
+            "durable": false,
+            "consumers": []
+        }
+    ]
+}
+`
+
+func newObjectParser(t *testing.T, objects ...JSONObject) *JSONParser {
+	for i := range objects {
+		require.NoError(t, objects[i].Init())
+	}
+	return &JSONParser{
+		MetricName: "json",
+		Objects:    objects,
+	}
+}
+
+func TestObjectExpandArray(t *testing.T) {
+	parser := newObjectParser(t, JSONObject{
+		MeasurementName: "rabbitmq_queue",
+		TimestampKey:    "time",
+		TimestampFormat: "unix",
+		ExpandArrays:    []string{"queues"},
+		TagKeys:         []string{"broker", "name"},
+		ExcludedKeys:    []string{"consumers_*"},
+		FieldTypes: map[string]string{
+			"depth":    "int",
+			"stats_*":  "int",
+			"durable":  "bool",
+			"unknown*": "string",
+		},
+	})
+
+	metrics, err := parser.Parse([]byte(queuesJSON))
+	require.NoError(t, err)
+
+	tm := time.Unix(1541185200, 0)
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"rabbitmq_queue",
+			map[string]string{
+				"broker": "rabbit-1",
+				"name":   "orders",
+			},
+			map[string]interface{}{
+				"depth":        int64(12),
+				"durable":      true,
+				"stats_uptime": int64(3600),
+			},
+			tm,
+		),
+		testutil.MustMetric(
+			"rabbitmq_queue",
+			map[string]string{
+				"broker": "rabbit-1",
+				"name":   "events",
+			},
+			map[string]interface{}{
+				"depth":        int64(0),
+				"durable":      false,
+				"stats_uptime": int64(3600),
+			},
+			tm,
+		),
+	}
+	require.Len(t, metrics, len(expected))
+	for i := range expected {
+		testutil.RequireMetricEqual(t, expected[i], metrics[i])
+	}
+}
+
+func TestObjectExpandNestedArrays(t *testing.T) {
+	parser := newObjectParser(t, JSONObject{
+		Path:         "queues",
+		ExpandArrays: []string{"consumers"},
+		TagKeys:      []string{"name", "id"},
+		IncludedKeys: []string{"prefetch"},
+	})
+
+	metrics, err := parser.Parse([]byte(queuesJSON))
+	require.NoError(t, err)
+
+	// The queue without consumers has no remaining fields and is dropped.
+	require.Len(t, metrics, 2)
+	require.Equal(t, "json", metrics[0].Name())
+	require.Equal(t, map[string]string{"name": "orders", "id": "a"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"prefetch": float64(10)}, metrics[0].Fields())
+	require.Equal(t, map[string]string{"name": "orders", "id": "b"}, metrics[1].Tags())
+	require.Equal(t, map[string]interface{}{"prefetch": float64(20)}, metrics[1].Fields())
+}
+
+func TestObjectFlattenArrays(t *testing.T) {
+	parser := newObjectParser(t, JSONObject{
+		MeasurementNameKey: "broker",
+		IncludedKeys:       []string{"queues_*_depth"},
+	})
+
+	metrics, err := parser.Parse([]byte(queuesJSON))
+	require.NoError(t, err)
+
+	require.Len(t, metrics, 1)
+	require.Equal(t, "rabbit-1", metrics[0].Name())
+	require.Equal(t, map[string]interface{}{
+		"queues_0_depth": float64(12),
+		"queues_1_depth": float64(0),
+	}, metrics[0].Fields())
+}
+
+func TestObjectStringFields(t *testing.T) {
+	parser := newObjectParser(t, JSONObject{
+		FieldTypes: map[string]string{"count": "uint", "ratio": "float"},
+	})
+	parser.SetDefaultTags(map[string]string{"host": "localhost"})
+
+	metrics, err := parser.Parse([]byte(`[{"state": "up", "count": "42", "ratio": "0.5"}]`))
+	require.NoError(t, err)
+
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"host": "localhost"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{
+		"state": "up",
+		"count": uint64(42),
+		"ratio": float64(0.5),
+	}, metrics[0].Fields())
+}
+
+func TestObjectInvalidType(t *testing.T) {
+	o := JSONObject{FieldTypes: map[string]string{"count": "integer"}}
+	require.Error(t, o.Init())
+
+	parser := newObjectParser(t, JSONObject{
+		FieldTypes: map[string]string{"count": "int"},
+	})
+	_, err := parser.Parse([]byte(`{"count": "many"}`))
+	require.Error(t, err)
+}
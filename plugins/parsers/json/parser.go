@@ -30,6 +30,10 @@ type JSONParser struct {
 	JSONTimeKey    string
 	JSONTimeFormat string
 	DefaultTags    map[string]string
+
+	// Objects enables the object mode of the parser, the other options
+	// are ignored when set.
+	Objects []JSONObject
 }
 
 func (p *JSONParser) parseArray(buf []byte) ([]telegraf.Metric, error) {
@@ -206,6 +210,14 @@ func (p *JSONParser) switchFieldToTag(tags map[string]string, fields map[string]
 }
 
 func (p *JSONParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if len(p.Objects) > 0 {
+		buf = bytes.TrimPrefix(bytes.TrimSpace(buf), utf8BOM)
+		if len(buf) == 0 {
+			return make([]telegraf.Metric, 0), nil
+		}
+		return p.parseObjects(buf)
+	}
+
 	if p.JSONQuery != "" {
 		result := gjson.GetBytes(buf, p.JSONQuery)
 		buf = []byte(result.Raw)
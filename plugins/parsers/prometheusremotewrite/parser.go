@@ -0,0 +1,91 @@
+package prometheusremotewrite
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Parser decodes snappy compressed Prometheus remote write requests.  Each
+// sample becomes a metric named after the series with the sample in the
+// "value" field, matching the untyped metrics of the prometheus input.
+type Parser struct {
+	DefaultTags map[string]string
+}
+
+// NewParser creates a parser.
+func NewParser(defaultTags map[string]string) *Parser {
+	return &Parser{
+		DefaultTags: defaultTags,
+	}
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	data, err := snappy.Decode(nil, buf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress write request: %v", err)
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal write request: %v", err)
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	for _, ts := range req.Timeseries {
+		tags := make(map[string]string, len(p.DefaultTags)+len(ts.Labels))
+		for k, v := range p.DefaultTags {
+			tags[k] = v
+		}
+
+		var name string
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			tags[l.Name] = l.Value
+		}
+		if name == "" {
+			return nil, fmt.Errorf("series has no metric name: %v", ts.Labels)
+		}
+
+		for _, s := range ts.Samples {
+			// Skip stale markers and other invalid values.
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+
+			fields := map[string]interface{}{"value": s.Value}
+			t := time.Unix(0, s.Timestamp*int64(time.Millisecond))
+			m, err := metric.New(name, tags, fields, t, telegraf.Untyped)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: prometheusremotewrite", line)
+	}
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
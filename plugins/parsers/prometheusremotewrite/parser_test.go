@@ -0,0 +1,77 @@
+package prometheusremotewrite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, req *prompb.WriteRequest) []byte {
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	return snappy.Encode(nil, data)
+}
+
+func TestParse(t *testing.T) {
+	buf := encode(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{
+					{Name: "__name__", Value: "go_goroutines"},
+					{Name: "instance", Value: "localhost:9090"},
+				},
+				Samples: []*prompb.Sample{
+					{Value: 12, Timestamp: 1500},
+					{Value: math.NaN(), Timestamp: 2000},
+				},
+			},
+		},
+	})
+
+	parser := NewParser(map[string]string{"source": "prometheus"})
+	metrics, err := parser.Parse(buf)
+	require.NoError(t, err)
+
+	expected := testutil.MustMetric(
+		"go_goroutines",
+		map[string]string{
+			"instance": "localhost:9090",
+			"source":   "prometheus",
+		},
+		map[string]interface{}{
+			"value": 12.0,
+		},
+		time.Unix(1, 500000000),
+		telegraf.Untyped,
+	)
+	require.Len(t, metrics, 1)
+	testutil.RequireMetricEqual(t, expected, metrics[0])
+}
+
+func TestParseMissingName(t *testing.T) {
+	buf := encode(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "instance", Value: "a"}},
+				Samples: []*prompb.Sample{{Value: 1}},
+			},
+		},
+	})
+
+	parser := NewParser(nil)
+	_, err := parser.Parse(buf)
+	require.Error(t, err)
+}
+
+func TestParseInvalid(t *testing.T) {
+	parser := NewParser(nil)
+	_, err := parser.Parse([]byte("cpu value=1"))
+	require.Error(t, err)
+}
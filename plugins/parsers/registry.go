@@ -6,6 +6,8 @@ import (
 
 	"github.com/influxdata/telegraf"
 
+	avroschema "github.com/influxdata/telegraf/internal/avro"
+	"github.com/influxdata/telegraf/plugins/parsers/avro"
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
@@ -15,8 +17,10 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/json"
 	"github.com/influxdata/telegraf/plugins/parsers/logfmt"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheusremotewrite"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 	"github.com/influxdata/telegraf/plugins/parsers/wavefront"
+	"github.com/influxdata/telegraf/plugins/parsers/xml"
 )
 
 type ParserFunc func() (Parser, error)
@@ -86,6 +90,10 @@ type Config struct {
 	// time format
 	JSONTimeFormat string
 
+	// object mode configuration of the json parser, one entry for each
+	// [[inputs.x.json_object]] table
+	JSONObjects []json.JSONObject
+
 	// Authentication file for collectd
 	CollectdAuthFile string
 	// One of none (default), sign, or encrypt
@@ -138,6 +146,18 @@ type Config struct {
 	CSVTimestampColumn   string   `toml:"csv_timestamp_column"`
 	CSVTimestampFormat   string   `toml:"csv_timestamp_format"`
 	CSVTrimSpace         bool     `toml:"csv_trim_space"`
+
+	//avro configuration
+	AvroSchemaRegistry  string   `toml:"avro_schema_registry"`
+	AvroSchemaFiles     []string `toml:"avro_schema_files"`
+	AvroMeasurement     string   `toml:"avro_measurement"`
+	AvroTags            []string `toml:"avro_tags"`
+	AvroFields          []string `toml:"avro_fields"`
+	AvroTimestamp       string   `toml:"avro_timestamp"`
+	AvroTimestampFormat string   `toml:"avro_timestamp_format"`
+
+	//xml configuration, one entry for each [[inputs.x.xml]] table
+	XMLConfig []xml.Config
 }
 
 // NewParser returns a Parser interface based on the given config.
@@ -146,13 +166,14 @@ func NewParser(config *Config) (Parser, error) {
 	var parser Parser
 	switch config.DataFormat {
 	case "json":
-		parser = newJSONParser(config.MetricName,
+		parser, err = newJSONParser(config.MetricName,
 			config.TagKeys,
 			config.JSONNameKey,
 			config.JSONStringFields,
 			config.JSONQuery,
 			config.JSONTimeKey,
 			config.JSONTimeFormat,
+			config.JSONObjects,
 			config.DefaultTags)
 	case "value":
 		parser, err = NewValueParser(config.MetricName,
@@ -204,6 +225,20 @@ func NewParser(config *Config) (Parser, error) {
 			config.DefaultTags)
 	case "logfmt":
 		parser, err = NewLogFmtParser(config.MetricName, config.DefaultTags)
+	case "prometheusremotewrite":
+		parser, err = NewPrometheusRemoteWriteParser(config.DefaultTags)
+	case "avro":
+		parser, err = newAvroParser(config.MetricName,
+			config.AvroSchemaRegistry,
+			config.AvroSchemaFiles,
+			config.AvroMeasurement,
+			config.AvroTags,
+			config.AvroFields,
+			config.AvroTimestamp,
+			config.AvroTimestampFormat,
+			config.DefaultTags)
+	case "xml":
+		parser, err = NewXMLParser(config.MetricName, config.XMLConfig, config.DefaultTags)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -268,6 +303,36 @@ func newCSVParser(metricName string,
 	return parser, nil
 }
 
+func newAvroParser(metricName string,
+	schemaRegistry string,
+	schemaFiles []string,
+	measurement string,
+	tags []string,
+	fields []string,
+	timestamp string,
+	timestampFormat string,
+	defaultTags map[string]string) (Parser, error) {
+
+	if schemaRegistry == "" && len(schemaFiles) == 0 {
+		return nil, fmt.Errorf("one of `avro_schema_registry` or `avro_schema_files` must be specified")
+	}
+
+	registry := avroschema.NewRegistry(schemaRegistry, 5*time.Second)
+	err := avro.LoadSchemaFiles(registry, schemaFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := avro.NewParser(metricName, registry)
+	parser.Measurement = measurement
+	parser.Tags = tags
+	parser.Fields = fields
+	parser.Timestamp = timestamp
+	parser.TimestampFormat = timestampFormat
+	parser.DefaultTags = defaultTags
+	return parser, nil
+}
+
 func newJSONParser(
 	metricName string,
 	tagKeys []string,
@@ -276,8 +341,18 @@ func newJSONParser(
 	jsonQuery string,
 	timeKey string,
 	timeFormat string,
+	objects []json.JSONObject,
 	defaultTags map[string]string,
-) Parser {
+) (Parser, error) {
+	// Copy the object configurations, as they are shared by every parser
+	// created from the config.
+	objects = append([]json.JSONObject(nil), objects...)
+	for i := range objects {
+		if err := objects[i].Init(); err != nil {
+			return nil, err
+		}
+	}
+
 	parser := &json.JSONParser{
 		MetricName:     metricName,
 		TagKeys:        tagKeys,
@@ -286,9 +361,10 @@ func newJSONParser(
 		JSONQuery:      jsonQuery,
 		JSONTimeKey:    timeKey,
 		JSONTimeFormat: timeFormat,
+		Objects:        objects,
 		DefaultTags:    defaultTags,
 	}
-	return parser
+	return parser, nil
 }
 
 //Deprecated: Use NewParser to get a JSONParser object
@@ -391,6 +467,21 @@ func NewLogFmtParser(metricName string, defaultTags map[string]string) (Parser,
 	return logfmt.NewParser(metricName, defaultTags), nil
 }
 
+// NewPrometheusRemoteWriteParser returns a parser for Prometheus remote write
+// requests.
+func NewPrometheusRemoteWriteParser(defaultTags map[string]string) (Parser, error) {
+	return prometheusremotewrite.NewParser(defaultTags), nil
+}
+
+func NewXMLParser(metricName string, configs []xml.Config, defaultTags map[string]string) (Parser, error) {
+	parser, err := xml.NewParser(metricName, configs)
+	if err != nil {
+		return nil, err
+	}
+	parser.SetDefaultTags(defaultTags)
+	return parser, nil
+}
+
 func NewWavefrontParser(defaultTags map[string]string) (Parser, error) {
 	return wavefront.NewWavefrontParser(defaultTags), nil
 }
@@ -0,0 +1,372 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Config describes how to extract metrics from a document using XPath
+// expressions.  All expressions other than Selection are evaluated relative
+// to the selected node.
+type Config struct {
+	// Selection selects the nodes to create a metric from, defaults to the
+	// document root.
+	Selection string
+
+	// MetricName is an expression for the metric name, defaults to the name
+	// of the parser.
+	MetricName string
+
+	// Timestamp is an expression for the metric timestamp parsed using
+	// TimestampFormat, defaults to the current time.
+	Timestamp       string
+	TimestampFormat string
+
+	// Tags, FieldsInt and Fields map the tag or field name to an expression
+	// for the value.  The type of fields is taken from the result of the
+	// expression, fields in FieldsInt are converted to integers.
+	Tags      map[string]string
+	FieldsInt map[string]string
+	Fields    map[string]string
+
+	// FieldSelection selects nodes to add as fields in bulk, the name and
+	// value of each field are given by the FieldName and FieldValue
+	// expressions, which default to the node name and text.
+	FieldSelection string
+	FieldName      string
+	FieldValue     string
+}
+
+// Parser creates metrics from XML documents.
+type Parser struct {
+	MetricName  string
+	Configs     []Config
+	DefaultTags map[string]string
+	TimeFunc    func() time.Time
+
+	// exprs holds the compiled expressions, which keep evaluation state and
+	// are guarded by mu.
+	mu    sync.Mutex
+	exprs map[string]*xpath.Expr
+}
+
+// Default expressions of the optional settings.
+const (
+	defaultSelection  = "/"
+	defaultFieldName  = "name()"
+	defaultFieldValue = "."
+)
+
+// NewParser creates a parser.
+func NewParser(metricName string, configs []Config) (*Parser, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no xml configuration specified")
+	}
+
+	// Compile all expressions upfront so configuration errors are reported
+	// at startup and documents are parsed without compiling them again.
+	compiled := make(map[string]*xpath.Expr)
+	for _, config := range configs {
+		exprs := []string{
+			defaultSelection,
+			defaultFieldName,
+			defaultFieldValue,
+			config.Selection,
+			config.MetricName,
+			config.Timestamp,
+			config.FieldSelection,
+			config.FieldName,
+			config.FieldValue,
+		}
+		for _, m := range []map[string]string{config.Tags, config.FieldsInt, config.Fields} {
+			for _, expr := range m {
+				exprs = append(exprs, expr)
+			}
+		}
+		for _, expr := range exprs {
+			if _, ok := compiled[expr]; ok || expr == "" {
+				continue
+			}
+			e, err := xpath.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid xpath expression %q: %v", expr, err)
+			}
+			compiled[expr] = e
+		}
+	}
+
+	return &Parser{
+		MetricName: metricName,
+		Configs:    configs,
+		TimeFunc:   time.Now,
+		exprs:      compiled,
+	}, nil
+}
+
+// Parse creates metrics from an XML document.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return []telegraf.Metric{}, nil
+	}
+
+	doc, err := xmlquery.Parse(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.TimeFunc()
+	metrics := make([]telegraf.Metric, 0)
+	for _, config := range p.Configs {
+		selection := config.Selection
+		if selection == "" {
+			selection = defaultSelection
+		}
+		nodes, err := p.selectNodes(xmlquery.CreateXPathNavigator(doc), selection)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			m, err := p.parseNode(node, &config, now)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+// ParseLine creates a single metric from an XML document.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: xml", line)
+	}
+	return metrics[0], nil
+}
+
+// SetDefaultTags adds tags to the metrics outputs of Parse and ParseLine.
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) parseNode(node xpath.NodeNavigator, config *Config, now time.Time) (telegraf.Metric, error) {
+	name := p.MetricName
+	if config.MetricName != "" {
+		v, err := p.evalString(node, config.MetricName)
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			name = v
+		}
+	}
+
+	tm := now
+	if config.Timestamp != "" {
+		v, err := p.evalString(node, config.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		tm, err = parseTime(v, config.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for key, expr := range config.Tags {
+		v, err := p.evalString(node, expr)
+		if err != nil {
+			return nil, err
+		}
+		tags[key] = v
+	}
+
+	fields := make(map[string]interface{})
+	for key, expr := range config.FieldsInt {
+		v, err := p.eval(node, expr)
+		if err != nil {
+			return nil, err
+		}
+		iv, err := toInt(v)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", key, err)
+		}
+		fields[key] = iv
+	}
+	for key, expr := range config.Fields {
+		v, err := p.eval(node, expr)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = toField(v)
+	}
+
+	if config.FieldSelection != "" {
+		selected, err := p.selectNodes(node, config.FieldSelection)
+		if err != nil {
+			return nil, err
+		}
+
+		nameExpr := config.FieldName
+		if nameExpr == "" {
+			nameExpr = defaultFieldName
+		}
+		valueExpr := config.FieldValue
+		if valueExpr == "" {
+			valueExpr = defaultFieldValue
+		}
+
+		for _, n := range selected {
+			key, err := p.evalString(n, nameExpr)
+			if err != nil {
+				return nil, err
+			}
+			if key == "" {
+				continue
+			}
+			v, err := p.eval(n, valueExpr)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = toField(v)
+		}
+	}
+
+	return metric.New(name, tags, fields, tm)
+}
+
+// expr returns the expression compiled by NewParser.
+func (p *Parser) expr(query string) (*xpath.Expr, error) {
+	expr, ok := p.exprs[query]
+	if !ok {
+		return nil, fmt.Errorf("xpath expression %q was not compiled", query)
+	}
+	return expr, nil
+}
+
+// selectNodes returns a navigator positioned at each node selected by the
+// expression.  The navigators share the document root, so absolute
+// expressions evaluated against them select from the whole document.
+func (p *Parser) selectNodes(node xpath.NodeNavigator, query string) ([]xpath.NodeNavigator, error) {
+	expr, err := p.expr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []xpath.NodeNavigator
+	iter := expr.Select(node.Copy())
+	for iter.MoveNext() {
+		nodes = append(nodes, iter.Current().Copy())
+	}
+	return nodes, nil
+}
+
+// eval evaluates the expression with node as the context node.  The result
+// is one of float64, string, bool or, for node-sets, the text of the first
+// node as a string.
+func (p *Parser) eval(node xpath.NodeNavigator, query string) (interface{}, error) {
+	expr, err := p.expr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := expr.Evaluate(node.Copy()).(type) {
+	case *xpath.NodeIterator:
+		if v.MoveNext() {
+			return v.Current().Value(), nil
+		}
+		return "", nil
+	default:
+		return v, nil
+	}
+}
+
+func (p *Parser) evalString(node xpath.NodeNavigator, query string) (string, error) {
+	v, err := p.eval(node, query)
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func toField(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string, float64, bool:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case float64:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %v to integer", v)
+}
+
+func parseTime(v, format string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+
+	var unit time.Duration
+	switch strings.ToLower(format) {
+	case "":
+		return time.Parse(time.RFC3339Nano, v)
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	default:
+		return time.Parse(format, v)
+	}
+
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(0, i*int64(unit)).UTC(), nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse timestamp %q with format %q", v, format)
+	}
+	return time.Unix(0, int64(f*float64(unit))).UTC(), nil
+}
@@ -0,0 +1,210 @@
+package xml
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func readSensors(t *testing.T) []byte {
+	buf, err := ioutil.ReadFile("testdata/sensors.xml")
+	require.NoError(t, err)
+	return buf
+}
+
+func TestParseDocument(t *testing.T) {
+	p, err := NewParser("xml", []Config{
+		{
+			MetricName: "string('gateway')",
+			Timestamp:  "/Gateway/Timestamp",
+			Tags: map[string]string{
+				"name": "/Gateway/Name",
+			},
+			FieldsInt: map[string]string{
+				"seqnr": "/Gateway/Sequence",
+			},
+			Fields: map[string]string{
+				"ok":     "/Gateway/Status = 'ok'",
+				"status": "/Gateway/Status",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	metrics, err := p.Parse(readSensors(t))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"gateway",
+			map[string]string{
+				"name": "Main Gateway",
+			},
+			map[string]interface{}{
+				"seqnr":  int64(12),
+				"ok":     true,
+				"status": "ok",
+			},
+			time.Date(2020, 8, 1, 15, 4, 3, 0, time.UTC),
+		),
+	}
+	require.Len(t, metrics, len(expected))
+	for i := range expected {
+		testutil.RequireMetricEqual(t, expected[i], metrics[i])
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	p, err := NewParser("sensors", []Config{
+		{
+			Selection:       "/Gateway/Bus/Sensor",
+			Timestamp:       "/Gateway/Timestamp",
+			TimestampFormat: "2006-01-02T15:04:05Z",
+			Tags: map[string]string{
+				"name": "substring-after(@name, 'Facility ')",
+			},
+			FieldsInt: map[string]string{
+				"consumers": "Variable/@consumers",
+			},
+			Fields: map[string]string{
+				"temperature": "number(Variable/@temperature)",
+				"power":       "number(Variable/@power)",
+				"ok":          "Mode != 'error'",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	metrics, err := p.Parse(readSensors(t))
+	require.NoError(t, err)
+
+	tm := time.Date(2020, 8, 1, 15, 4, 3, 0, time.UTC)
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"sensors",
+			map[string]string{"name": "A"},
+			map[string]interface{}{
+				"consumers":   int64(3),
+				"temperature": float64(20.0),
+				"power":       float64(123.4),
+				"ok":          true,
+			},
+			tm,
+		),
+		testutil.MustMetric(
+			"sensors",
+			map[string]string{"name": "B"},
+			map[string]interface{}{
+				"consumers":   int64(1),
+				"temperature": float64(23.1),
+				"power":       float64(14.3),
+				"ok":          true,
+			},
+			tm,
+		),
+		testutil.MustMetric(
+			"sensors",
+			map[string]string{"name": "C"},
+			map[string]interface{}{
+				"consumers":   int64(0),
+				"temperature": float64(19.7),
+				"power":       float64(0.02),
+				"ok":          false,
+			},
+			tm,
+		),
+	}
+	require.Len(t, metrics, len(expected))
+	for i := range expected {
+		testutil.RequireMetricEqual(t, expected[i], metrics[i])
+	}
+}
+
+func TestParseFieldSelection(t *testing.T) {
+	p, err := NewParser("sensors", []Config{
+		{
+			Selection:      "/Gateway/Bus/Sensor[1]",
+			FieldSelection: "Variable/@*",
+			FieldValue:     "number(.)",
+		},
+		{
+			MetricName:     "string('gateway')",
+			Selection:      "/Gateway",
+			FieldSelection: "*[not(*)]",
+		},
+	})
+	require.NoError(t, err)
+	p.TimeFunc = func() time.Time { return time.Unix(42, 0) }
+	p.SetDefaultTags(map[string]string{"host": "localhost"})
+
+	metrics, err := p.Parse(readSensors(t))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"sensors",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{
+				"temperature": float64(20.0),
+				"power":       float64(123.4),
+				"frequency":   float64(49.78),
+				"consumers":   float64(3),
+			},
+			time.Unix(42, 0),
+		),
+		testutil.MustMetric(
+			"gateway",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{
+				"Name":      "Main Gateway",
+				"Timestamp": "2020-08-01T15:04:03Z",
+				"Sequence":  "12",
+				"Status":    "ok",
+			},
+			time.Unix(42, 0),
+		),
+	}
+	require.Len(t, metrics, len(expected))
+	for i := range expected {
+		testutil.RequireMetricEqual(t, expected[i], metrics[i])
+	}
+}
+
+func TestParseTimestampFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		value    string
+		expected time.Time
+	}{
+		{"unix", "1596294243", time.Unix(1596294243, 0)},
+		{"unix_ms", "1596294243123", time.Unix(1596294243, 123000000)},
+		{"unix_ns", "1596294243123456789", time.Unix(1596294243, 123456789)},
+		{"", "2020-08-01T15:04:03Z", time.Unix(1596294243, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			tm, err := parseTime(tt.value, tt.format)
+			require.NoError(t, err)
+			require.True(t, tt.expected.Equal(tm), "expected %v, got %v", tt.expected, tm)
+		})
+	}
+}
+
+func TestInvalidExpression(t *testing.T) {
+	_, err := NewParser("xml", []Config{
+		{Selection: "/Gateway/Bus/Sensor["},
+	})
+	require.Error(t, err)
+}
+
+func TestParseInvalidDocument(t *testing.T) {
+	p, err := NewParser("xml", []Config{{}})
+	require.NoError(t, err)
+
+	_, err = p.Parse([]byte("<Gateway><Name>"))
+	require.Error(t, err)
+}
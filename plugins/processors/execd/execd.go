@@ -0,0 +1,215 @@
+package execd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/process"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	parsers_influx "github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+const sampleConfig = `
+  ## Program to run as daemon
+  ## eg: command = ["/path/to/your_program", "arg1", "arg2"]
+  command = ["cat"]
+
+  ## Delay before the process is restarted after an unexpected termination.
+  ## The delay doubles on every consecutive failure, up to 5 minutes.
+  restart_delay = "10s"
+
+  ## Maximum time to wait for the program to read a metric.  A program blocked
+  ## on its input is restarted.
+  # timeout = "5s"
+
+  ## Data format used to send metrics to the program and to read the
+  ## processed metrics back.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+`
+
+// maxLineBytes is the largest line accepted from the process output.
+const maxLineBytes = 1024 * 1024
+
+// queueSize is the number of metrics waiting to be written to the process
+// before Apply blocks.
+const queueSize = 1000
+
+type Execd struct {
+	Command      []string          `toml:"command"`
+	RestartDelay internal.Duration `toml:"restart_delay"`
+	Timeout      internal.Duration `toml:"timeout"`
+
+	parser     parsers.Parser
+	serializer serializers.Serializer
+	process    *process.Process
+
+	out   chan<- telegraf.Metric
+	queue chan telegraf.Metric
+	wg    sync.WaitGroup
+}
+
+func (e *Execd) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Execd) Description() string {
+	return "Run executable as long-running processor plugin"
+}
+
+func (e *Execd) SetParser(parser parsers.Parser) {
+	e.parser = parser
+}
+
+func (e *Execd) SetSerializer(serializer serializers.Serializer) {
+	e.serializer = serializer
+}
+
+// Start starts the program, the metrics it emits are sent to out.
+func (e *Execd) Start(out chan<- telegraf.Metric) error {
+	p, err := process.New(e.Command)
+	if err != nil {
+		return fmt.Errorf("error creating process %s: %v", e.Command, err)
+	}
+	p.RestartDelay = e.RestartDelay.Duration
+	p.LogName = "processors.execd"
+	p.ReadStdoutFn = e.cmdReadOut
+	p.ReadStderrFn = e.cmdReadErr
+
+	e.out = out
+
+	if err = p.Start(); err != nil {
+		return fmt.Errorf("failed to start process %s: %v", e.Command, err)
+	}
+	e.process = p
+
+	e.queue = make(chan telegraf.Metric, queueSize)
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.writeLoop()
+	}()
+
+	return nil
+}
+
+// Apply queues the metrics to be written to the program.  The metrics the
+// program emits are sent to the channel passed to Start.
+func (e *Execd) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		e.queue <- m
+	}
+	return nil
+}
+
+// Stop writes the queued metrics and stops the program, killing it if it does
+// not exit once its stdin is closed.
+func (e *Execd) Stop() {
+	if e.process == nil {
+		return
+	}
+
+	close(e.queue)
+	e.wg.Wait()
+	e.process.Stop()
+}
+
+// writeLoop writes the queued metrics to the program.  Metrics that cannot be
+// written are passed on unmodified.
+func (e *Execd) writeLoop() {
+	for m := range e.queue {
+		b, err := e.serializer.Serialize(m)
+		if err != nil {
+			log.Printf("E! [processors.execd] Error serializing metric: %v", err)
+			e.out <- m
+			continue
+		}
+
+		if err := e.write(b); err != nil {
+			log.Printf("E! [processors.execd] Error writing to process stdin: %v", err)
+			e.out <- m
+		}
+	}
+}
+
+// write writes to the stdin of the program.  If the write does not complete
+// within the timeout the program is killed, which unblocks the write, and
+// restarted.
+func (e *Execd) write(b []byte) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.process.Write(b)
+		done <- err
+	}()
+
+	timer := time.NewTimer(e.Timeout.Duration)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		if err := e.process.Signal(os.Kill); err != nil {
+			log.Printf("E! [processors.execd] Error killing process: %v", err)
+		}
+		return errors.New("timeout writing to process stdin, killed process")
+	}
+}
+
+func (e *Execd) cmdReadOut(out io.Reader) {
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+
+	for scanner.Scan() {
+		metrics, err := e.parser.Parse(scanner.Bytes())
+		if err != nil {
+			log.Printf("E! [processors.execd] Parse error: %v", err)
+			continue
+		}
+
+		for _, m := range metrics {
+			e.out <- m
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("E! [processors.execd] Error reading stdout: %v", err)
+	}
+}
+
+func (e *Execd) cmdReadErr(out io.Reader) {
+	scanner := bufio.NewScanner(out)
+
+	for scanner.Scan() {
+		log.Printf("E! [processors.execd] stderr: %q", scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("E! [processors.execd] Error reading stderr: %v", err)
+	}
+}
+
+func init() {
+	processors.Add("execd", func() telegraf.Processor {
+		return &Execd{
+			RestartDelay: internal.Duration{Duration: 10 * time.Second},
+			Timeout:      internal.Duration{Duration: 5 * time.Second},
+			parser:       parsers_influx.NewParser(parsers_influx.NewMetricHandler()),
+			serializer:   influx.NewSerializer(),
+		}
+	})
+}
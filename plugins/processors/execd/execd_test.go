@@ -0,0 +1,105 @@
+// +build !windows
+
+package execd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecd(t *testing.T) {
+	processor := processors.Processors["execd"]().(*Execd)
+	processor.Command = []string{"sed", "-u", "s/^cpu/processed_cpu/"}
+	processor.RestartDelay = internal.Duration{Duration: 5 * time.Second}
+
+	out := make(chan telegraf.Metric, 10)
+	require.NoError(t, processor.Start(out))
+	defer processor.Stop()
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 42.0},
+		time.Unix(0, 0),
+	)
+
+	require.Empty(t, processor.Apply(m))
+
+	expected := testutil.MustMetric(
+		"processed_cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 42.0},
+		time.Unix(0, 0),
+	)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected},
+		[]telegraf.Metric{<-out})
+}
+
+func TestExecdDroppedMetric(t *testing.T) {
+	processor := processors.Processors["execd"]().(*Execd)
+	processor.Command = []string{"grep", "--line-buffered", "^mem"}
+
+	out := make(chan telegraf.Metric, 10)
+	require.NoError(t, processor.Start(out))
+	defer processor.Stop()
+
+	cpu := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{"usage": 42.0},
+		time.Unix(0, 0),
+	)
+	mem := testutil.MustMetric(
+		"mem",
+		map[string]string{},
+		map[string]interface{}{"used": 42.0},
+		time.Unix(0, 0),
+	)
+
+	// Dropping the first metric does not delay the second.
+	start := time.Now()
+	require.Empty(t, processor.Apply(cpu))
+	require.Empty(t, processor.Apply(mem))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{mem},
+		[]telegraf.Metric{<-out})
+	require.True(t, time.Since(start) < processor.Timeout.Duration)
+}
+
+func TestExecdStop(t *testing.T) {
+	processor := processors.Processors["execd"]().(*Execd)
+	processor.Command = []string{"cat"}
+
+	out := make(chan telegraf.Metric, 10)
+	require.NoError(t, processor.Start(out))
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{"usage": 42.0},
+		time.Unix(0, 0),
+	)
+	require.Empty(t, processor.Apply(m))
+
+	// Queued metrics are written and their results emitted before Stop
+	// returns.
+	processor.Stop()
+	require.Len(t, out, 1)
+
+	_, err := processor.process.Write([]byte("cpu usage=1\n"))
+	require.Error(t, err)
+}
+
+func TestExecdStartError(t *testing.T) {
+	processor := processors.Processors["execd"]().(*Execd)
+	processor.Command = []string{"/nonexistent/program"}
+
+	out := make(chan telegraf.Metric, 10)
+	require.Error(t, processor.Start(out))
+	processor.Stop()
+}
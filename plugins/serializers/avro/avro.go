@@ -0,0 +1,230 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/avro"
+)
+
+// Serializer encodes metrics as Avro records, either using a supplied schema
+// or one generated from the shape of each metric.  When a schema registry is
+// configured the schema is registered and the record is prefixed with the
+// schema registry wire format header, otherwise the bare record is written
+// and can not be read back by the avro parser.
+type Serializer struct {
+	Registry         *avro.Registry
+	Schema           *avro.Schema
+	Namespace        string
+	TimestampField   string
+	MeasurementField string
+}
+
+// NewSerializer creates a serializer.  schema may be nil, in which case a
+// schema is generated for each metric.
+func NewSerializer(registry *avro.Registry, schema *avro.Schema, namespace, timestampField, measurementField string) *Serializer {
+	if timestampField == "" {
+		timestampField = "timestamp"
+	}
+	return &Serializer{
+		Registry:         registry,
+		Schema:           schema,
+		Namespace:        namespace,
+		TimestampField:   timestampField,
+		MeasurementField: measurementField,
+	}
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return s.appendMetric(nil, metric)
+}
+
+// SerializeBatch concatenates the encoded metrics.  Since Avro records are
+// not self delimiting this is only useful when the reader knows the schema.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf []byte
+	for _, m := range metrics {
+		var err error
+		buf, err = s.appendMetric(buf, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func (s *Serializer) appendMetric(buf []byte, metric telegraf.Metric) ([]byte, error) {
+	schema := s.Schema
+	if schema == nil {
+		var err error
+		schema, err = s.generateSchema(metric)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Registry != nil && s.Registry.URL != "" {
+		id, err := s.Registry.Register(schema.FullName()+"-value", schema)
+		if err != nil {
+			return nil, err
+		}
+		buf = avro.AppendHeader(buf, id)
+	}
+
+	return avro.Encode(schema, buf, s.record(schema, metric))
+}
+
+// record builds the native value for the schema, record fields are looked up
+// in the metric tags and then the metric fields.
+func (s *Serializer) record(schema *avro.Schema, metric telegraf.Metric) map[string]interface{} {
+	names := make(map[string]string)
+	for _, tag := range metric.TagList() {
+		names[sanitize(tag.Key)] = tag.Key
+	}
+	for _, field := range metric.FieldList() {
+		names[sanitize(field.Key)] = field.Key
+	}
+
+	record := make(map[string]interface{}, len(schema.Fields))
+	for _, f := range schema.Fields {
+		switch f.Name {
+		case s.TimestampField:
+			switch f.Type.LogicalType {
+			case "timestamp-millis", "timestamp-micros":
+				record[f.Name] = metric.Time()
+			default:
+				record[f.Name] = metric.Time().UnixNano() / int64(time.Millisecond)
+			}
+			continue
+		case s.MeasurementField:
+			record[f.Name] = metric.Name()
+			continue
+		}
+
+		key, ok := names[f.Name]
+		if !ok {
+			key = f.Name
+		}
+		if v, ok := metric.GetTag(key); ok {
+			record[f.Name] = v
+		} else if v, ok := metric.GetField(key); ok {
+			record[f.Name] = v
+		} else if f.Type.Nullable() {
+			record[f.Name] = nil
+		}
+	}
+	return record
+}
+
+type schemaField struct {
+	Name    string      `json:"name"`
+	Type    interface{} `json:"type"`
+	Default interface{} `json:"default"`
+}
+
+type schemaRecord struct {
+	Type      string        `json:"type"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	Fields    []schemaField `json:"fields"`
+}
+
+// generateSchema creates a record schema named after the measurement with a
+// nullable field for each tag and field.
+func (s *Serializer) generateSchema(metric telegraf.Metric) (*avro.Schema, error) {
+	record := schemaRecord{
+		Type:      "record",
+		Name:      sanitize(metric.Name()),
+		Namespace: s.Namespace,
+	}
+
+	record.Fields = append(record.Fields, schemaField{
+		Name:    s.TimestampField,
+		Type:    map[string]string{"type": "long", "logicalType": "timestamp-micros"},
+		Default: 0,
+	})
+	if s.MeasurementField != "" {
+		record.Fields = append(record.Fields, schemaField{
+			Name:    s.MeasurementField,
+			Type:    "string",
+			Default: "",
+		})
+	}
+
+	tags := make([]schemaField, 0, len(metric.TagList()))
+	for _, tag := range metric.TagList() {
+		if s.reserved(tag.Key) {
+			continue
+		}
+		tags = append(tags, schemaField{
+			Name: sanitize(tag.Key),
+			Type: []string{"null", "string"},
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	fields := make([]schemaField, 0, len(metric.FieldList()))
+	for _, field := range metric.FieldList() {
+		if s.reserved(field.Key) {
+			continue
+		}
+		var typ string
+		switch field.Value.(type) {
+		case int64, uint64:
+			typ = "long"
+		case float64:
+			typ = "double"
+		case bool:
+			typ = "boolean"
+		case string:
+			typ = "string"
+		default:
+			return nil, fmt.Errorf("unsupported field type %T for field %q", field.Value, field.Key)
+		}
+		fields = append(fields, schemaField{
+			Name: sanitize(field.Key),
+			Type: []string{"null", typ},
+		})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	record.Fields = append(record.Fields, tags...)
+	record.Fields = append(record.Fields, fields...)
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return avro.ParseSchema(string(b))
+}
+
+// reserved returns true if the key would collide with the timestamp or
+// measurement field of a generated schema.
+func (s *Serializer) reserved(key string) bool {
+	name := sanitize(key)
+	return name == s.TimestampField || (s.MeasurementField != "" && name == s.MeasurementField)
+}
+
+// sanitize converts a name into a valid Avro name by replacing invalid
+// characters with an underscore.
+func sanitize(name string) string {
+	var b bytes.Buffer
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
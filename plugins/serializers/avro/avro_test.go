@@ -0,0 +1,117 @@
+package avro
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/avro"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeGeneratedSchema(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"host": "localhost",
+		},
+		map[string]interface{}{
+			"usage-idle": 91.5,
+			"count":      int64(3),
+		},
+		time.Unix(0, 1500000000),
+	)
+
+	s := NewSerializer(nil, nil, "telegraf", "", "measurement")
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	schema, err := s.generateSchema(m)
+	require.NoError(t, err)
+	require.Equal(t, "telegraf.cpu", schema.FullName())
+
+	actual, rest, err := avro.Decode(schema, buf)
+	require.NoError(t, err)
+	require.Len(t, rest, 0)
+	require.Equal(t, map[string]interface{}{
+		"timestamp":   time.Unix(0, 1500000000).UTC(),
+		"measurement": "cpu",
+		"host":        "localhost",
+		"usage_idle":  91.5,
+		"count":       int64(3),
+	}, actual)
+}
+
+func TestSerializeSuppliedSchema(t *testing.T) {
+	schema, err := avro.ParseSchema(`{
+	  "type": "record",
+	  "name": "cpu",
+	  "fields": [
+	    {"name": "time", "type": "long"},
+	    {"name": "host", "type": "string"},
+	    {"name": "usage_idle", "type": "double"},
+	    {"name": "missing", "type": ["null", "long"], "default": null}
+	  ]
+	}`)
+	require.NoError(t, err)
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"host": "localhost",
+		},
+		map[string]interface{}{
+			"usage_idle": 91.5,
+		},
+		time.Unix(2, 0),
+	)
+
+	s := NewSerializer(nil, schema, "", "time", "")
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	actual, _, err := avro.Decode(schema, buf)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"time":       int64(2000),
+		"host":       "localhost",
+		"usage_idle": 91.5,
+		"missing":    nil,
+	}, actual)
+}
+
+func TestSerializeRegistersSchema(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "POST", r.Method)
+		require.Equal(t, "/subjects/cpu-value/versions", r.URL.Path)
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.NotEmpty(t, req["schema"])
+		w.Write([]byte(`{"id": 12}`))
+	}))
+	defer ts.Close()
+
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{
+			"value": int64(1),
+		},
+		time.Unix(0, 0),
+	)
+
+	s := NewSerializer(avro.NewRegistry(ts.URL, time.Second), nil, "", "", "")
+	buf, err := s.SerializeBatch([]telegraf.Metric{m, m})
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+
+	id, _, err := avro.SplitHeader(buf)
+	require.NoError(t, err)
+	require.Equal(t, 12, id)
+}
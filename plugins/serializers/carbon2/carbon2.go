@@ -0,0 +1,134 @@
+package carbon2
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+type format string
+
+const (
+	// Carbon2FormatFieldSeparate writes the field name as the "field"
+	// intrinsic tag, ie: metric=cpu field=usage_idle
+	Carbon2FormatFieldSeparate format = "field_separate"
+	// Carbon2FormatMetricIncludesField joins the measurement and field name
+	// into the "metric" intrinsic tag, ie: metric=cpu_usage_idle
+	Carbon2FormatMetricIncludesField format = "metric_includes_field"
+)
+
+var (
+	// whitespace and equal signs are not allowed in keys or values
+	invalidChars = strings.NewReplacer(
+		" ", "_",
+		"\t", "_",
+		"\n", "_",
+		"=", "_",
+	)
+)
+
+type Serializer struct {
+	Format    format
+	Separator string
+}
+
+// NewSerializer creates a serializer, format is one of "field_separate" or
+// "metric_includes_field" and separator is used to join the measurement and
+// field name in the latter.
+func NewSerializer(f string, separator string) (*Serializer, error) {
+	var fmtv format
+	switch format(f) {
+	case "", Carbon2FormatFieldSeparate:
+		fmtv = Carbon2FormatFieldSeparate
+	case Carbon2FormatMetricIncludesField:
+		fmtv = Carbon2FormatMetricIncludesField
+	default:
+		return nil, fmt.Errorf("unknown carbon2 format: %s", f)
+	}
+
+	if separator == "" {
+		separator = "_"
+	}
+
+	return &Serializer{
+		Format:    fmtv,
+		Separator: separator,
+	}, nil
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return s.createObject(metric), nil
+}
+
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var batch bytes.Buffer
+	for _, metric := range metrics {
+		batch.Write(s.createObject(metric))
+	}
+	return batch.Bytes(), nil
+}
+
+func (s *Serializer) createObject(metric telegraf.Metric) []byte {
+	var m bytes.Buffer
+	for _, field := range metric.FieldList() {
+		value := formatValue(field.Value)
+		if value == "" {
+			continue
+		}
+
+		switch s.Format {
+		case Carbon2FormatMetricIncludesField:
+			m.WriteString("metric=")
+			m.WriteString(sanitize(metric.Name() + s.Separator + field.Key))
+		default:
+			m.WriteString("metric=")
+			m.WriteString(sanitize(metric.Name()))
+			m.WriteString(" field=")
+			m.WriteString(sanitize(field.Key))
+		}
+
+		// Tags are written as meta tags, separated from the intrinsic tags
+		// by two spaces.
+		m.WriteString(" ")
+		for _, tag := range metric.TagList() {
+			m.WriteString(" ")
+			m.WriteString(sanitize(tag.Key))
+			m.WriteString("=")
+			value := sanitize(tag.Value)
+			if value == "" {
+				value = "null"
+			}
+			m.WriteString(value)
+		}
+		m.WriteString(" ")
+		m.WriteString(value)
+		m.WriteString(" ")
+		m.WriteString(strconv.FormatInt(metric.Time().Unix(), 10))
+		m.WriteString("\n")
+	}
+	return m.Bytes()
+}
+
+func sanitize(value string) string {
+	return invalidChars.Replace(value)
+}
+
+func formatValue(fieldValue interface{}) string {
+	switch v := fieldValue.(type) {
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package carbon2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeFieldSeparate(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"cpu": "cpu0",
+		},
+		map[string]interface{}{
+			"usage_idle": float64(91.5),
+		},
+		time.Unix(1530000000, 0),
+	)
+
+	s, err := NewSerializer("", "")
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "metric=cpu field=usage_idle  cpu=cpu0 91.5 1530000000\n", string(buf))
+}
+
+func TestSerializeMetricIncludesField(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"cpu":  "cpu 0",
+			"unit": "",
+		},
+		map[string]interface{}{
+			"usage_idle": int64(91),
+		},
+		time.Unix(1530000000, 0),
+	)
+
+	s, err := NewSerializer("metric_includes_field", ".")
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "metric=cpu.usage_idle  cpu=cpu_0 unit=null 91 1530000000\n", string(buf))
+}
+
+func TestSerializeSkipsStrings(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{
+			"state": "on",
+			"up":    true,
+		},
+		time.Unix(0, 0),
+	)
+
+	s, err := NewSerializer("field_separate", "")
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "metric=cpu field=up  1 0\n", string(buf))
+}
+
+func TestSerializeBatch(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{
+			"value": 42.0,
+		},
+		time.Unix(0, 0),
+	)
+
+	s, err := NewSerializer("", "")
+	require.NoError(t, err)
+	buf, err := s.SerializeBatch([]telegraf.Metric{m, m})
+	require.NoError(t, err)
+	require.Equal(t, "metric=cpu field=value  42 0\nmetric=cpu field=value  42 0\n", string(buf))
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewSerializer("bogus", "")
+	require.Error(t, err)
+}
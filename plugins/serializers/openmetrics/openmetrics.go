@@ -0,0 +1,413 @@
+package openmetrics
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+var (
+	invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	labelValueEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	)
+
+	helpEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		"\n", `\n`,
+	)
+)
+
+// help is the help of the families built from metrics.
+const help = "Telegraf collected metric"
+
+// Serializer writes metrics in the OpenMetrics text exposition format.
+// Metrics are converted to metric families using the same rules as the
+// prometheus_client output, with the family type taken from the
+// telegraf.ValueType of the metric.
+type Serializer struct {
+	StringAsLabel bool
+}
+
+// NewSerializer creates a serializer.
+func NewSerializer(stringAsLabel bool) (*Serializer, error) {
+	return &Serializer{
+		StringAsLabel: stringAsLabel,
+	}, nil
+}
+
+// Serialize writes the metric families of a single metric.  The "# EOF"
+// marker is not written, use SerializeBatch for a complete exposition.
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, f := range s.families([]telegraf.Metric{metric}) {
+		f.write(&buf)
+	}
+	return buf.Bytes(), nil
+}
+
+// SerializeBatch writes a complete exposition, the samples of all metrics
+// are grouped by family and followed by the "# EOF" marker.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteFamilies(&buf, s.families(metrics)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Family is a metric family of an exposition.
+type Family struct {
+	Name    string
+	Type    string
+	Help    string
+	Samples []*Sample
+}
+
+// Sample is a sample of a metric family.
+type Sample struct {
+	// Suffix is appended to the family name, such as "_bucket".
+	Suffix string
+	Labels []Label
+	Value  float64
+	// Timestamp is not written if zero.
+	Timestamp time.Time
+	// Exemplar is written after the value if set.
+	Exemplar *Exemplar
+
+	// bound is the quantile or upper bound, used for ordering
+	bound float64
+}
+
+// Label is a label of a sample or an exemplar.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Exemplar is an example observation of a histogram bucket.
+type Exemplar struct {
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+// WriteFamilies writes the families as a complete exposition, followed by
+// the "# EOF" marker.
+func WriteFamilies(w io.Writer, families []*Family) error {
+	var buf bytes.Buffer
+	for _, f := range families {
+		f.write(&buf)
+	}
+	buf.WriteString("# EOF\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (f *Family) write(buf *bytes.Buffer) {
+	buf.WriteString("# TYPE ")
+	buf.WriteString(f.Name)
+	buf.WriteString(" ")
+	buf.WriteString(f.Type)
+	buf.WriteString("\n")
+	if f.Help != "" {
+		buf.WriteString("# HELP ")
+		buf.WriteString(f.Name)
+		buf.WriteString(" ")
+		buf.WriteString(helpEscaper.Replace(f.Help))
+		buf.WriteString("\n")
+	}
+
+	for _, s := range f.Samples {
+		buf.WriteString(f.Name)
+		buf.WriteString(s.Suffix)
+		writeLabels(buf, s.Labels)
+		buf.WriteString(" ")
+		buf.WriteString(formatFloat(s.Value))
+		if !s.Timestamp.IsZero() {
+			buf.WriteString(" ")
+			buf.WriteString(formatTimestamp(s.Timestamp.UnixNano()))
+		}
+		if e := s.Exemplar; e != nil {
+			buf.WriteString(" # ")
+			buf.WriteString("{")
+			writeLabelPairs(buf, e.Labels)
+			buf.WriteString("} ")
+			buf.WriteString(formatFloat(e.Value))
+			if !e.Timestamp.IsZero() {
+				buf.WriteString(" ")
+				buf.WriteString(formatTimestamp(e.Timestamp.UnixNano()))
+			}
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// writeLabels writes the labels of a sample, if any, in braces.
+func writeLabels(buf *bytes.Buffer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	buf.WriteString("{")
+	writeLabelPairs(buf, labels)
+	buf.WriteString("}")
+}
+
+func writeLabelPairs(buf *bytes.Buffer, labels []Label) {
+	for i, l := range labels {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(l.Name)
+		buf.WriteString(`="`)
+		buf.WriteString(labelValueEscaper.Replace(l.Value))
+		buf.WriteString(`"`)
+	}
+}
+
+// families groups the samples of the metrics into metric families, sorted by
+// name.  A name can only be used by a single family, the samples of metrics
+// with another type than the first family of that name are dropped.
+func (s *Serializer) families(metrics []telegraf.Metric) []*Family {
+	byName := make(map[string]*Family)
+	for _, m := range metrics {
+		s.addMetric(byName, m)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	families := make([]*Family, 0, len(names))
+	for _, name := range names {
+		families = append(families, byName[name])
+	}
+	return families
+}
+
+func (s *Serializer) addMetric(byName map[string]*Family, m telegraf.Metric) {
+	labels := make(map[string]string)
+	for _, tag := range m.TagList() {
+		labels[sanitize(tag.Key)] = tag.Value
+	}
+
+	// OpenMetrics doesn't have a string value type, so convert string
+	// fields to labels if enabled.
+	if s.StringAsLabel {
+		for _, field := range m.FieldList() {
+			if v, ok := field.Value.(string); ok {
+				labels[sanitize(field.Key)] = v
+			}
+		}
+	}
+
+	ts := m.Time()
+	getFamily := func(name, typ string) *Family {
+		f, ok := byName[name]
+		if !ok {
+			f = &Family{Name: name, Type: typ, Help: help}
+			byName[name] = f
+		}
+		if f.Type != typ {
+			log.Printf("W! [serializers.openmetrics] Dropping %s samples of metric %q: family %q already has type %s",
+				typ, m.Name(), name, f.Type)
+			return nil
+		}
+		return f
+	}
+
+	switch m.Type() {
+	case telegraf.Summary, telegraf.Histogram:
+		name := sanitize(m.Name())
+		typ := "summary"
+		if m.Type() == telegraf.Histogram {
+			typ = "histogram"
+		}
+		f := getFamily(name, typ)
+		if f == nil {
+			return
+		}
+
+		var buckets, quantiles, totals []*Sample
+		hasInf := false
+		var count float64
+		for _, field := range m.FieldList() {
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+
+			switch field.Key {
+			case "sum":
+				totals = append(totals, &Sample{Suffix: "_sum", Labels: sortLabels(labels, nil), Value: value, Timestamp: ts})
+			case "count":
+				count = value
+				totals = append(totals, &Sample{Suffix: "_count", Labels: sortLabels(labels, nil), Value: value, Timestamp: ts})
+			default:
+				limit, err := strconv.ParseFloat(field.Key, 64)
+				if err != nil {
+					continue
+				}
+				if m.Type() == telegraf.Summary {
+					quantiles = append(quantiles, &Sample{
+						Labels:    sortLabels(labels, &Label{"quantile", formatFloat(limit)}),
+						Value:     value,
+						Timestamp: ts,
+						bound:     limit,
+					})
+				} else {
+					if math.IsInf(limit, 1) {
+						hasInf = true
+					}
+					buckets = append(buckets, &Sample{
+						Suffix:    "_bucket",
+						Labels:    sortLabels(labels, &Label{"le", formatFloat(limit)}),
+						Value:     value,
+						Timestamp: ts,
+						bound:     limit,
+					})
+				}
+			}
+		}
+
+		// Histograms must include the +Inf bucket, which is equal to the
+		// count.
+		if m.Type() == telegraf.Histogram && !hasInf {
+			buckets = append(buckets, &Sample{
+				Suffix:    "_bucket",
+				Labels:    sortLabels(labels, &Label{"le", "+Inf"}),
+				Value:     count,
+				Timestamp: ts,
+				bound:     math.Inf(1),
+			})
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].bound < buckets[j].bound })
+		sort.Slice(quantiles, func(i, j int) bool { return quantiles[i].bound < quantiles[j].bound })
+		sort.Slice(totals, func(i, j int) bool { return totals[i].Suffix < totals[j].Suffix })
+
+		f.Samples = append(f.Samples, buckets...)
+		f.Samples = append(f.Samples, quantiles...)
+		f.Samples = append(f.Samples, totals...)
+	default:
+		for _, field := range m.FieldList() {
+			// Ignore string and bool fields.
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+
+			// Special handling of value field; supports passthrough from
+			// the prometheus input.
+			var name string
+			switch m.Type() {
+			case telegraf.Counter:
+				if field.Key == "counter" {
+					name = sanitize(m.Name())
+				}
+			case telegraf.Gauge:
+				if field.Key == "gauge" {
+					name = sanitize(m.Name())
+				}
+			}
+			if name == "" {
+				if field.Key == "value" {
+					name = sanitize(m.Name())
+				} else {
+					name = sanitize(m.Name() + "_" + field.Key)
+				}
+			}
+
+			smp := &Sample{Labels: sortLabels(labels, nil), Value: value, Timestamp: ts}
+			var typ string
+			switch m.Type() {
+			case telegraf.Counter:
+				// Counter samples must have the _total suffix, which is not
+				// part of the family name.
+				typ = "counter"
+				name = strings.TrimSuffix(name, "_total")
+				smp.Suffix = "_total"
+			case telegraf.Gauge:
+				typ = "gauge"
+			default:
+				typ = "unknown"
+			}
+			if f := getFamily(name, typ); f != nil {
+				f.Samples = append(f.Samples, smp)
+			}
+		}
+	}
+}
+
+func sortLabels(labels map[string]string, extra *Label) []Label {
+	ls := make([]Label, 0, len(labels)+1)
+	for k, v := range labels {
+		if extra != nil && k == extra.Name {
+			continue
+		}
+		ls = append(ls, Label{k, v})
+	}
+	if extra != nil {
+		ls = append(ls, *extra)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return ls
+}
+
+func sanitize(value string) string {
+	return invalidNameCharRE.ReplaceAllString(value, "_")
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// formatTimestamp formats a timestamp in nanoseconds as seconds.
+func formatTimestamp(ns int64) string {
+	sign := ""
+	abs := uint64(ns)
+	if ns < 0 {
+		sign = "-"
+		abs = uint64(-ns)
+	}
+
+	sec := strconv.FormatUint(abs/1e9, 10)
+	frac := abs % 1e9
+	if frac == 0 {
+		return sign + sec
+	}
+	s := strconv.FormatFloat(float64(frac)/1e9, 'f', -1, 64)
+	return sign + sec + strings.TrimPrefix(s, "0")
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,205 @@
+package openmetrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeUntyped(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"host": "example.org",
+		},
+		map[string]interface{}{
+			"time_idle": float64(42),
+			"state":     "on",
+		},
+		time.Unix(0, 0),
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	expected := `# TYPE cpu_time_idle unknown
+# HELP cpu_time_idle Telegraf collected metric
+cpu_time_idle{host="example.org"} 42 0
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeStringAsLabel(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{
+			"value": float64(42),
+			"state": "on \"now\"\n",
+		},
+		time.Unix(1, 500000000),
+		telegraf.Gauge,
+	)
+
+	s, err := NewSerializer(true)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	expected := `# TYPE cpu gauge
+# HELP cpu Telegraf collected metric
+cpu{state="on \"now\"\n"} 42 1.5
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeCounter(t *testing.T) {
+	m := testutil.MustMetric(
+		"http_requests_total",
+		map[string]string{},
+		map[string]interface{}{
+			"counter": float64(1027),
+		},
+		time.Unix(0, 0),
+		telegraf.Counter,
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	expected := `# TYPE http_requests counter
+# HELP http_requests Telegraf collected metric
+http_requests_total 1027 0
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeHistogram(t *testing.T) {
+	m := testutil.MustMetric(
+		"request_latency",
+		map[string]string{},
+		map[string]interface{}{
+			"0.5":   float64(10),
+			"1":     float64(20),
+			"sum":   float64(12.5),
+			"count": float64(25),
+		},
+		time.Unix(0, 0),
+		telegraf.Histogram,
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	expected := `# TYPE request_latency histogram
+# HELP request_latency Telegraf collected metric
+request_latency_bucket{le="0.5"} 10 0
+request_latency_bucket{le="1"} 20 0
+request_latency_bucket{le="+Inf"} 25 0
+request_latency_count 25 0
+request_latency_sum 12.5 0
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeSummary(t *testing.T) {
+	m := testutil.MustMetric(
+		"rpc_duration_seconds",
+		map[string]string{},
+		map[string]interface{}{
+			"0.99":  math.NaN(),
+			"0.5":   float64(0.2),
+			"sum":   float64(8),
+			"count": float64(40),
+		},
+		time.Unix(0, 0),
+		telegraf.Summary,
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	expected := `# TYPE rpc_duration_seconds summary
+# HELP rpc_duration_seconds Telegraf collected metric
+rpc_duration_seconds{quantile="0.5"} 0.2 0
+rpc_duration_seconds{quantile="0.99"} NaN 0
+rpc_duration_seconds_count 40 0
+rpc_duration_seconds_sum 8 0
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeBatch(t *testing.T) {
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"value": float64(1)},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"cpu": "cpu1"},
+			map[string]interface{}{"value": float64(2)},
+			time.Unix(0, 0),
+		),
+	}
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+	expected := `# TYPE cpu unknown
+# HELP cpu Telegraf collected metric
+cpu{cpu="cpu0"} 1 0
+cpu{cpu="cpu1"} 2 0
+# EOF
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestSerializeBatchTypeConflict(t *testing.T) {
+	metrics := []telegraf.Metric{
+		testutil.MustMetric(
+			"http_requests_total",
+			map[string]string{},
+			map[string]interface{}{"counter": float64(1027)},
+			time.Unix(0, 0),
+			telegraf.Counter,
+		),
+		testutil.MustMetric(
+			"http_requests",
+			map[string]string{},
+			map[string]interface{}{"gauge": float64(3)},
+			time.Unix(0, 0),
+			telegraf.Gauge,
+		),
+	}
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+	expected := `# TYPE http_requests counter
+# HELP http_requests Telegraf collected metric
+http_requests_total 1027 0
+# EOF
+`
+	require.Equal(t, expected, string(buf))
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	require.Equal(t, "0", formatTimestamp(0))
+	require.Equal(t, "1.5", formatTimestamp(1500000000))
+	require.Equal(t, "-1.5", formatTimestamp(-1500000000))
+	require.Equal(t, "-2", formatTimestamp(-2000000000))
+	require.Equal(t, "-0.001", formatTimestamp(-1000000))
+	require.Equal(t, "-9223372036.854775808", formatTimestamp(math.MinInt64))
+}
@@ -0,0 +1,214 @@
+package prometheusremotewrite
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/prompb"
+)
+
+var invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Serializer encodes metrics as a snappy compressed Prometheus remote write
+// request.  Metrics are converted to series using the same rules as the
+// prometheus_client output.
+type Serializer struct {
+	StringAsLabel bool
+}
+
+// NewSerializer creates a serializer.
+func NewSerializer(stringAsLabel bool) (*Serializer, error) {
+	return &Serializer{
+		StringAsLabel: stringAsLabel,
+	}, nil
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return s.SerializeBatch([]telegraf.Metric{metric})
+}
+
+// SerializeBatch creates a single write request containing all metrics.
+// Samples belonging to the same series are grouped together.
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	series := make(map[string]*prompb.TimeSeries)
+	for _, m := range metrics {
+		for _, sample := range s.samples(m) {
+			key := sample.key()
+			ts, ok := series[key]
+			if !ok {
+				ts = &prompb.TimeSeries{Labels: sample.labels}
+				series[key] = ts
+			}
+			ts.Samples = append(ts.Samples, &prompb.Sample{
+				Value:     sample.value,
+				Timestamp: m.Time().UnixNano() / 1000000,
+			})
+		}
+	}
+
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	req := &prompb.WriteRequest{
+		Timeseries: make([]*prompb.TimeSeries, 0, len(series)),
+	}
+	for _, key := range keys {
+		ts := series[key]
+		sort.SliceStable(ts.Samples, func(i, j int) bool {
+			return ts.Samples[i].Timestamp < ts.Samples[j].Timestamp
+		})
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal write request: %v", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+type sample struct {
+	labels []*prompb.Label
+	value  float64
+}
+
+// key returns a string uniquely identifying the series of the sample.
+func (s *sample) key() string {
+	pairs := make([]string, 0, len(s.labels))
+	for _, l := range s.labels {
+		pairs = append(pairs, l.Name+"="+l.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// samples converts a metric into Prometheus samples.
+func (s *Serializer) samples(m telegraf.Metric) []*sample {
+	labels := make(map[string]string)
+	for _, tag := range m.TagList() {
+		labels[sanitize(tag.Key)] = tag.Value
+	}
+
+	// Prometheus doesn't have a string value type, so convert string
+	// fields to labels if enabled.
+	if s.StringAsLabel {
+		for _, field := range m.FieldList() {
+			if v, ok := field.Value.(string); ok {
+				labels[sanitize(field.Key)] = v
+			}
+		}
+	}
+
+	var samples []*sample
+	name := sanitize(m.Name())
+	switch m.Type() {
+	case telegraf.Summary, telegraf.Histogram:
+		for _, field := range m.FieldList() {
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+
+			switch field.Key {
+			case "sum":
+				samples = append(samples, newSample(name+"_sum", labels, nil, value))
+			case "count":
+				samples = append(samples, newSample(name+"_count", labels, nil, value))
+			default:
+				limit, err := strconv.ParseFloat(field.Key, 64)
+				if err != nil {
+					continue
+				}
+				if m.Type() == telegraf.Summary {
+					extra := map[string]string{"quantile": formatFloat(limit)}
+					samples = append(samples, newSample(name, labels, extra, value))
+				} else {
+					extra := map[string]string{"le": formatFloat(limit)}
+					samples = append(samples, newSample(name+"_bucket", labels, extra, value))
+				}
+			}
+		}
+	default:
+		for _, field := range m.FieldList() {
+			// Ignore string and bool fields.
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+
+			// Special handling of value field; supports passthrough from
+			// the prometheus input.
+			var mname string
+			switch m.Type() {
+			case telegraf.Counter:
+				if field.Key == "counter" {
+					mname = name
+				}
+			case telegraf.Gauge:
+				if field.Key == "gauge" {
+					mname = name
+				}
+			}
+			if mname == "" {
+				if field.Key == "value" {
+					mname = name
+				} else {
+					mname = sanitize(m.Name() + "_" + field.Key)
+				}
+			}
+
+			samples = append(samples, newSample(mname, labels, nil, value))
+		}
+	}
+	return samples
+}
+
+// newSample creates a sample with the labels sorted by name, as required by
+// the remote write protocol.
+func newSample(name string, labels, extra map[string]string, value float64) *sample {
+	ls := make([]*prompb.Label, 0, len(labels)+len(extra)+1)
+	ls = append(ls, &prompb.Label{Name: "__name__", Value: name})
+	for k, v := range labels {
+		if _, ok := extra[k]; ok {
+			continue
+		}
+		ls = append(ls, &prompb.Label{Name: k, Value: v})
+	}
+	for k, v := range extra {
+		ls = append(ls, &prompb.Label{Name: k, Value: v})
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return &sample{labels: ls, value: value}
+}
+
+func sanitize(value string) string {
+	return invalidNameCharRE.ReplaceAllString(value, "_")
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,146 @@
+package prometheusremotewrite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, buf []byte) *prompb.WriteRequest {
+	data, err := snappy.Decode(nil, buf)
+	require.NoError(t, err)
+	var req prompb.WriteRequest
+	require.NoError(t, proto.Unmarshal(data, &req))
+	return &req
+}
+
+func labels(pairs ...string) []*prompb.Label {
+	var ls []*prompb.Label
+	for i := 0; i < len(pairs); i += 2 {
+		ls = append(ls, &prompb.Label{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return ls
+}
+
+func TestSerializeUntyped(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{
+			"host":     "localhost",
+			"cpu-name": "cpu0",
+		},
+		map[string]interface{}{
+			"value":      int64(42),
+			"usage_idle": 91.5,
+			"state":      "on",
+			"ok":         true,
+		},
+		time.Unix(1, 500000000),
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	req := decode(t, buf)
+	require.Equal(t, []*prompb.TimeSeries{
+		{
+			Labels:  labels("__name__", "cpu", "cpu_name", "cpu0", "host", "localhost"),
+			Samples: []*prompb.Sample{{Value: 42, Timestamp: 1500}},
+		},
+		{
+			Labels:  labels("__name__", "cpu_usage_idle", "cpu_name", "cpu0", "host", "localhost"),
+			Samples: []*prompb.Sample{{Value: 91.5, Timestamp: 1500}},
+		},
+	}, req.Timeseries)
+}
+
+func TestSerializeStringAsLabel(t *testing.T) {
+	m := testutil.MustMetric(
+		"cpu",
+		map[string]string{},
+		map[string]interface{}{
+			"counter": 1.0,
+			"state":   "on",
+		},
+		time.Unix(0, 0),
+		telegraf.Counter,
+	)
+
+	s, err := NewSerializer(true)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	req := decode(t, buf)
+	require.Len(t, req.Timeseries, 1)
+	require.Equal(t, labels("__name__", "cpu", "state", "on"), req.Timeseries[0].Labels)
+}
+
+func TestSerializeHistogram(t *testing.T) {
+	m := testutil.MustMetric(
+		"http_request_duration_seconds",
+		map[string]string{},
+		map[string]interface{}{
+			"0.5":   10.0,
+			"+Inf":  20.0,
+			"sum":   3.5,
+			"count": 20.0,
+		},
+		time.Unix(0, 0),
+		telegraf.Histogram,
+	)
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	req := decode(t, buf)
+	var names []string
+	for _, ts := range req.Timeseries {
+		var desc string
+		for _, l := range ts.Labels {
+			desc += l.Name + "=" + l.Value + " "
+		}
+		names = append(names, desc)
+	}
+	require.Equal(t, []string{
+		"__name__=http_request_duration_seconds_bucket le=+Inf ",
+		"__name__=http_request_duration_seconds_bucket le=0.5 ",
+		"__name__=http_request_duration_seconds_count ",
+		"__name__=http_request_duration_seconds_sum ",
+	}, names)
+}
+
+func TestSerializeBatchGroupsSeries(t *testing.T) {
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+	}
+
+	s, err := NewSerializer(false)
+	require.NoError(t, err)
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+
+	req := decode(t, buf)
+	require.Len(t, req.Timeseries, 1)
+	require.Equal(t, []*prompb.Sample{
+		{Value: 1.0, Timestamp: 1000},
+		{Value: 2.0, Timestamp: 2000},
+	}, req.Timeseries[0].Samples)
+}
+
+func TestFormatFloat(t *testing.T) {
+	require.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	require.Equal(t, "0.99", formatFloat(0.99))
+}
@@ -2,14 +2,20 @@ package serializers
 
 import (
 	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/influxdata/telegraf"
 
+	avroschema "github.com/influxdata/telegraf/internal/avro"
+	"github.com/influxdata/telegraf/plugins/serializers/avro"
+	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/plugins/serializers/openmetrics"
 	"github.com/influxdata/telegraf/plugins/serializers/photon"
+	"github.com/influxdata/telegraf/plugins/serializers/prometheusremotewrite"
 	"github.com/influxdata/telegraf/plugins/serializers/splunkmetric"
 )
 
@@ -68,6 +74,30 @@ type Config struct {
 
 	//PhotonSenderId supported by photon_binary
 	PhotonSenderId string
+
+	// Convert string fields to labels; prometheusremotewrite and openmetrics
+	// formats only
+	PrometheusStringAsLabel bool
+
+	// URL of the schema registry; avro format only
+	AvroSchemaRegistry string
+
+	// Path to a schema to use instead of generating one; avro format only
+	AvroSchemaFile string
+
+	// Namespace of generated schemas; avro format only
+	AvroNamespace string
+
+	// Record field names holding the metric timestamp and name; avro format only
+	AvroTimestampField   string
+	AvroMeasurementField string
+
+	// Carbon2 metric format, one of: field_separate or metric_includes_field
+	Carbon2Format string
+
+	// Separator between the measurement and field name when the field is
+	// included in the metric; carbon2 format only
+	Carbon2Separator string
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -85,6 +115,14 @@ func NewSerializer(config *Config) (Serializer, error) {
 		serializer, err = NewSplunkmetricSerializer(config.HecRouting)
 	case "photon_binary":
 		serializer, err = NewPhotonBinarySerializer(config)
+	case "prometheusremotewrite":
+		serializer, err = NewPrometheusRemoteWriteSerializer(config)
+	case "avro":
+		serializer, err = NewAvroSerializer(config)
+	case "carbon2":
+		serializer, err = NewCarbon2Serializer(config)
+	case "openmetrics":
+		serializer, err = NewOpenMetricsSerializer(config)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -132,3 +170,33 @@ func NewGraphiteSerializer(prefix, template string, tag_support bool) (Serialize
 func NewPhotonBinarySerializer(config *Config) (Serializer, error) {
 	return photon.NewSerializer(config.PhotonSenderId), nil
 }
+
+func NewPrometheusRemoteWriteSerializer(config *Config) (Serializer, error) {
+	return prometheusremotewrite.NewSerializer(config.PrometheusStringAsLabel)
+}
+
+func NewAvroSerializer(config *Config) (Serializer, error) {
+	var schema *avroschema.Schema
+	if config.AvroSchemaFile != "" {
+		text, err := ioutil.ReadFile(config.AvroSchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		schema, err = avroschema.ParseSchema(string(text))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	registry := avroschema.NewRegistry(config.AvroSchemaRegistry, 5*time.Second)
+	return avro.NewSerializer(registry, schema, config.AvroNamespace,
+		config.AvroTimestampField, config.AvroMeasurementField), nil
+}
+
+func NewCarbon2Serializer(config *Config) (Serializer, error) {
+	return carbon2.NewSerializer(config.Carbon2Format, config.Carbon2Separator)
+}
+
+func NewOpenMetricsSerializer(config *Config) (Serializer, error) {
+	return openmetrics.NewSerializer(config.PrometheusStringAsLabel)
+}
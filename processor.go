@@ -10,3 +10,24 @@ type Processor interface {
 	// Apply the filter to the given metric
 	Apply(in ...Metric) []Metric
 }
+
+// ServiceProcessor is a processor that emits its results asynchronously.
+type ServiceProcessor interface {
+	// SampleConfig returns the default configuration of the Processor
+	SampleConfig() string
+
+	// Description returns a one-sentence description on the Processor
+	Description() string
+
+	// Start starts the services of the Processor, the metrics it emits are
+	// sent to out
+	Start(out chan<- Metric) error
+
+	// Apply hands the given metrics to the Processor and returns immediately,
+	// the processed metrics are sent to the channel passed to Start
+	Apply(in ...Metric) []Metric
+
+	// Stop stops the services of the Processor, called once no more metrics
+	// will be applied.  No metrics are sent to out after it returns.
+	Stop()
+}